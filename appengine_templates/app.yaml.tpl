@@ -6,6 +6,7 @@ env_variables:
   BLAISE_BASE_URL: _BLAISE_BASE_URL
   SERVERPARK: _SERVERPARK
   GIN_MODE: release
+  TRUSTED_PLATFORM: X-Appengine-User-Ip
 
 vpc_access_connector:
   name: _VPC_CONNECTOR
@@ -2,23 +2,58 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/datastore"
 	"github.com/ONSDigital/blaise-uac-service/blaiserestapi"
 	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
 	"github.com/ONSDigital/blaise-uac-service/webserver"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/kelseyhightower/envconfig"
+	_ "modernc.org/sqlite"
 )
 
 type Config struct {
-	Serverpark       string `default:"gusty"`
-	DatastoreProject string `required:"true" split_words:"true"`
-	BlaiseBaseUrl    string `required:"true" split_words:"true"`
-	Port             string `default:"8082"`
-	UacKind          string `default:"uac" split_words:"true"`
+	Serverpark        string        `default:"gusty"`
+	DatastoreProject  string        `split_words:"true"`
+	BlaiseBaseUrl     string        `required:"true" split_words:"true"`
+	Port              string        `default:"8082"`
+	UacKind           string        `default:"uac" split_words:"true"`
+	UacKinds          []string      `split_words:"true"`
+	MemorySqlite      bool          `split_words:"true"`
+	SqlDialect        string        `split_words:"true"`
+	SqlDsn            string        `split_words:"true"`
+	ExportEncryption  string        `default:"age" split_words:"true"`
+	ExportRecipients  []string      `split_words:"true"`
+	ExportDir         string        `split_words:"true"`
+	UacSecretFile     string        `split_words:"true"`
+	ArchiveRetention  time.Duration `default:"720h" split_words:"true"`
+	AuthClientLimit   int           `default:"10" split_words:"true"`
+	AuthClientWindow  time.Duration `default:"1m" split_words:"true"`
+	AuthGlobalLimit   int           `default:"1000" split_words:"true"`
+	AuthGlobalWindow  time.Duration `default:"1m" split_words:"true"`
+	AuthMaxFailures   int           `default:"5" split_words:"true"`
+	AuthLockout       time.Duration `default:"1m" split_words:"true"`
+	AuthMaxLockout    time.Duration `default:"24h" split_words:"true"`
+	DenylistFile      string        `split_words:"true"`
+	RejectWeakImports bool          `split_words:"true"`
+	TrustedPlatform   string        `split_words:"true"`
+	TrustedProxies    []string      `split_words:"true"`
+	DebugAddr         string        `split_words:"true"`
+	JobSweepInterval  time.Duration `default:"1m" split_words:"true"`
+}
+
+// sqlDrivers are the database/sql drivers used for each SQL dialect
+var sqlDrivers = map[string]string{
+	uacgenerator.SQLITE:   "sqlite",
+	uacgenerator.POSTGRES: "pgx",
 }
 
 func main() {
@@ -27,28 +62,155 @@ func main() {
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	for _, uacKind := range append([]string{config.UacKind}, config.UacKinds...) {
+		if _, ok := uacgenerator.GetUacFormat(uacKind); !ok {
+			log.Fatalf("Unknown UAC kind '%s'", uacKind)
+		}
+	}
 
-	ctx := context.Background()
-	datastoreClient, err := datastore.NewClient(ctx, config.DatastoreProject)
+	uacGenerator, err := newUacGenerator(config)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	uacGenerator.UacKinds = config.UacKinds
+	uacGenerator.ArchiveRetention = config.ArchiveRetention
+	uacGenerator.RejectWeakImports = config.RejectWeakImports
+	if config.DenylistFile != "" {
+		uacGenerator.Denylist, err = uacgenerator.LoadDenylist(config.DenylistFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	if config.UacSecretFile != "" {
+		uacGenerator.UacKeyer, err = uacgenerator.LoadUacKeyer(config.UacSecretFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-keyed-uacs" {
+		uacGenerator.Actor = "migrate-keyed-uacs"
+		migrated, err := uacGenerator.MigrateToKeyedUacs()
+		if err != nil {
+			log.Fatalf("Migrated %d UACs before failing: %s", migrated, err)
+		}
+		log.Printf("Migrated %d UACs", migrated)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "purge-archives" {
+		uacGenerator.Actor = "purge-archives"
+		purged, err := uacGenerator.PurgeArchives()
+		if err != nil {
+			log.Fatalf("Purged the archives of %v before failing: %s", purged, err)
+		}
+		log.Printf("Purged the archives of %v", purged)
+		return
+	}
+	if len(config.ExportRecipients) > 0 {
+		if config.ExportDir == "" {
+			log.Fatal("required key EXPORT_DIR missing value")
+		}
+		uacGenerator.ExportEncrypter, err = uacgenerator.LoadExportEncrypter(config.ExportEncryption, config.ExportRecipients)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		uacGenerator.ExportDir = config.ExportDir
+	}
 
 	blaiseRestAPI := &blaiserestapi.BlaiseRestApi{
 		Serverpark: config.Serverpark,
 		BaseUrl:    config.BlaiseBaseUrl,
 		Client:     &http.Client{},
 	}
-	uacGenerator := uacgenerator.NewUacGenerator(datastoreClient, config.UacKind)
 
 	server := &webserver.Server{
 		BlaiseRestApi: blaiseRestAPI,
 		UacGenerator:  uacGenerator,
+		AuthLimiter: &webserver.AuthLimiter{
+			ClientLimit:  config.AuthClientLimit,
+			ClientWindow: config.AuthClientWindow,
+			GlobalLimit:  config.AuthGlobalLimit,
+			GlobalWindow: config.AuthGlobalWindow,
+			MaxFailures:  config.AuthMaxFailures,
+			Lockout:      config.AuthLockout,
+			MaxLockout:   config.AuthMaxLockout,
+		},
+		TrustedPlatform: config.TrustedPlatform,
+		TrustedProxies:  config.TrustedProxies,
 	}
 
-	httpRouter := server.SetupRouter()
+	go uacGenerator.SweepGenerationJobs(context.Background(), config.JobSweepInterval)
+	if config.DebugAddr != "" {
+		go func() {
+			log.Println(http.ListenAndServe(config.DebugAddr, webserver.DebugHandler()))
+		}()
+	}
+	httpRouter, err := server.SetupRouter()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	err = httpRouter.Run(fmt.Sprintf(":%s", config.Port))
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 }
+
+// newUacGenerator keeps UACs in a SQL database when SQL_DIALECT is set, in an in-memory SQLite database when
+// MEMORY_SQLITE is set, otherwise in Datastore.
+func newUacGenerator(config Config) (*uacgenerator.UacGenerator, error) {
+	if config.MemorySqlite {
+		log.Println("Using an in-memory SQLite database, UACs will be lost when the service stops")
+		config.SqlDialect = uacgenerator.SQLITE
+		config.SqlDsn = ":memory:"
+	}
+	if config.SqlDialect == "" {
+		datastoreClient, err := newDatastore(config)
+		if err != nil {
+			return nil, err
+		}
+		return uacgenerator.NewUacGenerator(datastoreClient, config.UacKind), nil
+	}
+	if config.SqlDialect == uacgenerator.SQLITE {
+		config.SqlDsn = sqliteDsn(config.SqlDsn)
+	}
+	db, err := sql.Open(sqlDrivers[config.SqlDialect], config.SqlDsn)
+	if err != nil {
+		return nil, err
+	}
+	if config.SqlDialect == uacgenerator.SQLITE {
+		// SQLite only runs one writing transaction at a time, and every connection to :memory: opens a new database
+		db.SetMaxOpenConns(1)
+	}
+	store, err := uacgenerator.NewSQLUacStore(db, config.SqlDialect)
+	if err != nil {
+		return nil, err
+	}
+	err = store.CreateSchema(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return uacgenerator.NewUacGeneratorWithStore(store, config.UacKind), nil
+}
+
+// sqliteDsn has SQLite wait for a lock held by another process, rather than failing at once with SQLITE_BUSY.
+func sqliteDsn(dsn string) string {
+	if strings.Contains(dsn, "busy_timeout") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=busy_timeout(5000)"
+}
+
+// newDatastore connects to Cloud Datastore.
+func newDatastore(config Config) (uacgenerator.Datastore, error) {
+	if config.DatastoreProject == "" {
+		return nil, errors.New("required key DATASTORE_PROJECT missing value")
+	}
+	datastoreClient, err := datastore.NewClient(context.Background(), config.DatastoreProject)
+	if err != nil {
+		return nil, err
+	}
+	return &uacgenerator.CloudDatastore{Client: datastoreClient}, nil
+}
@@ -0,0 +1,199 @@
+package uacgenerator
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/zenthangplus/goccm"
+)
+
+const (
+	ARCHIVEKIND     = "instrument_archive"
+	ARCHIVEDUACKIND = "archived_uac"
+	// DEFAULTARCHIVERETENTION is how long an instrument's UACs are kept after an admin delete before they can be purged.
+	DEFAULTARCHIVERETENTION = 30 * 24 * time.Hour
+)
+
+// InstrumentArchive records an admin delete of an instrument. Its UACs are kept in the archive, and can be
+// restored, until it is purged after PurgeAfter.
+type InstrumentArchive struct {
+	InstrumentName string    `json:"instrument_name" datastore:"-"`
+	UacKind        string    `json:"uac_kind" datastore:"uac_kind,noindex"`
+	DeletedAt      time.Time `json:"deleted_at" datastore:"deleted_at,noindex"`
+	DeletedBy      string    `json:"deleted_by" datastore:"deleted_by,noindex"`
+	PurgeAfter     time.Time `json:"purge_after" datastore:"purge_after,noindex"`
+	// ValidFrom and ValidUntil are the instrument's validity when it was deleted, which it is given again when it is
+	// restored.
+	ValidFrom  time.Time `json:"valid_from,omitzero" datastore:"valid_from,omitempty,noindex"`
+	ValidUntil time.Time `json:"valid_until,omitzero" datastore:"valid_until,omitempty,noindex"`
+}
+
+// RestoreResult reports the restore of an instrument. Conflicts are the case slots, by CaseSlotKey, left in the
+// archive because the slot, or the UAC, has been given out again since the instrument was deleted.
+type RestoreResult struct {
+	Restored  int      `json:"restored"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// archivedUac is the tombstone of a UAC, kept under its instrument's archive with the key it is restored to.
+type archivedUac struct {
+	UacKind string `datastore:"uac_kind,noindex"`
+	Uac     string `datastore:"uac,noindex"`
+	UacInfo
+}
+
+// uacInfo returns the archived UAC with the key it is restored to.
+func (archived *archivedUac) uacInfo() *UacInfo {
+	uacInfo := archived.UacInfo
+	uacInfo.UAC = uacKindKey(archived.UacKind, archived.Uac)
+	return &uacInfo
+}
+
+func (archive *InstrumentArchive) validity() Validity {
+	return Validity{ValidFrom: archive.ValidFrom, ValidUntil: archive.ValidUntil}
+}
+
+func (uacGenerator *UacGenerator) GetInstrumentArchives() ([]*InstrumentArchive, error) {
+	archives, err := uacGenerator.Store.ListInstrumentArchives(uacGenerator.Context)
+	if err != nil {
+		return nil, err
+	}
+	if archives == nil {
+		archives = []*InstrumentArchive{}
+	}
+	return archives, nil
+}
+
+// RestoreInstrument moves an instrument's UACs back from its archive, recording the instrument's UAC kind and validity
+// again. The archive is removed once every UAC has been restored.
+func (uacGenerator *UacGenerator) RestoreInstrument(instrumentName string) (*RestoreResult, error) {
+	archive, err := uacGenerator.Store.GetInstrumentArchive(uacGenerator.Context, instrumentName)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, ErrNoArchive
+	}
+	if err != nil {
+		return nil, err
+	}
+	instrumentConfig, err := uacGenerator.Store.GetInstrumentConfig(uacGenerator.Context, instrumentName)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		return nil, err
+	}
+	if instrumentConfig == nil || instrumentConfig.UacKind != archive.UacKind {
+		err = uacGenerator.SetInstrumentUacKind(instrumentName, archive.UacKind)
+		if err != nil {
+			return nil, err
+		}
+	}
+	before := Validity{}
+	if instrumentConfig != nil {
+		before = instrumentConfig.validity()
+	}
+	if validity := archive.validity(); !before.equal(validity) {
+		err = uacGenerator.Store.SaveInstrumentValidity(uacGenerator.Context, instrumentName, validity,
+			uacGenerator.auditEntries(validityAuditEntry(instrumentName, archive.UacKind, before, validity)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	uacInfos, err := uacGenerator.Store.ListArchivedUacs(uacGenerator.Context, instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	if len(uacInfos) == 0 {
+		return &RestoreResult{Conflicts: []string{}}, uacGenerator.Store.DeleteInstrumentArchive(uacGenerator.Context, instrumentName, nil)
+	}
+
+	var (
+		result    = &RestoreResult{Conflicts: []string{}}
+		errors    []error
+		restoreMu sync.Mutex
+	)
+	concurrent := goccm.New(MAXCONCURRENT)
+	for _, uacInfo := range uacInfos {
+		concurrent.Wait()
+		go func(archivedUacInfo *UacInfo) {
+			defer concurrent.Done()
+			uacInfo, err := uacGenerator.restoredUac(archivedUacInfo)
+			if err == nil {
+				err = uacGenerator.Store.RestoreUac(uacGenerator.Context, archivedUacInfo.UAC, uacInfo,
+					uacGenerator.auditEntries(uacAuditEntry(AUDITRESTORE, uacInfo.UAC, nil, uacInfo)))
+			}
+			restoreMu.Lock()
+			defer restoreMu.Unlock()
+			switch {
+			case err == nil:
+				result.Restored++
+			case err == ErrCaseHasUac || alreadyExistsError(err):
+				result.Conflicts = append(result.Conflicts, uacInfo.CaseSlot())
+			default:
+				errors = append(errors, err)
+			}
+		}(uacInfo)
+	}
+	concurrent.WaitAllDone()
+	sort.Strings(result.Conflicts)
+
+	if len(errors) > 0 {
+		return result, errors[0]
+	}
+	if len(result.Conflicts) > 0 {
+		log.Printf("Could not restore %d UACs of '%s' as their cases or UACs are in use", len(result.Conflicts), instrumentName)
+		return result, nil
+	}
+	return result, uacGenerator.Store.DeleteInstrumentArchive(uacGenerator.Context, instrumentName, nil)
+}
+
+// PurgeArchives permanently deletes the UACs of every archive whose retention has passed, returning the
+// instruments purged.
+func (uacGenerator *UacGenerator) PurgeArchives() ([]string, error) {
+	archives, err := uacGenerator.Store.ListInstrumentArchives(uacGenerator.Context)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	purged := []string{}
+	for _, archive := range archives {
+		if archive.PurgeAfter.After(now) {
+			continue
+		}
+		purgedCount := 0
+		err = uacGenerator.Store.DeleteInstrumentArchive(uacGenerator.Context, archive.InstrumentName, func(uacInfo *UacInfo) *AuditEntry {
+			purgedCount++
+			return uacGenerator.auditEntry(uacAuditEntry(AUDITPURGE, uacInfo.UAC, uacInfo, nil))
+		})
+		if err != nil {
+			return purged, err
+		}
+		log.Printf("Purged %d archived UACs of '%s'", purgedCount, archive.InstrumentName)
+		purged = append(purged, archive.InstrumentName)
+	}
+	return purged, nil
+}
+
+// restoredUac returns an archived UAC as it is restored, keyed by its hash when it was archived before UACs were keyed.
+func (uacGenerator *UacGenerator) restoredUac(archivedUacInfo *UacInfo) (*UacInfo, error) {
+	uacInfo := *archivedUacInfo
+	if uacGenerator.UacKeyer == nil || uacGenerator.UacKeyer.IsKeyName(uacInfo.UAC.Name) {
+		return &uacInfo, nil
+	}
+	sealedUac, err := uacGenerator.UacKeyer.Seal(uacInfo.UAC.Name)
+	if err != nil {
+		return nil, err
+	}
+	uacInfo.UAC = uacGenerator.uacKey(uacInfo.UAC.Kind, uacInfo.UAC.Name)
+	uacInfo.SealedUAC = sealedUac
+	return &uacInfo, nil
+}
+
+func archiveKey(instrumentName string) *datastore.Key {
+	return datastore.NameKey(ARCHIVEKIND, strings.ToLower(instrumentName), nil)
+}
+
+// archivedUacKey names an archived UAC by its kind and key, as UACs are only unique within their kind.
+func archivedUacKey(instrumentName string, uac *datastore.Key) *datastore.Key {
+	return datastore.NameKey(ARCHIVEDUACKIND, uac.Kind+":"+uac.Name, archiveKey(instrumentName))
+}
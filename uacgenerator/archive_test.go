@@ -0,0 +1,200 @@
+package uacgenerator_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Instrument archives", func() {
+	archiveSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var (
+			uacGenerator *uacgenerator.UacGenerator
+			uacs         uacgenerator.Uacs
+		)
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			_, err := uacGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+			uacs, err = uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacGenerator.DisableUac(uacs["2"].FullUAC)).To(Succeed())
+			Expect(uacGenerator.WithActor("alice@example.com").AdminDelete("LOLcat")).To(Succeed())
+		})
+
+		It("archives the UACs of a deleted instrument", func() {
+			_, err := uacGenerator.GetUacInfo(uacs["1"].FullUAC)
+			Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+			Expect(uacGenerator.GetUacCount("lolcat")).To(Equal(0))
+			Expect(uacGenerator.GetInstruments()).To(BeEmpty())
+
+			archives, err := uacGenerator.GetInstrumentArchives()
+			Expect(err).To(BeNil())
+			Expect(archives).To(HaveLen(1))
+			Expect(archives[0].InstrumentName).To(Equal("lolcat"))
+			Expect(archives[0].UacKind).To(Equal("uac"))
+			Expect(archives[0].DeletedBy).To(Equal("alice@example.com"))
+			Expect(archives[0].PurgeAfter).To(BeTemporally("~", time.Now().Add(uacgenerator.DEFAULTARCHIVERETENTION), time.Minute))
+		})
+
+		It("restores the UACs of a deleted instrument", func() {
+			result, err := uacGenerator.RestoreInstrument("LOLcat")
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&uacgenerator.RestoreResult{Restored: 2, Conflicts: []string{}}))
+
+			restored, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(restored).To(HaveLen(2))
+			Expect(restored["1"].FullUAC).To(Equal(uacs["1"].FullUAC))
+			Expect(restored["1"].Disabled).To(BeFalse())
+			Expect(restored["2"].FullUAC).To(Equal(uacs["2"].FullUAC))
+			Expect(restored["2"].Disabled).To(BeTrue())
+			Expect(uacGenerator.GetInstrumentArchives()).To(BeEmpty())
+
+			again, err := uacGenerator.Generate("lolcat", []string{"1", "3"})
+			Expect(err).To(BeNil())
+			Expect(again.Existing).To(Equal([]string{"1"}))
+
+			entries, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{UAC: uacs["1"].FullUAC})
+			Expect(err).To(BeNil())
+			Expect(entries.Entries[len(entries.Entries)-1].Action).To(Equal(uacgenerator.AUDITRESTORE))
+		})
+
+		It("restores the validity of a deleted instrument", func() {
+			_, err := uacGenerator.Generate("dst2108a", []string{"1"})
+			Expect(err).To(BeNil())
+			expired, err := uacGenerator.GetAllUacsByCaseID("dst2108a")
+			Expect(err).To(BeNil())
+			validity := uacgenerator.Validity{ValidUntil: time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)}
+			Expect(uacGenerator.SetInstrumentValidity("dst2108a", validity)).To(Succeed())
+			Expect(uacGenerator.AdminDelete("dst2108a")).To(Succeed())
+
+			archive, err := uacGenerator.Store.GetInstrumentArchive(context.Background(), "dst2108a")
+			Expect(err).To(BeNil())
+			Expect(archive.ValidUntil).To(Equal(validity.ValidUntil))
+
+			Expect(uacGenerator.RestoreInstrument("dst2108a")).To(Equal(&uacgenerator.RestoreResult{Restored: 1, Conflicts: []string{}}))
+			Expect(uacGenerator.GetInstrumentValidity("dst2108a")).To(Equal(validity))
+			_, err = uacGenerator.AuthenticateUac(expired["1"].FullUAC)
+			Expect(err).To(Equal(uacgenerator.ErrUacExpired))
+		})
+
+		It("leaves cases that have been given a UAC since in the archive", func() {
+			_, err := uacGenerator.Generate("lolcat", []string{"2"})
+			Expect(err).To(BeNil())
+
+			result, err := uacGenerator.RestoreInstrument("lolcat")
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&uacgenerator.RestoreResult{Restored: 1, Conflicts: []string{"2"}}))
+			Expect(uacGenerator.GetUacCount("lolcat")).To(Equal(2))
+			Expect(uacGenerator.GetInstrumentArchives()).To(HaveLen(1))
+		})
+
+		It("cannot restore an instrument without an archive", func() {
+			_, err := uacGenerator.RestoreInstrument("dst2108a")
+			Expect(err).To(Equal(uacgenerator.ErrNoArchive))
+		})
+
+		It("only purges archives whose retention has passed", func() {
+			Expect(uacGenerator.PurgeArchives()).To(BeEmpty())
+
+			_, err := uacGenerator.Generate("dst2108a", []string{"1"})
+			Expect(err).To(BeNil())
+			uacGenerator.ArchiveRetention = -time.Minute
+			Expect(uacGenerator.AdminDelete("dst2108a")).To(Succeed())
+
+			Expect(uacGenerator.PurgeArchives()).To(Equal([]string{"dst2108a"}))
+			_, err = uacGenerator.RestoreInstrument("dst2108a")
+			Expect(err).To(Equal(uacgenerator.ErrNoArchive))
+			Expect(uacGenerator.GetInstrumentArchives()).To(HaveLen(1))
+		})
+
+		It("keys UACs archived before UACs were keyed when they are restored", func() {
+			uacKeyer, err := uacgenerator.NewUacKeyer([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).To(BeNil())
+			uacGenerator.UacKeyer = uacKeyer
+
+			Expect(uacGenerator.RestoreInstrument("lolcat")).To(Equal(&uacgenerator.RestoreResult{Restored: 2, Conflicts: []string{}}))
+			uacInfo, err := uacGenerator.GetUacInfo(uacs["1"].FullUAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.UAC.Name).To(Equal(uacKeyer.KeyName(uacs["1"].FullUAC)))
+			Expect(uacInfo.CaseID).To(Equal("1"))
+		})
+	}
+
+	Context("with Datastore", func() {
+		archiveSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		archiveSpecs(newSQLUacGenerator)
+	})
+
+	Describe("DatastoreUacStore.ArchiveInstrument", func() {
+		var (
+			store         *uacgenerator.DatastoreUacStore
+			mockDatastore *mocks.Datastore
+			mutations     []string
+			chunkErr      error
+		)
+
+		BeforeEach(func() {
+			mockDatastore = &mocks.Datastore{}
+			store = uacgenerator.NewDatastoreUacStore(mockDatastore)
+			mutations = nil
+			chunkErr = nil
+
+			mockDatastore.On("GetAll",
+				context.Background(),
+				mock.AnythingOfType("*datastore.Query"),
+				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
+			).Return(func(ctx context.Context, query *datastore.Query, dst interface{}) []*datastore.Key {
+				*dst.(*[]*uacgenerator.UacInfo) = []*uacgenerator.UacInfo{
+					{InstrumentName: "lolcat", CaseID: "1", UAC: datastore.NameKey("uac", "123412341234", nil)},
+				}
+				return nil
+			}, nil)
+			// The archive and the deletion of the UAC kind are one mutation each, and archiving a UAC with a case
+			// index is four with its audit entry
+			mockDatastore.On("Mutate",
+				context.Background(),
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil, func(ctx context.Context, mutation ...*datastore.Mutation) error {
+				if len(mutation) == 1 {
+					mutations = append(mutations, "one")
+					return nil
+				}
+				mutations = append(mutations, "chunk")
+				return chunkErr
+			})
+		})
+
+		archive := func() error {
+			return store.ArchiveInstrument(context.Background(), &uacgenerator.InstrumentArchive{InstrumentName: "lolcat", UacKind: "uac"},
+				func(uacInfo *uacgenerator.UacInfo) *uacgenerator.AuditEntry {
+					return &uacgenerator.AuditEntry{ID: "1", UAC: uacInfo.UAC.Name}
+				})
+		}
+
+		It("deletes the instrument's UAC kind after its UACs are archived", func() {
+			Expect(archive()).To(Succeed())
+			Expect(mutations).To(Equal([]string{"one", "chunk", "one"}))
+		})
+
+		It("keeps the instrument's UAC kind when a chunk of UACs fails", func() {
+			chunkErr = errors.New("Datastore is unavailable")
+			Expect(archive()).To(Equal(chunkErr))
+			Expect(mutations).To(Equal([]string{"one", "chunk"}))
+		})
+	})
+})
@@ -0,0 +1,220 @@
+package uacgenerator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	AUDITKIND = "uac_audit"
+	// auditIDTime starts each audit entry's ID, so entries are kept in the order they were made.
+	auditIDTime = "20060102T150405.000000000Z"
+)
+
+// Actions recorded in the audit trail
+const (
+	AUDITGENERATE    = "generate"
+	AUDITCLAIM       = "claim"
+	AUDITIMPORT      = "import"
+	AUDITDISABLE     = "disable"
+	AUDITENABLE      = "enable"
+	AUDITADMINDELETE = "admin_delete"
+	AUDITRESTORE     = "restore"
+	AUDITPURGE       = "purge"
+	AUDITSETUACKIND  = "set_uac_kind"
+	AUDITREKEY       = "rekey"
+	AUDITREISSUE     = "reissue"
+	AUDITSETVALIDITY = "set_validity"
+)
+
+// AuditEntry records a change made to a UAC, or to an instrument's UAC kind or validity when UAC is empty. When UACs are keyed
+// UAC is the UAC's hash. Entries are only ever added.
+type AuditEntry struct {
+	ID             string      `json:"id" datastore:"-"`
+	UAC            string      `json:"uac,omitempty" datastore:"uac"`
+	UacKind        string      `json:"uac_kind" datastore:"uac_kind"`
+	InstrumentName string      `json:"instrument_name" datastore:"instrument_name"`
+	Action         string      `json:"action" datastore:"action"`
+	Actor          string      `json:"actor" datastore:"actor"`
+	Timestamp      time.Time   `json:"timestamp" datastore:"timestamp"`
+	Before         *AuditState `json:"before" datastore:"before,noindex"`
+	After          *AuditState `json:"after" datastore:"after,noindex"`
+	// Reason is the reason code a UAC was disabled or enabled for, with Note saying more.
+	Reason string `json:"reason,omitempty" datastore:"reason,omitempty,noindex"`
+	Note   string `json:"note,omitempty" datastore:"note,omitempty,noindex"`
+}
+
+// AuditState is a UAC, or an instrument's UAC kind and validity, before or after a change. It is nil when there was
+// nothing before the change or nothing is left after it.
+type AuditState struct {
+	InstrumentName string    `json:"instrument_name,omitempty" datastore:"instrument_name,noindex"`
+	CaseID         string    `json:"case_id,omitempty" datastore:"case_id,noindex"`
+	Slot           int       `json:"slot,omitempty" datastore:"slot,omitempty,noindex"`
+	Disabled       bool      `json:"disabled" datastore:"disabled,noindex"`
+	UacKind        string    `json:"uac_kind,omitempty" datastore:"uac_kind,noindex"`
+	ValidFrom      time.Time `json:"valid_from,omitzero" datastore:"valid_from,omitempty,noindex"`
+	ValidUntil     time.Time `json:"valid_until,omitzero" datastore:"valid_until,omitempty,noindex"`
+}
+
+// AuditQuery filters the audit trail by the fields that are set. Entries are returned from From up to, but not
+// including, To, starting after the entry with the ID Cursor.
+type AuditQuery struct {
+	UAC            string
+	InstrumentName string
+	Actor          string
+	From           time.Time
+	To             time.Time
+	Cursor         string
+	Limit          int
+}
+
+// AuditPage is one page of the audit trail, oldest first. Cursor fetches the next page and is empty on the last page.
+type AuditPage struct {
+	Entries []*AuditEntry `json:"entries"`
+	Cursor  string        `json:"cursor,omitempty"`
+}
+
+// WithActor returns a copy of the generator that records actor in the audit trail against the changes it makes.
+func (uacGenerator *UacGenerator) WithActor(actor string) UacGeneratorInterface {
+	actingGenerator := *uacGenerator
+	actingGenerator.Actor = actor
+	return &actingGenerator
+}
+
+// GetAuditEntries returns a page of the audit trail. A UAC is looked up by its hash when UACs are keyed.
+// A limit outside 1 to MAXPAGESIZE returns MAXPAGESIZE entries.
+func (uacGenerator *UacGenerator) GetAuditEntries(query AuditQuery) (*AuditPage, error) {
+	if query.Cursor != "" && !validAuditID(query.Cursor) {
+		return nil, ErrInvalidCursor
+	}
+	if query.Limit < 1 || query.Limit > MAXPAGESIZE {
+		query.Limit = MAXPAGESIZE
+	}
+	if query.UAC != "" {
+		uacKinds := uacGenerator.DetectUacKinds(query.UAC)
+		if len(uacKinds) == 0 {
+			uacKinds = []string{uacGenerator.UacKind}
+		}
+		query.UAC = uacGenerator.uacKey(uacKinds[0], normaliseUac(uacKinds[0], query.UAC)).Name
+	}
+	query.InstrumentName = strings.ToLower(query.InstrumentName)
+	entries, err := uacGenerator.Store.ListAuditEntries(uacGenerator.Context, query)
+	if err != nil {
+		return nil, err
+	}
+	page := &AuditPage{Entries: entries}
+	if page.Entries == nil {
+		page.Entries = []*AuditEntry{}
+	}
+	if len(entries) == query.Limit {
+		page.Cursor = entries[len(entries)-1].ID
+	}
+	return page, nil
+}
+
+// auditEntries stamps entries with their IDs, the generator's actor and the time, for the store to add them to the
+// audit trail in the commit that makes the changes they record.
+func (uacGenerator *UacGenerator) auditEntries(entries ...*AuditEntry) []*AuditEntry {
+	now := uacTimestamp()
+	for _, entry := range entries {
+		entry.ID = newAuditID(now)
+		entry.Actor = uacGenerator.Actor
+		entry.Timestamp = now
+	}
+	return entries
+}
+
+// auditEntry stamps a single entry for the store to add.
+func (uacGenerator *UacGenerator) auditEntry(entry *AuditEntry) *AuditEntry {
+	return uacGenerator.auditEntries(entry)[0]
+}
+
+// uacAuditEntry records a change to a UAC from before to after, either of which may be nil.
+func uacAuditEntry(action string, uac *datastore.Key, before, after *UacInfo) *AuditEntry {
+	entry := &AuditEntry{
+		UAC:     uac.Name,
+		UacKind: uac.Kind,
+		Action:  action,
+		Before:  uacAuditState(before),
+		After:   uacAuditState(after),
+	}
+	if after != nil {
+		entry.InstrumentName = strings.ToLower(after.InstrumentName)
+	} else if before != nil {
+		entry.InstrumentName = strings.ToLower(before.InstrumentName)
+	}
+	return entry
+}
+
+func uacAuditState(uacInfo *UacInfo) *AuditState {
+	if uacInfo == nil {
+		return nil
+	}
+	return &AuditState{
+		InstrumentName: strings.ToLower(uacInfo.InstrumentName),
+		CaseID:         strings.ToLower(uacInfo.CaseID),
+		Slot:           uacInfo.Slot,
+		Disabled:       uacInfo.Disabled,
+		ValidFrom:      uacInfo.ValidFrom,
+		ValidUntil:     uacInfo.ValidUntil,
+	}
+}
+
+// uacKindAuditEntry records a change to the UAC kind of an instrument, from nothing when before is empty.
+func uacKindAuditEntry(instrumentName, before, after string) *AuditEntry {
+	entry := &AuditEntry{
+		UacKind:        after,
+		InstrumentName: strings.ToLower(instrumentName),
+		Action:         AUDITSETUACKIND,
+		After:          &AuditState{UacKind: after},
+	}
+	if before != "" {
+		entry.Before = &AuditState{UacKind: before}
+	}
+	return entry
+}
+
+// validityAuditEntry records a change to the validity of an instrument's UACs.
+func validityAuditEntry(instrumentName, uacKind string, before, after Validity) *AuditEntry {
+	return &AuditEntry{
+		UacKind:        uacKind,
+		InstrumentName: strings.ToLower(instrumentName),
+		Action:         AUDITSETVALIDITY,
+		Before:         &AuditState{UacKind: uacKind, ValidFrom: before.ValidFrom, ValidUntil: before.ValidUntil},
+		After:          &AuditState{UacKind: uacKind, ValidFrom: after.ValidFrom, ValidUntil: after.ValidUntil},
+	}
+}
+
+// newAuditID starts with the time of the entry, so IDs sort by time and a time range is a range of IDs. Reading random
+// bytes never fails.
+func newAuditID(timestamp time.Time) string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return timestamp.UTC().Format(auditIDTime) + "-" + hex.EncodeToString(suffix)
+}
+
+// auditIDBound is the ID range bound for a time, sorting before every entry made at or after that time.
+func auditIDBound(timestamp time.Time) string {
+	return timestamp.UTC().Format(auditIDTime)
+}
+
+func validAuditID(id string) bool {
+	timestamp, suffix, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(auditIDTime, timestamp)
+	if err != nil {
+		return false
+	}
+	_, err = hex.DecodeString(suffix)
+	return err == nil
+}
+
+func auditKey(id string) *datastore.Key {
+	return datastore.NameKey(AUDITKIND, id, nil)
+}
@@ -0,0 +1,169 @@
+package uacgenerator_test
+
+import (
+	"time"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit trail", func() {
+	auditSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var (
+			uacGenerator    *uacgenerator.UacGenerator
+			actingGenerator uacgenerator.UacGeneratorInterface
+		)
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			actingGenerator = uacGenerator.WithActor("alice@example.com")
+		})
+
+		getAuditEntries := func(query uacgenerator.AuditQuery) []*uacgenerator.AuditEntry {
+			page, err := uacGenerator.GetAuditEntries(query)
+			Expect(err).To(BeNil())
+			return page.Entries
+		}
+
+		generateUac := func(generator uacgenerator.UacGeneratorInterface, instrumentName, caseID string) string {
+			_, err := generator.Generate(instrumentName, []string{caseID})
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID(instrumentName)
+			Expect(err).To(BeNil())
+			return uacs[caseID].FullUAC
+		}
+
+		actions := func(entries []*uacgenerator.AuditEntry) []string {
+			var actions []string
+			for _, entry := range entries {
+				actions = append(actions, entry.Action)
+			}
+			return actions
+		}
+
+		It("records who changed a UAC and how", func() {
+			uac := generateUac(actingGenerator, "LOLcat", "1")
+			Expect(actingGenerator.DisableUac(uac)).To(Succeed())
+			Expect(uacGenerator.WithActor("bob@example.com").EnableUac(uac)).To(Succeed())
+
+			entries := getAuditEntries(uacgenerator.AuditQuery{UAC: uac})
+			Expect(actions(entries)).To(Equal([]string{uacgenerator.AUDITGENERATE, uacgenerator.AUDITDISABLE, uacgenerator.AUDITENABLE}))
+			for _, entry := range entries {
+				Expect(entry.UAC).To(Equal(uac))
+				Expect(entry.UacKind).To(Equal("uac"))
+				Expect(entry.InstrumentName).To(Equal("lolcat"))
+				Expect(entry.Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+			}
+			Expect(entries[0].Actor).To(Equal("alice@example.com"))
+			Expect(entries[0].Before).To(BeNil())
+			Expect(entries[0].After).To(Equal(&uacgenerator.AuditState{InstrumentName: "lolcat", CaseID: "1"}))
+			Expect(entries[1].Before.Disabled).To(BeFalse())
+			Expect(entries[1].After.Disabled).To(BeTrue())
+			Expect(entries[2].Actor).To(Equal("bob@example.com"))
+			Expect(entries[2].Before.Disabled).To(BeTrue())
+			Expect(entries[2].After.Disabled).To(BeFalse())
+		})
+
+		It("records imports, claims and admin deletes", func() {
+			Expect(actingGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+			_, err := actingGenerator.GenerateWithOptions("lolcat", []string{"1"}, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+			Expect(actingGenerator.AdminDelete("lolcat")).To(Succeed())
+
+			entries := getAuditEntries(uacgenerator.AuditQuery{UAC: "123412341234"})
+			Expect(actions(entries)).To(Equal([]string{uacgenerator.AUDITIMPORT, uacgenerator.AUDITCLAIM, uacgenerator.AUDITADMINDELETE}))
+			Expect(entries[0].After.InstrumentName).To(Equal(uacgenerator.UNKNOWNINSTRUMENT))
+			Expect(entries[1].After).To(Equal(&uacgenerator.AuditState{InstrumentName: "lolcat", CaseID: "1"}))
+			Expect(entries[2].Before).To(Equal(&uacgenerator.AuditState{InstrumentName: "lolcat", CaseID: "1"}))
+			Expect(entries[2].After).To(BeNil())
+		})
+
+		It("records purges", func() {
+			uacGenerator.ArchiveRetention = -time.Minute
+			actingGenerator = uacGenerator.WithActor("alice@example.com")
+			uac := generateUac(actingGenerator, "lolcat", "1")
+			Expect(actingGenerator.AdminDelete("lolcat")).To(Succeed())
+			Expect(actingGenerator.PurgeArchives()).To(Equal([]string{"lolcat"}))
+
+			entries := getAuditEntries(uacgenerator.AuditQuery{UAC: uac})
+			Expect(actions(entries)).To(Equal([]string{uacgenerator.AUDITGENERATE, uacgenerator.AUDITADMINDELETE, uacgenerator.AUDITPURGE}))
+			Expect(entries[2].Actor).To(Equal("alice@example.com"))
+			Expect(entries[2].Before).To(Equal(&uacgenerator.AuditState{InstrumentName: "lolcat", CaseID: "1"}))
+			Expect(entries[2].After).To(BeNil())
+		})
+
+		It("records changes to an instrument's UAC kind", func() {
+			uacGenerator.UacKinds = []string{uacgenerator.UAC16KIND}
+			actingGenerator = uacGenerator.WithActor("alice@example.com")
+			Expect(actingGenerator.SetInstrumentUacKind("lolcat", "uac16")).To(Succeed())
+			Expect(actingGenerator.SetInstrumentUacKind("lolcat", "uac")).To(Succeed())
+
+			entries := getAuditEntries(uacgenerator.AuditQuery{InstrumentName: "LOLcat"})
+			Expect(actions(entries)).To(Equal([]string{uacgenerator.AUDITSETUACKIND, uacgenerator.AUDITSETUACKIND}))
+			Expect(entries[0].UAC).To(BeEmpty())
+			Expect(entries[0].Before).To(Equal(&uacgenerator.AuditState{UacKind: "uac"}))
+			Expect(entries[0].After).To(Equal(&uacgenerator.AuditState{UacKind: "uac16"}))
+			Expect(entries[1].Before).To(Equal(&uacgenerator.AuditState{UacKind: "uac16"}))
+			Expect(entries[1].After).To(Equal(&uacgenerator.AuditState{UacKind: "uac"}))
+		})
+
+		It("filters by instrument, actor and time", func() {
+			generateUac(actingGenerator, "lolcat", "1")
+			between := time.Now()
+			time.Sleep(time.Millisecond)
+			generateUac(uacGenerator.WithActor("bob@example.com"), "dst2108a", "1")
+
+			// Generating for an instrument first records its UAC kind
+			Expect(actions(getAuditEntries(uacgenerator.AuditQuery{InstrumentName: "lolcat"}))).To(Equal([]string{uacgenerator.AUDITSETUACKIND, uacgenerator.AUDITGENERATE}))
+			Expect(getAuditEntries(uacgenerator.AuditQuery{Actor: "bob@example.com"})[0].InstrumentName).To(Equal("dst2108a"))
+			Expect(getAuditEntries(uacgenerator.AuditQuery{To: between})[0].InstrumentName).To(Equal("lolcat"))
+			Expect(getAuditEntries(uacgenerator.AuditQuery{From: between})[0].InstrumentName).To(Equal("dst2108a"))
+			Expect(getAuditEntries(uacgenerator.AuditQuery{From: between, To: between})).To(BeEmpty())
+			Expect(getAuditEntries(uacgenerator.AuditQuery{Actor: "carol@example.com"})).To(BeEmpty())
+		})
+
+		It("pages through the audit trail", func() {
+			_, err := actingGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+
+			page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{InstrumentName: "lolcat", Limit: 2})
+			Expect(err).To(BeNil())
+			Expect(page.Entries).To(HaveLen(2))
+			Expect(page.Cursor).To(Equal(page.Entries[1].ID))
+
+			nextPage, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{InstrumentName: "lolcat", Limit: 2, Cursor: page.Cursor})
+			Expect(err).To(BeNil())
+			Expect(nextPage.Entries).To(HaveLen(1))
+			Expect(nextPage.Cursor).To(BeEmpty())
+			Expect(nextPage.Entries[0].ID > page.Cursor).To(BeTrue())
+		})
+
+		It("rejects an invalid cursor", func() {
+			_, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{Cursor: "lolcat"})
+			Expect(err).To(Equal(uacgenerator.ErrInvalidCursor))
+		})
+
+		It("records the hash of keyed UACs", func() {
+			uacKeyer, err := uacgenerator.NewUacKeyer([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).To(BeNil())
+			uacGenerator.UacKeyer = uacKeyer
+			result, err := uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+
+			entries := getAuditEntries(uacgenerator.AuditQuery{UAC: result.Uacs["1"]})
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].UAC).To(Equal(uacKeyer.KeyName(result.Uacs["1"])))
+		})
+	}
+
+	Context("with Datastore", func() {
+		auditSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		auditSpecs(newSQLUacGenerator)
+	})
+})
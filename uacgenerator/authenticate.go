@@ -0,0 +1,31 @@
+package uacgenerator
+
+import (
+	"strings"
+
+	"cloud.google.com/go/datastore"
+)
+
+// AuthenticateUac looks up a UAC typed by a respondent, returning it when it can be used now. A UAC that cannot be
+// used is returned as ErrUacDisabled, ErrUacReissued, ErrUacExpired or ErrUacNotYetValid, and imported UACs that have
+// not been given to a case are not found.
+func (uacGenerator *UacGenerator) AuthenticateUac(uac string) (*UacInfo, error) {
+	uacInfo, err := uacGenerator.GetUacInfo(uac)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(uacInfo.InstrumentName, UNKNOWNINSTRUMENT) {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	switch {
+	case uacInfo.ReissuedTo != "":
+		return nil, ErrUacReissued
+	case uacInfo.Status == UACDISABLED:
+		return nil, ErrUacDisabled
+	case uacInfo.Status == UACEXPIRED:
+		return nil, ErrUacExpired
+	case uacInfo.Status == UACNOTYETVALID:
+		return nil, ErrUacNotYetValid
+	}
+	return uacInfo, nil
+}
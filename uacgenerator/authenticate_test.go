@@ -0,0 +1,76 @@
+package uacgenerator_test
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuthenticateUac", func() {
+	authenticateSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var (
+			uacGenerator *uacgenerator.UacGenerator
+			uacs         uacgenerator.Uacs
+		)
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			_, err := uacGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+			uacs, err = uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+		})
+
+		It("authenticates a UAC typed with separators", func() {
+			chunks := uacgenerator.ChunkUAC(uacs["1"].FullUAC)
+			uacInfo, err := uacGenerator.AuthenticateUac(" " + chunks.UAC1 + "-" + chunks.UAC2 + " " + chunks.UAC3 + "\t")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.InstrumentName).To(Equal("lolcat"))
+			Expect(uacInfo.CaseID).To(Equal("1"))
+		})
+
+		It("does not authenticate UACs that cannot be used", func() {
+			Expect(uacGenerator.DisableUac(uacs["1"].FullUAC)).To(Succeed())
+			_, err := uacGenerator.AuthenticateUac(uacs["1"].FullUAC)
+			Expect(err).To(Equal(uacgenerator.ErrUacDisabled))
+
+			Expect(uacGenerator.SetUacValidity(uacs["2"].FullUAC, uacgenerator.Validity{ValidUntil: time.Now().Add(-time.Hour)})).To(Succeed())
+			_, err = uacGenerator.AuthenticateUac(uacs["2"].FullUAC)
+			Expect(err).To(Equal(uacgenerator.ErrUacExpired))
+
+			Expect(uacGenerator.SetUacValidity(uacs["2"].FullUAC, uacgenerator.Validity{ValidFrom: time.Now().Add(time.Hour)})).To(Succeed())
+			_, err = uacGenerator.AuthenticateUac(uacs["2"].FullUAC)
+			Expect(err).To(Equal(uacgenerator.ErrUacNotYetValid))
+		})
+
+		It("does not authenticate a reissued UAC", func() {
+			reissued, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+			_, err = uacGenerator.AuthenticateUac(uacs["1"].FullUAC)
+			Expect(err).To(Equal(uacgenerator.ErrUacReissued))
+
+			uacInfo, err := uacGenerator.AuthenticateUac(reissued.FullUAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.CaseID).To(Equal("1"))
+		})
+
+		It("does not find imported UACs that have not been given to a case", func() {
+			Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+			_, err := uacGenerator.AuthenticateUac("1234 1234 1234")
+			Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		})
+	}
+
+	Context("with Datastore", func() {
+		authenticateSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		authenticateSpecs(newSQLUacGenerator)
+	})
+})
@@ -0,0 +1,260 @@
+package uacgenerator
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/zenthangplus/goccm"
+)
+
+const (
+	// MAXBULKITEMS is the most UACs and cases a bulk change takes.
+	MAXBULKITEMS = 5000
+	// BULKBATCHSIZE is the most UACs changed in one commit, as each UAC is updated and given an audit entry.
+	BULKBATCHSIZE = MAXMUTATIONS / 2
+)
+
+// Reasons UACs are disabled or enabled for
+const (
+	REASONREFUSAL       = "refusal"
+	REASONSAFEGUARDING  = "safeguarding"
+	REASONDECEASED      = "deceased"
+	REASONCOMPROMISED   = "compromised"
+	REASONISSUEDINERROR = "issued_in_error"
+	REASONREINSTATED    = "reinstated"
+	REASONOTHER         = "other"
+	// REASONREISSUED is only given to UACs replaced by ReissueUac.
+	REASONREISSUED = "reissued"
+)
+
+// Statuses of the items of a bulk change
+const (
+	BULKUPDATED   = "updated"
+	BULKUNCHANGED = "unchanged"
+	BULKNOTFOUND  = "not_found"
+	BULKFAILED    = "failed"
+)
+
+var reasons = []string{
+	REASONREFUSAL, REASONSAFEGUARDING, REASONDECEASED, REASONCOMPROMISED, REASONISSUEDINERROR, REASONREINSTATED,
+	REASONOTHER,
+}
+
+// UacStatusChange disables or enables UACs with the reason it is made for, which is empty when none was given.
+type UacStatusChange struct {
+	Disabled bool
+	Reason   string
+	Note     string
+}
+
+// BulkUacRequest disables or enables an instrument's UACs, given by UAC, by case ID, or both, for a reason. Case IDs
+// give the current UAC of a case slot by its CaseSlotKey, so a case ID alone gives the UAC of the case's slot 0.
+type BulkUacRequest struct {
+	UACs    []string `json:"uacs"`
+	CaseIDs []string `json:"case_ids"`
+	Reason  string   `json:"reason"`
+	Note    string   `json:"note"`
+}
+
+// BulkUacResult reports a bulk change, with an item for each UAC and then each case ID in the order they were given.
+type BulkUacResult struct {
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	NotFound  int           `json:"not_found"`
+	Failed    int           `json:"failed"`
+	Items     []BulkUacItem `json:"items"`
+}
+
+// BulkUacItem is what happened to one UAC or case of a bulk change. UACs that were already disabled or enabled are
+// unchanged, and UACs of another instrument are not found.
+type BulkUacItem struct {
+	UAC    string `json:"uac,omitempty"`
+	CaseID string `json:"case_id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (result *BulkUacResult) HasFailures() bool {
+	return result.Failed > 0
+}
+
+// ValidReason reports whether reason is one of the reason codes UACs are disabled or enabled for.
+func ValidReason(reason string) bool {
+	for _, validReason := range reasons {
+		if reason == validReason {
+			return true
+		}
+	}
+	return false
+}
+
+// DisableUacs disables an instrument's UACs for a reason, in batches of BULKBATCHSIZE.
+func (uacGenerator *UacGenerator) DisableUacs(instrumentName string, request BulkUacRequest) (*BulkUacResult, error) {
+	return uacGenerator.setUacsDisabled(instrumentName, request, true)
+}
+
+// EnableUacs enables an instrument's UACs for a reason, in batches of BULKBATCHSIZE.
+func (uacGenerator *UacGenerator) EnableUacs(instrumentName string, request BulkUacRequest) (*BulkUacResult, error) {
+	return uacGenerator.setUacsDisabled(instrumentName, request, false)
+}
+
+func (uacGenerator *UacGenerator) setUacsDisabled(instrumentName string, request BulkUacRequest, disabled bool) (*BulkUacResult, error) {
+	if !ValidReason(request.Reason) {
+		return nil, ErrInvalidReason
+	}
+	itemCount := len(request.UACs) + len(request.CaseIDs)
+	if itemCount == 0 {
+		return nil, ErrNoBulkItems
+	}
+	if itemCount > MAXBULKITEMS {
+		return nil, ErrTooManyBulkItems
+	}
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]BulkUacItem, itemCount)
+	uacInfos := make([]*UacInfo, itemCount)
+	concurrent := goccm.New(MAXCONCURRENT)
+	for i := range items {
+		concurrent.Wait()
+		go func(i int) {
+			defer concurrent.Done()
+			items[i], uacInfos[i] = uacGenerator.findBulkItem(uacKind, instrumentName, request, i)
+		}(i)
+	}
+	concurrent.WaitAllDone()
+
+	// A UAC given by UAC and by case ID is only changed once, and reported like the first item that gave it
+	var (
+		changes    []int
+		duplicates = make(map[int]int)
+		firstItems = make(map[string]int)
+	)
+	for i, uacInfo := range uacInfos {
+		if uacInfo == nil {
+			continue
+		}
+		if uacInfo.Disabled == disabled {
+			items[i].Status = BULKUNCHANGED
+			continue
+		}
+		if !disabled && uacInfo.ReissuedTo != "" {
+			items[i].Status = BULKFAILED
+			items[i].Error = ErrUacReissued.Error()
+			continue
+		}
+		uacName := uacInfo.UAC.Kind + ":" + uacInfo.UAC.Name
+		if first, ok := firstItems[uacName]; ok {
+			duplicates[i] = first
+			continue
+		}
+		firstItems[uacName] = i
+		changes = append(changes, i)
+	}
+
+	change := UacStatusChange{Disabled: disabled, Reason: request.Reason, Note: request.Note}
+	action := AUDITENABLE
+	if disabled {
+		action = AUDITDISABLE
+	}
+	for start := 0; start < len(changes); start += BULKBATCHSIZE {
+		end := start + BULKBATCHSIZE
+		if end > len(changes) {
+			end = len(changes)
+		}
+		uacs := make([]*datastore.Key, 0, end-start)
+		auditEntries := make([]*AuditEntry, 0, end-start)
+		for _, i := range changes[start:end] {
+			uacs = append(uacs, uacInfos[i].UAC)
+			after := *uacInfos[i]
+			after.Disabled = disabled
+			auditEntry := uacAuditEntry(action, uacInfos[i].UAC, uacInfos[i], &after)
+			auditEntry.Reason = request.Reason
+			auditEntry.Note = request.Note
+			auditEntries = append(auditEntries, auditEntry)
+		}
+		err := uacGenerator.Store.SetUacsDisabled(uacGenerator.Context, uacs, change, uacGenerator.auditEntries(auditEntries...))
+		for _, i := range changes[start:end] {
+			if err != nil {
+				items[i].Status = BULKFAILED
+				items[i].Error = err.Error()
+				continue
+			}
+			items[i].Status = BULKUPDATED
+		}
+	}
+
+	result := &BulkUacResult{Items: items}
+	for i := range items {
+		if first, ok := duplicates[i]; ok {
+			items[i].Status = items[first].Status
+			items[i].Error = items[first].Error
+		}
+		switch items[i].Status {
+		case BULKUPDATED:
+			result.Updated++
+		case BULKUNCHANGED:
+			result.Unchanged++
+		case BULKNOTFOUND:
+			result.NotFound++
+		case BULKFAILED:
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// findBulkItem looks up the UAC of the ith item of a bulk request, returning no UAC when it is not found or the
+// lookup failed.
+func (uacGenerator *UacGenerator) findBulkItem(uacKind, instrumentName string, request BulkUacRequest, i int) (BulkUacItem, *UacInfo) {
+	var (
+		item    BulkUacItem
+		uacInfo *UacInfo
+		err     error
+	)
+	if i < len(request.UACs) {
+		item.UAC = request.UACs[i]
+		uacInfo, err = uacGenerator.findUacInfo(item.UAC)
+		if err == nil && uacInfo.InstrumentName != strings.ToLower(instrumentName) {
+			uacInfo, err = nil, datastore.ErrNoSuchEntity
+		}
+	} else {
+		item.CaseID = request.CaseIDs[i-len(request.UACs)]
+		caseID, slot := ParseCaseSlotKey(item.CaseID)
+		var uacInfos []*UacInfo
+		uacInfos, err = uacGenerator.Store.ListCaseUacs(uacGenerator.Context, uacKind, instrumentName, caseID)
+		if err == nil {
+			uacInfo = currentCaseUac(uacInfos, slot)
+			if uacInfo == nil {
+				err = datastore.ErrNoSuchEntity
+			}
+		}
+	}
+	switch {
+	case err == datastore.ErrNoSuchEntity || err == ErrProbableTypo:
+		item.Status = BULKNOTFOUND
+	case err != nil:
+		item.Status = BULKFAILED
+		item.Error = err.Error()
+	}
+	return item, uacInfo
+}
+
+// apply makes the change to a UAC as it is saved at now. A UAC that was already disabled keeps when and why it was
+// disabled.
+func (change UacStatusChange) apply(uacInfo *UacInfo, now time.Time) {
+	uacInfo.UpdatedAt = now
+	if !change.Disabled {
+		uacInfo.DisabledAt = time.Time{}
+		uacInfo.DisabledReason = ""
+		uacInfo.DisabledNote = ""
+	} else if !uacInfo.Disabled {
+		uacInfo.DisabledAt = now
+		uacInfo.DisabledReason = change.Reason
+		uacInfo.DisabledNote = change.Note
+	}
+	uacInfo.Disabled = change.Disabled
+}
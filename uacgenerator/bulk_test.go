@@ -0,0 +1,140 @@
+package uacgenerator_test
+
+import (
+	"fmt"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulk disable and enable", func() {
+	bulkSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+		})
+
+		generateUacs := func(instrumentName string, caseIDs ...string) uacgenerator.Uacs {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID(instrumentName)
+			Expect(err).To(BeNil())
+			return uacs
+		}
+
+		It("disables UACs given by UAC or case ID and reports each item", func() {
+			uacs := generateUacs("lolcat", "1", "2", "3")
+			otherUac := generateUacs("dogcat", "1")["1"].FullUAC
+			Expect(uacGenerator.DisableUac(uacs["3"].FullUAC)).To(Succeed())
+
+			result, err := uacGenerator.DisableUacs("LOLcat", uacgenerator.BulkUacRequest{
+				UACs:    []string{uacs["1"].FullUAC, otherUac, "bcdfbcdfbcdf"},
+				CaseIDs: []string{"2", "3", "4"},
+				Reason:  uacgenerator.REASONREFUSAL,
+				Note:    "Rang to refuse",
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Items).To(Equal([]uacgenerator.BulkUacItem{
+				{UAC: uacs["1"].FullUAC, Status: uacgenerator.BULKUPDATED},
+				{UAC: otherUac, Status: uacgenerator.BULKNOTFOUND},
+				{UAC: "bcdfbcdfbcdf", Status: uacgenerator.BULKNOTFOUND},
+				{CaseID: "2", Status: uacgenerator.BULKUPDATED},
+				{CaseID: "3", Status: uacgenerator.BULKUNCHANGED},
+				{CaseID: "4", Status: uacgenerator.BULKNOTFOUND},
+			}))
+			Expect(result.Updated).To(Equal(2))
+			Expect(result.Unchanged).To(Equal(1))
+			Expect(result.NotFound).To(Equal(3))
+			Expect(result.HasFailures()).To(BeFalse())
+
+			uacs = generateUacs("lolcat")
+			for _, caseID := range []string{"1", "2"} {
+				Expect(uacs[caseID].Disabled).To(BeTrue())
+				Expect(uacs[caseID].DisabledReason).To(Equal(uacgenerator.REASONREFUSAL))
+				Expect(uacs[caseID].DisabledNote).To(Equal("Rang to refuse"))
+			}
+			Expect(uacs["3"].DisabledReason).To(BeEmpty())
+			Expect(generateUacs("dogcat")["1"].Disabled).To(BeFalse())
+
+			page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{UAC: uacs["2"].FullUAC})
+			Expect(err).To(BeNil())
+			Expect(page.Entries).To(HaveLen(2))
+			Expect(page.Entries[1].Action).To(Equal(uacgenerator.AUDITDISABLE))
+			Expect(page.Entries[1].Reason).To(Equal(uacgenerator.REASONREFUSAL))
+			Expect(page.Entries[1].Note).To(Equal("Rang to refuse"))
+			Expect(page.Entries[1].After.Disabled).To(BeTrue())
+		})
+
+		It("enables UACs and clears why they were disabled", func() {
+			uacs := generateUacs("lolcat", "1", "2")
+			_, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{
+				CaseIDs: []string{"1", "2"},
+				Reason:  uacgenerator.REASONCOMPROMISED,
+			})
+			Expect(err).To(BeNil())
+
+			result, err := uacGenerator.EnableUacs("lolcat", uacgenerator.BulkUacRequest{
+				UACs:    []string{uacs["1"].FullUAC},
+				CaseIDs: []string{"1"},
+				Reason:  uacgenerator.REASONREINSTATED,
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(Equal(2))
+
+			uacs = generateUacs("lolcat")
+			Expect(uacs["1"].Disabled).To(BeFalse())
+			Expect(uacs["1"].DisabledReason).To(BeEmpty())
+			Expect(uacs["1"].DisabledAt.IsZero()).To(BeTrue())
+			Expect(uacs["2"].Disabled).To(BeTrue())
+
+			page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{UAC: uacs["1"].FullUAC})
+			Expect(err).To(BeNil())
+			Expect(page.Entries).To(HaveLen(3))
+			Expect(page.Entries[2].Action).To(Equal(uacgenerator.AUDITENABLE))
+			Expect(page.Entries[2].Reason).To(Equal(uacgenerator.REASONREINSTATED))
+		})
+
+		It("changes more UACs than fit in one batch", func() {
+			var caseIDs []string
+			for i := 0; i <= uacgenerator.MAXMUTATIONS; i++ {
+				caseIDs = append(caseIDs, fmt.Sprint(i))
+			}
+			generateUacs("lolcat", caseIDs...)
+
+			result, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{CaseIDs: caseIDs, Reason: uacgenerator.REASONOTHER})
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(Equal(len(caseIDs)))
+			disabled, err := uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(len(caseIDs)))
+		})
+
+		It("needs a valid reason and some UACs or cases", func() {
+			generateUacs("lolcat", "1")
+			_, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{CaseIDs: []string{"1"}})
+			Expect(err).To(Equal(uacgenerator.ErrInvalidReason))
+			_, err = uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{CaseIDs: []string{"1"}, Reason: "bored"})
+			Expect(err).To(Equal(uacgenerator.ErrInvalidReason))
+			_, err = uacGenerator.EnableUacs("lolcat", uacgenerator.BulkUacRequest{Reason: uacgenerator.REASONREINSTATED})
+			Expect(err).To(Equal(uacgenerator.ErrNoBulkItems))
+			_, err = uacGenerator.EnableUacs("lolcat", uacgenerator.BulkUacRequest{
+				CaseIDs: make([]string, uacgenerator.MAXBULKITEMS+1),
+				Reason:  uacgenerator.REASONREINSTATED,
+			})
+			Expect(err).To(Equal(uacgenerator.ErrTooManyBulkItems))
+			Expect(generateUacs("lolcat")["1"].Disabled).To(BeFalse())
+		})
+	}
+
+	Context("with Datastore", func() {
+		bulkSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		bulkSpecs(newSQLUacGenerator)
+	})
+})
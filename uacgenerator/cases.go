@@ -0,0 +1,150 @@
+package uacgenerator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	CASEKIND = "case_uac"
+	// CASEBATCHSIZE is the most cases given a UAC in one commit, as each case inserts a UAC, its case index and its
+	// audit entry.
+	CASEBATCHSIZE = MAXMUTATIONS / 3
+	// MAXUACSPERCASE is the most UACs a case can need, one for each respondent slot.
+	MAXUACSPERCASE = 16
+	// CASESLOTSEPARATOR separates the case ID of a CaseSlotKey from its slot.
+	CASESLOTSEPARATOR = "/"
+)
+
+// CaseUac indexes the UAC given to a slot of a case. It is inserted in the same transaction as the UAC, so a slot of
+// a case can never be given two UACs.
+type CaseUac struct {
+	UacKind string `datastore:"uac_kind,noindex"`
+	UAC     string `datastore:"uac,noindex"`
+}
+
+// respondentSlot is a slot of a case to be given a UAC.
+type respondentSlot struct {
+	caseID string
+	slot   int
+}
+
+func (caseSlot respondentSlot) key() string {
+	return CaseSlotKey(caseSlot.caseID, caseSlot.slot)
+}
+
+// CaseSlotKey identifies a slot of a case in listings, generation results and bulk changes. Slot 0 is identified by
+// its case ID alone, so cases with one UAC are identified as they were before cases had slots, and other slots by
+// their case ID and slot separated by a slash, like 1001/1. Every slot of a case ID containing a slash, slot 0
+// included, is identified with its slot, like a/1/0, so no CaseSlotKey identifies slots of two cases.
+func CaseSlotKey(caseID string, slot int) string {
+	if slot == 0 && !strings.Contains(caseID, CASESLOTSEPARATOR) {
+		return caseID
+	}
+	return caseID + CASESLOTSEPARATOR + strconv.Itoa(slot)
+}
+
+// ParseCaseSlotKey returns the case ID and slot a CaseSlotKey identifies, splitting it at its last slash. A key that
+// is not a CaseSlotKey, like a case ID containing a slash without its slot, is taken to be the case ID of slot 0.
+func ParseCaseSlotKey(key string) (string, int) {
+	separator := strings.LastIndex(key, CASESLOTSEPARATOR)
+	if separator == -1 {
+		return key, 0
+	}
+	caseID, slotText := key[:separator], key[separator+1:]
+	slot, err := strconv.Atoi(slotText)
+	if err != nil || slot < 0 || strconv.Itoa(slot) != slotText || CaseSlotKey(caseID, slot) != key {
+		return key, 0
+	}
+	return caseID, slot
+}
+
+// validateCaseID returns why a case cannot be given UACs.
+func validateCaseID(caseID string) error {
+	if caseID == "" {
+		return fmt.Errorf("Cannot generate UACs for blank caseIDs")
+	}
+	return nil
+}
+
+// caseHasUac returns ErrCaseHasUac when the index entity of the case's slot exists.
+func caseHasUac(transaction Transaction, instrumentName, caseID string, slot int) error {
+	err := transaction.Get(caseKey(instrumentName, caseID, slot), &CaseUac{})
+	if err == nil {
+		return ErrCaseHasUac
+	}
+	if err == datastore.ErrNoSuchEntity {
+		return nil
+	}
+	return err
+}
+
+// checkInstrumentUacKind returns ErrInstrumentUacKindChanged when the instrument has recorded a UAC kind other than
+// uacKind. Reading the instrument in the transaction that gives its cases UACs makes the transaction fail when the
+// kind is changed before it commits.
+func checkInstrumentUacKind(transaction Transaction, instrumentName, uacKind string) error {
+	instrumentConfig := &InstrumentConfig{}
+	err := transaction.Get(instrumentKey(instrumentName), instrumentConfig)
+	if err == datastore.ErrNoSuchEntity {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if instrumentConfig.UacKind != uacKind {
+		return ErrInstrumentUacKindChanged
+	}
+	return nil
+}
+
+func caseUacMutations(uacInfo *UacInfo) []*datastore.Mutation {
+	return []*datastore.Mutation{
+		datastore.NewInsert(uacInfo.UAC, uacEntity(uacInfo)),
+		datastore.NewInsert(
+			caseKey(uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot),
+			&CaseUac{UacKind: uacInfo.UAC.Kind, UAC: uacInfo.UAC.Name},
+		),
+	}
+}
+
+// uacEntity returns the properties of a UAC that are saved, with the instrument and case lowercased.
+func uacEntity(uacInfo *UacInfo) *UacInfo {
+	return &UacInfo{
+		InstrumentName:  strings.ToLower(uacInfo.InstrumentName),
+		CaseID:          strings.ToLower(uacInfo.CaseID),
+		Slot:            uacInfo.Slot,
+		Disabled:        uacInfo.Disabled,
+		SealedUAC:       uacInfo.SealedUAC,
+		CreatedAt:       uacInfo.CreatedAt,
+		UpdatedAt:       uacInfo.UpdatedAt,
+		DisabledAt:      uacInfo.DisabledAt,
+		FirstAccessedAt: uacInfo.FirstAccessedAt,
+		DisabledReason:  uacInfo.DisabledReason,
+		DisabledNote:    uacInfo.DisabledNote,
+		ReissuedFrom:    uacInfo.ReissuedFrom,
+		ReissuedTo:      uacInfo.ReissuedTo,
+		ValidFrom:       uacInfo.ValidFrom,
+		ValidUntil:      uacInfo.ValidUntil,
+	}
+}
+
+// caseKeySlot returns the CaseSlotKey of the case slot a case index entity is keyed by.
+func caseKeySlot(key *datastore.Key) string {
+	if key.Name != "" {
+		return key.Name
+	}
+	return CaseSlotKey(key.Parent.Name, int(key.ID))
+}
+
+// caseKey returns the key of the index entity of a slot of a case. Slot 0 is keyed by case ID under the instrument's
+// key, as cases were before they had slots, and other slots by number under that, so no two slots share a key.
+func caseKey(instrumentName, caseID string, slot int) *datastore.Key {
+	key := datastore.NameKey(CASEKIND, strings.ToLower(caseID), instrumentKey(instrumentName))
+	if slot == 0 {
+		return key
+	}
+	return datastore.IDKey(CASEKIND, int64(slot), key)
+}
@@ -0,0 +1,133 @@
+package uacgenerator_test
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/api/iterator"
+)
+
+// runTransactions runs every transaction on the mock Datastore against the mock Transaction,
+// where no case has a UAC yet.
+func runTransactions(mockDatastore *mocks.Datastore, mockTransaction *mocks.Transaction) {
+	mockDatastore.On("RunInTransaction",
+		mock.Anything,
+		mock.Anything,
+	).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+		return f(mockTransaction)
+	})
+
+	mockTransaction.On("Get",
+		mock.AnythingOfType("*datastore.Key"),
+		mock.AnythingOfType("*uacgenerator.CaseUac"),
+	).Return(datastore.ErrNoSuchEntity)
+	readNoInstrumentConfigs(mockTransaction)
+}
+
+// readNoInstrumentConfigs answers the instrument reads of transactions giving cases UACs as if no kind was recorded.
+func readNoInstrumentConfigs(mockTransaction *mocks.Transaction) {
+	mockTransaction.On("Get",
+		mock.AnythingOfType("*datastore.Key"),
+		mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+	).Return(datastore.ErrNoSuchEntity)
+}
+
+// listCaseSlots answers the reads of an instrument's case slots when generating with the case index entities of
+// caseUacs, keyed by case ID, and the keys of uacs.
+func listCaseSlots(mockDatastore *mocks.Datastore, caseUacs map[string]*uacgenerator.CaseUac, uacs ...*datastore.Key) {
+	mockDatastore.On("GetAll",
+		mock.Anything,
+		mock.AnythingOfType("*datastore.Query"),
+		mock.AnythingOfType("*[]*uacgenerator.CaseUac"),
+	).Return(func(ctx context.Context, qry *datastore.Query, dst interface{}) []*datastore.Key {
+		var caseKeys []*datastore.Key
+		for caseID, caseUac := range caseUacs {
+			caseKeys = append(caseKeys, datastore.NameKey(uacgenerator.CASEKIND, caseID, nil))
+			*dst.(*[]*uacgenerator.CaseUac) = append(*dst.(*[]*uacgenerator.CaseUac), caseUac)
+		}
+		return caseKeys
+	}, nil)
+
+	mockIterator := &mocks.Iterator{}
+	for _, uac := range uacs {
+		mockIterator.On("Next", nil).Once().Return(uac, nil)
+	}
+	mockIterator.On("Next", nil).Return(nil, iterator.Done)
+	mockDatastore.On("Run", mock.Anything, mock.AnythingOfType("*datastore.Query")).Return(mockIterator)
+}
+
+var _ = Describe("One UAC per case", func() {
+	var (
+		uacGenerator    *uacgenerator.UacGenerator
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+			*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+			return nil
+		})
+
+		mockDatastore.On("RunInTransaction",
+			uacGenerator.Context,
+			mock.Anything,
+		).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+			return f(mockTransaction)
+		})
+
+		mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
+		readNoInstrumentConfigs(mockTransaction)
+	})
+
+	Context("when a case has been given a UAC by another instance", func() {
+		BeforeEach(func() {
+			mockTransaction.On("Get",
+				datastore.NameKey("case_uac", "74628568", datastore.NameKey("instrument", "lolcat", nil)),
+				mock.AnythingOfType("*uacgenerator.CaseUac"),
+			).Return(nil)
+		})
+
+		It("does not give the case another UAC", func() {
+			uac, err := uacGenerator.NewUac("LOLcat", "74628568", 0)
+			Expect(uac).To(BeEmpty())
+			Expect(err).To(Equal(uacgenerator.ErrCaseHasUac))
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+		})
+	})
+
+	Context("when a case does not have a UAC", func() {
+		BeforeEach(func() {
+			mockTransaction.On("Get",
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.CaseUac"),
+			).Return(datastore.ErrNoSuchEntity)
+		})
+
+		It("inserts the UAC, the case index and the audit entry in one transaction", func() {
+			uac, err := uacGenerator.NewUac("LOLcat", "74628568", 0)
+			Expect(err).To(BeNil())
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			// The UAC is saved with the time it was created, so only the case index can be compared
+			mutations := mockTransaction.Calls[len(mockTransaction.Calls)-1].Arguments.Get(0).([]*datastore.Mutation)
+			Expect(mutations).To(HaveLen(3))
+			Expect(mutations[1]).To(Equal(datastore.NewInsert(
+				datastore.NameKey("case_uac", "74628568", datastore.NameKey("instrument", "lolcat", nil)),
+				&uacgenerator.CaseUac{UacKind: "uac", UAC: uac},
+			)))
+		})
+	})
+})
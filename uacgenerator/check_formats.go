@@ -0,0 +1,132 @@
+package uacgenerator
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+const (
+	UAC12CHECKKIND = "uaccheck"
+	UAC16CHECKKIND = "uac16check"
+)
+
+// CheckedUacFormat is implemented by formats whose UACs end in a check character,
+// so a mistyped UAC can be spotted without looking it up.
+type CheckedUacFormat interface {
+	UacFormat
+	// ValidShape reports whether a UAC has the length and alphabet of the format,
+	// without verifying the check character.
+	ValidShape(string) bool
+}
+
+func init() {
+	RegisterUacFormat(UAC12CHECKKIND, Uac12CheckFormat{})
+	RegisterUacFormat(UAC16CHECKKIND, Uac16CheckFormat{})
+}
+
+// dammTable is the weakly totally anti-symmetric quasigroup of order 10 used by the Damm algorithm.
+var dammTable = [10][10]int{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+func dammDigit(digits string) int {
+	interim := 0
+	for _, digit := range digits {
+		interim = dammTable[interim][digit-'0']
+	}
+	return interim
+}
+
+// Uac12CheckFormat is a 12 digit UAC made of three 1000-9999 blocks, where the
+// last digit is a Damm check digit over the first eleven.
+type Uac12CheckFormat struct{}
+
+var uac12CheckRegex = regexp.MustCompile(`^[1-9]\d{3}[1-9]\d{3}[1-9]\d{3}$`)
+
+func (Uac12CheckFormat) Generate(randomizer *rand.Rand) string {
+	uac := fmt.Sprintf("%d%d%d",
+		randomizer.Int63n(9000)+1000,
+		randomizer.Int63n(9000)+1000,
+		randomizer.Int63n(900)+100,
+	)
+	return fmt.Sprintf("%s%d", uac, dammDigit(uac))
+}
+
+func (Uac12CheckFormat) ValidShape(uac string) bool {
+	return uac12CheckRegex.MatchString(uac)
+}
+
+func (format Uac12CheckFormat) Validate(uac string) bool {
+	return format.ValidShape(uac) && dammDigit(uac) == 0
+}
+
+func (Uac12CheckFormat) Alphabet() string {
+	return digits
+}
+
+func (Uac12CheckFormat) Normalise(uac string) string {
+	return Uac12Format{}.Normalise(uac)
+}
+
+func (Uac12CheckFormat) Chunk(uac string) *UacChunks {
+	return chunkUAC(uac)
+}
+
+// Uac16CheckFormat is a 16 character UAC drawn from APPROVEDCHARACTERS, where the
+// last character is a check character over the first fifteen. The check follows
+// Luhn mod N, weighting alternate characters by 2, but does not fold the doubled
+// values back into the alphabet as that would lose errors for an odd sized alphabet.
+type Uac16CheckFormat struct{}
+
+func weightedModN(uac string, weight int) int {
+	var (
+		sum = 0
+		n   = len(APPROVEDCHARACTERS)
+	)
+	for i := len(uac) - 1; i >= 0; i-- {
+		sum += weight * strings.IndexByte(APPROVEDCHARACTERS, uac[i])
+		weight = 3 - weight
+	}
+	return sum % n
+}
+
+func (Uac16CheckFormat) Generate(randomizer *rand.Rand) string {
+	b := make([]byte, 15)
+	for i := range b {
+		b[i] = APPROVEDCHARACTERS[randomizer.Intn(len(APPROVEDCHARACTERS))]
+	}
+	n := len(APPROVEDCHARACTERS)
+	checkCharacter := APPROVEDCHARACTERS[(n-weightedModN(string(b), 2))%n]
+	return string(append(b, checkCharacter))
+}
+
+func (Uac16CheckFormat) ValidShape(uac string) bool {
+	return Uac16Format{}.Validate(uac)
+}
+
+func (format Uac16CheckFormat) Validate(uac string) bool {
+	return format.ValidShape(uac) && weightedModN(uac, 1) == 0
+}
+
+func (Uac16CheckFormat) Alphabet() string {
+	return APPROVEDCHARACTERS
+}
+
+func (Uac16CheckFormat) Normalise(uac string) string {
+	return Uac16Format{}.Normalise(uac)
+}
+
+func (Uac16CheckFormat) Chunk(uac string) *UacChunks {
+	return chunkUAC(uac)
+}
@@ -0,0 +1,174 @@
+package uacgenerator_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Uac12CheckFormat", func() {
+	var uacFormat = uacgenerator.Uac12CheckFormat{}
+
+	It("Generates 12 digit UACs with a valid Damm check digit", func() {
+		randomizer := rand.New(rand.NewSource(1))
+		for i := 1; i <= 50; i++ {
+			uac := uacFormat.Generate(randomizer)
+
+			Expect(uac).To(MatchRegexp(`^([1-9]\d{3}){3}$`))
+			Expect(uacFormat.Validate(uac)).To(BeTrue())
+		}
+	})
+
+	It("catches every single digit substitution", func() {
+		uac := uacFormat.Generate(rand.New(rand.NewSource(2)))
+		for i := 0; i < len(uac); i++ {
+			for digit := '0'; digit <= '9'; digit++ {
+				if rune(uac[i]) == digit {
+					continue
+				}
+				typo := uac[:i] + string(digit) + uac[i+1:]
+				Expect(uacFormat.Validate(typo)).To(BeFalse(), typo)
+			}
+		}
+	})
+
+	It("catches every adjacent transposition", func() {
+		uac := uacFormat.Generate(rand.New(rand.NewSource(3)))
+		for i := 0; i < len(uac)-1; i++ {
+			if uac[i] == uac[i+1] {
+				continue
+			}
+			typo := uac[:i] + string(uac[i+1]) + string(uac[i]) + uac[i+2:]
+			Expect(uacFormat.Validate(typo)).To(BeFalse(), typo)
+		}
+	})
+
+	DescribeTable("Validations",
+		func(uac string, validShape, valid bool) {
+			Expect(uacFormat.ValidShape(uac)).To(Equal(validShape))
+			Expect(uacFormat.Validate(uac)).To(Equal(valid))
+		},
+		Entry("short", "21314", false, false),
+		Entry("letters", "abcdabcdabcd", false, false),
+		Entry("leading zero block", "123401231234", false, false),
+		Entry("bad check digit", "123412341235", true, false),
+		Entry("good check digit", "123412341234", true, true),
+	)
+})
+
+var _ = Describe("Uac16CheckFormat", func() {
+	var uacFormat = uacgenerator.Uac16CheckFormat{}
+
+	It("Generates 16 character UACs with a valid check character", func() {
+		randomizer := rand.New(rand.NewSource(1))
+		for i := 1; i <= 50; i++ {
+			uac := uacFormat.Generate(randomizer)
+
+			Expect(uac).To(MatchRegexp(fmt.Sprintf(`^[%s]{16}$`, uacgenerator.APPROVEDCHARACTERS)))
+			Expect(uacFormat.Validate(uac)).To(BeTrue())
+		}
+	})
+
+	It("catches every single character substitution", func() {
+		uac := uacFormat.Generate(rand.New(rand.NewSource(2)))
+		for i := 0; i < len(uac); i++ {
+			for _, character := range uacgenerator.APPROVEDCHARACTERS {
+				if rune(uac[i]) == character {
+					continue
+				}
+				typo := uac[:i] + string(character) + uac[i+1:]
+				Expect(uacFormat.Validate(typo)).To(BeFalse(), typo)
+			}
+		}
+	})
+
+	It("catches every adjacent transposition", func() {
+		uac := uacFormat.Generate(rand.New(rand.NewSource(4)))
+		for i := 0; i < len(uac)-1; i++ {
+			if uac[i] == uac[i+1] {
+				continue
+			}
+			typo := uac[:i] + string(uac[i+1]) + string(uac[i]) + uac[i+2:]
+			Expect(uacFormat.Validate(typo)).To(BeFalse(), typo)
+		}
+	})
+
+	It("has the shape of a 16 character UAC regardless of the check character", func() {
+		uac := uacFormat.Generate(rand.New(rand.NewSource(3)))
+		last := strings.IndexByte(uacgenerator.APPROVEDCHARACTERS, uac[15])
+		typo := uac[:15] + string(uacgenerator.APPROVEDCHARACTERS[(last+1)%len(uacgenerator.APPROVEDCHARACTERS)])
+
+		Expect(uacFormat.ValidShape(typo)).To(BeTrue())
+		Expect(uacFormat.Validate(typo)).To(BeFalse())
+		Expect(uacFormat.ValidShape("abcdabcdabcdabcd")).To(BeFalse())
+	})
+})
+
+var _ = Describe("GetUacInfo with a check character format", func() {
+	var (
+		uacGenerator  *uacgenerator.UacGenerator
+		mockDatastore *mocks.Datastore
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uaccheck")
+
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.UacInfo"),
+		).Return(datastore.ErrNoSuchEntity)
+	})
+
+	It("returns a probable typo without querying datastore", func() {
+		uacInfo, err := uacGenerator.GetUacInfo("123412341235")
+		Expect(uacInfo).To(BeNil())
+		Expect(err).To(Equal(uacgenerator.ErrProbableTypo))
+		mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 0)
+	})
+
+	It("looks up UACs with a valid check digit", func() {
+		_, err := uacGenerator.GetUacInfo("123412341234")
+		Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 1)
+	})
+
+	It("looks up UACs that do not have the shape of the format", func() {
+		_, err := uacGenerator.GetUacInfo("lemons")
+		Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 1)
+	})
+
+	Context("when the plain kind is enabled too", func() {
+		BeforeEach(func() {
+			uacGenerator.UacKind = "uac"
+			uacGenerator.UacKinds = []string{"uaccheck"}
+		})
+
+		It("returns a probable typo once it is not found as a plain UAC", func() {
+			uacInfo, err := uacGenerator.GetUacInfo("123412341235")
+			Expect(uacInfo).To(BeNil())
+			Expect(err).To(Equal(uacgenerator.ErrProbableTypo))
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 1)
+		})
+
+		It("finds plain UACs that fail the check", func() {
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+			uacGenerator.UacKinds = []string{"uaccheck"}
+			Expect(uacGenerator.AddUacToDatastore("123412341235", "lolcat", "1")).To(Succeed())
+
+			uacInfo, err := uacGenerator.GetUacInfo("123412341235")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.CaseID).To(Equal("1"))
+		})
+	})
+})
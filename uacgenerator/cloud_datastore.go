@@ -0,0 +1,57 @@
+package uacgenerator
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+)
+
+// Generate mocks by running "go generate ./..."
+//
+//go:generate mockery --name Transaction --unroll-variadic=false
+type Transaction interface {
+	Get(*datastore.Key, interface{}) error
+	// GetAll runs an ancestor query in the transaction, so the transaction fails when the query's results change
+	// before it commits.
+	GetAll(*datastore.Query, interface{}) ([]*datastore.Key, error)
+	Mutate(...*datastore.Mutation) error
+}
+
+// Iterator is the part of datastore.Iterator used to page through query results.
+//
+//go:generate mockery --name Iterator
+type Iterator interface {
+	Next(interface{}) (*datastore.Key, error)
+	Cursor() (datastore.Cursor, error)
+}
+
+// CloudDatastore adapts a Cloud Datastore client to the Datastore interface.
+type CloudDatastore struct {
+	*datastore.Client
+}
+
+func (cloudDatastore *CloudDatastore) RunInTransaction(ctx context.Context, f func(Transaction) error) error {
+	_, err := cloudDatastore.Client.RunInTransaction(ctx, func(transaction *datastore.Transaction) error {
+		return f(&cloudTransaction{Transaction: transaction, client: cloudDatastore.Client, ctx: ctx})
+	})
+	return err
+}
+
+func (cloudDatastore *CloudDatastore) Run(ctx context.Context, query *datastore.Query) Iterator {
+	return cloudDatastore.Client.Run(ctx, query)
+}
+
+type cloudTransaction struct {
+	*datastore.Transaction
+	client *datastore.Client
+	ctx    context.Context
+}
+
+func (transaction *cloudTransaction) GetAll(query *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+	return transaction.client.GetAll(transaction.ctx, query.Transaction(transaction.Transaction), dst)
+}
+
+func (transaction *cloudTransaction) Mutate(mutations ...*datastore.Mutation) error {
+	_, err := transaction.Transaction.Mutate(mutations...)
+	return err
+}
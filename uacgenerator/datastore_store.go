@@ -0,0 +1,802 @@
+package uacgenerator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+	"github.com/zenthangplus/goccm"
+	"google.golang.org/api/iterator"
+)
+
+// DatastoreUacStore keeps UACs in Datastore as entities of their kind keyed by UAC, with a case_uac entity
+// under the instrument for every slot of a case that has a UAC.
+type DatastoreUacStore struct {
+	Client Datastore
+}
+
+func NewDatastoreUacStore(datastoreClient Datastore) *DatastoreUacStore {
+	return &DatastoreUacStore{Client: datastoreClient}
+}
+
+func (store *DatastoreUacStore) InsertCaseUacs(ctx context.Context, uacInfos []*UacInfo, auditEntries []*AuditEntry) error {
+	mutations := make([]*datastore.Mutation, 0, 2*len(uacInfos)+len(auditEntries))
+	instrumentUacKinds := make(map[string]string)
+	for _, uacInfo := range uacInfos {
+		mutations = append(mutations, caseUacMutations(uacInfo)...)
+		instrumentUacKinds[strings.ToLower(uacInfo.InstrumentName)] = uacInfo.UAC.Kind
+	}
+	mutations = withAuditEntries(auditEntries, mutations...)
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		for instrumentName, uacKind := range instrumentUacKinds {
+			err := checkInstrumentUacKind(transaction, instrumentName, uacKind)
+			if err != nil {
+				return err
+			}
+		}
+		return transaction.Mutate(mutations...)
+	})
+}
+
+func (store *DatastoreUacStore) InsertCaseUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		err := checkInstrumentUacKind(transaction, uacInfo.InstrumentName, uacInfo.UAC.Kind)
+		if err != nil {
+			return err
+		}
+		err = caseHasUac(transaction, uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot)
+		if err != nil {
+			return err
+		}
+		return transaction.Mutate(withAuditEntries(auditEntries, caseUacMutations(uacInfo)...)...)
+	})
+}
+
+func (store *DatastoreUacStore) InsertPoolUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	newUACMutation := datastore.NewInsert(uacInfo.UAC, &UacInfo{
+		InstrumentName: UNKNOWNINSTRUMENT,
+		CaseID:         UNKNOWNINSTRUMENT,
+		SealedUAC:      uacInfo.SealedUAC,
+		CreatedAt:      uacInfo.CreatedAt,
+		UpdatedAt:      uacInfo.UpdatedAt,
+	})
+	_, err := store.Client.Mutate(ctx, withAuditEntries(auditEntries, newUACMutation)...)
+	return err
+}
+
+func (store *DatastoreUacStore) ListPoolUacs(ctx context.Context, uacKind string, limit int) ([]*datastore.Key, error) {
+	return store.Client.GetAll(ctx, poolQuery(uacKind).Limit(limit), nil)
+}
+
+// ClaimPoolUac makes the checks, the update and the case index insert in one transaction,
+// so a UAC can only be claimed once and a slot of a case can only claim one UAC.
+func (store *DatastoreUacStore) ClaimPoolUac(ctx context.Context, uacKey *datastore.Key, instrumentName, caseID string, slot int, auditEntries []*AuditEntry) (bool, error) {
+	var claimed bool
+	err := store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		claimed = false
+		uacInfo := &UacInfo{}
+		err := transaction.Get(uacKey, uacInfo)
+		if err == datastore.ErrNoSuchEntity {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if uacInfo.InstrumentName != UNKNOWNINSTRUMENT || uacInfo.Disabled {
+			return nil
+		}
+		err = checkInstrumentUacKind(transaction, instrumentName, uacKey.Kind)
+		if err != nil {
+			return err
+		}
+		err = caseHasUac(transaction, instrumentName, caseID, slot)
+		if err != nil {
+			return err
+		}
+		// A claimed UAC is created when it is given to its case
+		now := uacTimestamp()
+		mutations := []*datastore.Mutation{
+			datastore.NewUpdate(uacKey, &UacInfo{
+				InstrumentName:  strings.ToLower(instrumentName),
+				CaseID:          strings.ToLower(caseID),
+				Slot:            slot,
+				SealedUAC:       uacInfo.SealedUAC,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+				FirstAccessedAt: uacInfo.FirstAccessedAt,
+				ValidFrom:       uacInfo.ValidFrom,
+				ValidUntil:      uacInfo.ValidUntil,
+			}),
+			datastore.NewInsert(caseKey(instrumentName, caseID, slot), &CaseUac{UacKind: uacKey.Kind, UAC: uacKey.Name}),
+		}
+		err = transaction.Mutate(withAuditEntries(auditEntries, mutations...)...)
+		if err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+func (store *DatastoreUacStore) GetUac(ctx context.Context, uac *datastore.Key) (*UacInfo, error) {
+	uacInfo := &UacInfo{}
+	err := store.Client.Get(ctx, uac, uacInfo)
+	if err != nil {
+		return nil, err
+	}
+	if uacInfo.UAC == nil {
+		uacInfo.UAC = uac
+	}
+	return uacInfo, nil
+}
+
+func (store *DatastoreUacStore) ListUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error) {
+	return store.getAll(ctx, instrumentQuery(uacKind, instrumentName))
+}
+
+// ListCaseSlots reads the case index, whose entities are much smaller than UACs, and streams the keys of the
+// instrument's UACs.
+// Only the UACs the case index does not point to are loaded, which are those given to cases before case index
+// entities were introduced and those replaced by a reissue.
+func (store *DatastoreUacStore) ListCaseSlots(ctx context.Context, uacKind, instrumentName string) ([]string, error) {
+	var caseUacs []*CaseUac
+	caseKeys, err := store.Client.GetAll(ctx, datastore.NewQuery(CASEKIND).Ancestor(instrumentKey(instrumentName)), &caseUacs)
+	if err != nil {
+		return nil, err
+	}
+	caseSlots := make([]string, 0, len(caseKeys))
+	indexedUacs := make(map[string]bool, len(caseKeys))
+	for i, caseKey := range caseKeys {
+		if caseUacs[i].UacKind != uacKind {
+			continue
+		}
+		caseSlots = append(caseSlots, caseKeySlot(caseKey))
+		indexedUacs[caseUacs[i].UAC] = true
+	}
+	var unindexedUacs []*datastore.Key
+	uacIterator := store.Client.Run(ctx, instrumentQuery(uacKind, instrumentName).KeysOnly())
+	for {
+		uac, err := uacIterator.Next(nil)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !indexedUacs[uac.Name] {
+			unindexedUacs = append(unindexedUacs, uac)
+		}
+	}
+	unindexedCaseSlots, err := store.getCaseSlots(ctx, unindexedUacs)
+	if err != nil {
+		return nil, err
+	}
+	return append(caseSlots, unindexedCaseSlots...), nil
+}
+
+// getCaseSlots loads UACs concurrently, returning the CaseSlotKeys of their case slots.
+func (store *DatastoreUacStore) getCaseSlots(ctx context.Context, uacs []*datastore.Key) ([]string, error) {
+	if len(uacs) == 0 {
+		return nil, nil
+	}
+	var (
+		caseSlots []string
+		errors    []error
+		resultMu  sync.Mutex
+	)
+	concurrent := goccm.New(MAXCONCURRENT)
+	for _, uac := range uacs {
+		concurrent.Wait()
+		go func(uac *datastore.Key) {
+			defer concurrent.Done()
+			uacInfo := &UacInfo{}
+			err := store.Client.Get(ctx, uac, uacInfo)
+			resultMu.Lock()
+			defer resultMu.Unlock()
+			if err != nil {
+				errors = append(errors, err)
+				return
+			}
+			caseSlots = append(caseSlots, uacInfo.CaseSlot())
+		}(uac)
+	}
+	concurrent.WaitAllDone()
+	if len(errors) > 0 {
+		return nil, errors[0]
+	}
+	return caseSlots, nil
+}
+
+// IterateUacs filters UACs as they are read, as Datastore cannot filter on ranges of more than one property, so
+// only matching UACs count towards the limit.
+func (store *DatastoreUacStore) IterateUacs(ctx context.Context, uacKind, instrumentName string, filter UacFilter, cursor string, limit int, f func(*UacInfo) error) (string, error) {
+	query := instrumentQuery(uacKind, instrumentName)
+	if cursor != "" {
+		startCursor, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			return "", ErrInvalidCursor
+		}
+		query = query.Start(startCursor)
+	}
+	if limit > 0 && filter.IsZero() {
+		query = query.Limit(limit)
+	}
+	uacIterator := store.Client.Run(ctx, query)
+	uacCount := 0
+	for limit <= 0 || uacCount < limit {
+		uacInfo := &UacInfo{}
+		_, err := uacIterator.Next(uacInfo)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if !filter.Match(uacInfo) {
+			continue
+		}
+		uacCount++
+		err = f(uacInfo)
+		if err != nil {
+			return "", err
+		}
+	}
+	if limit <= 0 || uacCount < limit {
+		return "", nil
+	}
+	nextCursor, err := uacIterator.Cursor()
+	if err != nil {
+		return "", err
+	}
+	return nextCursor.String(), nil
+}
+
+func (store *DatastoreUacStore) ListDisabledUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error) {
+	return store.getAll(ctx, instrumentUacDisabledQuery(uacKind, instrumentName))
+}
+
+func (store *DatastoreUacStore) ListCaseUacs(ctx context.Context, uacKind, instrumentName, caseID string) ([]*UacInfo, error) {
+	return store.getAll(ctx, instrumentCaseQuery(uacKind, instrumentName, caseID))
+}
+
+func (store *DatastoreUacStore) CountUacs(ctx context.Context, uacKind, instrumentName string) (int, error) {
+	return store.Client.Count(ctx, instrumentQuery(uacKind, instrumentName))
+}
+
+// SetUacsDisabled reads the UACs again in one transaction, so the time of a lookup made since they were read is kept.
+func (store *DatastoreUacStore) SetUacsDisabled(ctx context.Context, uacs []*datastore.Key, change UacStatusChange, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		now := uacTimestamp()
+		mutations := make([]*datastore.Mutation, len(uacs))
+		for i, uac := range uacs {
+			uacInfo := &UacInfo{}
+			err := transaction.Get(uac, uacInfo)
+			if err != nil {
+				return err
+			}
+			change.apply(uacInfo, now)
+			mutations[i] = datastore.NewUpdate(uac, uacEntity(uacInfo))
+		}
+		return transaction.Mutate(withAuditEntries(auditEntries, mutations...)...)
+	})
+}
+
+// ReissueCaseUac finds the current UAC through the case index in the transaction, so of concurrent reissues of a
+// case slot only one replaces each UAC. Cases given UACs before case index entities were introduced are found by
+// their UAC and given one.
+func (store *DatastoreUacStore) ReissueCaseUac(ctx context.Context, uacInfo *UacInfo, change UacStatusChange, auditEntries func(replaced *UacInfo) []*AuditEntry) (*UacInfo, error) {
+	caseUacs, err := store.ListCaseUacs(ctx, uacInfo.UAC.Kind, uacInfo.InstrumentName, uacInfo.CaseID)
+	if err != nil {
+		return nil, err
+	}
+	var replaced *UacInfo
+	err = store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		caseUacKey := caseKey(uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot)
+		caseUac := &CaseUac{}
+		err := transaction.Get(caseUacKey, caseUac)
+		var current *datastore.Key
+		switch {
+		case err == nil:
+			current = uacKindKey(caseUac.UacKind, caseUac.UAC)
+		case err == datastore.ErrNoSuchEntity:
+			legacyUac := currentCaseUac(caseUacs, uacInfo.Slot)
+			if legacyUac == nil {
+				return datastore.ErrNoSuchEntity
+			}
+			current = legacyUac.UAC
+		default:
+			return err
+		}
+		replaced = &UacInfo{}
+		err = transaction.Get(current, replaced)
+		if err != nil {
+			return err
+		}
+		replaced.UAC = current
+		after := *replaced
+		change.apply(&after, uacTimestamp())
+		after.ReissuedTo = uacInfo.UAC.Name
+		uacInfo.ReissuedFrom = current.Name
+		mutations := []*datastore.Mutation{
+			datastore.NewInsert(uacInfo.UAC, uacEntity(uacInfo)),
+			datastore.NewUpdate(current, uacEntity(&after)),
+			datastore.NewUpsert(caseUacKey, &CaseUac{UacKind: uacInfo.UAC.Kind, UAC: uacInfo.UAC.Name}),
+		}
+		return transaction.Mutate(withAuditEntries(auditEntries(replaced), mutations...)...)
+	})
+	if err != nil {
+		if alreadyExistsError(err) {
+			return nil, ErrUacExists
+		}
+		return nil, err
+	}
+	return replaced, nil
+}
+
+func (store *DatastoreUacStore) SetUacValidity(ctx context.Context, uac *datastore.Key, validity Validity, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		uacInfo := &UacInfo{}
+		err := transaction.Get(uac, uacInfo)
+		if err != nil {
+			return err
+		}
+		uacInfo.ValidFrom = validity.ValidFrom
+		uacInfo.ValidUntil = validity.ValidUntil
+		return transaction.Mutate(withAuditEntries(auditEntries, datastore.NewUpdate(uac, uacEntity(uacInfo)))...)
+	})
+}
+
+func (store *DatastoreUacStore) RecordUacAccess(ctx context.Context, uac *datastore.Key) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		uacInfo := &UacInfo{}
+		err := transaction.Get(uac, uacInfo)
+		if err != nil {
+			return err
+		}
+		if !uacInfo.FirstAccessedAt.IsZero() {
+			return nil
+		}
+		uacInfo.FirstAccessedAt = uacTimestamp()
+		return transaction.Mutate(datastore.NewUpdate(uac, uacEntity(uacInfo)))
+	})
+}
+
+// RekeyUac inserts the UAC under its new key with its links renamed, deletes the old key and points the case index at
+// the new key in one transaction.
+func (store *DatastoreUacStore) RekeyUac(ctx context.Context, uacInfo *UacInfo, uac *datastore.Key, sealedUac string, linkName func(string) string, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		currentUacInfo := &UacInfo{}
+		err := transaction.Get(uacInfo.UAC, currentUacInfo)
+		if err != nil {
+			return err
+		}
+		currentUacInfo.SealedUAC = sealedUac
+		currentUacInfo.renameLinks(linkName)
+		mutations := []*datastore.Mutation{
+			datastore.NewInsert(uac, uacEntity(currentUacInfo)),
+			datastore.NewDelete(uacInfo.UAC),
+		}
+		if currentUacInfo.InstrumentName != UNKNOWNINSTRUMENT && currentUacInfo.ReissuedTo == "" {
+			mutations = append(mutations, datastore.NewUpsert(
+				caseKey(currentUacInfo.InstrumentName, currentUacInfo.CaseID, currentUacInfo.Slot),
+				&CaseUac{UacKind: uac.Kind, UAC: uac.Name},
+			))
+		}
+		return transaction.Mutate(withAuditEntries(auditEntries, mutations...)...)
+	})
+}
+
+func (store *DatastoreUacStore) ListInstruments(ctx context.Context, uacKind string) ([]string, error) {
+	uacInfos, err := store.getAll(ctx, instrumentNamesQuery(uacKind))
+	if err != nil {
+		return nil, err
+	}
+	instrumentNames := make([]string, len(uacInfos))
+	for i, uacInfo := range uacInfos {
+		instrumentNames[i] = uacInfo.InstrumentName
+	}
+	return instrumentNames, nil
+}
+
+// ArchiveInstrument saves the archive first, so UACs archived before a failure can still be restored, then moves
+// the UACs in concurrent chunks, each deleting the UACs and their case index entities in the commit that archives
+// them and adding their audit entries. Every chunk is tried before the first error is returned. The instrument's UAC
+// kind is only deleted once every chunk has been committed, so a retry still finds the UACs left behind by a failure.
+func (store *DatastoreUacStore) ArchiveInstrument(ctx context.Context, archive *InstrumentArchive, auditEntry func(*UacInfo) *AuditEntry) error {
+	uacInfos, err := store.getAll(ctx, instrumentQuery(archive.UacKind, archive.InstrumentName))
+	if err != nil {
+		return err
+	}
+	_, err = store.Client.Mutate(ctx, datastore.NewUpsert(archiveKey(archive.InstrumentName), archive))
+	if err != nil {
+		return err
+	}
+	var mutationChunks [][]*datastore.Mutation
+	// Archiving a UAC takes up to three mutations and its audit entry
+	for start := 0; start < len(uacInfos); start += MAXMUTATIONS / 4 {
+		end := start + MAXMUTATIONS/4
+		if end > len(uacInfos) {
+			end = len(uacInfos)
+		}
+		mutations := make([]*datastore.Mutation, 0, 4*(end-start))
+		for _, uacInfo := range uacInfos[start:end] {
+			mutations = append(mutations,
+				datastore.NewUpsert(archivedUacKey(archive.InstrumentName, uacInfo.UAC), &archivedUac{
+					UacKind: uacInfo.UAC.Kind,
+					Uac:     uacInfo.UAC.Name,
+					UacInfo: *uacEntity(uacInfo),
+				}),
+				datastore.NewDelete(uacInfo.UAC),
+			)
+			// Imported UACs all belong to the same unknown case, which has no index entity, and a case's index
+			// entity belongs to its current UAC
+			if uacInfo.InstrumentName != UNKNOWNINSTRUMENT && uacInfo.ReissuedTo == "" {
+				mutations = append(mutations, datastore.NewDelete(caseKey(uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot)))
+			}
+			mutations = withAuditEntries([]*AuditEntry{auditEntry(uacInfo)}, mutations...)
+		}
+		mutationChunks = append(mutationChunks, mutations)
+	}
+	err = store.mutateConcurrently(ctx, mutationChunks)
+	if err != nil {
+		return err
+	}
+	_, err = store.Client.Mutate(ctx, datastore.NewDelete(instrumentKey(archive.InstrumentName)))
+	return err
+}
+
+func (store *DatastoreUacStore) GetInstrumentArchive(ctx context.Context, instrumentName string) (*InstrumentArchive, error) {
+	archive := &InstrumentArchive{}
+	err := store.Client.Get(ctx, archiveKey(instrumentName), archive)
+	if err != nil {
+		return nil, err
+	}
+	archive.InstrumentName = strings.ToLower(instrumentName)
+	return archive, nil
+}
+
+func (store *DatastoreUacStore) ListInstrumentArchives(ctx context.Context) ([]*InstrumentArchive, error) {
+	var archives []*InstrumentArchive
+	keys, err := store.Client.GetAll(ctx, datastore.NewQuery(ARCHIVEKIND), &archives)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		archives[i].InstrumentName = key.Name
+	}
+	return archives, nil
+}
+
+func (store *DatastoreUacStore) ListArchivedUacs(ctx context.Context, instrumentName string) ([]*UacInfo, error) {
+	var archivedUacs []*archivedUac
+	_, err := store.Client.GetAll(ctx, datastore.NewQuery(ARCHIVEDUACKIND).Ancestor(archiveKey(instrumentName)), &archivedUacs)
+	if err != nil {
+		return nil, err
+	}
+	uacInfos := make([]*UacInfo, len(archivedUacs))
+	for i, archived := range archivedUacs {
+		uacInfos[i] = archived.uacInfo()
+	}
+	return uacInfos, nil
+}
+
+// RestoreUac checks the case, inserts the UAC and its case index and deletes the archived UAC in one transaction.
+// UACs replaced by a reissued UAC are restored without the case index, which belongs to the case's current UAC.
+func (store *DatastoreUacStore) RestoreUac(ctx context.Context, archivedUac *datastore.Key, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		mutations := []*datastore.Mutation{
+			datastore.NewDelete(archivedUacKey(uacInfo.InstrumentName, archivedUac)),
+			datastore.NewInsert(uacInfo.UAC, uacEntity(uacInfo)),
+		}
+		if uacInfo.InstrumentName != UNKNOWNINSTRUMENT && uacInfo.ReissuedTo == "" {
+			err := caseHasUac(transaction, uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot)
+			if err != nil {
+				return err
+			}
+			mutations = append(mutations, datastore.NewInsert(
+				caseKey(uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot),
+				&CaseUac{UacKind: uacInfo.UAC.Kind, UAC: uacInfo.UAC.Name},
+			))
+		}
+		return transaction.Mutate(withAuditEntries(auditEntries, mutations...)...)
+	})
+}
+
+// DeleteInstrumentArchive deletes the archived UACs in concurrent chunks, each adding the audit entries of the UACs it
+// deletes, and only deletes the archive once they have all gone, so a failed purge can be run again.
+func (store *DatastoreUacStore) DeleteInstrumentArchive(ctx context.Context, instrumentName string, auditEntry func(*UacInfo) *AuditEntry) error {
+	var archivedUacs []*archivedUac
+	archivedUacKeys, err := store.Client.GetAll(ctx, datastore.NewQuery(ARCHIVEDUACKIND).Ancestor(archiveKey(instrumentName)), &archivedUacs)
+	if err != nil {
+		return err
+	}
+	var mutationChunks [][]*datastore.Mutation
+	// Deleting an archived UAC takes a mutation and its audit entry
+	for start := 0; start < len(archivedUacKeys); start += MAXMUTATIONS / 2 {
+		end := start + MAXMUTATIONS/2
+		if end > len(archivedUacKeys) {
+			end = len(archivedUacKeys)
+		}
+		mutations := make([]*datastore.Mutation, 0, 2*(end-start))
+		for i := start; i < end; i++ {
+			mutations = append(mutations, datastore.NewDelete(archivedUacKeys[i]))
+			if auditEntry != nil {
+				mutations = withAuditEntries([]*AuditEntry{auditEntry(archivedUacs[i].uacInfo())}, mutations...)
+			}
+		}
+		mutationChunks = append(mutationChunks, mutations)
+	}
+	err = store.mutateConcurrently(ctx, mutationChunks)
+	if err != nil {
+		return err
+	}
+	_, err = store.Client.Mutate(ctx, datastore.NewDelete(archiveKey(instrumentName)))
+	return err
+}
+
+func (store *DatastoreUacStore) GetInstrumentConfig(ctx context.Context, instrumentName string) (*InstrumentConfig, error) {
+	instrumentConfig := &InstrumentConfig{}
+	err := store.Client.Get(ctx, instrumentKey(instrumentName), instrumentConfig)
+	if err != nil {
+		return nil, err
+	}
+	instrumentConfig.InstrumentName = strings.ToLower(instrumentName)
+	return instrumentConfig, nil
+}
+
+func (store *DatastoreUacStore) InsertInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error {
+	newInstrumentMutation := datastore.NewInsert(instrumentKey(instrumentName), &InstrumentConfig{UacKind: uacKind})
+	_, err := store.Client.Mutate(ctx, withAuditEntries(auditEntries, newInstrumentMutation)...)
+	return err
+}
+
+// SaveInstrumentConfig looks for the case index entities of UACs of another kind in the transaction that records the
+// kind, so cases given UACs before it commits make it fail. Every case UAC of an instrument is of the same kind, so the
+// first is enough.
+func (store *DatastoreUacStore) SaveInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		instrumentConfig := &InstrumentConfig{}
+		err := transaction.Get(instrumentKey(instrumentName), instrumentConfig)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if instrumentConfig.UacKind != uacKind {
+			var caseUacs []*CaseUac
+			_, err = transaction.GetAll(datastore.NewQuery(CASEKIND).Ancestor(instrumentKey(instrumentName)).Limit(1), &caseUacs)
+			if err != nil {
+				return err
+			}
+			if len(caseUacs) > 0 && caseUacs[0].UacKind != uacKind {
+				return ErrInstrumentHasUacs
+			}
+		}
+		instrumentConfig.UacKind = uacKind
+		return transaction.Mutate(withAuditEntries(auditEntries, datastore.NewUpsert(instrumentKey(instrumentName), instrumentConfig))...)
+	})
+}
+
+func (store *DatastoreUacStore) SaveInstrumentValidity(ctx context.Context, instrumentName string, validity Validity, auditEntries []*AuditEntry) error {
+	return store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		instrumentConfig := &InstrumentConfig{}
+		err := transaction.Get(instrumentKey(instrumentName), instrumentConfig)
+		if err != nil {
+			return err
+		}
+		instrumentConfig.ValidFrom = validity.ValidFrom
+		instrumentConfig.ValidUntil = validity.ValidUntil
+		return transaction.Mutate(withAuditEntries(auditEntries, datastore.NewUpdate(instrumentKey(instrumentName), instrumentConfig))...)
+	})
+}
+
+// ListAuditEntries filters time ranges and cursors on the key, as audit keys sort by time.
+func (store *DatastoreUacStore) ListAuditEntries(ctx context.Context, query AuditQuery) ([]*AuditEntry, error) {
+	auditQuery := datastore.NewQuery(AUDITKIND)
+	if query.UAC != "" {
+		auditQuery = auditQuery.FilterField("uac", "=", query.UAC)
+	}
+	if query.InstrumentName != "" {
+		auditQuery = auditQuery.FilterField("instrument_name", "=", query.InstrumentName)
+	}
+	if query.Actor != "" {
+		auditQuery = auditQuery.FilterField("actor", "=", query.Actor)
+	}
+	if !query.From.IsZero() {
+		auditQuery = auditQuery.FilterField("__key__", ">=", auditKey(auditIDBound(query.From)))
+	}
+	if !query.To.IsZero() {
+		auditQuery = auditQuery.FilterField("__key__", "<", auditKey(auditIDBound(query.To)))
+	}
+	if query.Cursor != "" {
+		auditQuery = auditQuery.FilterField("__key__", ">", auditKey(query.Cursor))
+	}
+	if query.Limit > 0 {
+		auditQuery = auditQuery.Limit(query.Limit)
+	}
+	var entries []*AuditEntry
+	keys, err := store.Client.GetAll(ctx, auditQuery, &entries)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		entries[i].ID = key.Name
+	}
+	return entries, nil
+}
+
+// InsertGenerationJob saves the job's cases in chunks under the job, numbered in order, then the job, so no job is
+// saved without all its cases.
+func (store *DatastoreUacStore) InsertGenerationJob(ctx context.Context, job *GenerationJob, cases []JobCase) error {
+	var mutationChunks [][]*datastore.Mutation
+	for start := 0; start < len(cases); start += JOBCASECHUNKSIZE {
+		end := start + JOBCASECHUNKSIZE
+		if end > len(cases) {
+			end = len(cases)
+		}
+		chunk := &jobCaseChunk{}
+		for _, jobCase := range cases[start:end] {
+			chunk.CaseIDs = append(chunk.CaseIDs, jobCase.CaseID)
+			chunk.Uacs = append(chunk.Uacs, jobCase.Uacs)
+		}
+		chunkKey := datastore.IDKey(JOBCASESKIND, int64(start/JOBCASECHUNKSIZE+1), jobKey(job.ID))
+		mutationChunks = append(mutationChunks, []*datastore.Mutation{datastore.NewInsert(chunkKey, chunk)})
+	}
+	err := store.mutateConcurrently(ctx, mutationChunks)
+	if err != nil {
+		return err
+	}
+	_, err = store.Client.Mutate(ctx, datastore.NewInsert(jobKey(job.ID), job))
+	return err
+}
+
+func (store *DatastoreUacStore) GetGenerationJob(ctx context.Context, jobID string) (*GenerationJob, error) {
+	job := &GenerationJob{}
+	err := store.Client.Get(ctx, jobKey(jobID), job)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = jobID
+	return job, nil
+}
+
+func (store *DatastoreUacStore) ListRunningGenerationJobs(ctx context.Context) ([]*GenerationJob, error) {
+	var jobs []*GenerationJob
+	keys, err := store.Client.GetAll(ctx, datastore.NewQuery(JOBKIND).FilterField("status", "=", JOBRUNNING), &jobs)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		jobs[i].ID = key.Name
+	}
+	return jobs, nil
+}
+
+func (store *DatastoreUacStore) ListGenerationJobCases(ctx context.Context, jobID string) ([]JobCase, error) {
+	var chunks []*jobCaseChunk
+	keys, err := store.Client.GetAll(ctx, datastore.NewQuery(JOBCASESKIND).Ancestor(jobKey(jobID)), &chunks)
+	if err != nil {
+		return nil, err
+	}
+	orderedChunks := make([]*jobCaseChunk, len(chunks))
+	for i, key := range keys {
+		if key.ID < 1 || int(key.ID) > len(chunks) {
+			return nil, fmt.Errorf("Generation job %s has an unexpected chunk of cases %d", jobID, key.ID)
+		}
+		orderedChunks[key.ID-1] = chunks[i]
+	}
+	var cases []JobCase
+	for _, chunk := range orderedChunks {
+		for i, caseID := range chunk.CaseIDs {
+			cases = append(cases, JobCase{CaseID: caseID, Uacs: chunk.Uacs[i]})
+		}
+	}
+	return cases, nil
+}
+
+func (store *DatastoreUacStore) UpdateGenerationJob(ctx context.Context, jobID string, update func(*GenerationJob) error) (*GenerationJob, error) {
+	var job *GenerationJob
+	err := store.Client.RunInTransaction(ctx, func(transaction Transaction) error {
+		job = &GenerationJob{}
+		err := transaction.Get(jobKey(jobID), job)
+		if err != nil {
+			return err
+		}
+		job.ID = jobID
+		err = update(job)
+		if err != nil {
+			return err
+		}
+		return transaction.Mutate(datastore.NewUpdate(jobKey(jobID), job))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (store *DatastoreUacStore) Close() error {
+	return store.Client.Close()
+}
+
+// mutateConcurrently commits each chunk of mutations on its own, logging the chunks that fail and returning the
+// first error once every chunk has been tried.
+func (store *DatastoreUacStore) mutateConcurrently(ctx context.Context, mutationChunks [][]*datastore.Mutation) error {
+	if len(mutationChunks) == 0 {
+		return nil
+	}
+	var (
+		errors  []error
+		errorMu sync.Mutex
+	)
+	concurrent := goccm.New(MAXCONCURRENT)
+	for _, mutations := range mutationChunks {
+		concurrent.Wait()
+		go func(mutations []*datastore.Mutation) {
+			defer concurrent.Done()
+			_, err := store.Client.Mutate(ctx, mutations...)
+			if err != nil {
+				log.Println(err)
+				errorMu.Lock()
+				errors = append(errors, err)
+				errorMu.Unlock()
+			}
+		}(mutations)
+	}
+	concurrent.WaitAllDone()
+	if len(errors) > 0 {
+		return errors[0]
+	}
+	return nil
+}
+
+// withAuditEntries adds the inserts of audit entries to the mutations that make the changes they record.
+func withAuditEntries(auditEntries []*AuditEntry, mutations ...*datastore.Mutation) []*datastore.Mutation {
+	for _, entry := range auditEntries {
+		mutations = append(mutations, datastore.NewInsert(auditKey(entry.ID), entry))
+	}
+	return mutations
+}
+
+func (store *DatastoreUacStore) getAll(ctx context.Context, query *datastore.Query) ([]*UacInfo, error) {
+	var uacInfos []*UacInfo
+	_, err := store.Client.GetAll(ctx, query, &uacInfos)
+	if err != nil {
+		return nil, err
+	}
+	return uacInfos, nil
+}
+
+func instrumentCaseQuery(uacKind, instrumentName, caseID string) *datastore.Query {
+	query := datastore.NewQuery(uacKind)
+	query = query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
+	return query.FilterField(strings.ToLower("case_id"), "=", strings.ToLower(caseID))
+}
+
+func instrumentQuery(uacKind, instrumentName string) *datastore.Query {
+	query := datastore.NewQuery(uacKind)
+	return query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
+}
+
+func instrumentUacDisabledQuery(uacKind, instrumentName string) *datastore.Query {
+	query := datastore.NewQuery(uacKind)
+
+	query = query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
+	return query.FilterField(strings.ToLower("disabled"), "=", true)
+}
+
+func instrumentNamesQuery(uacKind string) *datastore.Query {
+	query := datastore.NewQuery(uacKind)
+	query = query.Project("instrument_name")
+	return query.DistinctOn("instrument_name")
+}
+
+func poolQuery(uacKind string) *datastore.Query {
+	query := instrumentQuery(uacKind, UNKNOWNINSTRUMENT)
+	return query.FilterField("disabled", "=", false).KeysOnly()
+}
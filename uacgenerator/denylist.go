@@ -0,0 +1,169 @@
+package uacgenerator
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// defaultDeniedWords are denied when no denylist is loaded. UACs have no vowels of their own, so words are mostly
+// spelt through digits standing in for letters or by their consonants alone.
+var defaultDeniedWords = []string{
+	"arse", "ass", "bastard", "bitch", "bollocks", "bomb", "boob", "cock", "crap", "cunt", "damn", "dead", "dick",
+	"fag", "fart", "fuck", "hitler", "jizz", "kill", "kkk", "knob", "minge", "nazi", "nob", "penis", "piss", "porn",
+	"prick", "rape", "scum", "sex", "shag", "shit", "slag", "slut", "spunk", "tosser", "twat", "vagina", "wank",
+	"whore",
+}
+
+// leetLetters are the letters digits are read as.
+var leetLetters = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'2': 'z',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'6': 'g',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// Denylist holds the words UACs must not spell. A UAC spells a word when, reading its digits as letters, it contains
+// the word, or contains the word's consonants in order with only vowels between them.
+type Denylist struct {
+	words []deniedWord
+}
+
+type deniedWord struct {
+	word       string
+	consonants string
+}
+
+// NewDenylist returns a denylist of words, which are read as UACs are.
+func NewDenylist(words []string) *Denylist {
+	denylist := &Denylist{}
+	for _, word := range words {
+		word = deleet(word)
+		if word == "" {
+			continue
+		}
+		denylist.words = append(denylist.words, deniedWord{word: word, consonants: consonants(word)})
+	}
+	return denylist
+}
+
+// DefaultDenylist returns the denylist used when none is loaded.
+func DefaultDenylist() *Denylist {
+	return NewDenylist(defaultDeniedWords)
+}
+
+// LoadDenylist reads a denylist from a file of one word a line, ignoring blank lines and lines starting with #.
+func LoadDenylist(path string) (*Denylist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewDenylist(words), nil
+}
+
+// Match returns the first denied word a UAC spells, or "" when it spells none. A nil Denylist denies nothing.
+func (denylist *Denylist) Match(uac string) string {
+	if denylist == nil {
+		return ""
+	}
+	letters := deleet(uac)
+	uacConsonants := consonants(letters)
+	for _, deniedWord := range denylist.words {
+		if strings.Contains(letters, deniedWord.word) {
+			return deniedWord.word
+		}
+		// Two consonants are too common to deny on their own
+		if len(deniedWord.consonants) >= 3 && strings.Contains(uacConsonants, deniedWord.consonants) {
+			return deniedWord.word
+		}
+	}
+	return ""
+}
+
+// deniedWord returns the denied word a UAC of a format spells. Only formats with letters in their alphabet are checked,
+// as reading the digits of numeric UACs as letters denies far more UACs than it keeps words out of them.
+func (uacGenerator *UacGenerator) deniedWord(uacFormat UacFormat, uac string) string {
+	if !spellsWords(uacFormat) {
+		return ""
+	}
+	return uacGenerator.Denylist.Match(uac)
+}
+
+// deleet lowercases a UAC or word, reading its digits as letters and dropping anything else.
+func deleet(s string) string {
+	return strings.Map(func(r rune) rune {
+		if letter, ok := leetLetters[r]; ok {
+			return letter
+		}
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(s))
+}
+
+func consonants(letters string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("aeiou", r) {
+			return -1
+		}
+		return r
+	}, letters)
+}
+
+// GetUacsDenied returns the UACs of an instrument's cases that spell a word on the generator's denylist, keyed by
+// CaseSlotKey with the word they spell. UACs that have been replaced are not included, and UACs of numeric kinds are
+// never denied.
+func (uacGenerator *UacGenerator) GetUacsDenied(instrumentName string) (Uacs, error) {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacFormat, _ := GetUacFormat(uacKind)
+	if !spellsWords(uacFormat) {
+		return make(Uacs), nil
+	}
+	uacInfos, err := uacGenerator.Store.ListUacs(uacGenerator.Context, uacKind, instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacs := make(Uacs)
+	for _, uacInfo := range uacInfos {
+		if uacInfo.ReissuedTo != "" {
+			continue
+		}
+		err = uacGenerator.revealUac(uacInfo)
+		if err != nil {
+			return nil, err
+		}
+		deniedWord := uacGenerator.deniedWord(uacFormat, uacInfo.FullUAC)
+		// Keyed UACs are only shown by their hash
+		uacInfo.FullUAC = ""
+		if deniedWord == "" {
+			continue
+		}
+		uacInfo.DeniedWord = deniedWord
+		uacGenerator.showUac(uacInfo)
+		uacs[uacInfo.CaseSlot()] = uacInfo
+	}
+	return uacs, nil
+}
@@ -0,0 +1,174 @@
+package uacgenerator_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// queuedUacFormat generates its UACs in turn.
+type queuedUacFormat struct {
+	uacs *[]string
+}
+
+func (format queuedUacFormat) Generate(*rand.Rand) string {
+	uac := (*format.uacs)[0]
+	*format.uacs = (*format.uacs)[1:]
+	return uac
+}
+
+func (queuedUacFormat) Validate(uac string) bool {
+	return len(uac) == 16
+}
+
+func (queuedUacFormat) Normalise(uac string) string {
+	return uac
+}
+
+func (queuedUacFormat) Chunk(uac string) *uacgenerator.UacChunks {
+	return &uacgenerator.UacChunks{UAC1: uac[0:4], UAC2: uac[4:8], UAC3: uac[8:12], UAC4: uac[12:]}
+}
+
+var _ = DescribeTable("Denylist.Match",
+	func(uac, expected string) {
+		Expect(uacgenerator.DefaultDenylist().Match(uac)).To(Equal(expected))
+	},
+	Entry("a clean 16 character UAC", "bcdfghjkmnpqrstv", ""),
+	Entry("a clean 12 digit UAC", "123412341234", ""),
+	Entry("digits for vowels", "bcd53xfghjkmnpqr", "sex"),
+	Entry("digits for consonants", "bcdf4r53ghjkmnpq", "arse"),
+	Entry("consonants alone", "bcdfckghjkmnpqrs", "fuck"),
+	Entry("consonants with a digit for a consonant", "bcdsh7ghjkmnpqrs", "shit"),
+	Entry("consonants with digits for vowels between them", "bcdf4ckghjkmnpqr", "fuck"),
+	Entry("a 12 digit UAC", "580085801234", "boob"),
+	Entry("upper case", "BCD53XFGHJKMNPQR", "sex"),
+)
+
+var _ = Describe("Denylist", func() {
+	It("denies nothing when nil", func() {
+		var denylist *uacgenerator.Denylist
+		Expect(denylist.Match("bcd53xfghjkmnpqr")).To(Equal(""))
+	})
+
+	It("loads words from a file", func() {
+		dir, err := os.MkdirTemp("", "denylist")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "denylist.txt")
+		Expect(os.WriteFile(path, []byte("# Words for testing\n\nZ00M\n  b4lm  \n"), 0600)).To(Succeed())
+
+		denylist, err := uacgenerator.LoadDenylist(path)
+		Expect(err).To(BeNil())
+		Expect(denylist.Match("bcdz00mfghjkmnpq")).To(Equal("zoom"))
+		Expect(denylist.Match("bcdblmfghjkmnpqr")).To(Equal("balm"))
+		Expect(denylist.Match("bcd53xfghjkmnpqr")).To(Equal(""))
+
+		_, err = uacgenerator.LoadDenylist(filepath.Join(dir, "missing.txt"))
+		Expect(err).ToNot(BeNil())
+	})
+
+	Describe("the generator", func() {
+		var (
+			uacGenerator *uacgenerator.UacGenerator
+			queued       []string
+		)
+
+		BeforeEach(func() {
+			uacgenerator.RegisterUacFormat("uacqueued", queuedUacFormat{uacs: &queued})
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uacqueued")
+		})
+
+		AfterEach(func() {
+			uacgenerator.UnregisterUacFormat("uacqueued")
+		})
+
+		It("generates UACs again when they are denied", func() {
+			queued = []string{"bcd53xfghjkmnpqr", "bcdfckghjkmnpqrs", "bcdfghjkmnpqrstv", "bcdf4r53ghjkmnpq", "cdfghjkmnpqrstvx"}
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(HaveLen(2))
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs["1"].FullUAC).To(Equal("bcdfghjkmnpqrstv"))
+			Expect(uacs["2"].FullUAC).To(Equal("cdfghjkmnpqrstvx"))
+		})
+
+		It("gives up when every UAC is denied", func() {
+			queued = make([]string, uacgenerator.MAXREJECTEDATTEMPTS)
+			for i := range queued {
+				queued[i] = "bcd53xfghjkmnpqr"
+			}
+			_, err := uacGenerator.NewUac("lolcat", "1", 0)
+			Expect(err).To(Equal(uacgenerator.ErrUacDenied))
+			Expect(queued).To(BeEmpty())
+		})
+
+		It("generates denied UACs without a denylist", func() {
+			uacGenerator.Denylist = nil
+			queued = []string{"bcd53xfghjkmnpqr"}
+			Expect(uacGenerator.NewUac("lolcat", "1", 0)).To(Equal("bcd53xfghjkmnpqr"))
+		})
+	})
+
+	Describe("importing and reporting", func() {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac16")
+		})
+
+		It("does not import denied UACs", func() {
+			_, err := uacGenerator.ImportUACs([]string{"bcdfghjkmnpqrstv", "bcd53xfghjkmnpqr"})
+			Expect(err).To(MatchError("Cannot import UACs because some spelt words on the denylist: [\"bcd53xfghjkmnpqr\"]"))
+			Expect(err.(*uacgenerator.ImportError).DeniedUACs).To(Equal([]string{"bcd53xfghjkmnpqr"}))
+			_, err = uacGenerator.GetUacInfo("bcdfghjkmnpqrstv")
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("imports numeric UACs whatever their digits read as", func() {
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+			Expect(uacGenerator.ImportUACs([]string{"455012341234", "580085801234"})).To(Equal(2))
+		})
+
+		It("reports the issued UACs that are denied", func() {
+			Expect(uacGenerator.AddUacToDatastore("bcd53xfghjkmnpqr", "lolcat", "1")).To(Succeed())
+			Expect(uacGenerator.AddUacToDatastore("bcdfghjkmnpqrstv", "lolcat", "2")).To(Succeed())
+
+			denied, err := uacGenerator.GetUacsDenied("LOLcat")
+			Expect(err).To(BeNil())
+			Expect(denied).To(HaveLen(1))
+			Expect(denied["1"].FullUAC).To(Equal("bcd53xfghjkmnpqr"))
+			Expect(denied["1"].DeniedWord).To(Equal("sex"))
+
+			reissued, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+			Expect(uacGenerator.Denylist.Match(reissued.FullUAC)).To(Equal(""))
+			Expect(uacGenerator.GetUacsDenied("lolcat")).To(BeEmpty())
+		})
+
+		It("does not report numeric UACs", func() {
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+			Expect(uacGenerator.AddUacToDatastore("580085801234", "lolcat", "1")).To(Succeed())
+			Expect(uacGenerator.GetUacsDenied("lolcat")).To(BeEmpty())
+		})
+
+		It("reports keyed UACs by their hash", func() {
+			uacKeyer, err := uacgenerator.NewUacKeyer([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).To(BeNil())
+			uacGenerator.UacKeyer = uacKeyer
+			Expect(uacGenerator.AddUacToDatastore("bcd53xfghjkmnpqr", "lolcat", "1")).To(Succeed())
+
+			denied, err := uacGenerator.GetUacsDenied("lolcat")
+			Expect(err).To(BeNil())
+			Expect(denied["1"].FullUAC).To(BeEmpty())
+			Expect(denied["1"].UacHash).To(Equal(uacKeyer.KeyName("bcd53xfghjkmnpqr")))
+			Expect(denied["1"].DeniedWord).To(Equal("sex"))
+		})
+	})
+})
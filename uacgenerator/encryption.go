@@ -0,0 +1,135 @@
+package uacgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const (
+	AGEENCRYPTION     = "age"
+	OPENPGPENCRYPTION = "openpgp"
+)
+
+// ExportEncrypter encrypts exports to the public keys of the recipients they are sent to.
+type ExportEncrypter interface {
+	// Encrypt returns a writer that encrypts to writer. The encryption is only complete once it has been closed.
+	Encrypt(writer io.Writer) (io.WriteCloser, error)
+	Name() string
+	Extension() string
+	// Recipients identifies the keys exports are encrypted to, for the manifest.
+	Recipients() []string
+}
+
+// LoadExportEncrypter loads the recipients' public keys for an encryption from local files.
+func LoadExportEncrypter(encryption string, paths []string) (ExportEncrypter, error) {
+	switch encryption {
+	case AGEENCRYPTION:
+		return LoadAgeEncrypter(paths...)
+	case OPENPGPENCRYPTION:
+		return LoadOpenPGPEncrypter(paths...)
+	}
+	return nil, fmt.Errorf("Unknown export encryption '%s'", encryption)
+}
+
+// AgeEncrypter encrypts exports with age.
+type AgeEncrypter struct {
+	recipients []age.Recipient
+}
+
+func NewAgeEncrypter(recipients ...age.Recipient) *AgeEncrypter {
+	return &AgeEncrypter{recipients: recipients}
+}
+
+// LoadAgeEncrypter reads age recipients files, which have a public key per line and may have # comments.
+func LoadAgeEncrypter(paths ...string) (*AgeEncrypter, error) {
+	encrypter := &AgeEncrypter{}
+	for _, path := range paths {
+		recipientsFile, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		recipients, err := age.ParseRecipients(recipientsFile)
+		recipientsFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		encrypter.recipients = append(encrypter.recipients, recipients...)
+	}
+	if len(encrypter.recipients) == 0 {
+		return nil, fmt.Errorf("No age recipients")
+	}
+	return encrypter, nil
+}
+
+func (encrypter *AgeEncrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return age.Encrypt(writer, encrypter.recipients...)
+}
+
+func (encrypter *AgeEncrypter) Name() string {
+	return AGEENCRYPTION
+}
+
+func (encrypter *AgeEncrypter) Extension() string {
+	return ".age"
+}
+
+func (encrypter *AgeEncrypter) Recipients() []string {
+	recipients := make([]string, len(encrypter.recipients))
+	for i, recipient := range encrypter.recipients {
+		recipients[i] = fmt.Sprint(recipient)
+	}
+	return recipients
+}
+
+// OpenPGPEncrypter encrypts exports with OpenPGP.
+type OpenPGPEncrypter struct {
+	entities openpgp.EntityList
+}
+
+// LoadOpenPGPEncrypter reads armored or binary OpenPGP public keys.
+func LoadOpenPGPEncrypter(paths ...string) (*OpenPGPEncrypter, error) {
+	encrypter := &OpenPGPEncrypter{}
+	for _, path := range paths {
+		keyRing, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyRing))
+		if err != nil {
+			entities, err = openpgp.ReadKeyRing(bytes.NewReader(keyRing))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		encrypter.entities = append(encrypter.entities, entities...)
+	}
+	if len(encrypter.entities) == 0 {
+		return nil, fmt.Errorf("No OpenPGP recipients")
+	}
+	return encrypter, nil
+}
+
+func (encrypter *OpenPGPEncrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return openpgp.Encrypt(writer, encrypter.entities, nil, &openpgp.FileHints{IsBinary: true}, nil)
+}
+
+func (encrypter *OpenPGPEncrypter) Name() string {
+	return OPENPGPENCRYPTION
+}
+
+func (encrypter *OpenPGPEncrypter) Extension() string {
+	return ".gpg"
+}
+
+func (encrypter *OpenPGPEncrypter) Recipients() []string {
+	recipients := make([]string, len(encrypter.entities))
+	for i, entity := range encrypter.entities {
+		recipients[i] = fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	}
+	return recipients
+}
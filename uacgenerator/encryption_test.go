@@ -0,0 +1,157 @@
+package uacgenerator_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encrypted exports", func() {
+	var (
+		uacGenerator *uacgenerator.UacGenerator
+		keyDir       string
+		exportDir    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		keyDir, err = os.MkdirTemp("", "keys")
+		Expect(err).To(BeNil())
+		exportDir, err = os.MkdirTemp("", "exports")
+		Expect(err).To(BeNil())
+		uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		_, err = uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(keyDir)
+		os.RemoveAll(exportDir)
+	})
+
+	writeKeyFile := func(name, content string) string {
+		path := filepath.Join(keyDir, name)
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	Describe("with age", func() {
+		var identities []*age.X25519Identity
+
+		BeforeEach(func() {
+			identities = nil
+			var recipientFiles []string
+			for _, name := range []string{"printer.txt", "archive.txt"} {
+				identity, err := age.GenerateX25519Identity()
+				Expect(err).To(BeNil())
+				identities = append(identities, identity)
+				recipientFiles = append(recipientFiles, writeKeyFile(name, "# "+name+"\n"+identity.Recipient().String()+"\n"))
+			}
+			encrypter, err := uacgenerator.LoadExportEncrypter(uacgenerator.AGEENCRYPTION, recipientFiles)
+			Expect(err).To(BeNil())
+			uacGenerator.ExportEncrypter = encrypter
+			uacGenerator.ExportDir = exportDir
+		})
+
+		It("saves an export every recipient can decrypt with a manifest", func() {
+			manifest, err := uacGenerator.SaveEncryptedExport("LOLCAT", uacgenerator.ExportOptions{Header: true})
+			Expect(err).To(BeNil())
+			Expect(manifest.File).To(MatchRegexp(`^lolcat-\d{8}T\d{6}Z\.csv\.age$`))
+			Expect(manifest.InstrumentName).To(Equal("lolcat"))
+			Expect(manifest.Records).To(Equal(3))
+			Expect(manifest.Encryption).To(Equal("age"))
+			Expect(manifest.Recipients).To(Equal([]string{
+				identities[0].Recipient().String(), identities[1].Recipient().String(),
+			}))
+
+			encrypted, err := os.ReadFile(filepath.Join(exportDir, manifest.File))
+			Expect(err).To(BeNil())
+			Expect(string(encrypted)).ToNot(ContainSubstring("TRAILER"))
+			for _, identity := range identities {
+				plaintextReader, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+				Expect(err).To(BeNil())
+				plaintext, err := io.ReadAll(plaintextReader)
+				Expect(err).To(BeNil())
+				checksum := sha256.Sum256(plaintext)
+				Expect(manifest.PlaintextSHA256).To(Equal(hex.EncodeToString(checksum[:])))
+				Expect(strings.Split(string(plaintext), "\n")).To(HaveLen(6))
+			}
+
+			manifestJSON, err := os.ReadFile(filepath.Join(exportDir, manifest.File+".manifest.json"))
+			Expect(err).To(BeNil())
+			savedManifest := &uacgenerator.ExportManifest{}
+			Expect(json.Unmarshal(manifestJSON, savedManifest)).To(Succeed())
+			Expect(savedManifest).To(Equal(manifest))
+		})
+
+		It("leaves nothing behind when the export fails", func() {
+			_, err := uacGenerator.SaveEncryptedExport("lolcat", uacgenerator.ExportOptions{Columns: []string{"postcode"}})
+			Expect(err).To(MatchError("Unknown column: postcode"))
+			entries, err := os.ReadDir(exportDir)
+			Expect(err).To(BeNil())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Describe("with OpenPGP", func() {
+		var entity *openpgp.Entity
+
+		BeforeEach(func() {
+			var err error
+			entity, err = openpgp.NewEntity("Print Supplier", "", "print@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+			Expect(err).To(BeNil())
+			publicKey := &bytes.Buffer{}
+			armorWriter, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+			Expect(err).To(BeNil())
+			Expect(entity.Serialize(armorWriter)).To(Succeed())
+			Expect(armorWriter.Close()).To(Succeed())
+			encrypter, err := uacgenerator.LoadExportEncrypter(uacgenerator.OPENPGPENCRYPTION, []string{writeKeyFile("printer.asc", publicKey.String())})
+			Expect(err).To(BeNil())
+			uacGenerator.ExportEncrypter = encrypter
+		})
+
+		It("encrypts an export the recipient can decrypt", func() {
+			encrypted := &bytes.Buffer{}
+			manifest, err := uacGenerator.ExportEncryptedUacs("lolcat", uacgenerator.ExportOptions{}, encrypted)
+			Expect(err).To(BeNil())
+			Expect(manifest.Encryption).To(Equal("openpgp"))
+			Expect(manifest.Recipients).To(Equal([]string{strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))}))
+
+			message, err := openpgp.ReadMessage(encrypted, openpgp.EntityList{entity}, nil, nil)
+			Expect(err).To(BeNil())
+			plaintext, err := io.ReadAll(message.UnverifiedBody)
+			Expect(err).To(BeNil())
+			checksum := sha256.Sum256(plaintext)
+			Expect(manifest.PlaintextSHA256).To(Equal(hex.EncodeToString(checksum[:])))
+			Expect(string(plaintext)).To(ContainSubstring("TRAILER,3,"))
+		})
+	})
+
+	It("returns ErrExportsNotEncrypted without recipients", func() {
+		_, err := uacGenerator.SaveEncryptedExport("lolcat", uacgenerator.ExportOptions{})
+		Expect(err).To(Equal(uacgenerator.ErrExportsNotEncrypted))
+	})
+
+	It("rejects a recipients file without keys", func() {
+		_, err := uacgenerator.LoadExportEncrypter(uacgenerator.AGEENCRYPTION, []string{writeKeyFile("empty.txt", "# nobody\n")})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("rejects an unknown encryption", func() {
+		_, err := uacgenerator.LoadExportEncrypter("rot13", nil)
+		Expect(err).To(MatchError("Unknown export encryption 'rot13'"))
+	})
+})
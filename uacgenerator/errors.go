@@ -1,39 +1,94 @@
 package uacgenerator
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+var (
+	ErrProbableTypo      = errors.New("UAC is probably mistyped")
+	ErrInvalidUacKind    = errors.New("Invalid UAC kind")
+	ErrInstrumentHasUacs = errors.New("Cannot change the UAC kind of an instrument that already has UACs")
+	// ErrInstrumentUacKindChanged is returned for UACs given to an instrument's cases after its kind was changed.
+	ErrInstrumentUacKindChanged = errors.New("The instrument's UAC kind has changed")
+	ErrJobFinished              = errors.New("Generation job has already finished")
+	ErrCaseHasUac               = errors.New("Case already has a UAC")
+	ErrUacExists                = errors.New("UAC already exists")
+	ErrInstrumentHasKind        = errors.New("Instrument has already recorded a UAC kind")
+	ErrJobTakenOver             = errors.New("Generation job has been taken over by another run")
+	ErrInvalidCursor            = errors.New("Invalid cursor")
+	ErrNoArchive                = errors.New("Instrument has no archived UACs")
+	ErrInvalidReason            = errors.New("Invalid reason")
+	ErrNoBulkItems              = errors.New("No UACs or case ids given")
+	// ErrTooManyBulkItems is returned for a bulk change of more than MAXBULKITEMS UACs and cases.
+	ErrTooManyBulkItems = errors.New("Too many UACs and case ids")
+	ErrCaseHasNoUac     = errors.New("Case has no UAC")
+	// ErrUacReissued is returned for enabling a UAC that has been replaced, as its case has a newer UAC.
+	ErrUacReissued = errors.New("UAC has been reissued")
+	// ErrInvalidValidity is returned for a validity that ends before it starts.
+	ErrInvalidValidity = errors.New("Valid until must be after valid from")
+	ErrUacDisabled     = errors.New("UAC is disabled")
+	ErrUacExpired      = errors.New("UAC has expired")
+	ErrUacNotYetValid  = errors.New("UAC is not valid yet")
+	// ErrUacDenied is returned when every UAC generated for a case spelt a word on the denylist.
+	ErrUacDenied = errors.New("Could not generate a UAC that is not on the denylist")
+	// ErrUacWeak is returned when every UAC generated for a case followed a weak pattern.
+	ErrUacWeak = errors.New("Could not generate a UAC that does not follow a weak pattern")
+	// ErrExportsNotEncrypted is returned for an encrypted export when no recipients have been configured.
+	ErrExportsNotEncrypted = errors.New("Encrypted exports need export recipients")
+	// ErrUacNotSealed is returned for revealing a keyed UAC that was saved without its sealed UAC.
+	ErrUacNotSealed = errors.New("Keyed UAC has no sealed UAC")
+	// ErrUacsKeyed is returned for a plain export when UACs are keyed, as they can only be exported encrypted.
+	ErrUacsKeyed = errors.New("UACs are keyed and can only be exported encrypted")
+	// ErrInvalidUacsPerCase is returned for a generation that would give a case no UACs or more than MAXUACSPERCASE.
+	ErrInvalidUacsPerCase = fmt.Errorf("UACs per case must be from 1 to %d", MAXUACSPERCASE)
+)
+
 type ImportError struct {
 	InvalidUACs    []string
 	InstrumentUACs []string
+	// DeniedUACs spell a word on the denylist.
+	DeniedUACs []string
+	// WeakUACs follow a weak pattern, which is only checked when imports are held to pattern rules.
+	WeakUACs []string
 }
 
 func (importError *ImportError) Error() string {
 	var err string
-	if len(importError.InvalidUACs) > 0 {
-		err = fmt.Sprintf("Cannot import UACs because some were invalid: [%s]", formatSlice(importError.InvalidUACs))
-	}
-	if len(importError.InstrumentUACs) > 0 {
+	for _, problem := range []struct {
+		uacs   []string
+		reason string
+	}{
+		{importError.InvalidUACs, "were invalid"},
+		{importError.InstrumentUACs, "were already in use by questionnaires"},
+		{importError.DeniedUACs, "spelt words on the denylist"},
+		{importError.WeakUACs, "followed weak patterns"},
+	} {
+		if len(problem.uacs) == 0 {
+			continue
+		}
 		if err == "" {
-			err = fmt.Sprintf(
-				"Cannot import UACs because some were already in use by questionnaires: [%s]",
-				formatSlice(importError.InstrumentUACs),
-			)
+			err = fmt.Sprintf("Cannot import UACs because some %s: [%s]", problem.reason, formatSlice(problem.uacs))
 		} else {
-			err = fmt.Sprintf(
-				"%s and some UACs were already in use by questionnaires: [%s]",
-				err, formatSlice(importError.InstrumentUACs),
-			)
-
+			err = fmt.Sprintf("%s and some UACs %s: [%s]", err, problem.reason, formatSlice(problem.uacs))
 		}
 	}
 	return err
 }
 
 func (importError *ImportError) HasErrors() bool {
-	return len(importError.InvalidUACs) > 0 || len(importError.InstrumentUACs) > 0
+	return len(importError.InvalidUACs) > 0 || len(importError.InstrumentUACs) > 0 || len(importError.DeniedUACs) > 0 ||
+		len(importError.WeakUACs) > 0
+}
+
+// ExportOptionsError is returned when an export is asked for with options it cannot be written with.
+type ExportOptionsError struct {
+	Reason string
+}
+
+func (exportOptionsError *ExportOptionsError) Error() string {
+	return exportOptionsError.Reason
 }
 
 func formatSlice(input []string) string {
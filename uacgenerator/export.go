@@ -0,0 +1,261 @@
+package uacgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Columns that can be exported.
+const (
+	EXPORTCASEID     = "case_id"
+	EXPORTSLOT       = "slot"
+	EXPORTUAC        = "uac"
+	EXPORTUACCHUNKS  = "uac_chunks"
+	EXPORTUAC1       = "uac1"
+	EXPORTUAC2       = "uac2"
+	EXPORTUAC3       = "uac3"
+	EXPORTUAC4       = "uac4"
+	EXPORTINSTRUMENT = "instrument_name"
+	EXPORTDISABLED   = "disabled"
+	// EXPORTTRAILER is the first field of the trailer row.
+	EXPORTTRAILER = "TRAILER"
+)
+
+var exportColumns = map[string]func(*UacInfo, string) string{
+	EXPORTCASEID: func(uacInfo *UacInfo, _ string) string { return uacInfo.CaseID },
+	EXPORTSLOT:   func(uacInfo *UacInfo, _ string) string { return strconv.Itoa(uacInfo.Slot) },
+	EXPORTUAC:    func(uacInfo *UacInfo, _ string) string { return uacInfo.FullUAC },
+	EXPORTUACCHUNKS: func(uacInfo *UacInfo, chunkSeparator string) string {
+		return joinUacChunks(uacInfo.UacChunks, chunkSeparator)
+	},
+	EXPORTUAC1:       func(uacInfo *UacInfo, _ string) string { return uacInfo.UacChunks.UAC1 },
+	EXPORTUAC2:       func(uacInfo *UacInfo, _ string) string { return uacInfo.UacChunks.UAC2 },
+	EXPORTUAC3:       func(uacInfo *UacInfo, _ string) string { return uacInfo.UacChunks.UAC3 },
+	EXPORTUAC4:       func(uacInfo *UacInfo, _ string) string { return uacInfo.UacChunks.UAC4 },
+	EXPORTINSTRUMENT: func(uacInfo *UacInfo, _ string) string { return uacInfo.InstrumentName },
+	EXPORTDISABLED:   func(uacInfo *UacInfo, _ string) string { return strconv.FormatBool(uacInfo.Disabled) },
+}
+
+// ExportOptions sets the layout of a CSV export. The zero value writes the default columns, without a header,
+// separated by commas.
+type ExportOptions struct {
+	// Columns are written in order. Defaults to case_id, uac and uac_chunks.
+	Columns []string
+	Header  bool
+	// Delimiter separates fields. Defaults to a comma.
+	Delimiter rune
+	// ChunkSeparator joins the chunks in the uac_chunks column. Defaults to a space.
+	ChunkSeparator string
+	// Filter limits the export to UACs whose lifecycle timestamps match, such as those created since the last
+	// print run.
+	Filter UacFilter
+}
+
+// ExportTrailer is written as the last row of an export. Checksum is the hex SHA-256 of the record rows
+// exactly as written, so it excludes the header and the trailer.
+type ExportTrailer struct {
+	Records  int
+	Checksum string
+}
+
+// DefaultExportColumns are written when ExportOptions has no columns.
+func DefaultExportColumns() []string {
+	return []string{EXPORTCASEID, EXPORTUAC, EXPORTUACCHUNKS}
+}
+
+// Validate fills in defaults and returns an ExportOptionsError for unknown columns or an unusable delimiter.
+func (options *ExportOptions) Validate() error {
+	if len(options.Columns) == 0 {
+		options.Columns = DefaultExportColumns()
+	}
+	if options.Delimiter == 0 {
+		options.Delimiter = ','
+	}
+	if options.ChunkSeparator == "" {
+		options.ChunkSeparator = " "
+	}
+	for _, column := range options.Columns {
+		if _, ok := exportColumns[column]; !ok {
+			return &ExportOptionsError{Reason: "Unknown column: " + column}
+		}
+	}
+	if options.Delimiter == '"' || options.Delimiter == '\r' || options.Delimiter == '\n' ||
+		options.Delimiter == utf8.RuneError || !utf8.ValidRune(options.Delimiter) {
+		return &ExportOptionsError{Reason: "Invalid delimiter"}
+	}
+	if strings.ContainsRune(options.ChunkSeparator, options.Delimiter) {
+		return &ExportOptionsError{Reason: "Chunk separator cannot contain the delimiter"}
+	}
+	return nil
+}
+
+// ExportUacs writes an instrument's UACs to writer as CSV, a row per UAC as they are read from the store,
+// followed by a trailer row of TRAILER, the record count and the checksum. The trailer is not written when
+// the export fails, so a file without one is incomplete. Keyed UACs can only be exported encrypted, so
+// ErrUacsKeyed is returned when UACs are keyed.
+func (uacGenerator *UacGenerator) ExportUacs(instrumentName string, options ExportOptions, writer io.Writer) (*ExportTrailer, error) {
+	if uacGenerator.UacKeyer != nil {
+		return nil, ErrUacsKeyed
+	}
+	return uacGenerator.exportUacs(instrumentName, options, writer)
+}
+
+func (uacGenerator *UacGenerator) exportUacs(instrumentName string, options ExportOptions, writer io.Writer) (*ExportTrailer, error) {
+	err := options.Validate()
+	if err != nil {
+		return nil, err
+	}
+	csvWriter := newExportWriter(writer, options.Delimiter)
+	if options.Header {
+		err = writeExportRow(csvWriter, options.Columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checksum := sha256.New()
+	recordWriter := newExportWriter(io.MultiWriter(writer, checksum), options.Delimiter)
+	trailer := &ExportTrailer{}
+	row := make([]string, len(options.Columns))
+	err = uacGenerator.streamUacs(instrumentName, options.Filter, true, func(uacInfo *UacInfo) error {
+		// Each case is exported with its current UAC
+		if uacInfo.ReissuedTo != "" {
+			return nil
+		}
+		for i, column := range options.Columns {
+			row[i] = exportColumns[column](uacInfo, options.ChunkSeparator)
+		}
+		trailer.Records++
+		return recordWriter.Write(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordWriter.Flush()
+	err = recordWriter.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	trailer.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	err = writeExportRow(csvWriter, []string{EXPORTTRAILER, strconv.Itoa(trailer.Records), trailer.Checksum})
+	if err != nil {
+		return nil, err
+	}
+	return trailer, nil
+}
+
+// ExportManifest describes an encrypted export, so the recipient can check the file they decrypt is the one
+// that was sent. PlaintextSHA256 is the hex SHA-256 of the whole CSV file before it was encrypted.
+type ExportManifest struct {
+	File            string    `json:"file,omitempty"`
+	InstrumentName  string    `json:"instrument_name"`
+	Records         int       `json:"records"`
+	PlaintextSHA256 string    `json:"plaintext_sha256"`
+	Encryption      string    `json:"encryption"`
+	Recipients      []string  `json:"recipients"`
+	Created         time.Time `json:"created"`
+}
+
+// ExportEncryptedUacs writes an instrument's UACs to writer as CSV encrypted with the generator's
+// ExportEncrypter, returning ErrExportsNotEncrypted when it has none.
+func (uacGenerator *UacGenerator) ExportEncryptedUacs(instrumentName string, options ExportOptions, writer io.Writer) (*ExportManifest, error) {
+	if uacGenerator.ExportEncrypter == nil {
+		return nil, ErrExportsNotEncrypted
+	}
+	encryptedWriter, err := uacGenerator.ExportEncrypter.Encrypt(writer)
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.New()
+	trailer, err := uacGenerator.exportUacs(instrumentName, options, io.MultiWriter(encryptedWriter, checksum))
+	if err != nil {
+		return nil, err
+	}
+	err = encryptedWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &ExportManifest{
+		InstrumentName:  strings.ToLower(instrumentName),
+		Records:         trailer.Records,
+		PlaintextSHA256: hex.EncodeToString(checksum.Sum(nil)),
+		Encryption:      uacGenerator.ExportEncrypter.Name(),
+		Recipients:      uacGenerator.ExportEncrypter.Recipients(),
+		Created:         time.Now().UTC(),
+	}, nil
+}
+
+// SaveEncryptedExport writes an encrypted export of an instrument's UACs to the generator's ExportDir, with its
+// manifest next to it as JSON. Nothing is left behind when the export fails.
+func (uacGenerator *UacGenerator) SaveEncryptedExport(instrumentName string, options ExportOptions) (*ExportManifest, error) {
+	if uacGenerator.ExportEncrypter == nil || uacGenerator.ExportDir == "" {
+		return nil, ErrExportsNotEncrypted
+	}
+	instrumentName = strings.ToLower(instrumentName)
+	if filepath.Base(instrumentName) != instrumentName {
+		return nil, fmt.Errorf("Cannot export instrument '%s' to a file", instrumentName)
+	}
+	fileName := fmt.Sprintf("%s-%s.csv%s", instrumentName, time.Now().UTC().Format("20060102T150405Z"),
+		uacGenerator.ExportEncrypter.Extension())
+	filePath := filepath.Join(uacGenerator.ExportDir, fileName)
+	exportFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := uacGenerator.ExportEncryptedUacs(instrumentName, options, exportFile)
+	closeErr := exportFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	manifest.File = fileName
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+	err = os.WriteFile(filePath+".manifest.json", manifestJSON, 0600)
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func newExportWriter(writer io.Writer, delimiter rune) *csv.Writer {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = delimiter
+	return csvWriter
+}
+
+func writeExportRow(csvWriter *csv.Writer, row []string) error {
+	err := csvWriter.Write(row)
+	if err != nil {
+		return err
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func joinUacChunks(uacChunks *UacChunks, chunkSeparator string) string {
+	chunks := []string{uacChunks.UAC1, uacChunks.UAC2, uacChunks.UAC3}
+	if uacChunks.UAC4 != "" {
+		chunks = append(chunks, uacChunks.UAC4)
+	}
+	return strings.Join(chunks, chunkSeparator)
+}
@@ -0,0 +1,108 @@
+package uacgenerator_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExportUacs", func() {
+	var (
+		uacGenerator *uacgenerator.UacGenerator
+		uacs         uacgenerator.Uacs
+		output       *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		_, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+		Expect(err).To(BeNil())
+		uacs, err = uacGenerator.GetAllUacsByCaseID("lolcat")
+		Expect(err).To(BeNil())
+		output = &bytes.Buffer{}
+	})
+
+	It("writes the default columns and a trailer", func() {
+		trailer, err := uacGenerator.ExportUacs("lolcat", uacgenerator.ExportOptions{}, output)
+		Expect(err).To(BeNil())
+		Expect(trailer.Records).To(Equal(3))
+
+		lines := strings.SplitAfter(output.String(), "\n")
+		Expect(lines).To(HaveLen(5))
+		Expect(lines[4]).To(BeEmpty())
+		for _, line := range lines[:3] {
+			fields := strings.Split(strings.TrimSuffix(line, "\n"), ",")
+			Expect(fields).To(HaveLen(3))
+			uac := uacs[fields[0]].FullUAC
+			Expect(fields[1]).To(Equal(uac))
+			Expect(fields[2]).To(Equal(fmt.Sprintf("%s %s %s", uac[0:4], uac[4:8], uac[8:12])))
+		}
+
+		checksum := sha256.Sum256([]byte(strings.Join(lines[:3], "")))
+		Expect(trailer.Checksum).To(Equal(hex.EncodeToString(checksum[:])))
+		Expect(lines[3]).To(Equal(fmt.Sprintf("TRAILER,3,%s\n", trailer.Checksum)))
+	})
+
+	It("writes the chosen layout", func() {
+		options := uacgenerator.ExportOptions{
+			Columns:        []string{"case_id", "uac_chunks", "uac1", "uac2", "uac3", "disabled"},
+			Header:         true,
+			Delimiter:      '\t',
+			ChunkSeparator: "-",
+		}
+		trailer, err := uacGenerator.ExportUacs("lolcat", options, output)
+		Expect(err).To(BeNil())
+
+		reader := csv.NewReader(output)
+		reader.Comma = '\t'
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		Expect(err).To(BeNil())
+		Expect(rows).To(HaveLen(5))
+		Expect(rows[0]).To(Equal([]string{"case_id", "uac_chunks", "uac1", "uac2", "uac3", "disabled"}))
+		for _, row := range rows[1:4] {
+			uacChunks := uacgenerator.ChunkUAC(uacs[row[0]].FullUAC)
+			Expect(row[1:]).To(Equal([]string{
+				fmt.Sprintf("%s-%s-%s", uacChunks.UAC1, uacChunks.UAC2, uacChunks.UAC3),
+				uacChunks.UAC1, uacChunks.UAC2, uacChunks.UAC3, "false",
+			}))
+		}
+		Expect(rows[4]).To(Equal([]string{"TRAILER", "3", trailer.Checksum}))
+	})
+
+	It("writes only a trailer for an instrument without UACs", func() {
+		trailer, err := uacGenerator.ExportUacs("dst2108a", uacgenerator.ExportOptions{}, output)
+		Expect(err).To(BeNil())
+		Expect(trailer.Records).To(Equal(0))
+		Expect(output.String()).To(Equal(
+			"TRAILER,0,e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n",
+		))
+	})
+
+	It("rejects an unknown column before writing anything", func() {
+		options := uacgenerator.ExportOptions{Columns: []string{"case_id", "postcode"}, Header: true}
+		_, err := uacGenerator.ExportUacs("lolcat", options, output)
+		Expect(err).To(MatchError("Unknown column: postcode"))
+		Expect(err).To(BeAssignableToTypeOf(&uacgenerator.ExportOptionsError{}))
+		Expect(output.Len()).To(Equal(0))
+	})
+
+	It("rejects a chunk separator containing the delimiter", func() {
+		options := uacgenerator.ExportOptions{Delimiter: '-', ChunkSeparator: "-"}
+		_, err := uacGenerator.ExportUacs("lolcat", options, output)
+		Expect(err).To(MatchError("Chunk separator cannot contain the delimiter"))
+	})
+
+	It("rejects a quote as the delimiter", func() {
+		options := uacgenerator.ExportOptions{Delimiter: '"'}
+		_, err := uacGenerator.ExportUacs("lolcat", options, output)
+		Expect(err).To(MatchError("Invalid delimiter"))
+	})
+})
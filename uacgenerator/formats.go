@@ -0,0 +1,191 @@
+package uacgenerator
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	UAC12KIND = "uac"
+	UAC16KIND = "uac16"
+)
+
+// UacFormat describes how UACs of a single kind are generated, validated and displayed.
+// New formats can be made available to the generator with RegisterUacFormat.
+type UacFormat interface {
+	Generate(*rand.Rand) string
+	Validate(string) bool
+	// Normalise turns a UAC as a respondent typed it into the UAC as it was generated.
+	Normalise(string) string
+	Chunk(string) *UacChunks
+}
+
+// AlphabetUacFormat is implemented by formats that declare the characters their UACs are drawn from.
+type AlphabetUacFormat interface {
+	UacFormat
+	Alphabet() string
+}
+
+// digits are the characters of numeric UACs.
+const digits = "0123456789"
+
+// spellsWords reports whether UACs of a format can spell words, which they cannot when its alphabet has no letters.
+// Formats that do not declare their alphabet are assumed to.
+func spellsWords(uacFormat UacFormat) bool {
+	alphabetFormat, ok := uacFormat.(AlphabetUacFormat)
+	return !ok || strings.IndexFunc(alphabetFormat.Alphabet(), unicode.IsLetter) >= 0
+}
+
+type uacFormatRegistry struct {
+	mu      sync.RWMutex
+	kinds   []string
+	formats map[string]UacFormat
+}
+
+var uacFormats = &uacFormatRegistry{formats: make(map[string]UacFormat)}
+
+func init() {
+	RegisterUacFormat(UAC12KIND, Uac12Format{})
+	RegisterUacFormat(UAC16KIND, Uac16Format{})
+}
+
+// RegisterUacFormat makes a UAC format available under the given kind name,
+// replacing any format previously registered with that name.
+func RegisterUacFormat(kind string, format UacFormat) {
+	uacFormats.mu.Lock()
+	defer uacFormats.mu.Unlock()
+	if _, exists := uacFormats.formats[kind]; !exists {
+		uacFormats.kinds = append(uacFormats.kinds, kind)
+	}
+	uacFormats.formats[kind] = format
+}
+
+// UnregisterUacFormat removes the format registered under the given kind name, so formats registered for a while,
+// such as in tests, do not outlive their use.
+func UnregisterUacFormat(kind string) {
+	uacFormats.mu.Lock()
+	defer uacFormats.mu.Unlock()
+	if _, exists := uacFormats.formats[kind]; !exists {
+		return
+	}
+	delete(uacFormats.formats, kind)
+	for i, registeredKind := range uacFormats.kinds {
+		if registeredKind == kind {
+			uacFormats.kinds = append(uacFormats.kinds[:i], uacFormats.kinds[i+1:]...)
+			break
+		}
+	}
+}
+
+// GetUacFormat returns the format registered under the given kind name.
+func GetUacFormat(kind string) (UacFormat, bool) {
+	uacFormats.mu.RLock()
+	defer uacFormats.mu.RUnlock()
+	format, ok := uacFormats.formats[kind]
+	return format, ok
+}
+
+// UacKinds returns the names of all registered formats in the order they were registered.
+func UacKinds() []string {
+	uacFormats.mu.RLock()
+	defer uacFormats.mu.RUnlock()
+	kinds := make([]string, len(uacFormats.kinds))
+	copy(kinds, uacFormats.kinds)
+	return kinds
+}
+
+type Uac12Format struct{}
+
+func (Uac12Format) Generate(randomizer *rand.Rand) string {
+	var uac string
+	for i := 0; i < 3; i++ {
+		uacSegmant := randomizer.Int63n(9999 - 1000)
+		uac = fmt.Sprintf("%s%d", uac, uacSegmant+1000)
+	}
+	return uac
+}
+
+func (Uac12Format) Validate(uac string) bool {
+	if len(uac) != 12 {
+		return false
+	}
+	chunkedUAC := chunkUAC(uac)
+	uacParts := []string{chunkedUAC.UAC1, chunkedUAC.UAC2, chunkedUAC.UAC3}
+	for _, uacPart := range uacParts {
+		uacInt, err := strconv.Atoi(uacPart)
+		if err != nil {
+			return false
+		}
+		if uacInt < 1000 || uacInt > 9999 {
+			return false
+		}
+	}
+	return true
+}
+
+func (Uac12Format) Alphabet() string {
+	return digits
+}
+
+func (Uac12Format) Normalise(uac string) string {
+	return stripSeparators(uac)
+}
+
+func (Uac12Format) Chunk(uac string) *UacChunks {
+	return chunkUAC(uac)
+}
+
+type Uac16Format struct{}
+
+// uac16Confusables are the characters outside APPROVEDCHARACTERS that can only have been typed for one inside it.
+// 0 and o are left alone, as there is nothing they could have been typed for.
+var uac16Confusables = map[rune]rune{
+	'1': 'l',
+	'i': 'l',
+}
+
+var uac16Regex = regexp.MustCompile(fmt.Sprintf(`^[%s]{16}$`, APPROVEDCHARACTERS))
+
+func (Uac16Format) Generate(randomizer *rand.Rand) string {
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = APPROVEDCHARACTERS[randomizer.Intn(len(APPROVEDCHARACTERS))]
+	}
+	return string(b)
+}
+
+func (Uac16Format) Validate(uac string) bool {
+	return uac16Regex.MatchString(uac)
+}
+
+func (Uac16Format) Alphabet() string {
+	return APPROVEDCHARACTERS
+}
+
+func (Uac16Format) Normalise(uac string) string {
+	return strings.Map(func(r rune) rune {
+		if confusable, ok := uac16Confusables[r]; ok {
+			return confusable
+		}
+		return r
+	}, strings.ToLower(stripSeparators(uac)))
+}
+
+func (Uac16Format) Chunk(uac string) *UacChunks {
+	return chunkUAC(uac)
+}
+
+// stripSeparators removes the spaces and dashes typed between the chunks of a UAC.
+func stripSeparators(uac string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Pd, r) {
+			return -1
+		}
+		return r
+	}, uac)
+}
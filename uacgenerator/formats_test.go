@@ -0,0 +1,171 @@
+package uacgenerator_test
+
+import (
+	"math/rand"
+	"strings"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+type testUacFormat struct{}
+
+func (testUacFormat) Generate(*rand.Rand) string {
+	return "testtesttest"
+}
+
+func (testUacFormat) Validate(uac string) bool {
+	return strings.HasPrefix(uac, "test")
+}
+
+func (testUacFormat) Normalise(uac string) string {
+	return strings.ToLower(uac)
+}
+
+func (testUacFormat) Chunk(uac string) *uacgenerator.UacChunks {
+	return &uacgenerator.UacChunks{UAC1: uac[0:4], UAC2: uac[4:8], UAC3: uac[8:]}
+}
+
+var _ = Describe("UacFormat registry", func() {
+	It("has the 12 digit and 16 character formats registered", func() {
+		Expect(uacgenerator.UacKinds()).To(ContainElements("uac", "uac16"))
+
+		uacFormat, ok := uacgenerator.GetUacFormat("uac")
+		Expect(ok).To(BeTrue())
+		Expect(uacFormat).To(Equal(uacgenerator.Uac12Format{}))
+
+		uacFormat, ok = uacgenerator.GetUacFormat("uac16")
+		Expect(ok).To(BeTrue())
+		Expect(uacFormat).To(Equal(uacgenerator.Uac16Format{}))
+	})
+
+	It("does not find unregistered formats", func() {
+		_, ok := uacgenerator.GetUacFormat("this is not a valid UWACKY")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("forgets formats once they are unregistered", func() {
+		uacgenerator.RegisterUacFormat("uacgone", testUacFormat{})
+		uacgenerator.UnregisterUacFormat("uacgone")
+		_, ok := uacgenerator.GetUacFormat("uacgone")
+		Expect(ok).To(BeFalse())
+		Expect(uacgenerator.UacKinds()).ToNot(ContainElement("uacgone"))
+	})
+
+	Context("when a new format is registered", func() {
+		var (
+			uacGenerator  *uacgenerator.UacGenerator
+			mockDatastore *mocks.Datastore
+		)
+
+		BeforeEach(func() {
+			uacgenerator.RegisterUacFormat("uactest", testUacFormat{})
+
+			mockDatastore = &mocks.Datastore{}
+			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uactest")
+			mockTransaction := &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+			mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
+
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockDatastore.On("Mutate",
+				uacGenerator.Context,
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil, nil)
+		})
+
+		AfterEach(func() {
+			uacgenerator.UnregisterUacFormat("uactest")
+		})
+
+		It("lists the new kind once", func() {
+			uacgenerator.RegisterUacFormat("uactest", testUacFormat{})
+			kinds := uacgenerator.UacKinds()
+			Expect(kinds[len(kinds)-1]).To(Equal("uactest"))
+			Expect(kinds[:len(kinds)-1]).ToNot(ContainElement("uactest"))
+		})
+
+		It("generates UACs with the new format", func() {
+			uac, err := uacGenerator.NewUac("lolcat", "74628568", 0)
+			Expect(err).To(BeNil())
+			Expect(uac).To(Equal("testtesttest"))
+		})
+
+		It("validates UACs with the new format", func() {
+			Expect(uacGenerator.ValidateUAC("testing")).To(BeTrue())
+			Expect(uacGenerator.ValidateUAC("123412341234")).To(BeFalse())
+		})
+
+		It("normalises UACs with the new format", func() {
+			Expect(uacGenerator.NormaliseUACs([]string{"TESTING", "Test"})).To(Equal([]string{"testing", "test"}))
+		})
+	})
+})
+
+var _ = DescribeTable("NormaliseUACs",
+	func(uacKind string, uacs []string, expected []string) {
+		uacGenerator := &uacgenerator.UacGenerator{UacKind: uacKind}
+		Expect(uacGenerator.NormaliseUACs(uacs)).To(Equal(expected))
+	},
+	Entry("12 digit", "uac", []string{" 123412341234 "}, []string{"123412341234"}),
+	Entry("12 digit with separators", "uac", []string{"1234 1234-1234", "1234\t1234\u20131234"}, []string{"123412341234", "123412341234"}),
+	Entry("16 character", "uac16", []string{" 23KL56mn78fd42bn"}, []string{"23kl56mn78fd42bn"}),
+	Entry("16 character with separators", "uac16", []string{"23KL-56MN-78FD-42BN", "23kl 56mn 78fd 42bn"}, []string{"23kl56mn78fd42bn", "23kl56mn78fd42bn"}),
+	Entry("16 character with confusables", "uac16", []string{"23K1-56MN-78FD-42BN", "23KI56mn78fd42bn", "23Ko56mn78fd42bn"}, []string{"23kl56mn78fd42bn", "23kl56mn78fd42bn", "23ko56mn78fd42bn"}),
+)
+
+var _ = Describe("Looking up UACs as respondents type them", func() {
+	var uacGenerator *uacgenerator.UacGenerator
+
+	BeforeEach(func() {
+		uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac16")
+		uacGenerator.UacKinds = []string{"uac"}
+		Expect(uacGenerator.AddUacToDatastore("23kl56mn78fd42bn", "lolcat", "1")).To(Succeed())
+		Expect(uacGenerator.SetInstrumentUacKind("dst2108a", "uac")).To(Succeed())
+		Expect(uacGenerator.Generate("dst2108a", []string{"1"})).ToNot(BeNil())
+	})
+
+	It("finds UACs typed with separators, in upper case or with confusable characters", func() {
+		for _, uac := range []string{"23KL-56MN-78FD-42BN", "23k1 56mn 78fd 42bn", "23KI56MN78FD42BN"} {
+			uacInfo, err := uacGenerator.GetUacInfo(uac)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.InstrumentName).To(Equal("lolcat"))
+		}
+
+		uacs, err := uacGenerator.GetAllUacsByCaseID("dst2108a")
+		Expect(err).To(BeNil())
+		chunks := uacgenerator.ChunkUAC(uacs["1"].FullUAC)
+		uacInfo, err := uacGenerator.GetUacInfo(chunks.UAC1 + " " + chunks.UAC2 + "-" + chunks.UAC3)
+		Expect(err).To(BeNil())
+		Expect(uacInfo.InstrumentName).To(Equal("dst2108a"))
+	})
+
+	It("disables and enables UACs as they are typed", func() {
+		Expect(uacGenerator.DisableUac("23KL-56MN-78FD-42BN")).To(Succeed())
+		uacInfo, err := uacGenerator.GetUacInfo("23kl56mn78fd42bn")
+		Expect(err).To(BeNil())
+		Expect(uacInfo.Disabled).To(BeTrue())
+
+		Expect(uacGenerator.EnableUac("23K1 56MN 78FD 42BN")).To(Succeed())
+		uacInfo, err = uacGenerator.GetUacInfo("23kl56mn78fd42bn")
+		Expect(err).To(BeNil())
+		Expect(uacInfo.Disabled).To(BeFalse())
+	})
+
+	It("imports UACs as they are typed", func() {
+		Expect(uacGenerator.ImportUACs([]string{"BCDF-GHJK-MNPQ-RSTV", "bcdf ghjk mnpq rstv"})).To(Equal(1))
+		uacInfo, err := uacGenerator.GetUacInfo("bcdfghjkmnpqrstv")
+		Expect(err).To(BeNil())
+		Expect(uacInfo.InstrumentName).To(Equal("unknown"))
+	})
+})
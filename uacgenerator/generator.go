@@ -2,13 +2,14 @@ package uacgenerator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/datastore"
 	"github.com/zenthangplus/goccm"
@@ -17,37 +18,69 @@ import (
 )
 
 const (
-	MAXCONCURRENT      = 500
+	MAXCONCURRENT = 500
+	// MAXMUTATIONS is the most mutations Datastore accepts in a single commit.
+	MAXMUTATIONS       = 500
 	APPROVEDCHARACTERS = "bcdfghjklmnpqrstvxz23456789"
 	UNKNOWNINSTRUMENT  = "unknown"
+	// MAXREJECTEDATTEMPTS is how many UACs are generated for a case before giving up on finding one that is neither
+	// denied nor weak.
+	MAXREJECTEDATTEMPTS = 100
 )
 
 // Generate mocks by running "go generate ./..."
 //
 //go:generate mockery --name UacGeneratorInterface
 type UacGeneratorInterface interface {
-	Generate(string, []string) error
+	Generate(string, []string) (*GenerateResult, error)
+	GenerateWithOptions(string, []string, GenerateOptions) (*GenerateResult, error)
 	GetAllUacs(string) (Uacs, error)
 	GetAllUacsByCaseID(string) (Uacs, error)
 	GetAllUacsDisabled(string) (Uacs, error)
+	GetUacsPage(string, UacFilter, string, int) (*UacPage, error)
+	GetUacsPageByCaseID(string, UacFilter, string, int) (*UacPage, error)
+	StreamUacs(string, UacFilter, func(*UacInfo) error) error
+	ExportUacs(string, ExportOptions, io.Writer) (*ExportTrailer, error)
+	SaveEncryptedExport(string, ExportOptions) (*ExportManifest, error)
 	GetUacCount(string) (int, error)
 	GetUacInfo(string) (*UacInfo, error)
+	AuthenticateUac(string) (*UacInfo, error)
 	GetInstruments() ([]string, error)
+	GetInstrumentUacKind(string) (string, error)
+	SetInstrumentUacKind(string, string) error
 	ImportUACs([]string) (int, error)
 	AdminDelete(string) error
+	GetInstrumentArchives() ([]*InstrumentArchive, error)
+	RestoreInstrument(string) (*RestoreResult, error)
+	PurgeArchives() ([]string, error)
 	DisableUac(string) error
 	EnableUac(string) error
+	DisableUacs(string, BulkUacRequest) (*BulkUacResult, error)
+	EnableUacs(string, BulkUacRequest) (*BulkUacResult, error)
+	ReissueUac(string, string, int, string) (*UacInfo, error)
+	GetInstrumentValidity(string) (Validity, error)
+	SetInstrumentValidity(string, Validity) error
+	SetUacValidity(string, Validity) error
+	GetUacsExpiring(string, time.Duration) (Uacs, error)
+	GetUacsDenied(string) (Uacs, error)
+	StartGenerationJob(string, []string, GenerateOptions) (*GenerationJob, error)
+	GetGenerationJob(string) (*GenerationJob, error)
+	CancelGenerationJob(string) (*GenerationJob, error)
+	GetAuditEntries(AuditQuery) (*AuditPage, error)
+	WithActor(string) UacGeneratorInterface
 }
 
 // Generate mocks by running "go generate ./..."
 //
-//go:generate mockery --name Datastore
+//go:generate mockery --name Datastore --unroll-variadic=false
 type Datastore interface {
 	Mutate(context.Context, ...*datastore.Mutation) ([]*datastore.Key, error)
 	GetAll(context.Context, *datastore.Query, interface{}) ([]*datastore.Key, error)
+	Run(context.Context, *datastore.Query) Iterator
 	Count(context.Context, *datastore.Query) (int, error)
 	Get(context.Context, *datastore.Key, interface{}) error
 	DeleteMulti(context.Context, []*datastore.Key) error
+	RunInTransaction(context.Context, func(Transaction) error) error
 	Close() error
 }
 
@@ -59,22 +92,72 @@ type UacChunks struct {
 }
 
 type UacGenerator struct {
-	UacKind         string
+	UacKind  string
+	UacKinds []string
+	Store    UacStore
+	// DatastoreClient is the client of the DatastoreUacStore the generator was made with. It is nil when UACs are kept
+	// in another store.
 	DatastoreClient Datastore
 	Context         context.Context
-	GenerateError   map[string]error
 	Randomizer      *rand.Rand
-	mu              sync.Mutex
-	importMu        sync.Mutex
+	// UacKeyer keys UACs by a hash rather than the UAC when set.
+	UacKeyer *UacKeyer
+	// ExportEncrypter encrypts exports saved to ExportDir. Encrypted exports are not available without both.
+	ExportEncrypter ExportEncrypter
+	ExportDir       string
+	// Actor is recorded in the audit trail against the changes the generator makes.
+	Actor string
+	// ArchiveRetention is how long the UACs of a deleted instrument are kept before they can be purged.
+	ArchiveRetention time.Duration
+	// Denylist holds the words generated and imported UACs must not spell. Nothing is denied when it is nil.
+	Denylist *Denylist
+	// PatternRules are the weak patterns generated numeric UACs must not follow, and imported ones too when
+	// RejectWeakImports is set.
+	PatternRules      []PatternRule
+	RejectWeakImports bool
 }
 
 type UacInfo struct {
-	InstrumentName string         `json:"instrument_name" datastore:"instrument_name"`
-	CaseID         string         `json:"case_id" datastore:"case_id"`
-	UacChunks      *UacChunks     `json:"uac_chunks,omitempty" datastore:"-"`
-	UAC            *datastore.Key `json:"-" datastore:"__key__"`
-	FullUAC        string         `json:"full_uac,omitempty" datastore:"-"`
-	Disabled       bool           `json:"disabled" datastore:"disabled"`
+	InstrumentName string `json:"instrument_name" datastore:"instrument_name"`
+	CaseID         string `json:"case_id" datastore:"case_id"`
+	// Slot numbers the UACs of a case with more than one respondent from 0. A case's only UAC is in slot 0.
+	Slot      int            `json:"slot,omitempty" datastore:"slot,omitempty,noindex"`
+	UacChunks *UacChunks     `json:"uac_chunks,omitempty" datastore:"-"`
+	UAC       *datastore.Key `json:"-" datastore:"__key__"`
+	FullUAC   string         `json:"full_uac,omitempty" datastore:"-"`
+	Disabled  bool           `json:"disabled" datastore:"disabled"`
+	// UacHash identifies the UAC in listings when UACs are keyed, as the full UAC is not shown.
+	UacHash string `json:"uac_hash,omitempty" datastore:"-"`
+	// SealedUAC is the UAC encrypted by the UacKeyer when UACs are keyed.
+	SealedUAC string `json:"-" datastore:"sealed_uac,noindex"`
+	// CreatedAt is when the UAC was given to its case, or imported while it is in the pool. UACs created before
+	// timestamps were recorded have none.
+	CreatedAt time.Time `json:"created_at,omitzero" datastore:"created_at,omitempty"`
+	// UpdatedAt is when the UAC was created or last disabled or enabled.
+	UpdatedAt time.Time `json:"updated_at,omitzero" datastore:"updated_at,omitempty"`
+	// DisabledAt is when a disabled UAC was disabled.
+	DisabledAt time.Time `json:"disabled_at,omitzero" datastore:"disabled_at,omitempty"`
+	// FirstAccessedAt is when the UAC was first looked up.
+	FirstAccessedAt time.Time `json:"first_accessed_at,omitzero" datastore:"first_accessed_at,omitempty"`
+	// DisabledReason is the reason code a disabled UAC was disabled for, with DisabledNote saying more.
+	DisabledReason string `json:"disabled_reason,omitempty" datastore:"disabled_reason,omitempty,noindex"`
+	DisabledNote   string `json:"disabled_note,omitempty" datastore:"disabled_note,omitempty,noindex"`
+	// ReissuedFrom names the UAC a reissued UAC replaced, and ReissuedTo the UAC a replaced UAC was reissued as.
+	// A case's current UAC is the one that has not been replaced.
+	ReissuedFrom string `json:"reissued_from,omitempty" datastore:"reissued_from,omitempty,noindex"`
+	ReissuedTo   string `json:"reissued_to,omitempty" datastore:"reissued_to,omitempty,noindex"`
+	// ValidFrom and ValidUntil override the ends of the instrument's validity that they set for the UAC. A looked up
+	// UAC is shown with the validity it has, its own or its instrument's, and its Status.
+	ValidFrom  time.Time `json:"valid_from,omitzero" datastore:"valid_from,omitempty,noindex"`
+	ValidUntil time.Time `json:"valid_until,omitzero" datastore:"valid_until,omitempty,noindex"`
+	Status     string    `json:"status,omitempty" datastore:"-"`
+	// DeniedWord is the word on the denylist a UAC listed by GetUacsDenied spells.
+	DeniedWord string `json:"denied_word,omitempty" datastore:"-"`
+}
+
+// CaseSlot returns the CaseSlotKey of the slot of the case a UAC belongs to.
+func (uacInfo *UacInfo) CaseSlot() string {
+	return CaseSlotKey(uacInfo.CaseID, uacInfo.Slot)
 }
 
 type Uacs map[string]*UacInfo
@@ -83,305 +166,757 @@ func (uacs Uacs) BuildUacChunks() {
 	for uac, uacInfo := range uacs {
 		if uacInfo.FullUAC != "" {
 			uac = uacInfo.FullUAC
+		} else if uacInfo.UacHash != "" {
+			continue
 		}
 		uacInfo.UacChunks = ChunkUAC(uac)
 	}
 }
 
+// Reveal returns the UACs with the case slots given UACs by a generation keyed by, and showing, their full UAC.
+// caseUacs is GenerateResult.Uacs, which is only set when UACs are keyed.
+func (uacs Uacs) Reveal(caseUacs map[string]string) Uacs {
+	if len(caseUacs) == 0 {
+		return uacs
+	}
+	revealed := make(Uacs, len(uacs))
+	for uac, uacInfo := range uacs {
+		if fullUAC, ok := caseUacs[uacInfo.CaseSlot()]; ok {
+			uacInfo.FullUAC = fullUAC
+			uacInfo.UacHash = ""
+			uac = fullUAC
+		}
+		revealed[uac] = uacInfo
+	}
+	return revealed
+}
+
 func NewUacGenerator(datastoreClient Datastore, uacKind string) *UacGenerator {
+	uacGenerator := NewUacGeneratorWithStore(NewDatastoreUacStore(datastoreClient), uacKind)
+	uacGenerator.DatastoreClient = datastoreClient
+	return uacGenerator
+}
+
+// NewUacGeneratorWithStore returns a generator that keeps UACs in a store other than Datastore.
+func NewUacGeneratorWithStore(store UacStore, uacKind string) *UacGenerator {
 	return &UacGenerator{
-		UacKind:         uacKind,
-		Context:         context.Background(),
-		Randomizer:      rand.New(cryptoSource{}),
-		DatastoreClient: datastoreClient,
+		UacKind:          uacKind,
+		Context:          context.Background(),
+		Randomizer:       rand.New(cryptoSource{}),
+		Store:            store,
+		ArchiveRetention: DEFAULTARCHIVERETENTION,
+		Denylist:         DefaultDenylist(),
+		PatternRules:     DefaultPatternRules(),
 	}
 }
 
 func (uacGenerator *UacGenerator) GenerateUac12() string {
-	var uac string
-	for i := 0; i < 3; i++ {
-		uacSegmant := uacGenerator.Randomizer.Int63n(9999 - 1000)
-		uac = fmt.Sprintf("%s%d", uac, uacSegmant+1000)
-	}
-	return uac
+	return Uac12Format{}.Generate(uacGenerator.Randomizer)
 }
 
 func (uacGenerator *UacGenerator) GenerateUac16() string {
-	b := make([]byte, 16)
-	for i := range b {
-		b[i] = APPROVEDCHARACTERS[uacGenerator.Randomizer.Intn(len(APPROVEDCHARACTERS))]
-	}
-	return string(b)
+	return Uac16Format{}.Generate(uacGenerator.Randomizer)
 }
 
 func (uacGenerator *UacGenerator) NewUac(instrumentName, caseID string, attempt int) (string, error) {
-	if caseID == "" {
-		return "", fmt.Errorf("Cannot generate UACs for blank caseIDs")
+	err := validateCaseID(caseID)
+	if err != nil {
+		return "", err
+	}
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return "", err
+	}
+	return uacGenerator.newUac(uacKind, instrumentName, caseID, 0, attempt)
+}
+
+func (uacGenerator *UacGenerator) newUac(uacKind, instrumentName, caseID string, slot, attempt int) (string, error) {
+	err := validateCaseID(caseID)
+	if err != nil {
+		return "", err
 	}
 	if attempt >= 10 {
 		return "", fmt.Errorf("Could not generate a unique UAC in 10 attempts")
 	}
 
-	var uac string
-	switch uacGenerator.UacKind {
-	case "uac":
-		uac = uacGenerator.GenerateUac12()
-	case "uac16":
-		uac = uacGenerator.GenerateUac16()
-	default:
+	uacFormat, ok := GetUacFormat(uacKind)
+	if !ok {
 		return "", fmt.Errorf("Cannot generate UACs for invalid UacKind")
 	}
+	uac, err := uacGenerator.generateUac(uacFormat)
+	if err != nil {
+		return "", err
+	}
 
-	err := uacGenerator.AddUacToDatastore(uac, instrumentName, caseID)
+	uacInfo, err := uacGenerator.newCaseUacInfo(uacKind, uac, instrumentName, caseID, slot)
+	if err != nil {
+		return "", err
+	}
+	err = uacGenerator.Store.InsertCaseUac(uacGenerator.Context, uacInfo,
+		uacGenerator.auditEntries(uacAuditEntry(AUDITGENERATE, uacInfo.UAC, nil, uacInfo)))
 	if err != nil {
 		if alreadyExistsError(err) {
-			return uacGenerator.NewUac(instrumentName, caseID, attempt+1)
+			return uacGenerator.newUac(uacKind, instrumentName, caseID, slot, attempt+1)
 		}
 		return "", err
 	}
 	return uac, nil
 }
 
+// generateUac generates a UAC of a format that is not on the generator's denylist and follows none of its weak
+// patterns.
+func (uacGenerator *UacGenerator) generateUac(uacFormat UacFormat) (string, error) {
+	err := ErrUacDenied
+	for attempt := 0; attempt < MAXREJECTEDATTEMPTS; attempt++ {
+		uac := uacFormat.Generate(uacGenerator.Randomizer)
+		switch {
+		case uacGenerator.deniedWord(uacFormat, uac) != "":
+			err = ErrUacDenied
+		case uacGenerator.WeakPattern(uac) != "":
+			err = ErrUacWeak
+		default:
+			return uac, nil
+		}
+	}
+	return "", err
+}
+
 func (uacGenerator *UacGenerator) AddUacToDatastore(uac string, instrumentName, caseID string) error {
-	// Cannot workout how the hell to mock/ test this :(
-	newUACMutation := datastore.NewInsert(uacGenerator.UacKey(uac), &UacInfo{
+	err := validateCaseID(caseID)
+	if err != nil {
+		return err
+	}
+	uacInfo, err := uacGenerator.newCaseUacInfo(uacGenerator.UacKind, uac, instrumentName, caseID, 0)
+	if err != nil {
+		return err
+	}
+	return uacGenerator.Store.InsertCaseUac(uacGenerator.Context, uacInfo,
+		uacGenerator.auditEntries(uacAuditEntry(AUDITGENERATE, uacInfo.UAC, nil, uacInfo)))
+}
+
+func (uacGenerator *UacGenerator) newCaseUacInfo(uacKind, uac, instrumentName, caseID string, slot int) (*UacInfo, error) {
+	now := uacTimestamp()
+	uacInfo := &UacInfo{
 		InstrumentName: strings.ToLower(instrumentName),
 		CaseID:         strings.ToLower(caseID),
-	})
-	_, err := uacGenerator.DatastoreClient.Mutate(uacGenerator.Context, newUACMutation)
+		Slot:           slot,
+		UAC:            uacGenerator.uacKey(uacKind, uac),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if uacGenerator.UacKeyer != nil {
+		sealedUac, err := uacGenerator.UacKeyer.Seal(uac)
+		if err != nil {
+			return nil, err
+		}
+		uacInfo.SealedUAC = sealedUac
+	}
+	return uacInfo, nil
+}
+
+func (uacGenerator *UacGenerator) UacKey(key string) *datastore.Key {
+	return uacGenerator.uacKey(uacGenerator.UacKind, key)
+}
+
+// uacKey returns the key of a UAC of a kind, which is named by the UAC's hash when UACs are keyed.
+func (uacGenerator *UacGenerator) uacKey(uacKind, uac string) *datastore.Key {
+	if uacGenerator.UacKeyer == nil {
+		return uacKindKey(uacKind, uac)
+	}
+	return uacKindKey(uacKind, uacGenerator.UacKeyer.KeyName(normaliseUac(uacKind, uac)))
+}
+
+// normaliseUac normalises a UAC with the format of a kind.
+func normaliseUac(uacKind, uac string) string {
+	if uacFormat, ok := GetUacFormat(uacKind); ok {
+		return uacFormat.Normalise(uac)
+	}
+	return uac
+}
+
+// showUac sets how a UAC is shown in listings, which is by its hash rather than the full UAC when UACs are keyed.
+func (uacGenerator *UacGenerator) showUac(uacInfo *UacInfo) {
+	if uacGenerator.UacKeyer == nil {
+		uacInfo.FullUAC = uacInfo.UAC.Name
+		return
+	}
+	uacInfo.UacHash = uacGenerator.uacHash(uacInfo)
+	uacGenerator.showLinks(uacInfo)
+}
+
+func (uacGenerator *UacGenerator) uacHash(uacInfo *UacInfo) string {
+	if uacGenerator.UacKeyer.IsKeyName(uacInfo.UAC.Name) {
+		return uacInfo.UAC.Name
+	}
+	// Not yet migrated
+	return uacGenerator.UacKeyer.KeyName(uacInfo.UAC.Name)
+}
+
+// revealUac sets the full UAC, unsealing it when UACs are keyed. It is only for generation and encrypted exports.
+// A UAC that has not yet been migrated to its hash is its own full UAC, but a keyed UAC must have been sealed.
+func (uacGenerator *UacGenerator) revealUac(uacInfo *UacInfo) error {
+	if uacGenerator.UacKeyer == nil || (uacInfo.SealedUAC == "" && !uacGenerator.UacKeyer.IsKeyName(uacInfo.UAC.Name)) {
+		uacInfo.FullUAC = uacInfo.UAC.Name
+		return nil
+	}
+	if uacInfo.SealedUAC == "" {
+		return ErrUacNotSealed
+	}
+	uac, err := uacGenerator.UacKeyer.Open(uacInfo.SealedUAC)
 	if err != nil {
 		return err
 	}
+	uacInfo.FullUAC = uac
 	return nil
 }
 
-func (uacGenerator *UacGenerator) UacKey(key string) *datastore.Key {
-	return datastore.NameKey(uacGenerator.UacKind, key, nil)
+func uacKindKey(uacKind, key string) *datastore.Key {
+	return datastore.NameKey(uacKind, key, nil)
 }
 
-func (uacGenerator *UacGenerator) UacExistsForCase(instrumentName, caseID string) (bool, error) {
-	var existingUACs []*UacInfo
-	existingUACKeys, err := uacGenerator.DatastoreClient.GetAll(
-		uacGenerator.Context,
-		uacGenerator.instrumentCaseQuery(instrumentName, caseID),
-		&existingUACs,
-	)
+// UacExistsForCase reports whether a slot of a case has been given a UAC.
+func (uacGenerator *UacGenerator) UacExistsForCase(instrumentName, caseID string, slot int) (bool, error) {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
 	if err != nil {
 		return false, err
 	}
-	if len(existingUACKeys) >= 1 {
-		return true, nil
+	return uacGenerator.uacExistsForCase(uacKind, instrumentName, caseID, slot)
+}
+
+func (uacGenerator *UacGenerator) uacExistsForCase(uacKind, instrumentName, caseID string, slot int) (bool, error) {
+	existingUACs, err := uacGenerator.Store.ListCaseUacs(uacGenerator.Context, uacKind, instrumentName, caseID)
+	if err != nil {
+		return false, err
+	}
+	for _, existingUAC := range existingUACs {
+		if existingUAC.Slot == slot {
+			return true, nil
+		}
 	}
 	return false, nil
 }
 
 func (uacGenerator *UacGenerator) GenerateUniqueUac(instrumentName, caseID string) error {
-	exists, err := uacGenerator.UacExistsForCase(instrumentName, caseID)
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return err
+	}
+	exists, err := uacGenerator.uacExistsForCase(uacKind, instrumentName, caseID, 0)
 	if err != nil {
-		uacGenerator.mu.Lock()
-		uacGenerator.GenerateError[instrumentName] = err
-		uacGenerator.mu.Unlock()
 		log.Println(err)
 		return err
 	}
-	if !exists {
-		_, err := uacGenerator.NewUac(instrumentName, caseID, 0)
+	if exists {
+		return nil
+	}
+	_, err = uacGenerator.newUac(uacKind, instrumentName, caseID, 0, 0)
+	if err == ErrCaseHasUac {
+		return nil
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (uacGenerator *UacGenerator) Generate(instrumentName string, caseIDs []string) (*GenerateResult, error) {
+	return uacGenerator.GenerateWithOptions(instrumentName, caseIDs, GenerateOptions{})
+}
+
+// GenerateWithOptions generates UACs for the slots of the cases that do not have one. A slot failing does not stop
+// the others, the result reports what happened to each slot. An error is only returned when no slots could be
+// attempted.
+func (uacGenerator *UacGenerator) GenerateWithOptions(instrumentName string, caseIDs []string, options GenerateOptions) (*GenerateResult, error) {
+	result := NewGenerateResult()
+	if len(caseIDs) == 0 {
+		return result, nil
+	}
+	err := options.Validate()
+	if err != nil {
+		return nil, err
+	}
+	uacKind, err := uacGenerator.recordInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	caseSlots := options.caseSlots(caseIDs)
+	var pool *uacPool
+	if options.ClaimFromPool {
+		pool, err = uacGenerator.newUacPool(uacKind, len(caseSlots))
 		if err != nil {
-			uacGenerator.mu.Lock()
-			uacGenerator.GenerateError[instrumentName] = err
-			uacGenerator.mu.Unlock()
-			log.Println(err)
-			return err
+			return nil, err
 		}
 	}
-	return nil
+	existingCaseSlots, err := uacGenerator.instrumentCaseSlots(uacKind, instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacGenerator.generateCases(uacKind, instrumentName, caseSlots, existingCaseSlots, pool, result.add)
+	result.sort()
+	if uacGenerator.UacKeyer != nil {
+		err = uacGenerator.revealGenerated(uacKind, instrumentName, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
-func (uacGenerator *UacGenerator) Generate(instrumentName string, caseIDs []string) error {
+// revealGenerated unseals the UACs given to case slots by a generation into the result. Only the UACs of the cases
+// given new UACs are read, one case at a time.
+func (uacGenerator *UacGenerator) revealGenerated(uacKind, instrumentName string, result *GenerateResult) error {
+	var (
+		newCaseSlots = make(map[string]bool, len(result.Generated)+len(result.FromPool))
+		newCases     = make(map[string]bool)
+		caseIDs      []string
+	)
+	for _, caseSlot := range append(append([]string{}, result.Generated...), result.FromPool...) {
+		newCaseSlots[strings.ToLower(caseSlot)] = true
+		caseID, _ := ParseCaseSlotKey(caseSlot)
+		if !newCases[strings.ToLower(caseID)] {
+			newCases[strings.ToLower(caseID)] = true
+			caseIDs = append(caseIDs, caseID)
+		}
+	}
+	result.Uacs = make(map[string]string, len(newCaseSlots))
 	if len(caseIDs) == 0 {
 		return nil
 	}
-	if uacGenerator.GenerateError == nil {
-		uacGenerator.GenerateError = make(map[string]error)
-	}
+
+	var (
+		errors   []error
+		revealMu sync.Mutex
+	)
 	concurrent := goccm.New(MAXCONCURRENT)
 	for _, caseID := range caseIDs {
 		concurrent.Wait()
 		go func(caseID string) {
 			defer concurrent.Done()
-			err := uacGenerator.GenerateUniqueUac(instrumentName, caseID)
+			uacInfos, err := uacGenerator.Store.ListCaseUacs(uacGenerator.Context, uacKind, instrumentName, caseID)
+			for _, uacInfo := range uacInfos {
+				if err != nil {
+					break
+				}
+				if newCaseSlots[uacInfo.CaseSlot()] {
+					err = uacGenerator.revealUac(uacInfo)
+				}
+			}
+			revealMu.Lock()
+			defer revealMu.Unlock()
 			if err != nil {
-				uacGenerator.mu.Lock()
-				uacGenerator.GenerateError[instrumentName] = err
-				uacGenerator.mu.Unlock()
+				errors = append(errors, err)
+				return
+			}
+			for _, uacInfo := range uacInfos {
+				if newCaseSlots[uacInfo.CaseSlot()] {
+					result.Uacs[uacInfo.CaseSlot()] = uacInfo.FullUAC
+				}
 			}
 		}(caseID)
 	}
 	concurrent.WaitAllDone()
-	err := uacGenerator.GenerateError[instrumentName]
-	uacGenerator.mu.Lock()
-	uacGenerator.GenerateError[instrumentName] = nil
-	uacGenerator.mu.Unlock()
-	return err
+	if len(errors) > 0 {
+		return errors[0]
+	}
+	return nil
+}
+
+// generateCases generates UACs for the case slots that are not in existingCaseSlots and reports the outcome of each
+// slot by its CaseSlotKey. Generated slots are added to existingCaseSlots. New UACs are inserted in batches rather
+// than one commit per slot.
+func (uacGenerator *UacGenerator) generateCases(uacKind, instrumentName string, caseSlots []respondentSlot, existingCaseSlots map[string]bool, pool *uacPool, report func(string, uacSource, error)) {
+	var reportMu sync.Mutex
+	safeReport := func(caseID string, source uacSource, err error) {
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		if err != nil {
+			log.Println(err)
+		}
+		report(caseID, source, err)
+	}
+
+	var newCaseSlots []respondentSlot
+	for _, caseSlot := range caseSlots {
+		if err := validateCaseID(caseSlot.caseID); err != nil {
+			safeReport(caseSlot.key(), uacFailed, err)
+			continue
+		}
+		if existingCaseSlots[strings.ToLower(caseSlot.key())] {
+			safeReport(caseSlot.key(), uacExisted, nil)
+			continue
+		}
+		existingCaseSlots[strings.ToLower(caseSlot.key())] = true
+		newCaseSlots = append(newCaseSlots, caseSlot)
+	}
+
+	if pool != nil {
+		newCaseSlots = uacGenerator.claimPoolUacs(pool, instrumentName, newCaseSlots, safeReport)
+	}
+
+	for start := 0; start < len(newCaseSlots); start += CASEBATCHSIZE {
+		end := start + CASEBATCHSIZE
+		if end > len(newCaseSlots) {
+			end = len(newCaseSlots)
+		}
+		uacGenerator.insertUacBatch(uacKind, instrumentName, newCaseSlots[start:end], safeReport)
+	}
+}
+
+// insertUacBatch gives each case slot a new UAC in a single commit. A slot whose UAC cannot be generated fails on its
+// own and is left out of the commit. A commit is applied all or nothing, so when any of the UACs or case slots already
+// exists the slots are retried one at a time, where a UAC collision only regenerates that slot's UAC and a slot that
+// has since been given a UAC elsewhere is reported as existing.
+func (uacGenerator *UacGenerator) insertUacBatch(uacKind, instrumentName string, caseSlots []respondentSlot, report func(string, uacSource, error)) {
+	uacFormat, ok := GetUacFormat(uacKind)
+	if !ok {
+		for _, caseSlot := range caseSlots {
+			report(caseSlot.key(), uacFailed, fmt.Errorf("Cannot generate UACs for invalid UacKind"))
+		}
+		return
+	}
+	var (
+		batchUACs      = make(map[string]bool, len(caseSlots))
+		uacInfos       = make([]*UacInfo, 0, len(caseSlots))
+		batchCaseSlots = make([]respondentSlot, 0, len(caseSlots))
+	)
+	for _, caseSlot := range caseSlots {
+		uac, err := uacGenerator.generateUac(uacFormat)
+		// Datastore rejects a commit that inserts the same key twice
+		for err == nil && batchUACs[uac] {
+			uac, err = uacGenerator.generateUac(uacFormat)
+		}
+		var uacInfo *UacInfo
+		if err == nil {
+			uacInfo, err = uacGenerator.newCaseUacInfo(uacKind, uac, instrumentName, caseSlot.caseID, caseSlot.slot)
+		}
+		if err != nil {
+			report(caseSlot.key(), uacFailed, err)
+			continue
+		}
+		batchUACs[uac] = true
+		uacInfos = append(uacInfos, uacInfo)
+		batchCaseSlots = append(batchCaseSlots, caseSlot)
+	}
+	if len(uacInfos) == 0 {
+		return
+	}
+	caseSlots = batchCaseSlots
+	auditEntries := make([]*AuditEntry, len(uacInfos))
+	for i, uacInfo := range uacInfos {
+		auditEntries[i] = uacAuditEntry(AUDITGENERATE, uacInfo.UAC, nil, uacInfo)
+	}
+	err := uacGenerator.Store.InsertCaseUacs(uacGenerator.Context, uacInfos, uacGenerator.auditEntries(auditEntries...))
+	if err == nil {
+		for _, caseSlot := range caseSlots {
+			report(caseSlot.key(), uacGenerated, nil)
+		}
+		return
+	}
+	if !alreadyExistsError(err) {
+		for _, caseSlot := range caseSlots {
+			report(caseSlot.key(), uacFailed, err)
+		}
+		return
+	}
+	concurrent := goccm.New(MAXCONCURRENT)
+	for _, caseSlot := range caseSlots {
+		concurrent.Wait()
+		go func(caseSlot respondentSlot) {
+			defer concurrent.Done()
+			_, err := uacGenerator.newUac(uacKind, instrumentName, caseSlot.caseID, caseSlot.slot, 1)
+			if err == ErrCaseHasUac {
+				report(caseSlot.key(), uacExisted, nil)
+				return
+			}
+			if err != nil {
+				report(caseSlot.key(), uacFailed, err)
+				return
+			}
+			report(caseSlot.key(), uacGenerated, nil)
+		}(caseSlot)
+	}
+	concurrent.WaitAllDone()
+}
+
+// instrumentCaseSlots loads the lowercased CaseSlotKeys of the case slots that already have a UAC for an instrument.
+func (uacGenerator *UacGenerator) instrumentCaseSlots(uacKind, instrumentName string) (map[string]bool, error) {
+	caseSlotKeys, err := uacGenerator.Store.ListCaseSlots(uacGenerator.Context, uacKind, instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	caseSlots := make(map[string]bool, len(caseSlotKeys))
+	for _, caseSlotKey := range caseSlotKeys {
+		caseSlots[strings.ToLower(caseSlotKey)] = true
+	}
+	return caseSlots, nil
 }
 
 func (uacGenerator *UacGenerator) GetAllUacs(instrumentName string) (Uacs, error) {
-	var uacInfos []*UacInfo
-	_, err := uacGenerator.DatastoreClient.GetAll(uacGenerator.Context, uacGenerator.instrumentQuery(instrumentName), &uacInfos)
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacInfos, err := uacGenerator.Store.ListUacs(uacGenerator.Context, uacKind, instrumentName)
 	if err != nil {
 		return nil, err
 	}
 	uacs := make(Uacs)
 	for _, uacInfo := range uacInfos {
+		if uacGenerator.UacKeyer != nil {
+			uacInfo.UacHash = uacGenerator.uacHash(uacInfo)
+			uacGenerator.showLinks(uacInfo)
+			uacs[uacInfo.UacHash] = uacInfo
+			continue
+		}
 		uacs[uacInfo.UAC.Name] = uacInfo
 	}
 	return uacs, nil
 }
 
+// GetAllUacsByCaseID returns the current UAC of each slot of an instrument's cases keyed by CaseSlotKey, returning an
+// error when two current UACs share a slot of a case.
 func (uacGenerator *UacGenerator) GetAllUacsByCaseID(instrumentName string) (Uacs, error) {
-	var uacInfos []*UacInfo
-	_, err := uacGenerator.DatastoreClient.GetAll(uacGenerator.Context, uacGenerator.instrumentQuery(instrumentName), &uacInfos)
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacInfos, err := uacGenerator.Store.ListUacs(uacGenerator.Context, uacKind, instrumentName)
 	if err != nil {
 		return nil, err
 	}
 	uacs := make(Uacs)
+	replacedCount := 0
 	for _, uacInfo := range uacInfos {
-		uacInfo.FullUAC = uacInfo.UAC.Name
-		uacs[uacInfo.CaseID] = uacInfo
+		if uacInfo.ReissuedTo != "" {
+			replacedCount++
+			continue
+		}
+		uacGenerator.showUac(uacInfo)
+		uacs[uacInfo.CaseSlot()] = uacInfo
 	}
-	if len(uacs) != len(uacInfos) {
+	if len(uacs) != len(uacInfos)-replacedCount {
 		return nil, fmt.Errorf("Fewer case ids than uacs, must be duplicate case ids")
 	}
 	return uacs, nil
 }
 
+// GetAllUacsDisabled returns the disabled current UACs of an instrument's case slots as GetAllUacsByCaseID does.
 func (uacGenerator *UacGenerator) GetAllUacsDisabled(instrumentName string) (Uacs, error) {
-	var uacInfos []*UacInfo
-	_, err := uacGenerator.DatastoreClient.GetAll(uacGenerator.Context, uacGenerator.instrumentUacDisabledQuery(instrumentName), &uacInfos)
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacInfos, err := uacGenerator.Store.ListDisabledUacs(uacGenerator.Context, uacKind, instrumentName)
 	if err != nil {
 		return nil, err
 	}
 	uacs := make(Uacs)
+	replacedCount := 0
 	for _, uacInfo := range uacInfos {
-		uacInfo.FullUAC = uacInfo.UAC.Name
-		uacs[uacInfo.CaseID] = uacInfo
+		if uacInfo.ReissuedTo != "" {
+			replacedCount++
+			continue
+		}
+		uacGenerator.showUac(uacInfo)
+		uacs[uacInfo.CaseSlot()] = uacInfo
 	}
-	if len(uacs) != len(uacInfos) {
+	if len(uacs) != len(uacInfos)-replacedCount {
 		return nil, fmt.Errorf("Fewer case ids than uacs, must be duplicate case ids")
 	}
 	return uacs, nil
 }
 
 func (uacGenerator *UacGenerator) DisableUac(uac string) error {
-	uacInfo := &UacInfo{}
-	err := uacGenerator.DatastoreClient.Get(uacGenerator.Context, uacGenerator.UacKey(uac), uacInfo)
-	if err != nil {
-		return err
-	}
-	newUACMutation := datastore.NewUpdate(uacGenerator.UacKey(uac), &UacInfo{
-		InstrumentName: strings.ToLower(uacInfo.InstrumentName),
-		CaseID:         strings.ToLower(uacInfo.CaseID),
-		Disabled:       true,
-	})
-	_, err = uacGenerator.DatastoreClient.Mutate(uacGenerator.Context, newUACMutation)
-	if err != nil {
-		return err
-	}
-	return nil
+	return uacGenerator.setUacDisabled(uac, true)
 }
 
 func (uacGenerator *UacGenerator) EnableUac(uac string) error {
-	uacInfo := &UacInfo{}
-	err := uacGenerator.DatastoreClient.Get(uacGenerator.Context, uacGenerator.UacKey(uac), uacInfo)
+	return uacGenerator.setUacDisabled(uac, false)
+}
+
+func (uacGenerator *UacGenerator) setUacDisabled(uac string, disabled bool) error {
+	uacInfo, err := uacGenerator.findUacInfo(uac)
 	if err != nil {
 		return err
 	}
-	newUACMutation := datastore.NewUpdate(uacGenerator.UacKey(uac), &UacInfo{
-		InstrumentName: strings.ToLower(uacInfo.InstrumentName),
-		CaseID:         strings.ToLower(uacInfo.CaseID),
-		Disabled:       false,
-	})
-	_, err = uacGenerator.DatastoreClient.Mutate(uacGenerator.Context, newUACMutation)
-	if err != nil {
-		return err
+	if !disabled && uacInfo.ReissuedTo != "" {
+		return ErrUacReissued
 	}
-	return nil
+	action := AUDITENABLE
+	if disabled {
+		action = AUDITDISABLE
+	}
+	after := *uacInfo
+	after.Disabled = disabled
+	return uacGenerator.Store.SetUacsDisabled(uacGenerator.Context, []*datastore.Key{uacInfo.UAC}, UacStatusChange{Disabled: disabled},
+		uacGenerator.auditEntries(uacAuditEntry(action, uacInfo.UAC, uacInfo, &after)))
 }
 
 func (uacGenerator *UacGenerator) GetUacCount(instrumentName string) (int, error) {
-	return uacGenerator.DatastoreClient.Count(uacGenerator.Context, uacGenerator.instrumentQuery(instrumentName))
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return 0, err
+	}
+	return uacGenerator.Store.CountUacs(uacGenerator.Context, uacKind, instrumentName)
 }
 
+// GetUacInfo looks up a UAC in each enabled kind whose format the UAC matches, recording when the UAC was first
+// looked up. UACs that do not match any enabled kind are looked up in the default kind. The UAC's status says
+// whether it can be used now.
 func (uacGenerator *UacGenerator) GetUacInfo(uac string) (*UacInfo, error) {
-	uacInfo := &UacInfo{}
-	err := uacGenerator.DatastoreClient.Get(uacGenerator.Context, uacGenerator.UacKey(uac), uacInfo)
+	uacInfo, err := uacGenerator.findUacInfo(uac)
+	if err != nil {
+		return nil, err
+	}
+	if uacInfo.FirstAccessedAt.IsZero() {
+		// A lookup is answered even when its time cannot be recorded
+		err = uacGenerator.Store.RecordUacAccess(uacGenerator.Context, uacInfo.UAC)
+		if err != nil {
+			log.Printf("Could not record the lookup of a UAC: %v", err)
+		}
+	}
+	err = uacGenerator.uacValidity(uacInfo)
 	if err != nil {
 		return nil, err
 	}
 	return uacInfo, nil
 }
 
-func (uacGenerator *UacGenerator) GetInstruments() ([]string, error) {
-	var (
-		uacInfos        []*UacInfo
-		instrumentNames []string
-	)
-	_, err := uacGenerator.DatastoreClient.GetAll(uacGenerator.Context, uacGenerator.instrumentNamesQuery(), &uacInfos)
-	if err != nil {
-		return nil, err
+// findUacInfo looks up a UAC as GetUacInfo does without recording the lookup. The UAC is normalised for each kind
+// it is looked up as.
+// A probable typo is only looked up as the kinds without a check character it matches, and is reported as a probable
+// typo when it is not one of them.
+func (uacGenerator *UacGenerator) findUacInfo(uac string) (*UacInfo, error) {
+	probableTypo := uacGenerator.ProbableTypo(uac)
+	uacKinds := uacGenerator.DetectUacKinds(uac)
+	if len(uacKinds) == 0 {
+		if probableTypo {
+			return nil, ErrProbableTypo
+		}
+		uacKinds = []string{uacGenerator.UacKind}
 	}
-	for _, uacInfo := range uacInfos {
-		instrumentNames = append(instrumentNames, uacInfo.InstrumentName)
+	for _, uacKind := range uacKinds {
+		uacInfo, err := uacGenerator.getUacInfo(uacKind, normaliseUac(uacKind, uac))
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		return uacInfo, err
+	}
+	if probableTypo {
+		return nil, ErrProbableTypo
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
+func (uacGenerator *UacGenerator) getUacInfo(uacKind, uac string) (*UacInfo, error) {
+	return uacGenerator.Store.GetUac(uacGenerator.Context, uacGenerator.uacKey(uacKind, uac))
+}
+
+func (uacGenerator *UacGenerator) GetInstruments() ([]string, error) {
+	var instrumentNames []string
+	seenInstrumentNames := make(map[string]bool)
+	for _, uacKind := range uacGenerator.EnabledUacKinds() {
+		kindInstrumentNames, err := uacGenerator.Store.ListInstruments(uacGenerator.Context, uacKind)
+		if err != nil {
+			return nil, err
+		}
+		for _, instrumentName := range kindInstrumentNames {
+			if seenInstrumentNames[instrumentName] {
+				continue
+			}
+			seenInstrumentNames[instrumentName] = true
+			instrumentNames = append(instrumentNames, instrumentName)
+		}
 	}
 	return instrumentNames, nil
 }
 
+// ImportUACs adds UACs to the unknown instrument, using the UAC kind recorded for it.
 func (uacGenerator *UacGenerator) ImportUACs(uacs []string) (int, error) {
-	if err := uacGenerator.ValidateUACs(uacs); err != nil {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(UNKNOWNINSTRUMENT)
+	if err != nil {
 		return 0, err
 	}
-	uacsToImport, err := uacGenerator.getUACsToImport(uacs)
+	uacFormat, ok := GetUacFormat(uacKind)
+	if !ok {
+		return 0, ErrInvalidUacKind
+	}
+	// UACs typed differently can normalise to the same UAC
+	uacs = uniqueUACs(normaliseUACs(uacFormat, uacs))
+	if err := uacGenerator.validateUACs(uacFormat, uacs); err != nil {
+		return 0, err
+	}
+	uacsToImport, err := uacGenerator.getUACsToImport(uacKind, uacs)
 	if err != nil {
 		return 0, err
 	}
-	return uacGenerator.importUACs(uacsToImport)
+	return uacGenerator.importUACs(uacKind, uacsToImport)
 }
 
 func (uacGenerator *UacGenerator) ValidateUAC12(uac string) bool {
-	if len(uac) != 12 {
-		return false
-	}
-	chunkedUAC := ChunkUAC(uac)
-	uacParts := []string{chunkedUAC.UAC1, chunkedUAC.UAC2, chunkedUAC.UAC3}
-	for _, uacPart := range uacParts {
-		uacInt, err := strconv.Atoi(uacPart)
-		if err != nil {
-			return false
-		}
-		if uacInt < 1000 || uacInt > 9999 {
-			return false
-		}
-	}
-	return true
+	return Uac12Format{}.Validate(uac)
 }
 
 func (uacGenerator *UacGenerator) ValidateUAC16(uac string) bool {
-	uac16Regex := regexp.MustCompile(fmt.Sprintf(`^[%s]{16}$`, APPROVEDCHARACTERS))
-	return uac16Regex.MatchString(uac)
+	return Uac16Format{}.Validate(uac)
 }
 
 func (uacGenerator *UacGenerator) ValidateUAC(uac string) bool {
-	if uacGenerator.UacKind == "uac16" {
-		return uacGenerator.ValidateUAC16(uac)
+	return uacGenerator.uacFormat().Validate(uac)
+}
+
+// ProbableTypo reports whether a UAC has the shape of an enabled kind with a check character but fails the check of
+// every such kind, meaning it cannot have been issued as one of them. It can still be a UAC of an enabled kind
+// without a check character.
+func (uacGenerator *UacGenerator) ProbableTypo(uac string) bool {
+	hasCheckedShape := false
+	for _, uacKind := range uacGenerator.EnabledUacKinds() {
+		uacFormat, _ := GetUacFormat(uacKind)
+		checkedFormat, ok := uacFormat.(CheckedUacFormat)
+		if !ok {
+			continue
+		}
+		normalisedUac := checkedFormat.Normalise(uac)
+		if checkedFormat.Validate(normalisedUac) {
+			return false
+		}
+		if checkedFormat.ValidShape(normalisedUac) {
+			hasCheckedShape = true
+		}
+	}
+	return hasCheckedShape
+}
+
+// DetectUacKinds returns the enabled kinds whose format the UAC matches once normalised for the kind, starting with
+// the default kind.
+func (uacGenerator *UacGenerator) DetectUacKinds(uac string) []string {
+	var uacKinds []string
+	for _, uacKind := range uacGenerator.EnabledUacKinds() {
+		uacFormat, _ := GetUacFormat(uacKind)
+		if uacFormat.Validate(uacFormat.Normalise(uac)) {
+			uacKinds = append(uacKinds, uacKind)
+		}
 	}
-	return uacGenerator.ValidateUAC12(uac)
+	return uacKinds
 }
 
+// ValidateUACs returns an ImportError listing the UACs that are invalid, that spell a word on the denylist when their
+// format has letters, and that follow a weak pattern when RejectWeakImports is set.
 func (uacGenerator *UacGenerator) ValidateUACs(uacs []string) error {
+	return uacGenerator.validateUACs(uacGenerator.uacFormat(), uacs)
+}
+
+func (uacGenerator *UacGenerator) validateUACs(uacFormat UacFormat, uacs []string) error {
 	var importError ImportError
 	for _, uac := range uacs {
-		if !uacGenerator.ValidateUAC(uac) {
+		switch {
+		case !uacFormat.Validate(uac):
 			importError.InvalidUACs = append(importError.InvalidUACs, uac)
+		case uacGenerator.deniedWord(uacFormat, uac) != "":
+			importError.DeniedUACs = append(importError.DeniedUACs, uac)
+		case uacGenerator.RejectWeakImports && uacGenerator.WeakPattern(uac) != "":
+			importError.WeakUACs = append(importError.WeakUACs, uac)
 		}
 	}
 	if importError.HasErrors() {
@@ -390,31 +925,64 @@ func (uacGenerator *UacGenerator) ValidateUACs(uacs []string) error {
 	return nil
 }
 
+func (uacGenerator *UacGenerator) NormaliseUACs(uacs []string) []string {
+	return normaliseUACs(uacGenerator.uacFormat(), uacs)
+}
+
+func normaliseUACs(uacFormat UacFormat, uacs []string) []string {
+	normalisedUACs := make([]string, len(uacs))
+	for i, uac := range uacs {
+		normalisedUACs[i] = uacFormat.Normalise(uac)
+	}
+	return normalisedUACs
+}
+
+func uniqueUACs(uacs []string) []string {
+	var (
+		unique  []string
+		seenUAC = make(map[string]bool)
+	)
+	for _, uac := range uacs {
+		if !seenUAC[uac] {
+			seenUAC[uac] = true
+			unique = append(unique, uac)
+		}
+	}
+	return unique
+}
+
+// AdminDelete moves an instrument's UACs to its archive, from which they can be restored until they are purged
+// after ArchiveRetention. The archive keeps the instrument's validity, as deleting the instrument deletes it.
 func (uacGenerator *UacGenerator) AdminDelete(instrumentName string) error {
-	instrumentUACKeys, err := uacGenerator.DatastoreClient.GetAll(uacGenerator.Context, uacGenerator.instrumentQuery(instrumentName).KeysOnly(), nil)
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
 	if err != nil {
 		return err
 	}
-	if len(instrumentUACKeys) == 0 {
-		return nil
-	}
-	uacKeyChunks := chunkDatastoreKeys(instrumentUACKeys)
-	concurrent := goccm.New(MAXCONCURRENT)
-	for _, uacKeyChunk := range uacKeyChunks {
-		concurrent.Wait()
-		go func(uacKeyChunk []*datastore.Key) {
-			uacGenerator.adminDeleteChunk(uacKeyChunk, concurrent)
-		}(uacKeyChunk)
+	validity, err := uacGenerator.GetInstrumentValidity(instrumentName)
+	if err != nil {
+		return err
 	}
-	concurrent.WaitAllDone()
-	return nil
+	// Datastore keeps times to the microsecond
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return uacGenerator.Store.ArchiveInstrument(uacGenerator.Context, &InstrumentArchive{
+		InstrumentName: strings.ToLower(instrumentName),
+		UacKind:        uacKind,
+		DeletedAt:      now,
+		DeletedBy:      uacGenerator.Actor,
+		PurgeAfter:     now.Add(uacGenerator.ArchiveRetention),
+		ValidFrom:      validity.ValidFrom,
+		ValidUntil:     validity.ValidUntil,
+	}, func(uacInfo *UacInfo) *AuditEntry {
+		return uacGenerator.auditEntry(uacAuditEntry(AUDITADMINDELETE, uacInfo.UAC, uacInfo, nil))
+	})
 }
 
-func (uacGenerator *UacGenerator) getUACsToImport(uacs []string) ([]string, error) {
+func (uacGenerator *UacGenerator) getUACsToImport(uacKind string, uacs []string) ([]string, error) {
 	var (
 		uacsToImport []string
 		importError  ImportError
 		errors       []error
+		importMu     sync.Mutex
 	)
 
 	if len(uacs) == 0 {
@@ -426,25 +994,25 @@ func (uacGenerator *UacGenerator) getUACsToImport(uacs []string) ([]string, erro
 		concurrent.Wait()
 		go func(uac string) {
 			defer concurrent.Done()
-			uacInfo, err := uacGenerator.GetUacInfo(uac)
+			uacInfo, err := uacGenerator.getUacInfo(uacKind, uac)
 			if err == datastore.ErrNoSuchEntity {
-				uacGenerator.importMu.Lock()
+				importMu.Lock()
 				uacsToImport = append(uacsToImport, uac)
-				uacGenerator.importMu.Unlock()
+				importMu.Unlock()
 				return
 			}
 			if err != nil {
-				uacGenerator.importMu.Lock()
+				importMu.Lock()
 				errors = append(errors, err)
-				uacGenerator.importMu.Unlock()
+				importMu.Unlock()
 				return
 			}
 			if uacInfo.InstrumentName == UNKNOWNINSTRUMENT {
 				return
 			}
-			uacGenerator.importMu.Lock()
+			importMu.Lock()
 			importError.InstrumentUACs = append(importError.InstrumentUACs, uac)
-			uacGenerator.importMu.Unlock()
+			importMu.Unlock()
 		}(uac)
 	}
 	concurrent.WaitAllDone()
@@ -459,10 +1027,11 @@ func (uacGenerator *UacGenerator) getUACsToImport(uacs []string) ([]string, erro
 	return uacsToImport, nil
 }
 
-func (uacGenerator *UacGenerator) importUACs(uacs []string) (int, error) {
+func (uacGenerator *UacGenerator) importUACs(uacKind string, uacs []string) (int, error) {
 	var (
 		updateCount = 0
 		errors      []error
+		importMu    sync.Mutex
 	)
 
 	if len(uacs) == 0 {
@@ -474,16 +1043,20 @@ func (uacGenerator *UacGenerator) importUACs(uacs []string) (int, error) {
 		concurrent.Wait()
 		go func(uac string) {
 			defer concurrent.Done()
-			err := uacGenerator.AddUacToDatastore(uac, UNKNOWNINSTRUMENT, UNKNOWNINSTRUMENT)
+			uacInfo, err := uacGenerator.newCaseUacInfo(uacKind, uac, UNKNOWNINSTRUMENT, UNKNOWNINSTRUMENT, 0)
+			if err == nil {
+				err = uacGenerator.Store.InsertPoolUac(uacGenerator.Context, uacInfo,
+					uacGenerator.auditEntries(uacAuditEntry(AUDITIMPORT, uacInfo.UAC, nil, uacInfo)))
+			}
 			if err != nil {
-				uacGenerator.importMu.Lock()
+				importMu.Lock()
 				errors = append(errors, err)
-				uacGenerator.importMu.Unlock()
+				importMu.Unlock()
 				return
 			}
-			uacGenerator.importMu.Lock()
+			importMu.Lock()
 			updateCount++
-			uacGenerator.importMu.Unlock()
+			importMu.Unlock()
 		}(uac)
 	}
 	concurrent.WaitAllDone()
@@ -496,6 +1069,16 @@ func (uacGenerator *UacGenerator) importUACs(uacs []string) (int, error) {
 }
 
 func ChunkUAC(uac string) *UacChunks {
+	for _, kind := range UacKinds() {
+		uacFormat, _ := GetUacFormat(kind)
+		if uacFormat.Validate(uac) {
+			return uacFormat.Chunk(uac)
+		}
+	}
+	return chunkUAC(uac)
+}
+
+func chunkUAC(uac string) *UacChunks {
 	var chunks []string
 	runes := []rune(uac)
 
@@ -517,57 +1100,19 @@ func ChunkUAC(uac string) *UacChunks {
 	return uacChunks
 }
 
-func (uacGenerator *UacGenerator) adminDeleteChunk(uacKeyChunk []*datastore.Key, concurrent goccm.ConcurrencyManager) {
-	defer concurrent.Done()
-	err := uacGenerator.DatastoreClient.DeleteMulti(uacGenerator.Context, uacKeyChunk)
-	if err != nil {
-		log.Println(err)
+// uacFormat returns the format for the configured UacKind, falling back to
+// 12 digit UACs when the kind has not been registered.
+func (uacGenerator *UacGenerator) uacFormat() UacFormat {
+	if uacFormat, ok := GetUacFormat(uacGenerator.UacKind); ok {
+		return uacFormat
 	}
-}
-
-func (uacGenerator *UacGenerator) instrumentCaseQuery(instrumentName, caseID string) *datastore.Query {
-	query := datastore.NewQuery(uacGenerator.UacKind)
-	query = query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
-	return query.FilterField(strings.ToLower("case_id"), "=", strings.ToLower(caseID))
-}
-
-func (uacGenerator *UacGenerator) instrumentQuery(instrumentName string) *datastore.Query {
-	query := datastore.NewQuery(uacGenerator.UacKind)
-	return query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
-}
-
-func (uacGenerator *UacGenerator) instrumentUacDisabledQuery(instrumentName string) *datastore.Query {
-	query := datastore.NewQuery(uacGenerator.UacKind)
-
-	query = query.FilterField("instrument_name", "=", strings.ToLower(instrumentName))
-	return query.FilterField(strings.ToLower("disabled"), "=", true)
-}
-
-func (uacGenerator *UacGenerator) instrumentNamesQuery() *datastore.Query {
-	query := datastore.NewQuery(uacGenerator.UacKind)
-	query = query.Project("instrument_name")
-	return query.DistinctOn("instrument_name")
-}
-
-func chunkDatastoreKeys(keys []*datastore.Key) [][]*datastore.Key {
-	var (
-		chunks    [][]*datastore.Key
-		chunkSize = MAXCONCURRENT
-	)
-	for i := 0; i < len(keys); i += chunkSize {
-		end := i + chunkSize
-
-		if end > len(keys) {
-			end = len(keys)
-		}
-
-		chunks = append(chunks, keys[i:end])
-	}
-
-	return chunks
+	return Uac12Format{}
 }
 
 func alreadyExistsError(err error) bool {
+	if errors.Is(err, ErrUacExists) || errors.Is(err, ErrInstrumentHasKind) {
+		return true
+	}
 	if statusErr, ok := status.FromError(err); ok {
 		return statusErr.Code() == codes.AlreadyExists
 	}
@@ -0,0 +1,79 @@
+package uacgenerator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+const benchmarkCaseCount = 5000
+
+// Run with "go test ./uacgenerator -run none -bench Generate"
+func BenchmarkGenerate(b *testing.B) {
+	caseIDs := make([]string, benchmarkCaseCount)
+	for i := range caseIDs {
+		caseIDs[i] = fmt.Sprintf("%d", 100000+i)
+	}
+
+	b.Run("one case at a time", func(b *testing.B) {
+		var datastoreCalls int
+		for i := 0; i < b.N; i++ {
+			uacGenerator, mockDatastore, mockTransaction := benchmarkUacGenerator()
+			for _, caseID := range caseIDs {
+				if err := uacGenerator.GenerateUniqueUac("lolcat", caseID); err != nil {
+					b.Fatal(err)
+				}
+			}
+			datastoreCalls += len(mockDatastore.Calls) + len(mockTransaction.Calls)
+		}
+		b.ReportMetric(float64(datastoreCalls)/float64(b.N), "datastore-calls/op")
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		var datastoreCalls int
+		for i := 0; i < b.N; i++ {
+			uacGenerator, mockDatastore, mockTransaction := benchmarkUacGenerator()
+			result, err := uacGenerator.Generate("lolcat", caseIDs)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(result.Generated) != len(caseIDs) {
+				b.Fatalf("generated %d UACs, expected %d", len(result.Generated), len(caseIDs))
+			}
+			datastoreCalls += len(mockDatastore.Calls) + len(mockTransaction.Calls)
+		}
+		b.ReportMetric(float64(datastoreCalls)/float64(b.N), "datastore-calls/op")
+	})
+}
+
+func benchmarkUacGenerator() (*uacgenerator.UacGenerator, *mocks.Datastore, *mocks.Transaction) {
+	mockDatastore := &mocks.Datastore{}
+	uacGenerator := uacgenerator.NewUacGenerator(mockDatastore, "uac")
+
+	mockDatastore.On("Get",
+		uacGenerator.Context,
+		mock.AnythingOfType("*datastore.Key"),
+		mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+	).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+		*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+		return nil
+	})
+
+	mockDatastore.On("GetAll",
+		uacGenerator.Context,
+		mock.AnythingOfType("*datastore.Query"),
+		mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
+	).Return(nil, nil)
+	listCaseSlots(mockDatastore, nil)
+
+	mockTransaction := &mocks.Transaction{}
+	runTransactions(mockDatastore, mockTransaction)
+	mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
+
+	return uacGenerator, mockDatastore, mockTransaction
+}
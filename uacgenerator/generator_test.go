@@ -68,9 +68,10 @@ var _ = Describe("GenerateUac16", func() {
 
 var _ = Describe("NewUac", func() {
 	var (
-		uacGenerator   *uacgenerator.UacGenerator
-		instrumentName = "lolcat"
-		caseID         = "74628568"
+		uacGenerator    *uacgenerator.UacGenerator
+		instrumentName  = "lolcat"
+		caseID          = "74628568"
+		mockTransaction *mocks.Transaction
 	)
 
 	Context("Generation rules for 12 digit UAC", func() {
@@ -80,11 +81,18 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("Generates a random 12 digit UAC", func() {
@@ -105,11 +113,18 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac16")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("Generates a random 16 character alphanumeric UAC", func() {
@@ -129,11 +144,18 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("returns an error", func() {
@@ -150,11 +172,18 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "this is not a valid UWACKY")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("returns an error", func() {
@@ -179,21 +208,27 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
-				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Twice().Return(nil, status.Error(codes.AlreadyExists, "Already exists"))
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Twice().Return(status.Error(codes.AlreadyExists, "Already exists"))
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("Regenerates a new random UAC and saves it to datastore", func() {
 			_, err := uacGenerator.NewUac(instrumentName, caseID, 0)
 			Expect(err).ShouldNot(HaveOccurred())
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
 		})
 	})
 
@@ -203,17 +238,24 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("Saves the UAC to datastore", func() {
 			_, err := uacGenerator.NewUac(instrumentName, caseID, 0)
 			Expect(err).ShouldNot(HaveOccurred())
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
 		})
 	})
 
@@ -223,17 +265,24 @@ var _ = Describe("NewUac", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("Mutate",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, status.Error(codes.AlreadyExists, "Already exists"))
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(status.Error(codes.AlreadyExists, "Already exists"))
 		})
 
 		It("gives up generating a UAC and returns an error", func() {
 			uac, err := uacGenerator.NewUac(instrumentName, caseID, 0)
 			Expect(uac).To(Equal(""))
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 10)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 10)
 			Expect(err).To(MatchError("Could not generate a unique UAC in 10 attempts"))
 		})
 	})
@@ -263,15 +312,24 @@ var _ = Describe("UacExistsForCase", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
 				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
-			).Return([]*datastore.Key{datastore.IncompleteKey("foo", nil)}, nil)
+			).Return(func(ctx context.Context, qry *datastore.Query, dst interface{}) []*datastore.Key {
+				*dst.(*[]*uacgenerator.UacInfo) = []*uacgenerator.UacInfo{{InstrumentName: instrumentName, CaseID: caseID}}
+				return []*datastore.Key{datastore.IncompleteKey("foo", nil)}
+			}, nil)
 		})
 
 		It("returns true", func() {
-			exists, err := uacGenerator.UacExistsForCase(instrumentName, caseID)
+			exists, err := uacGenerator.UacExistsForCase(instrumentName, caseID, 0)
 
 			Expect(exists).To(BeTrue())
 			Expect(err).To(BeNil())
@@ -284,6 +342,12 @@ var _ = Describe("UacExistsForCase", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
@@ -292,7 +356,7 @@ var _ = Describe("UacExistsForCase", func() {
 		})
 
 		It("returns false", func() {
-			exists, err := uacGenerator.UacExistsForCase(instrumentName, caseID)
+			exists, err := uacGenerator.UacExistsForCase(instrumentName, caseID, 0)
 
 			Expect(exists).To(BeFalse())
 			Expect(err).To(BeNil())
@@ -311,7 +375,8 @@ var _ = Describe("Generate", func() {
 			"74628563",
 			"74628564",
 		}
-		mockDatastore *mocks.Datastore
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
 	)
 
 	Context("when none of the cases have a uac", func() {
@@ -319,24 +384,71 @@ var _ = Describe("Generate", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("GetAll",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Query"),
-				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
 
-			mockDatastore.On("Mutate",
-				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+			listCaseSlots(mockDatastore, nil)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("generates uacs for all case ids in an instrument", func() {
-			Expect(uacGenerator.Generate(instrumentName, caseIDs)).To(BeNil())
+			result, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(ConsistOf(caseIDs))
+			Expect(result.HasFailures()).To(BeFalse())
+
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", 1)
+			// A UAC, a case index and an audit entry for each case
+			Expect(mockTransaction.Calls[len(mockTransaction.Calls)-1].Arguments.Get(0)).To(HaveLen(3 * len(caseIDs)))
+		})
+	})
+
+	Context("when a UAC in the batch already exists", func() {
+		BeforeEach(func() {
+			mockDatastore = &mocks.Datastore{}
+
+			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
+
+			listCaseSlots(mockDatastore, nil)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Once().Return(status.Error(codes.AlreadyExists, "Already exists"))
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
+		})
+
+		It("retries each case on its own", func() {
+			result, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(ConsistOf(caseIDs))
 
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", len(caseIDs))
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", len(caseIDs))
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", len(caseIDs)+1)
 		})
 	})
 
@@ -345,61 +457,208 @@ var _ = Describe("Generate", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("GetAll",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Query"),
-				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
-			).Return(nil, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
 
-			mockDatastore.On("Mutate",
+			listCaseSlots(mockDatastore, nil)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Once().Return(status.Error(codes.AlreadyExists, "Already exists"))
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Once().Return(nil)
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Once().Return(fmt.Errorf("Massive mutation explosion"))
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
+		})
+
+		It("reports the failed case and carries on with the others", func() {
+			result, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			Expect(result.HasFailures()).To(BeTrue())
+			Expect(result.Failed).To(HaveLen(1))
+			Expect(result.Failed[0].Error).To(Equal("Massive mutation explosion"))
+			Expect(caseIDs).To(ContainElement(result.Failed[0].CaseID))
+			Expect(result.Generated).To(HaveLen(len(caseIDs) - 1))
+			Expect(result.Generated).ToNot(ContainElement(result.Failed[0].CaseID))
+		})
+	})
+
+	Context("when the batch insert errors", func() {
+		BeforeEach(func() {
+			mockDatastore = &mocks.Datastore{}
+
+			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Once().Return(nil, nil)
-			mockDatastore.On("Mutate",
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
+
+			listCaseSlots(mockDatastore, nil)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(fmt.Errorf("Massive mutation explosion"))
+		})
+
+		It("reports every case in the batch as failed", func() {
+			result, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			Expect(result.Failed).To(HaveLen(len(caseIDs)))
+			Expect(result.Generated).To(BeEmpty())
+
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+		})
+	})
+
+	Context("when one of the cases already has a uac", func() {
+		BeforeEach(func() {
+			mockDatastore = &mocks.Datastore{}
+
+			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Once().Return(nil, fmt.Errorf("Massive mutation explosion"))
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
+
+			// One case has a case index entity, and another a UAC from before case index entities were introduced
+			listCaseSlots(mockDatastore,
+				map[string]*uacgenerator.CaseUac{"74628568": {UacKind: "uac", UAC: "123412341234"}},
+				datastore.NameKey("uac", "123412341234", nil),
+				datastore.NameKey("uac", "234523452345", nil),
+			)
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				datastore.NameKey("uac", "234523452345", nil),
+				mock.AnythingOfType("*uacgenerator.UacInfo"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: instrumentName, CaseID: "74628561"}
+				return nil
+			})
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
+		})
+
+		It("generates uacs for all case ids in an instrument", func() {
+			result, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			Expect(result.Existing).To(Equal([]string{"74628561", "74628568"}))
+			Expect(result.Generated).To(ConsistOf(caseIDs[2:]))
+
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", 1)
+			// Only the UAC missing from the case index is loaded
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 2)
+			Expect(mockTransaction.Calls[len(mockTransaction.Calls)-1].Arguments.Get(0)).To(HaveLen(3 * (len(caseIDs) - 2)))
+		})
+	})
+
+	Context("when the instrument has not recorded a UAC kind", func() {
+		BeforeEach(func() {
+			mockDatastore = &mocks.Datastore{}
+
+			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac16")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
+			listCaseSlots(mockDatastore, nil)
+
 			mockDatastore.On("Mutate",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
+				mock.AnythingOfType("[]*datastore.Mutation"),
 			).Return(nil, nil)
+
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
-		It("returns an error", func() {
-			Expect(uacGenerator.Generate(instrumentName, caseIDs)).To(MatchError("Massive mutation explosion"))
+		It("records the default UAC kind against the instrument and generates uacs", func() {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+
+			// The kind with its audit entry
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", 1)
 		})
 	})
 
-	Context("when one of the cases already has a uac", func() {
+	Context("when the instrument has recorded a different UAC kind", func() {
 		BeforeEach(func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
 
-			mockDatastore.On("GetAll",
+			mockDatastore.On("Get",
 				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Query"),
-				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
-			).Once().Return([]*datastore.Key{datastore.IncompleteKey("foo", nil)}, nil)
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac16"}
+				return nil
+			})
 
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
 				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
 			).Return(nil, nil)
+			listCaseSlots(mockDatastore, nil)
 
-			mockDatastore.On("Mutate",
-				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
-		It("generates uacs for all case ids in an instrument", func() {
-			Expect(uacGenerator.Generate(instrumentName, caseIDs)).To(BeNil())
+		It("generates uacs of the instrument's kind", func() {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs[:1])
+			Expect(err).To(BeNil())
 
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", len(caseIDs)-1)
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", len(caseIDs))
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			uacs, err := uacGenerator.GetAllUacs(instrumentName)
+			Expect(err).To(BeNil())
+			Expect(uacs).To(BeEmpty())
+			Expect(mockDatastore.Calls[len(mockDatastore.Calls)-1].Arguments.Get(1).(*datastore.Query)).To(Equal(
+				datastore.NewQuery("uac16").FilterField("instrument_name", "=", instrumentName),
+			))
 		})
 	})
 
@@ -408,6 +667,17 @@ var _ = Describe("Generate", func() {
 			mockDatastore = &mocks.Datastore{}
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+			mockTransaction = &mocks.Transaction{}
+			runTransactions(mockDatastore, mockTransaction)
+
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
 
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
@@ -415,16 +685,17 @@ var _ = Describe("Generate", func() {
 				mock.AnythingOfType("*[]*uacgenerator.UacInfo"),
 			).Return(nil, nil)
 
-			mockDatastore.On("Mutate",
-				uacGenerator.Context,
-				mock.AnythingOfType("*datastore.Mutation"),
-			).Return(nil, nil)
+			mockTransaction.On("Mutate",
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil)
 		})
 
 		It("generates uacs for all case ids in an instrument", func() {
-			Expect(uacGenerator.Generate(instrumentName, []string{})).To(BeNil())
+			result, err := uacGenerator.Generate(instrumentName, []string{})
+			Expect(err).To(BeNil())
+			Expect(*result).To(Equal(*uacgenerator.NewGenerateResult()))
 
-			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
 			mockDatastore.AssertNumberOfCalls(GinkgoT(), "GetAll", 0)
 		})
 	})
@@ -442,6 +713,12 @@ var _ = Describe("GetAllUacs", func() {
 
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("GetAll",
 			uacGenerator.Context,
 			mock.AnythingOfType("*datastore.Query"),
@@ -492,6 +769,12 @@ var _ = Describe("GetAllUacs", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
@@ -531,6 +814,12 @@ var _ = Describe("GetAllUacs", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
@@ -581,6 +870,12 @@ var _ = Describe("GetUacCount", func() {
 
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("Count",
 			uacGenerator.Context,
 			mock.AnythingOfType("*datastore.Query"),
@@ -596,16 +891,24 @@ var _ = Describe("GetUacCount", func() {
 
 var _ = Describe("GetUacInfo", func() {
 	var (
-		uacGenerator   *uacgenerator.UacGenerator
-		instrumentName = "lolcat"
-		mockDatastore  *mocks.Datastore
+		uacGenerator    *uacgenerator.UacGenerator
+		instrumentName  = "lolcat"
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
 	)
 
 	BeforeEach(func() {
 		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
 
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("Get",
 			uacGenerator.Context,
 			mock.AnythingOfType("*datastore.Key"),
@@ -621,6 +924,21 @@ var _ = Describe("GetUacInfo", func() {
 				}
 				return nil
 			})
+
+		mockDatastore.On("RunInTransaction",
+			uacGenerator.Context,
+			mock.Anything,
+		).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+			return f(mockTransaction)
+		})
+		mockTransaction.On("Get",
+			uacGenerator.UacKey("lemons"),
+			mock.AnythingOfType("*uacgenerator.UacInfo"),
+		).Return(func(key *datastore.Key, dst interface{}) error {
+			*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: instrumentName, CaseID: "12343"}
+			return nil
+		})
+		mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
 	})
 
 	It("Returns the uac info for a valid uac key", func() {
@@ -629,6 +947,14 @@ var _ = Describe("GetUacInfo", func() {
 		Expect(uacInfo.CaseID).To(Equal("12343"))
 		Expect(err).To(BeNil())
 	})
+
+	It("records when the UAC was first looked up", func() {
+		_, err := uacGenerator.GetUacInfo("lemons")
+		Expect(err).To(BeNil())
+		mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+		mutations := mockTransaction.Calls[len(mockTransaction.Calls)-1].Arguments.Get(0).([]*datastore.Mutation)
+		Expect(mutations).To(HaveLen(1))
+	})
 })
 
 var _ = Describe("GetInstruments", func() {
@@ -642,6 +968,12 @@ var _ = Describe("GetInstruments", func() {
 
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("GetAll",
 			uacGenerator.Context,
 			mock.AnythingOfType("*datastore.Query"),
@@ -704,9 +1036,15 @@ var _ = Describe("ImportUacs", func() {
 		mockDatastore = &mocks.Datastore{}
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("Mutate",
 			uacGenerator.Context,
-			mock.AnythingOfType("*datastore.Mutation"),
+			mock.AnythingOfType("[]*datastore.Mutation"),
 		).Return(nil, nil)
 	})
 
@@ -743,6 +1081,7 @@ var _ = Describe("ImportUacs", func() {
 				updateCount, err := uacGenerator.ImportUACs(uacs)
 				Expect(updateCount).To(Equal(3))
 				Expect(err).To(BeNil())
+				// Each UAC with its audit entry
 				mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
 			})
 		})
@@ -965,6 +1304,12 @@ var _ = Describe("GetDisabledUacs", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
@@ -1006,6 +1351,12 @@ var _ = Describe("GetDisabledUacs", func() {
 
 			uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+
 			mockDatastore.On("GetAll",
 				uacGenerator.Context,
 				mock.AnythingOfType("*datastore.Query"),
@@ -1050,18 +1401,26 @@ var _ = Describe("GetDisabledUacs", func() {
 
 var _ = Describe("EnableUAC", func() {
 	var (
-		uacGenerator  *uacgenerator.UacGenerator
-		mockDatastore *mocks.Datastore
-		uac           string
+		uacGenerator    *uacgenerator.UacGenerator
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
+		uac             string
 	)
 
 	BeforeEach(func() {
 		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("Mutate",
 			uacGenerator.Context,
-			mock.AnythingOfType("*datastore.Mutation"),
+			mock.AnythingOfType("[]*datastore.Mutation"),
 		).Return(nil, nil)
 	})
 
@@ -1091,12 +1450,28 @@ var _ = Describe("EnableUAC", func() {
 					}
 					return nil
 				})
+				mockDatastore.On("RunInTransaction",
+					uacGenerator.Context,
+					mock.Anything,
+				).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+					return f(mockTransaction)
+				})
+				mockTransaction.On("Get",
+					mock.AnythingOfType("*datastore.Key"),
+					mock.AnythingOfType("*uacgenerator.UacInfo"),
+				).Return(func(key *datastore.Key, dst interface{}) error {
+					*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "dst2108a", CaseID: "1234", Disabled: false}
+					return nil
+				})
+				mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
 			})
 
 			It("enables the UAC", func() {
 				err := uacGenerator.EnableUac(uac)
 				Expect(err).To(BeNil())
-				mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+				// The audit entry is added in the transaction
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+				mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
 			})
 		})
 	})
@@ -1126,18 +1501,26 @@ var _ = Describe("EnableUAC", func() {
 
 var _ = Describe("DisableUAC", func() {
 	var (
-		uacGenerator  *uacgenerator.UacGenerator
-		mockDatastore *mocks.Datastore
-		uac           string
+		uacGenerator    *uacgenerator.UacGenerator
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
+		uac             string
 	)
 
 	BeforeEach(func() {
 		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
 		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
 
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
 		mockDatastore.On("Mutate",
 			uacGenerator.Context,
-			mock.AnythingOfType("*datastore.Mutation"),
+			mock.AnythingOfType("[]*datastore.Mutation"),
 		).Return(nil, nil)
 	})
 
@@ -1167,12 +1550,28 @@ var _ = Describe("DisableUAC", func() {
 					}
 					return nil
 				})
+				mockDatastore.On("RunInTransaction",
+					uacGenerator.Context,
+					mock.Anything,
+				).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+					return f(mockTransaction)
+				})
+				mockTransaction.On("Get",
+					mock.AnythingOfType("*datastore.Key"),
+					mock.AnythingOfType("*uacgenerator.UacInfo"),
+				).Return(func(key *datastore.Key, dst interface{}) error {
+					*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "dst2108a", CaseID: "1234", Disabled: true}
+					return nil
+				})
+				mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
 			})
 
 			It("disables the UAC", func() {
 				err := uacGenerator.DisableUac(uac)
 				Expect(err).To(BeNil())
-				mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+				// The audit entry is added in the transaction
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+				mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
 			})
 		})
 	})
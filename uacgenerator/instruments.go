@@ -0,0 +1,104 @@
+package uacgenerator
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+const INSTRUMENTKIND = "instrument"
+
+type InstrumentConfig struct {
+	InstrumentName string `json:"instrument_name" datastore:"-"`
+	UacKind        string `json:"uac_kind" datastore:"uac_kind"`
+	// ValidFrom and ValidUntil are when the instrument's UACs can be used, unless a UAC has validity of its own.
+	ValidFrom  time.Time `json:"valid_from,omitzero" datastore:"valid_from,omitempty,noindex"`
+	ValidUntil time.Time `json:"valid_until,omitzero" datastore:"valid_until,omitempty,noindex"`
+}
+
+// EnabledUacKinds returns the registered kinds this service issues and looks up, starting with the default kind.
+func (uacGenerator *UacGenerator) EnabledUacKinds() []string {
+	var uacKinds []string
+	for _, uacKind := range append([]string{uacGenerator.UacKind}, uacGenerator.UacKinds...) {
+		if _, ok := GetUacFormat(uacKind); !ok || containsString(uacKinds, uacKind) {
+			continue
+		}
+		uacKinds = append(uacKinds, uacKind)
+	}
+	return uacKinds
+}
+
+func (uacGenerator *UacGenerator) uacKindEnabled(uacKind string) bool {
+	return containsString(uacGenerator.EnabledUacKinds(), uacKind)
+}
+
+// GetInstrumentUacKind returns the UAC kind recorded for an instrument, or the default
+// kind if the instrument has not recorded one.
+func (uacGenerator *UacGenerator) GetInstrumentUacKind(instrumentName string) (string, error) {
+	instrumentConfig, err := uacGenerator.Store.GetInstrumentConfig(uacGenerator.Context, instrumentName)
+	if err == datastore.ErrNoSuchEntity {
+		return uacGenerator.UacKind, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return instrumentConfig.UacKind, nil
+}
+
+// SetInstrumentUacKind records the UAC kind for an instrument. The kind cannot be
+// changed once the instrument has UACs of another kind.
+func (uacGenerator *UacGenerator) SetInstrumentUacKind(instrumentName, uacKind string) error {
+	if !uacGenerator.uacKindEnabled(uacKind) {
+		return ErrInvalidUacKind
+	}
+	currentUacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return err
+	}
+	if currentUacKind != uacKind {
+		uacCount, err := uacGenerator.Store.CountUacs(uacGenerator.Context, currentUacKind, instrumentName)
+		if err != nil {
+			return err
+		}
+		if uacCount > 0 {
+			return ErrInstrumentHasUacs
+		}
+	}
+	return uacGenerator.Store.SaveInstrumentConfig(uacGenerator.Context, instrumentName, uacKind,
+		uacGenerator.auditEntries(uacKindAuditEntry(instrumentName, currentUacKind, uacKind)))
+}
+
+// recordInstrumentUacKind returns the UAC kind for an instrument, recording the
+// default kind against the instrument if it does not have one yet.
+func (uacGenerator *UacGenerator) recordInstrumentUacKind(instrumentName string) (string, error) {
+	instrumentConfig, err := uacGenerator.Store.GetInstrumentConfig(uacGenerator.Context, instrumentName)
+	if err == nil {
+		return instrumentConfig.UacKind, nil
+	}
+	if err != datastore.ErrNoSuchEntity {
+		return "", err
+	}
+	err = uacGenerator.Store.InsertInstrumentConfig(uacGenerator.Context, instrumentName, uacGenerator.UacKind,
+		uacGenerator.auditEntries(uacKindAuditEntry(instrumentName, "", uacGenerator.UacKind)))
+	if err != nil {
+		if alreadyExistsError(err) {
+			return uacGenerator.GetInstrumentUacKind(instrumentName)
+		}
+		return "", err
+	}
+	return uacGenerator.UacKind, nil
+}
+
+func instrumentKey(instrumentName string) *datastore.Key {
+	return datastore.NameKey(INSTRUMENTKIND, strings.ToLower(instrumentName), nil)
+}
+
+func containsString(values []string, value string) bool {
+	for _, existingValue := range values {
+		if existingValue == value {
+			return true
+		}
+	}
+	return false
+}
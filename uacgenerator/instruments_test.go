@@ -0,0 +1,239 @@
+package uacgenerator_test
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("EnabledUacKinds", func() {
+	It("starts with the default kind and skips unregistered and duplicate kinds", func() {
+		uacGenerator := &uacgenerator.UacGenerator{
+			UacKind:  "uac16",
+			UacKinds: []string{"uac", "uac16", "not a kind", "uaccheck"},
+		}
+		Expect(uacGenerator.EnabledUacKinds()).To(Equal([]string{"uac16", "uac", "uaccheck"}))
+	})
+})
+
+var _ = Describe("GetInstrumentUacKind", func() {
+	var (
+		uacGenerator  *uacgenerator.UacGenerator
+		mockDatastore *mocks.Datastore
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+	})
+
+	Context("when the instrument has recorded a UAC kind", func() {
+		BeforeEach(func() {
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				datastore.NameKey("instrument", "lolcat", nil),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac16"}
+				return nil
+			})
+		})
+
+		It("returns the recorded kind", func() {
+			uacKind, err := uacGenerator.GetInstrumentUacKind("LOLcat")
+			Expect(err).To(BeNil())
+			Expect(uacKind).To(Equal("uac16"))
+		})
+	})
+
+	Context("when the instrument has not recorded a UAC kind", func() {
+		BeforeEach(func() {
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(datastore.ErrNoSuchEntity)
+		})
+
+		It("returns the default kind", func() {
+			uacKind, err := uacGenerator.GetInstrumentUacKind("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacKind).To(Equal("uac"))
+		})
+	})
+})
+
+var _ = Describe("SetInstrumentUacKind", func() {
+	var (
+		uacGenerator  *uacgenerator.UacGenerator
+		mockDatastore *mocks.Datastore
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+		uacGenerator.UacKinds = []string{"uac16"}
+
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+
+		mockDatastore.On("Mutate",
+			uacGenerator.Context,
+			mock.AnythingOfType("[]*datastore.Mutation"),
+		).Return(nil, nil)
+		// Saves the kind, keeping the instrument's validity
+		mockDatastore.On("RunInTransaction", uacGenerator.Context, mock.Anything).Return(nil)
+	})
+
+	Context("when the kind is not enabled", func() {
+		It("returns an error", func() {
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uaccheck")).To(Equal(uacgenerator.ErrInvalidUacKind))
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+		})
+	})
+
+	Context("when the instrument has no UACs", func() {
+		BeforeEach(func() {
+			mockDatastore.On("Count",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Query"),
+			).Return(0, nil)
+		})
+
+		It("records the kind", func() {
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac16")).To(BeNil())
+			// The audit entry is added in the transaction that records the kind
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "RunInTransaction", 1)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+		})
+	})
+
+	Context("when the instrument already has UACs of another kind", func() {
+		BeforeEach(func() {
+			mockDatastore.On("Count",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Query"),
+			).Return(20, nil)
+		})
+
+		It("returns an error", func() {
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac16")).To(Equal(uacgenerator.ErrInstrumentHasUacs))
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+		})
+	})
+
+	Context("when the kind is unchanged", func() {
+		It("records the kind without counting UACs", func() {
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac")).To(BeNil())
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Count", 0)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "RunInTransaction", 1)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+		})
+	})
+})
+
+var _ = Describe("GetUacInfo with several enabled kinds", func() {
+	var (
+		uacGenerator  *uacgenerator.UacGenerator
+		mockDatastore *mocks.Datastore
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+		uacGenerator.UacKinds = []string{"uac16"}
+
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			datastore.NameKey("uac16", "23kl56mn78fd42bn", nil),
+			mock.AnythingOfType("*uacgenerator.UacInfo"),
+		).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+			*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "lolcat", CaseID: "12343", UAC: key}
+			return nil
+		})
+		// Records the first lookup of the UAC
+		mockDatastore.On("RunInTransaction", uacGenerator.Context, mock.Anything).Return(nil)
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.UacInfo"),
+		).Return(datastore.ErrNoSuchEntity)
+		// The instrument has no validity
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(datastore.ErrNoSuchEntity)
+	})
+
+	It("looks up the UAC in the kind matching its shape", func() {
+		uacInfo, err := uacGenerator.GetUacInfo("23kl56mn78fd42bn")
+		Expect(err).To(BeNil())
+		Expect(uacInfo.CaseID).To(Equal("12343"))
+		Expect(uacInfo.UAC.Kind).To(Equal("uac16"))
+		Expect(uacInfo.Status).To(Equal(uacgenerator.UACACTIVE))
+		// The UAC and its instrument
+		mockDatastore.AssertNumberOfCalls(GinkgoT(), "Get", 2)
+	})
+
+	It("returns no such entity when the UAC is not found", func() {
+		_, err := uacGenerator.GetUacInfo("123412341234")
+		Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		mockDatastore.AssertCalled(GinkgoT(), "Get", uacGenerator.Context, datastore.NameKey("uac", "123412341234", nil), mock.Anything)
+	})
+})
+
+var _ = Describe("Changing an instrument's UAC kind while its cases are given UACs", func() {
+	kindChangeSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			uacGenerator.UacKinds = []string{"uac16"}
+		})
+
+		It("does not record another kind once a case has a UAC", func() {
+			_, err := uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+
+			err = uacGenerator.Store.SaveInstrumentConfig(uacGenerator.Context, "lolcat", "uac16", nil)
+			Expect(err).To(MatchError(uacgenerator.ErrInstrumentHasUacs))
+			Expect(uacGenerator.GetInstrumentUacKind("lolcat")).To(Equal("uac"))
+		})
+
+		It("does not give cases UACs of the kind that was replaced", func() {
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac")).To(Succeed())
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac16")).To(Succeed())
+
+			uacInfo := &uacgenerator.UacInfo{InstrumentName: "lolcat", CaseID: "1", UAC: uacGenerator.UacKey("123412341234")}
+			Expect(uacGenerator.Store.InsertCaseUac(uacGenerator.Context, uacInfo, nil)).To(MatchError(uacgenerator.ErrInstrumentUacKindChanged))
+			Expect(uacGenerator.Store.InsertCaseUacs(uacGenerator.Context, []*uacgenerator.UacInfo{uacInfo}, nil)).To(MatchError(uacgenerator.ErrInstrumentUacKindChanged))
+
+			Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+			_, err := uacGenerator.Store.ClaimPoolUac(uacGenerator.Context, uacGenerator.UacKey("123412341234"), "lolcat", "1", 0, nil)
+			Expect(err).To(MatchError(uacgenerator.ErrInstrumentUacKindChanged))
+
+			uacCount, err := uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacCount).To(Equal(0))
+		})
+	}
+
+	Context("with Datastore", func() {
+		kindChangeSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		kindChangeSpecs(newSQLUacGenerator)
+	})
+})
@@ -0,0 +1,362 @@
+package uacgenerator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	JOBKIND      = "generation_job"
+	JOBCASESKIND = "generation_job_cases"
+	// JOBBATCHSIZE is the number of case slots generated between saves of a job's progress.
+	JOBBATCHSIZE = 500
+	// MAXJOBFAILURES is the most failed case slots listed in a job, which keeps the job well under Datastore's
+	// entity size limit however many of its cases fail. Failed still counts every one.
+	MAXJOBFAILURES = 1000
+	// JOBCASECHUNKSIZE is the most cases of a job saved in one entity, which keeps each well under Datastore's entity
+	// size limit.
+	JOBCASECHUNKSIZE = 5000
+	// JOBLEASE is how long a running job is left to the instance running it after it last saved its progress. A job
+	// whose lease has expired, as the instance running it has stopped, is resumed by the next sweep of any instance.
+	JOBLEASE = 5 * time.Minute
+)
+
+const (
+	JOBRUNNING               = "running"
+	JOBCOMPLETED             = "completed"
+	JOBCOMPLETEDWITHFAILURES = "completed_with_failures"
+	JOBFAILED                = "failed"
+	JOBCANCELLED             = "cancelled"
+)
+
+// GenerationJob records the progress of generating UACs for an instrument in the background.
+// Jobs are saved in the UacStore with their cases, so any instance can report on, cancel or resume a job started by
+// another. Its counts are of the slots of the job's cases, of which each case has one unless it needs more UACs.
+// Only the first MAXJOBFAILURES failed case slots are listed in FailedCases.
+type GenerationJob struct {
+	ID              string        `json:"id" datastore:"-"`
+	InstrumentName  string        `json:"instrument_name" datastore:"instrument_name"`
+	Status          string        `json:"status" datastore:"status"`
+	ClaimFromPool   bool          `json:"claim_pool" datastore:"claim_pool,noindex"`
+	UacsPerCase     int           `json:"uacs_per_case,omitempty" datastore:"uacs_per_case,omitempty,noindex"`
+	Total           int           `json:"total" datastore:"total,noindex"`
+	Processed       int           `json:"processed" datastore:"processed,noindex"`
+	Created         int           `json:"created" datastore:"created,noindex"`
+	FromPool        int           `json:"from_pool" datastore:"from_pool,noindex"`
+	Existing        int           `json:"existing" datastore:"existing,noindex"`
+	Failed          int           `json:"failed" datastore:"failed,noindex"`
+	FailedCases     []CaseFailure `json:"failed_cases,omitempty" datastore:"failed_cases,noindex"`
+	Error           string        `json:"error,omitempty" datastore:"error,noindex"`
+	CancelRequested bool          `json:"cancel_requested" datastore:"cancel_requested,noindex"`
+	// StartedBy is the actor the job's changes are recorded against in the audit trail, whichever instance runs it.
+	StartedBy string `json:"started_by,omitempty" datastore:"started_by,omitempty,noindex"`
+	// RunnerID identifies the run of the job that holds its lease until LeaseExpires. A run stops once another has
+	// taken over the job.
+	RunnerID     string    `json:"-" datastore:"runner_id,noindex"`
+	LeaseExpires time.Time `json:"lease_expires" datastore:"lease_expires,noindex"`
+	// Resumed counts the times the job has been resumed after the instance running it stopped.
+	Resumed   int       `json:"resumed" datastore:"resumed,noindex"`
+	StartedAt time.Time `json:"started_at" datastore:"started_at"`
+	UpdatedAt time.Time `json:"updated_at" datastore:"updated_at,noindex"`
+}
+
+// JobCase is a case a generation job gives UACs, with the number of UACs it needs when GenerateOptions.CaseUacs
+// overrides it, and 0 otherwise.
+type JobCase struct {
+	CaseID string
+	Uacs   int
+}
+
+// jobCaseChunk is a chunk of up to JOBCASECHUNKSIZE of a job's cases, saved in Datastore under the job.
+type jobCaseChunk struct {
+	CaseIDs []string `datastore:"case_ids,noindex"`
+	Uacs    []int    `datastore:"uacs,noindex"`
+}
+
+// StartGenerationJob saves a new job with its cases and generates UACs for them in the background.
+func (uacGenerator *UacGenerator) StartGenerationJob(instrumentName string, caseIDs []string, options GenerateOptions) (*GenerationJob, error) {
+	err := options.Validate()
+	if err != nil {
+		return nil, err
+	}
+	jobID, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	runnerID, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := &GenerationJob{
+		ID:             jobID,
+		InstrumentName: instrumentName,
+		Status:         JOBRUNNING,
+		ClaimFromPool:  options.ClaimFromPool,
+		UacsPerCase:    options.UacsPerCase,
+		Total:          len(options.caseSlots(caseIDs)),
+		StartedBy:      uacGenerator.Actor,
+		RunnerID:       runnerID,
+		LeaseExpires:   now.Add(JOBLEASE),
+		StartedAt:      now,
+		UpdatedAt:      now,
+	}
+	err = uacGenerator.Store.InsertGenerationJob(uacGenerator.Context, job, jobCases(caseIDs, options))
+	if err != nil {
+		return nil, err
+	}
+	started := *job
+	go uacGenerator.runGenerationJob(job, caseIDs, options)
+	return &started, nil
+}
+
+// RunGenerationJob generates UACs for the case slots of a job from the first it has not processed, saving its
+// progress and renewing its lease after every batch of slots. The job stops after the current batch once a
+// cancellation has been requested, or once another run has taken it over. Slots processed after the last save of a
+// run that stopped are counted as existing when the job is resumed.
+func (uacGenerator *UacGenerator) RunGenerationJob(job *GenerationJob, caseIDs []string, options GenerateOptions) error {
+	uacKind, err := uacGenerator.recordInstrumentUacKind(job.InstrumentName)
+	if err != nil {
+		return uacGenerator.failGenerationJob(job, err)
+	}
+	caseSlots := options.caseSlots(caseIDs)
+	var pool *uacPool
+	if options.ClaimFromPool {
+		pool, err = uacGenerator.newUacPool(uacKind, len(caseSlots)-job.Processed)
+		if err != nil {
+			return uacGenerator.failGenerationJob(job, err)
+		}
+	}
+	existingCaseSlots, err := uacGenerator.instrumentCaseSlots(uacKind, job.InstrumentName)
+	if err != nil {
+		return uacGenerator.failGenerationJob(job, err)
+	}
+	for start := job.Processed; start < len(caseSlots); start += JOBBATCHSIZE {
+		end := start + JOBBATCHSIZE
+		if end > len(caseSlots) {
+			end = len(caseSlots)
+		}
+		uacGenerator.generateCases(uacKind, job.InstrumentName, caseSlots[start:end], existingCaseSlots, pool, func(caseSlot string, source uacSource, err error) {
+			job.Processed++
+			if err != nil {
+				job.Failed++
+				if len(job.FailedCases) < MAXJOBFAILURES {
+					job.FailedCases = append(job.FailedCases, CaseFailure{CaseID: caseSlot, Error: err.Error()})
+				}
+				return
+			}
+			switch source {
+			case uacExisted:
+				job.Existing++
+			case uacFromPool:
+				job.FromPool++
+				job.Created++
+			case uacGenerated:
+				job.Created++
+			}
+		})
+		err = uacGenerator.saveGenerationJob(job)
+		if err != nil {
+			return err
+		}
+		if job.Status == JOBCANCELLED {
+			return nil
+		}
+	}
+	job.Status = JOBCOMPLETED
+	if job.Failed > 0 {
+		job.Status = JOBCOMPLETEDWITHFAILURES
+	}
+	return uacGenerator.saveGenerationJob(job)
+}
+
+func (uacGenerator *UacGenerator) GetGenerationJob(jobID string) (*GenerationJob, error) {
+	return uacGenerator.Store.GetGenerationJob(uacGenerator.Context, jobID)
+}
+
+// CancelGenerationJob asks a running job to stop. The job is cancelled by whichever instance is running it
+// once it finishes its current batch of cases.
+func (uacGenerator *UacGenerator) CancelGenerationJob(jobID string) (*GenerationJob, error) {
+	return uacGenerator.Store.UpdateGenerationJob(uacGenerator.Context, jobID, func(job *GenerationJob) error {
+		if job.Status != JOBRUNNING {
+			return ErrJobFinished
+		}
+		job.CancelRequested = true
+		job.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// ResumeGenerationJobs resumes every running job whose lease has expired, returning the IDs of the jobs resumed.
+func (uacGenerator *UacGenerator) ResumeGenerationJobs() ([]string, error) {
+	jobs, err := uacGenerator.Store.ListRunningGenerationJobs(uacGenerator.Context)
+	if err != nil {
+		return nil, err
+	}
+	resumed := []string{}
+	now := time.Now()
+	for _, job := range jobs {
+		if now.Before(job.LeaseExpires) {
+			continue
+		}
+		jobResumed, err := uacGenerator.resumeGenerationJob(job.ID)
+		if err != nil {
+			return resumed, err
+		}
+		if jobResumed {
+			resumed = append(resumed, job.ID)
+		}
+	}
+	return resumed, nil
+}
+
+// SweepGenerationJobs resumes abandoned jobs straight away and then every interval, until ctx is done.
+func (uacGenerator *UacGenerator) SweepGenerationJobs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		resumed, err := uacGenerator.ResumeGenerationJobs()
+		if err != nil {
+			log.Printf("Could not resume generation jobs: %s", err)
+		}
+		if len(resumed) > 0 {
+			log.Printf("Resumed generation jobs %v", resumed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resumeGenerationJob takes over a running job whose lease has expired and runs it in the background from the first
+// case slot it had not processed, reporting whether it did. A job whose cancellation was requested is cancelled
+// instead, as the run it was requested of has stopped.
+func (uacGenerator *UacGenerator) resumeGenerationJob(jobID string) (bool, error) {
+	runnerID, err := newJobID()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	resumed := false
+	job, err := uacGenerator.Store.UpdateGenerationJob(uacGenerator.Context, jobID, func(job *GenerationJob) error {
+		if job.Status != JOBRUNNING || now.Before(job.LeaseExpires) {
+			return nil
+		}
+		job.UpdatedAt = now
+		if job.CancelRequested {
+			job.Status = JOBCANCELLED
+			return nil
+		}
+		job.RunnerID = runnerID
+		job.LeaseExpires = now.Add(JOBLEASE)
+		job.Resumed++
+		resumed = true
+		return nil
+	})
+	if err != nil || !resumed {
+		return false, err
+	}
+	cases, err := uacGenerator.Store.ListGenerationJobCases(uacGenerator.Context, jobID)
+	if err != nil {
+		return false, err
+	}
+	caseIDs, options := jobCaseOptions(job, cases)
+	log.Printf("Resuming generation job %s from case slot %d of %d", jobID, job.Processed, job.Total)
+	runner := *uacGenerator
+	runner.Actor = job.StartedBy
+	running := *job
+	go runner.runGenerationJob(&running, caseIDs, options)
+	return true, nil
+}
+
+// runGenerationJob runs a job in the background, logging why it stopped when it did not finish.
+func (uacGenerator *UacGenerator) runGenerationJob(job *GenerationJob, caseIDs []string, options GenerateOptions) {
+	err := uacGenerator.RunGenerationJob(job, caseIDs, options)
+	if err != nil {
+		log.Printf("Generation job %s: %s", job.ID, err)
+	}
+}
+
+func (uacGenerator *UacGenerator) failGenerationJob(job *GenerationJob, err error) error {
+	job.Status = JOBFAILED
+	job.Error = err.Error()
+	saveErr := uacGenerator.saveGenerationJob(job)
+	if saveErr != nil {
+		log.Println(saveErr)
+	}
+	return err
+}
+
+// saveGenerationJob saves the progress of a job, picking up any cancellation requested since it was last saved, and
+// renews its lease while it is running. A running job with a cancellation request is saved as cancelled. It returns
+// ErrJobTakenOver, saving nothing, once another run holds the job's lease.
+func (uacGenerator *UacGenerator) saveGenerationJob(job *GenerationJob) error {
+	_, err := uacGenerator.Store.UpdateGenerationJob(uacGenerator.Context, job.ID, func(savedJob *GenerationJob) error {
+		if savedJob.RunnerID != job.RunnerID {
+			return ErrJobTakenOver
+		}
+		job.CancelRequested = savedJob.CancelRequested
+		if job.CancelRequested && job.Status == JOBRUNNING {
+			job.Status = JOBCANCELLED
+		}
+		job.UpdatedAt = time.Now().UTC()
+		if job.Status == JOBRUNNING {
+			job.LeaseExpires = job.UpdatedAt.Add(JOBLEASE)
+		}
+		job.Resumed = savedJob.Resumed
+		*savedJob = *job
+		return nil
+	})
+	return err
+}
+
+// jobCases returns the cases of a job in the order they were given, with the number of UACs of those the options
+// override.
+func jobCases(caseIDs []string, options GenerateOptions) []JobCase {
+	caseUacs := make(map[string]int, len(options.CaseUacs))
+	for caseID, uacCount := range options.CaseUacs {
+		caseUacs[strings.ToLower(caseID)] = uacCount
+	}
+	cases := make([]JobCase, len(caseIDs))
+	for i, caseID := range caseIDs {
+		cases[i] = JobCase{CaseID: caseID, Uacs: caseUacs[strings.ToLower(caseID)]}
+	}
+	return cases
+}
+
+// jobCaseOptions returns the case IDs and options a job was started with from its saved cases.
+func jobCaseOptions(job *GenerationJob, cases []JobCase) ([]string, GenerateOptions) {
+	options := GenerateOptions{ClaimFromPool: job.ClaimFromPool, UacsPerCase: job.UacsPerCase}
+	caseIDs := make([]string, len(cases))
+	for i, jobCase := range cases {
+		caseIDs[i] = jobCase.CaseID
+		if jobCase.Uacs > 0 {
+			if options.CaseUacs == nil {
+				options.CaseUacs = make(map[string]int)
+			}
+			options.CaseUacs[jobCase.CaseID] = jobCase.Uacs
+		}
+	}
+	return caseIDs, options
+}
+
+func jobKey(jobID string) *datastore.Key {
+	return datastore.NameKey(JOBKIND, jobID, nil)
+}
+
+func newJobID() (string, error) {
+	jobID := make([]byte, 16)
+	_, err := rand.Read(jobID)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(jobID), nil
+}
@@ -0,0 +1,250 @@
+package uacgenerator_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Generation jobs", func() {
+	var (
+		uacGenerator    *uacgenerator.UacGenerator
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
+		savedJob        uacgenerator.GenerationJob
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+		savedJob = uacgenerator.GenerationJob{InstrumentName: "lolcat", Status: uacgenerator.JOBRUNNING}
+
+		mockDatastore.On("RunInTransaction",
+			uacGenerator.Context,
+			mock.Anything,
+		).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+			return f(mockTransaction)
+		})
+
+		mockTransaction.On("Get",
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.GenerationJob"),
+		).Return(func(key *datastore.Key, dst interface{}) error {
+			*dst.(*uacgenerator.GenerationJob) = savedJob
+			return nil
+		})
+
+		mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
+		readNoInstrumentConfigs(mockTransaction)
+	})
+
+	Describe("RunGenerationJob", func() {
+		var (
+			job     *uacgenerator.GenerationJob
+			caseIDs []string
+		)
+
+		BeforeEach(func() {
+			caseIDs = []string{}
+			for i := 0; i < 600; i++ {
+				caseIDs = append(caseIDs, fmt.Sprintf("%d", 10000+i))
+			}
+			job = &uacgenerator.GenerationJob{ID: "job1", InstrumentName: "lolcat", Status: uacgenerator.JOBRUNNING, Total: len(caseIDs)}
+
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+				return nil
+			})
+
+			listCaseSlots(mockDatastore, nil)
+
+			mockDatastore.On("Mutate",
+				uacGenerator.Context,
+				mock.AnythingOfType("[]*datastore.Mutation"),
+			).Return(nil, nil)
+		})
+
+		Context("when the job is not cancelled", func() {
+			It("generates UACs for every case and completes", func() {
+				Expect(uacGenerator.RunGenerationJob(job, caseIDs, uacgenerator.GenerateOptions{})).To(BeNil())
+				Expect(job.Status).To(Equal(uacgenerator.JOBCOMPLETED))
+				Expect(job.Processed).To(Equal(600))
+				Expect(job.Created).To(Equal(600))
+				Expect(job.Existing).To(Equal(0))
+				Expect(job.Failed).To(Equal(0))
+				// Five commits of generated UACs, four for the first batch and one for the second, and three saves of
+				// the job
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 8)
+			})
+		})
+
+		Context("when more cases fail than a job lists", func() {
+			BeforeEach(func() {
+				caseIDs = []string{}
+				for i := 0; i < uacgenerator.MAXJOBFAILURES+100; i++ {
+					caseIDs = append(caseIDs, "")
+				}
+				job.Total = len(caseIDs)
+			})
+
+			It("counts every failure but only lists the first", func() {
+				Expect(uacGenerator.RunGenerationJob(job, caseIDs, uacgenerator.GenerateOptions{})).To(BeNil())
+				Expect(job.Status).To(Equal(uacgenerator.JOBCOMPLETEDWITHFAILURES))
+				Expect(job.Failed).To(Equal(uacgenerator.MAXJOBFAILURES + 100))
+				Expect(job.FailedCases).To(HaveLen(uacgenerator.MAXJOBFAILURES))
+				Expect(job.FailedCases[0]).To(Equal(uacgenerator.CaseFailure{CaseID: "", Error: "Cannot generate UACs for blank caseIDs"}))
+			})
+		})
+
+		Context("when the job has been cancelled", func() {
+			BeforeEach(func() {
+				savedJob.CancelRequested = true
+			})
+
+			It("stops after the first batch", func() {
+				Expect(uacGenerator.RunGenerationJob(job, caseIDs, uacgenerator.GenerateOptions{})).To(BeNil())
+				Expect(job.Status).To(Equal(uacgenerator.JOBCANCELLED))
+				Expect(job.Processed).To(Equal(uacgenerator.JOBBATCHSIZE))
+				Expect(job.CancelRequested).To(BeTrue())
+				// Four commits of generated UACs and one save of the job
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 5)
+			})
+		})
+
+		Context("when the job has been taken over by another run", func() {
+			BeforeEach(func() {
+				savedJob.RunnerID = "run2"
+			})
+
+			It("stops after the first batch without saving the job", func() {
+				err := uacGenerator.RunGenerationJob(job, caseIDs, uacgenerator.GenerateOptions{})
+				Expect(err).To(Equal(uacgenerator.ErrJobTakenOver))
+				Expect(job.Processed).To(Equal(uacgenerator.JOBBATCHSIZE))
+				// Four commits of generated UACs
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 4)
+			})
+		})
+
+		Context("when the job is resumed", func() {
+			BeforeEach(func() {
+				job.Processed = 550
+				job.Created = 550
+			})
+
+			It("generates UACs for the cases it had not processed", func() {
+				Expect(uacGenerator.RunGenerationJob(job, caseIDs, uacgenerator.GenerateOptions{})).To(BeNil())
+				Expect(job.Status).To(Equal(uacgenerator.JOBCOMPLETED))
+				Expect(job.Processed).To(Equal(600))
+				Expect(job.Created).To(Equal(600))
+				// One commit of generated UACs and two saves of the job
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
+			})
+		})
+	})
+
+	Describe("GetGenerationJob", func() {
+		It("returns the saved job with its ID", func() {
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				datastore.NameKey("generation_job", "job1", nil),
+				mock.AnythingOfType("*uacgenerator.GenerationJob"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.GenerationJob) = uacgenerator.GenerationJob{InstrumentName: "lolcat", Processed: 20}
+				return nil
+			})
+
+			job, err := uacGenerator.GetGenerationJob("job1")
+			Expect(err).To(BeNil())
+			Expect(job.ID).To(Equal("job1"))
+			Expect(job.Processed).To(Equal(20))
+		})
+
+		It("does not resume a running job whose lease has expired", func() {
+			mockDatastore.On("Get",
+				uacGenerator.Context,
+				datastore.NameKey("generation_job", "job1", nil),
+				mock.AnythingOfType("*uacgenerator.GenerationJob"),
+			).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.GenerationJob) = uacgenerator.GenerationJob{
+					Status:       uacgenerator.JOBRUNNING,
+					LeaseExpires: time.Now().Add(-time.Minute),
+				}
+				return nil
+			})
+
+			job, err := uacGenerator.GetGenerationJob("job1")
+			Expect(err).To(BeNil())
+			Expect(job.Status).To(Equal(uacgenerator.JOBRUNNING))
+			mockDatastore.AssertNotCalled(GinkgoT(), "RunInTransaction", mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("ResumeGenerationJobs", func() {
+		BeforeEach(func() {
+			mockDatastore.On("GetAll",
+				uacGenerator.Context,
+				mock.AnythingOfType("*datastore.Query"),
+				mock.AnythingOfType("*[]*uacgenerator.GenerationJob"),
+			).Return(func(ctx context.Context, query *datastore.Query, dst interface{}) []*datastore.Key {
+				*dst.(*[]*uacgenerator.GenerationJob) = []*uacgenerator.GenerationJob{
+					{Status: uacgenerator.JOBRUNNING, LeaseExpires: time.Now().Add(time.Minute)},
+					{Status: uacgenerator.JOBRUNNING, LeaseExpires: time.Now().Add(-time.Minute)},
+				}
+				return []*datastore.Key{datastore.NameKey("generation_job", "job1", nil), datastore.NameKey("generation_job", "job2", nil)}
+			}, nil)
+		})
+
+		Context("when a job with an expired lease has a cancellation request", func() {
+			BeforeEach(func() {
+				savedJob.CancelRequested = true
+				savedJob.LeaseExpires = time.Now().Add(-time.Minute)
+			})
+
+			It("cancels the job rather than resuming it", func() {
+				resumed, err := uacGenerator.ResumeGenerationJobs()
+				Expect(err).To(BeNil())
+				Expect(resumed).To(BeEmpty())
+				mockDatastore.AssertNumberOfCalls(GinkgoT(), "RunInTransaction", 1)
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+				mutations := mockTransaction.Calls[len(mockTransaction.Calls)-1].Arguments.Get(0).([]*datastore.Mutation)
+				Expect(mutations).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("CancelGenerationJob", func() {
+		Context("when the job is running", func() {
+			It("requests the job is cancelled", func() {
+				job, err := uacGenerator.CancelGenerationJob("job1")
+				Expect(err).To(BeNil())
+				Expect(job.ID).To(Equal("job1"))
+				Expect(job.CancelRequested).To(BeTrue())
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+			})
+		})
+
+		Context("when the job has finished", func() {
+			BeforeEach(func() {
+				savedJob.Status = uacgenerator.JOBCOMPLETED
+			})
+
+			It("returns an error", func() {
+				_, err := uacGenerator.CancelGenerationJob("job1")
+				Expect(err).To(Equal(uacgenerator.ErrJobFinished))
+				mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 0)
+			})
+		})
+	})
+})
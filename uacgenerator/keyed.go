@@ -0,0 +1,137 @@
+package uacgenerator
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// MINUACSECRETSIZE is the fewest bytes a secret for keyed UACs can have.
+const MINUACSECRETSIZE = 32
+
+// UacKeyer keys UACs by an HMAC of the normalised UAC rather than the UAC itself, so storage never holds a usable
+// UAC. The UAC is sealed with AES-GCM alongside, for encrypted exports. Both keys are derived from a single secret,
+// which cannot be changed without migrating every UAC again.
+type UacKeyer struct {
+	hmacKey []byte
+	sealer  cipher.AEAD
+}
+
+func NewUacKeyer(secret []byte) (*UacKeyer, error) {
+	if len(secret) < MINUACSECRETSIZE {
+		return nil, fmt.Errorf("UAC secret must be at least %d bytes", MINUACSECRETSIZE)
+	}
+	block, err := aes.NewCipher(deriveUacKey(secret, "seal"))
+	if err != nil {
+		return nil, err
+	}
+	sealer, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &UacKeyer{hmacKey: deriveUacKey(secret, "key"), sealer: sealer}, nil
+}
+
+// LoadUacKeyer reads the secret from a file, ignoring surrounding whitespace.
+func LoadUacKeyer(path string) (*UacKeyer, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewUacKeyer(bytes.TrimSpace(secret))
+}
+
+// KeyName returns the name of a normalised UAC's key.
+func (keyer *UacKeyer) KeyName(uac string) string {
+	mac := hmac.New(sha256.New, keyer.hmacKey)
+	mac.Write([]byte(uac))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsKeyName reports whether a key name is an HMAC rather than a UAC.
+func (keyer *UacKeyer) IsKeyName(name string) bool {
+	_, err := hex.DecodeString(name)
+	return err == nil && len(name) == 2*sha256.Size
+}
+
+func (keyer *UacKeyer) Seal(uac string) (string, error) {
+	nonce := make([]byte, keyer.sealer.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(keyer.sealer.Seal(nonce, nonce, []byte(uac), nil)), nil
+}
+
+func (keyer *UacKeyer) Open(sealedUac string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(sealedUac)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < keyer.sealer.NonceSize() {
+		return "", errors.New("Sealed UAC is too short")
+	}
+	nonce, ciphertext := sealed[:keyer.sealer.NonceSize()], sealed[keyer.sealer.NonceSize():]
+	uac, err := keyer.sealer.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(uac), nil
+}
+
+func deriveUacKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("blaise-uac-service " + purpose))
+	return mac.Sum(nil)
+}
+
+// MigrateToKeyedUacs moves every UAC still keyed by the UAC itself to its hash, sealing the UAC and linking it to the
+// UACs it was reissued from and to by their hashes, and returns how many were moved. UACs that have already been moved
+// are skipped, so it can be run again after a failure.
+func (uacGenerator *UacGenerator) MigrateToKeyedUacs() (int, error) {
+	if uacGenerator.UacKeyer == nil {
+		return 0, errors.New("Cannot migrate to keyed UACs without a UAC secret")
+	}
+	migrated := 0
+	for _, uacKind := range UacKinds() {
+		instrumentNames, err := uacGenerator.Store.ListInstruments(uacGenerator.Context, uacKind)
+		if err != nil {
+			return migrated, err
+		}
+		for _, instrumentName := range instrumentNames {
+			uacInfos, err := uacGenerator.Store.ListUacs(uacGenerator.Context, uacKind, instrumentName)
+			if err != nil {
+				return migrated, err
+			}
+			for _, uacInfo := range uacInfos {
+				if uacGenerator.UacKeyer.IsKeyName(uacInfo.UAC.Name) {
+					continue
+				}
+				sealedUac, err := uacGenerator.UacKeyer.Seal(uacInfo.UAC.Name)
+				if err != nil {
+					return migrated, err
+				}
+				uacKey := uacGenerator.uacKey(uacKind, uacInfo.UAC.Name)
+				// The entry is made against the hash, as the audit trail must not hold UACs once they are keyed
+				err = uacGenerator.Store.RekeyUac(uacGenerator.Context, uacInfo, uacKey, sealedUac,
+					func(link string) string { return uacGenerator.keyedLinkName(uacKind, link) },
+					uacGenerator.auditEntries(uacAuditEntry(AUDITREKEY, uacKey, uacInfo, uacInfo)))
+				if err != nil {
+					return migrated, err
+				}
+				migrated++
+			}
+			log.Printf("Migrated the %s UACs of '%s' to keyed UACs", uacKind, instrumentName)
+		}
+	}
+	return migrated, nil
+}
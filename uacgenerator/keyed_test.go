@@ -0,0 +1,224 @@
+package uacgenerator_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"cloud.google.com/go/datastore"
+	"filippo.io/age"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keyed UACs", func() {
+	var secret = []byte("0123456789abcdef0123456789abcdef")
+
+	It("needs a secret of at least 32 bytes", func() {
+		_, err := uacgenerator.NewUacKeyer([]byte("too short"))
+		Expect(err).To(MatchError("UAC secret must be at least 32 bytes"))
+	})
+
+	It("seals and opens UACs", func() {
+		uacKeyer, err := uacgenerator.NewUacKeyer(secret)
+		Expect(err).To(BeNil())
+		sealedUac, err := uacKeyer.Seal("123412341234")
+		Expect(err).To(BeNil())
+		Expect(sealedUac).ToNot(ContainSubstring("123412341234"))
+		Expect(uacKeyer.Open(sealedUac)).To(Equal("123412341234"))
+		Expect(uacKeyer.KeyName("123412341234")).To(HaveLen(64))
+		Expect(uacKeyer.IsKeyName(uacKeyer.KeyName("123412341234"))).To(BeTrue())
+		Expect(uacKeyer.IsKeyName("123412341234")).To(BeFalse())
+	})
+
+	keyedSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var (
+			uacGenerator *uacgenerator.UacGenerator
+			uacKeyer     *uacgenerator.UacKeyer
+		)
+
+		BeforeEach(func() {
+			var err error
+			uacKeyer, err = uacgenerator.NewUacKeyer(secret)
+			Expect(err).To(BeNil())
+			uacGenerator = newUacGenerator()
+		})
+
+		expectNoPlaintextKeys := func(instrumentName string) {
+			uacInfos, err := uacGenerator.Store.ListUacs(context.Background(), "uac", instrumentName)
+			Expect(err).To(BeNil())
+			Expect(uacInfos).ToNot(BeEmpty())
+			for _, uacInfo := range uacInfos {
+				Expect(uacKeyer.IsKeyName(uacInfo.UAC.Name)).To(BeTrue())
+				Expect(uacInfo.SealedUAC).ToNot(BeEmpty())
+			}
+		}
+
+		Context("when UACs are keyed", func() {
+			var result *uacgenerator.GenerateResult
+
+			BeforeEach(func() {
+				uacGenerator.UacKeyer = uacKeyer
+				var err error
+				result, err = uacGenerator.Generate("lolcat", []string{"1", "2"})
+				Expect(err).To(BeNil())
+			})
+
+			It("returns the full UACs only from the generation", func() {
+				Expect(result.Uacs).To(HaveLen(2))
+				expectNoPlaintextKeys("lolcat")
+
+				uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+				Expect(err).To(BeNil())
+				Expect(uacs["1"].FullUAC).To(BeEmpty())
+				Expect(uacs["1"].UacHash).To(Equal(uacKeyer.KeyName(result.Uacs["1"])))
+
+				again, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+				Expect(err).To(BeNil())
+				Expect(again.Existing).To(Equal([]string{"1", "2"}))
+				Expect(again.Uacs).To(HaveLen(1))
+				Expect(again.Uacs).To(HaveKey("3"))
+			})
+
+			It("returns the full UACs of only the new slots of a case", func() {
+				again, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1", "4"}, uacgenerator.GenerateOptions{UacsPerCase: 2})
+				Expect(err).To(BeNil())
+				Expect(again.Existing).To(Equal([]string{"1"}))
+				Expect(again.Uacs).To(HaveLen(3))
+				Expect(again.Uacs).To(HaveKey("1/1"))
+				Expect(again.Uacs).To(HaveKey("4"))
+				Expect(again.Uacs).To(HaveKey("4/1"))
+
+				uacInfo, err := uacGenerator.GetUacInfo(again.Uacs["1/1"])
+				Expect(err).To(BeNil())
+				Expect(uacInfo.CaseID).To(Equal("1"))
+				Expect(uacInfo.Slot).To(Equal(1))
+			})
+
+			It("looks UACs up by their hash", func() {
+				uacInfo, err := uacGenerator.GetUacInfo(result.Uacs["1"])
+				Expect(err).To(BeNil())
+				Expect(uacInfo.CaseID).To(Equal("1"))
+
+				Expect(uacGenerator.DisableUac(result.Uacs["1"])).To(Succeed())
+				uacInfo, err = uacGenerator.GetUacInfo(result.Uacs["1"])
+				Expect(err).To(BeNil())
+				Expect(uacInfo.Disabled).To(BeTrue())
+				Expect(uacInfo.SealedUAC).ToNot(BeEmpty())
+			})
+
+			It("only exports UACs encrypted", func() {
+				_, err := uacGenerator.ExportUacs("lolcat", uacgenerator.ExportOptions{}, &bytes.Buffer{})
+				Expect(err).To(Equal(uacgenerator.ErrUacsKeyed))
+
+				identity, err := age.GenerateX25519Identity()
+				Expect(err).To(BeNil())
+				uacGenerator.ExportEncrypter = uacgenerator.NewAgeEncrypter(identity.Recipient())
+				encrypted := &bytes.Buffer{}
+				_, err = uacGenerator.ExportEncryptedUacs("lolcat", uacgenerator.ExportOptions{Columns: []string{"uac"}}, encrypted)
+				Expect(err).To(BeNil())
+				plaintextReader, err := age.Decrypt(encrypted, identity)
+				Expect(err).To(BeNil())
+				plaintext, err := io.ReadAll(plaintextReader)
+				Expect(err).To(BeNil())
+				Expect(string(plaintext)).To(ContainSubstring(result.Uacs["1"] + "\n"))
+				Expect(string(plaintext)).To(ContainSubstring(result.Uacs["2"] + "\n"))
+			})
+
+			It("does not export a keyed UAC without its sealed UAC", func() {
+				Expect(uacGenerator.Store.InsertCaseUac(context.Background(), &uacgenerator.UacInfo{
+					InstrumentName: "lolcat",
+					CaseID:         "3",
+					UAC:            uacGenerator.UacKey("123412341234"),
+				}, nil)).To(Succeed())
+
+				identity, err := age.GenerateX25519Identity()
+				Expect(err).To(BeNil())
+				uacGenerator.ExportEncrypter = uacgenerator.NewAgeEncrypter(identity.Recipient())
+				_, err = uacGenerator.ExportEncryptedUacs("lolcat", uacgenerator.ExportOptions{Columns: []string{"uac"}}, &bytes.Buffer{})
+				Expect(err).To(Equal(uacgenerator.ErrUacNotSealed))
+			})
+
+			It("returns imported UACs once they are claimed", func() {
+				Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+				expectNoPlaintextKeys(uacgenerator.UNKNOWNINSTRUMENT)
+				claimed, err := uacGenerator.GenerateWithOptions("lolcat", []string{"3"}, uacgenerator.GenerateOptions{ClaimFromPool: true})
+				Expect(err).To(BeNil())
+				Expect(claimed.FromPool).To(Equal([]string{"3"}))
+				Expect(claimed.Uacs).To(Equal(map[string]string{"3": "123412341234"}))
+			})
+		})
+
+		Context("when migrating", func() {
+			var result *uacgenerator.GenerateResult
+
+			BeforeEach(func() {
+				var err error
+				result, err = uacGenerator.Generate("lolcat", []string{"1", "2"})
+				Expect(err).To(BeNil())
+				uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+				Expect(err).To(BeNil())
+				result.Uacs = map[string]string{"1": uacs["1"].FullUAC, "2": uacs["2"].FullUAC}
+				Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+				uacGenerator.UacKeyer = uacKeyer
+			})
+
+			It("moves every UAC to its hash", func() {
+				Expect(uacGenerator.MigrateToKeyedUacs()).To(Equal(3))
+				expectNoPlaintextKeys("lolcat")
+				expectNoPlaintextKeys(uacgenerator.UNKNOWNINSTRUMENT)
+
+				uacInfo, err := uacGenerator.GetUacInfo(result.Uacs["2"])
+				Expect(err).To(BeNil())
+				Expect(uacInfo.CaseID).To(Equal("2"))
+				_, err = uacGenerator.Store.GetUac(context.Background(), datastore.NameKey("uac", result.Uacs["2"], nil))
+				Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+
+				Expect(uacGenerator.MigrateToKeyedUacs()).To(Equal(0))
+			})
+
+			It("links reissued UACs by their hashes", func() {
+				uacGenerator.UacKeyer = nil
+				reissued, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+				Expect(err).To(BeNil())
+				uacGenerator.UacKeyer = uacKeyer
+
+				Expect(uacGenerator.MigrateToKeyedUacs()).To(Equal(4))
+				expectNoPlaintextKeys("lolcat")
+				uacInfos, err := uacGenerator.Store.ListUacs(context.Background(), "uac", "lolcat")
+				Expect(err).To(BeNil())
+				for _, uacInfo := range uacInfos {
+					for _, uac := range []string{result.Uacs["1"], result.Uacs["2"], reissued.FullUAC} {
+						Expect(uacInfo.ReissuedFrom).ToNot(Equal(uac))
+						Expect(uacInfo.ReissuedTo).ToNot(Equal(uac))
+					}
+				}
+
+				replaced, err := uacGenerator.GetUacInfo(result.Uacs["1"])
+				Expect(err).To(BeNil())
+				Expect(replaced.ReissuedTo).To(Equal(uacKeyer.KeyName(reissued.FullUAC)))
+				current, err := uacGenerator.GetUacInfo(reissued.FullUAC)
+				Expect(err).To(BeNil())
+				Expect(current.ReissuedFrom).To(Equal(uacKeyer.KeyName(result.Uacs["1"])))
+				Expect(current.Disabled).To(BeFalse())
+			})
+
+			It("keeps one UAC per case", func() {
+				Expect(uacGenerator.MigrateToKeyedUacs()).To(Equal(3))
+				err := uacGenerator.AddUacToDatastore("567856785678", "lolcat", "1")
+				Expect(err).To(Equal(uacgenerator.ErrCaseHasUac))
+			})
+		})
+	}
+
+	Context("with Datastore", func() {
+		keyedSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		keyedSpecs(newSQLUacGenerator)
+	})
+})
@@ -0,0 +1,65 @@
+package uacgenerator
+
+import "time"
+
+// TimeRange includes the times from From up to, but not including, To. Either end is left open when it is zero.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (timeRange TimeRange) IsZero() bool {
+	return timeRange.From.IsZero() && timeRange.To.IsZero()
+}
+
+// Contains is always true for an open range, and never true of a zero time otherwise.
+func (timeRange TimeRange) Contains(t time.Time) bool {
+	if timeRange.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	if !timeRange.From.IsZero() && t.Before(timeRange.From) {
+		return false
+	}
+	return timeRange.To.IsZero() || t.Before(timeRange.To)
+}
+
+// UacFilter limits listings to UACs whose lifecycle timestamps are in the ranges that are set.
+type UacFilter struct {
+	Created       TimeRange
+	Updated       TimeRange
+	Disabled      TimeRange
+	FirstAccessed TimeRange
+}
+
+func (uacFilter UacFilter) IsZero() bool {
+	return uacFilter == UacFilter{}
+}
+
+func (uacFilter UacFilter) Match(uacInfo *UacInfo) bool {
+	return uacFilter.Created.Contains(uacInfo.CreatedAt) &&
+		uacFilter.Updated.Contains(uacInfo.UpdatedAt) &&
+		uacFilter.Disabled.Contains(uacInfo.DisabledAt) &&
+		uacFilter.FirstAccessed.Contains(uacInfo.FirstAccessedAt)
+}
+
+// Filter returns the UACs that match a filter.
+func (uacs Uacs) Filter(uacFilter UacFilter) Uacs {
+	if uacFilter.IsZero() {
+		return uacs
+	}
+	filtered := make(Uacs)
+	for key, uacInfo := range uacs {
+		if uacFilter.Match(uacInfo) {
+			filtered[key] = uacInfo
+		}
+	}
+	return filtered
+}
+
+// uacTimestamp is the time recorded against a change to a UAC. Datastore keeps times to the microsecond.
+func uacTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,168 @@
+package uacgenerator_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UAC lifecycle timestamps", func() {
+	lifecycleSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+		})
+
+		generateUacs := func(instrumentName string, caseIDs ...string) uacgenerator.Uacs {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID(instrumentName)
+			Expect(err).To(BeNil())
+			return uacs
+		}
+
+		// between returns a time after everything done so far and before anything done next
+		between := func() time.Time {
+			time.Sleep(time.Millisecond)
+			defer time.Sleep(time.Millisecond)
+			return time.Now()
+		}
+
+		It("records when a UAC was created", func() {
+			uacInfo := generateUacs("lolcat", "1")["1"]
+			Expect(uacInfo.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(uacInfo.UpdatedAt).To(Equal(uacInfo.CreatedAt))
+			Expect(uacInfo.DisabledAt.IsZero()).To(BeTrue())
+			Expect(uacInfo.FirstAccessedAt.IsZero()).To(BeTrue())
+		})
+
+		It("records when a UAC was disabled and enabled", func() {
+			uac := generateUacs("lolcat", "1")["1"].FullUAC
+			Expect(uacGenerator.DisableUac(uac)).To(Succeed())
+			disabled := generateUacs("lolcat")["1"]
+			Expect(disabled.DisabledAt).To(BeTemporally(">", disabled.CreatedAt))
+			Expect(disabled.UpdatedAt).To(Equal(disabled.DisabledAt))
+
+			between()
+			Expect(uacGenerator.DisableUac(uac)).To(Succeed())
+			Expect(generateUacs("lolcat")["1"].DisabledAt).To(Equal(disabled.DisabledAt))
+
+			Expect(uacGenerator.EnableUac(uac)).To(Succeed())
+			enabled := generateUacs("lolcat")["1"]
+			Expect(enabled.DisabledAt.IsZero()).To(BeTrue())
+			Expect(enabled.UpdatedAt).To(BeTemporally(">", disabled.UpdatedAt))
+			Expect(enabled.CreatedAt).To(Equal(disabled.CreatedAt))
+		})
+
+		It("records when a UAC was first looked up", func() {
+			uac := generateUacs("lolcat", "1")["1"].FullUAC
+			_, err := uacGenerator.GetUacInfo(uac)
+			Expect(err).To(BeNil())
+			firstAccessed := generateUacs("lolcat")["1"].FirstAccessedAt
+			Expect(firstAccessed).To(BeTemporally("~", time.Now(), time.Minute))
+
+			between()
+			uacInfo, err := uacGenerator.GetUacInfo(uac)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.FirstAccessedAt).To(Equal(firstAccessed))
+			Expect(uacGenerator.DisableUac(uac)).To(Succeed())
+			Expect(generateUacs("lolcat")["1"].FirstAccessedAt).To(Equal(firstAccessed))
+		})
+
+		It("creates imported UACs again when they are claimed", func() {
+			Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+			claimedFrom := between()
+			_, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1"}, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+
+			uacInfo := generateUacs("lolcat")["1"]
+			Expect(uacInfo.FullUAC).To(Equal("123412341234"))
+			Expect(uacInfo.CreatedAt).To(BeTemporally(">", claimedFrom))
+		})
+
+		It("limits pages, streams and exports to the UACs matching a filter", func() {
+			generateUacs("lolcat", "1")
+			lastPrintRun := between()
+			uacs := generateUacs("lolcat", "2", "3")
+			Expect(uacGenerator.DisableUac(uacs["3"].FullUAC)).To(Succeed())
+
+			issued := uacgenerator.UacFilter{Created: uacgenerator.TimeRange{From: lastPrintRun}}
+			page, err := uacGenerator.GetUacsPageByCaseID("lolcat", issued, "", 1)
+			Expect(err).To(BeNil())
+			Expect(page.Uacs).To(HaveLen(1))
+			Expect(page.Cursor).NotTo(BeEmpty())
+			nextPage, err := uacGenerator.GetUacsPageByCaseID("lolcat", issued, page.Cursor, 1)
+			Expect(err).To(BeNil())
+			Expect(nextPage.Uacs).To(HaveLen(1))
+			for caseID := range page.Uacs {
+				Expect(nextPage.Uacs).NotTo(HaveKey(caseID))
+			}
+			lastPage, err := uacGenerator.GetUacsPageByCaseID("lolcat", issued, nextPage.Cursor, 1)
+			Expect(err).To(BeNil())
+			Expect(lastPage.Uacs).To(BeEmpty())
+
+			page, err = uacGenerator.GetUacsPageByCaseID("lolcat", uacgenerator.UacFilter{Created: uacgenerator.TimeRange{To: lastPrintRun}}, "", 0)
+			Expect(err).To(BeNil())
+			Expect(page.Uacs).To(HaveLen(1))
+			Expect(page.Uacs).To(HaveKey("1"))
+
+			var streamed []string
+			disabled := uacgenerator.UacFilter{Disabled: uacgenerator.TimeRange{From: lastPrintRun}}
+			Expect(uacGenerator.StreamUacs("lolcat", disabled, func(uacInfo *uacgenerator.UacInfo) error {
+				streamed = append(streamed, uacInfo.CaseID)
+				return nil
+			})).To(Succeed())
+			Expect(streamed).To(Equal([]string{"3"}))
+
+			trailer, err := uacGenerator.ExportUacs("lolcat", uacgenerator.ExportOptions{Filter: issued}, &bytes.Buffer{})
+			Expect(err).To(BeNil())
+			Expect(trailer.Records).To(Equal(2))
+		})
+
+		It("keeps the timestamps of archived UACs when they are restored", func() {
+			uacs := generateUacs("lolcat", "1")
+			Expect(uacGenerator.DisableUac(uacs["1"].FullUAC)).To(Succeed())
+			disabled := generateUacs("lolcat")["1"]
+			Expect(uacGenerator.AdminDelete("lolcat")).To(Succeed())
+			_, err := uacGenerator.RestoreInstrument("lolcat")
+			Expect(err).To(BeNil())
+
+			restored := generateUacs("lolcat")["1"]
+			Expect(restored.CreatedAt).To(Equal(disabled.CreatedAt))
+			Expect(restored.DisabledAt).To(Equal(disabled.DisabledAt))
+		})
+	}
+
+	Context("with Datastore", func() {
+		lifecycleSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		lifecycleSpecs(newSQLUacGenerator)
+	})
+})
+
+var _ = Describe("UacFilter", func() {
+	var (
+		from = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+		to   = time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	)
+
+	It("includes the start of a range and excludes its end", func() {
+		filter := uacgenerator.UacFilter{Created: uacgenerator.TimeRange{From: from, To: to}}
+		Expect(filter.Match(&uacgenerator.UacInfo{CreatedAt: from})).To(BeTrue())
+		Expect(filter.Match(&uacgenerator.UacInfo{CreatedAt: to})).To(BeFalse())
+		Expect(filter.Match(&uacgenerator.UacInfo{CreatedAt: from.Add(-time.Microsecond)})).To(BeFalse())
+	})
+
+	It("only matches UACs without a timestamp when its range is open", func() {
+		Expect(uacgenerator.UacFilter{}.Match(&uacgenerator.UacInfo{})).To(BeTrue())
+		Expect(uacgenerator.UacFilter{Disabled: uacgenerator.TimeRange{To: to}}.Match(&uacgenerator.UacInfo{})).To(BeFalse())
+	})
+})
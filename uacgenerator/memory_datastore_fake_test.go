@@ -0,0 +1,771 @@
+package uacgenerator
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"cloud.google.com/go/datastore"
+	pb "cloud.google.com/go/datastore/apiv1/datastorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MEMORYTRANSACTIONATTEMPTS matches the number of times the Cloud Datastore client attempts a transaction.
+const MEMORYTRANSACTIONATTEMPTS = 3
+
+// MemoryDatastore is an in-memory implementation of the Datastore interface for running the specs against
+// DatastoreUacStore without a Google Cloud project. It supports the queries the generator uses: equality filters,
+// inequality filters, ancestors, projections, distinct, keys-only and limits. It reads the unexported fields of the
+// client's queries and mutations, so it is only built into the tests, and fails a query or mutation with an error
+// when the client's fields are not as it expects.
+type MemoryDatastore struct {
+	mu       sync.RWMutex
+	entities map[string]*memoryEntity
+	version  int64
+	nextID   int64
+}
+
+type memoryEntity struct {
+	key        *datastore.Key
+	properties []datastore.Property
+	version    int64
+}
+
+func NewMemoryDatastore() *MemoryDatastore {
+	return &MemoryDatastore{entities: map[string]*memoryEntity{}}
+}
+
+func (memoryDatastore *MemoryDatastore) Mutate(ctx context.Context, mutations ...*datastore.Mutation) ([]*datastore.Key, error) {
+	memoryDatastore.mu.Lock()
+	defer memoryDatastore.mu.Unlock()
+	return memoryDatastore.apply(mutations)
+}
+
+func (memoryDatastore *MemoryDatastore) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	memoryDatastore.mu.RLock()
+	defer memoryDatastore.mu.RUnlock()
+	entity, ok := memoryDatastore.entities[memoryKey(key)]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	return loadMemoryEntity(dst, entity.key, entity.properties)
+}
+
+func (memoryDatastore *MemoryDatastore) GetAll(ctx context.Context, query *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+	memoryDatastore.mu.RLock()
+	defer memoryDatastore.mu.RUnlock()
+	memoryQuery, err := readMemoryQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	results, err := memoryDatastore.run(memoryQuery)
+	if err != nil {
+		return nil, err
+	}
+	return loadMemoryResults(memoryQuery, results, dst)
+}
+
+// loadMemoryResults loads the results of a query into dst, a pointer to a slice, returning their keys.
+func loadMemoryResults(memoryQuery *memoryQuery, results []*memoryEntity, dst interface{}) ([]*datastore.Key, error) {
+	keys := make([]*datastore.Key, len(results))
+	for i, result := range results {
+		keys[i] = result.key
+	}
+	if dst == nil || memoryQuery.keysOnly {
+		return keys, nil
+	}
+	var err error
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Ptr || dstValue.Elem().Kind() != reflect.Slice {
+		return nil, datastore.ErrInvalidEntityType
+	}
+	sliceValue := dstValue.Elem()
+	elemType := sliceValue.Type().Elem()
+	for _, result := range results {
+		var elem, ptr reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			ptr = reflect.New(elemType.Elem())
+			elem = ptr
+		} else {
+			ptr = reflect.New(elemType)
+			elem = ptr.Elem()
+		}
+		err = loadMemoryEntity(ptr.Interface(), result.key, result.properties)
+		if err != nil {
+			return nil, err
+		}
+		sliceValue.Set(reflect.Append(sliceValue, elem))
+	}
+	return keys, nil
+}
+
+// Run returns an iterator over a snapshot of the query's results. Its cursors continue after the last
+// key returned, so they stay valid when entities are added or removed.
+func (memoryDatastore *MemoryDatastore) Run(ctx context.Context, query *datastore.Query) Iterator {
+	memoryDatastore.mu.RLock()
+	defer memoryDatastore.mu.RUnlock()
+	memoryQuery, err := readMemoryQuery(query)
+	if err != nil {
+		return &memoryIterator{err: err}
+	}
+	results, err := memoryDatastore.run(memoryQuery)
+	return &memoryIterator{
+		results:  results,
+		keysOnly: memoryQuery.keysOnly,
+		start:    memoryQuery.start,
+		err:      err,
+	}
+}
+
+func (memoryDatastore *MemoryDatastore) Count(ctx context.Context, query *datastore.Query) (int, error) {
+	memoryDatastore.mu.RLock()
+	defer memoryDatastore.mu.RUnlock()
+	memoryQuery, err := readMemoryQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	results, err := memoryDatastore.run(memoryQuery)
+	if err != nil {
+		return 0, err
+	}
+	return len(results), nil
+}
+
+func (memoryDatastore *MemoryDatastore) DeleteMulti(ctx context.Context, keys []*datastore.Key) error {
+	memoryDatastore.mu.Lock()
+	defer memoryDatastore.mu.Unlock()
+	for _, key := range keys {
+		delete(memoryDatastore.entities, memoryKey(key))
+	}
+	return nil
+}
+
+// RunInTransaction runs f with optimistic concurrency, as Cloud Datastore does. The mutations of a
+// transaction are applied together when f returns, unless an entity it read has been changed since,
+// in which case f is run again.
+func (memoryDatastore *MemoryDatastore) RunInTransaction(ctx context.Context, f func(Transaction) error) error {
+	for attempt := 0; attempt < MEMORYTRANSACTIONATTEMPTS; attempt++ {
+		transaction := &memoryTransaction{datastore: memoryDatastore, reads: map[string]int64{}, queries: map[*memoryQuery]string{}}
+		err := f(transaction)
+		if err != nil {
+			return err
+		}
+		err = transaction.commit()
+		if err != datastore.ErrConcurrentTransaction {
+			return err
+		}
+	}
+	return datastore.ErrConcurrentTransaction
+}
+
+func (memoryDatastore *MemoryDatastore) Close() error {
+	return nil
+}
+
+// apply validates every mutation before applying any of them, so a failed commit changes nothing.
+// The caller must hold the write lock.
+func (memoryDatastore *MemoryDatastore) apply(mutations []*datastore.Mutation) ([]*datastore.Key, error) {
+	pending := map[string]*memoryEntity{}
+	lookup := func(id string) *memoryEntity {
+		if entity, ok := pending[id]; ok {
+			return entity
+		}
+		return memoryDatastore.entities[id]
+	}
+	keys := make([]*datastore.Key, len(mutations))
+	for i, mutation := range mutations {
+		var (
+			mutationErr error
+			mut         *pb.Mutation
+		)
+		value := reflect.ValueOf(mutation).Elem()
+		if err := readUnexportedField(value, "err", &mutationErr); err != nil {
+			return nil, err
+		}
+		if mutationErr != nil {
+			return nil, mutationErr
+		}
+		if err := readUnexportedField(value, "mut", &mut); err != nil {
+			return nil, err
+		}
+		if mut == nil {
+			return nil, fmt.Errorf("datastore: uninitialized mutation")
+		}
+		if deleteKey := mut.GetDelete(); deleteKey != nil {
+			key := memoryKeyFromProto(deleteKey)
+			pending[memoryKey(key)] = nil
+			keys[i] = key
+			continue
+		}
+		var entityProto *pb.Entity
+		switch {
+		case mut.GetInsert() != nil:
+			entityProto = mut.GetInsert()
+		case mut.GetUpdate() != nil:
+			entityProto = mut.GetUpdate()
+		default:
+			entityProto = mut.GetUpsert()
+		}
+		key := memoryKeyFromProto(entityProto.Key)
+		if key.Incomplete() {
+			memoryDatastore.nextID++
+			key.ID = memoryDatastore.nextID
+		}
+		id := memoryKey(key)
+		if mut.GetInsert() != nil && lookup(id) != nil {
+			return nil, status.Errorf(codes.AlreadyExists, "entity already exists: %s", key)
+		}
+		if mut.GetUpdate() != nil && lookup(id) == nil {
+			return nil, status.Errorf(codes.NotFound, "no entity to update: %s", key)
+		}
+		properties, err := memoryProperties(entityProto)
+		if err != nil {
+			return nil, err
+		}
+		pending[id] = &memoryEntity{key: key, properties: properties}
+		keys[i] = key
+	}
+	for id, entity := range pending {
+		if entity == nil {
+			delete(memoryDatastore.entities, id)
+			continue
+		}
+		memoryDatastore.version++
+		entity.version = memoryDatastore.version
+		memoryDatastore.entities[id] = entity
+	}
+	return keys, nil
+}
+
+// memoryQuery is what MemoryDatastore reads of a query.
+type memoryQuery struct {
+	kind       string
+	ancestor   *datastore.Key
+	filters    []datastore.EntityFilter
+	projection []string
+	distinctOn []string
+	keysOnly   bool
+	start      []byte
+	offset     int32
+	limit      int32
+}
+
+func readMemoryQuery(query *datastore.Query) (*memoryQuery, error) {
+	var (
+		value      = reflect.ValueOf(query).Elem()
+		readQuery  = &memoryQuery{}
+		queryErr   error
+		distinct   bool
+		err        error
+		readFields = func(fields map[string]interface{}) {
+			for name, dst := range fields {
+				if err == nil {
+					err = readUnexportedField(value, name, dst)
+				}
+			}
+		}
+	)
+	readFields(map[string]interface{}{
+		"err":        &queryErr,
+		"kind":       &readQuery.kind,
+		"ancestor":   &readQuery.ancestor,
+		"filter":     &readQuery.filters,
+		"projection": &readQuery.projection,
+		"distinct":   &distinct,
+		"distinctOn": &readQuery.distinctOn,
+		"keysOnly":   &readQuery.keysOnly,
+		"start":      &readQuery.start,
+		"offset":     &readQuery.offset,
+		"limit":      &readQuery.limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	orders := value.FieldByName("order")
+	if !orders.IsValid() || orders.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("memory datastore: %s has no order field", value.Type())
+	}
+	if orders.Len() > 0 {
+		return nil, fmt.Errorf("memory datastore: query orders are not supported")
+	}
+	if distinct {
+		readQuery.distinctOn = readQuery.projection
+	}
+	return readQuery, nil
+}
+
+// run returns the entities matching a query, ordered by key. The caller must hold a lock.
+func (memoryDatastore *MemoryDatastore) run(query *memoryQuery) ([]*memoryEntity, error) {
+	kind, ancestor, filters, projection, distinctOn := query.kind, query.ancestor, query.filters, query.projection, query.distinctOn
+
+	var results []*memoryEntity
+	for _, entity := range memoryDatastore.entities {
+		if kind != "" && entity.key.Kind != kind {
+			continue
+		}
+		if ancestor != nil && !hasMemoryAncestor(entity.key, ancestor) {
+			continue
+		}
+		matched := true
+		for _, filter := range filters {
+			ok, err := matchMemoryFilter(entity, filter)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if len(projection) > 0 {
+			properties := projectMemoryProperties(entity.properties, projection)
+			if properties == nil {
+				continue
+			}
+			entity = &memoryEntity{key: entity.key, properties: properties}
+		}
+		results = append(results, entity)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return memoryKey(results[i].key) < memoryKey(results[j].key)
+	})
+
+	if len(distinctOn) > 0 {
+		seen := map[string]bool{}
+		distinct := results[:0]
+		for _, entity := range results {
+			values := make([]string, len(distinctOn))
+			for i, name := range distinctOn {
+				value, _ := memoryProperty(entity.properties, name)
+				values[i] = fmt.Sprintf("%#v", value)
+			}
+			id := strings.Join(values, "\x00")
+			if !seen[id] {
+				seen[id] = true
+				distinct = append(distinct, entity)
+			}
+		}
+		results = distinct
+	}
+
+	if start := query.start; len(start) > 0 {
+		position := sort.Search(len(results), func(i int) bool {
+			return memoryKey(results[i].key) > string(start)
+		})
+		results = results[position:]
+	}
+	if offset := int(query.offset); offset > 0 {
+		if offset > len(results) {
+			offset = len(results)
+		}
+		results = results[offset:]
+	}
+	if limit := int(query.limit); limit >= 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+type memoryIterator struct {
+	results  []*memoryEntity
+	keysOnly bool
+	start    []byte
+	err      error
+}
+
+func (memoryIterator *memoryIterator) Next(dst interface{}) (*datastore.Key, error) {
+	if memoryIterator.err != nil {
+		return nil, memoryIterator.err
+	}
+	if len(memoryIterator.results) == 0 {
+		return nil, iterator.Done
+	}
+	entity := memoryIterator.results[0]
+	memoryIterator.results = memoryIterator.results[1:]
+	memoryIterator.start = []byte(memoryKey(entity.key))
+	if dst != nil && !memoryIterator.keysOnly {
+		err := loadMemoryEntity(dst, entity.key, entity.properties)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entity.key, nil
+}
+
+func (memoryIterator *memoryIterator) Cursor() (datastore.Cursor, error) {
+	return datastore.DecodeCursor(base64.URLEncoding.EncodeToString(memoryIterator.start))
+}
+
+type memoryTransaction struct {
+	datastore *MemoryDatastore
+	reads     map[string]int64
+	// queries are the queries run in the transaction with the keys and versions of their results.
+	queries   map[*memoryQuery]string
+	mutations []*datastore.Mutation
+}
+
+func (transaction *memoryTransaction) GetAll(query *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+	transaction.datastore.mu.RLock()
+	defer transaction.datastore.mu.RUnlock()
+	memoryQuery, err := readMemoryQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	results, err := transaction.datastore.run(memoryQuery)
+	if err != nil {
+		return nil, err
+	}
+	transaction.queries[memoryQuery] = memoryResultVersions(results)
+	return loadMemoryResults(memoryQuery, results, dst)
+}
+
+func memoryResultVersions(results []*memoryEntity) string {
+	var versions strings.Builder
+	for _, result := range results {
+		fmt.Fprintf(&versions, "%s\x02%d\x03", memoryKey(result.key), result.version)
+	}
+	return versions.String()
+}
+
+func (transaction *memoryTransaction) Get(key *datastore.Key, dst interface{}) error {
+	transaction.datastore.mu.RLock()
+	defer transaction.datastore.mu.RUnlock()
+	id := memoryKey(key)
+	entity, ok := transaction.datastore.entities[id]
+	if !ok {
+		transaction.reads[id] = 0
+		return datastore.ErrNoSuchEntity
+	}
+	transaction.reads[id] = entity.version
+	return loadMemoryEntity(dst, entity.key, entity.properties)
+}
+
+func (transaction *memoryTransaction) Mutate(mutations ...*datastore.Mutation) error {
+	transaction.mutations = append(transaction.mutations, mutations...)
+	return nil
+}
+
+func (transaction *memoryTransaction) commit() error {
+	transaction.datastore.mu.Lock()
+	defer transaction.datastore.mu.Unlock()
+	for id, version := range transaction.reads {
+		var current int64
+		if entity, ok := transaction.datastore.entities[id]; ok {
+			current = entity.version
+		}
+		if current != version {
+			return datastore.ErrConcurrentTransaction
+		}
+	}
+	for memoryQuery, versions := range transaction.queries {
+		results, err := transaction.datastore.run(memoryQuery)
+		if err != nil {
+			return err
+		}
+		if memoryResultVersions(results) != versions {
+			return datastore.ErrConcurrentTransaction
+		}
+	}
+	_, err := transaction.datastore.apply(transaction.mutations)
+	return err
+}
+
+func matchMemoryFilter(entity *memoryEntity, filter datastore.EntityFilter) (bool, error) {
+	switch filter := filter.(type) {
+	case datastore.AndFilter:
+		for _, subFilter := range filter.Filters {
+			ok, err := matchMemoryFilter(entity, subFilter)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case datastore.PropertyFilter:
+		operator := strings.TrimSpace(filter.Operator)
+		if operator != "=" {
+			return matchMemoryRange(entity, filter.FieldName, operator, filter.Value)
+		}
+		if filter.FieldName == "__key__" {
+			key, ok := filter.Value.(*datastore.Key)
+			return ok && key.Equal(entity.key), nil
+		}
+		value, ok := memoryProperty(entity.properties, filter.FieldName)
+		if !ok {
+			return false, nil
+		}
+		if values, ok := value.([]interface{}); ok {
+			for _, value := range values {
+				if equalMemoryValues(value, filter.Value) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return equalMemoryValues(value, filter.Value), nil
+	default:
+		return false, fmt.Errorf("memory datastore: %T filters are not supported", filter)
+	}
+}
+
+// matchMemoryRange matches an inequality filter on the key or a string, integer or time property.
+func matchMemoryRange(entity *memoryEntity, fieldName, operator string, value interface{}) (bool, error) {
+	var comparison int
+	if fieldName == "__key__" {
+		key, ok := value.(*datastore.Key)
+		if !ok {
+			return false, fmt.Errorf("memory datastore: __key__ filters need a key")
+		}
+		comparison = strings.Compare(memoryKey(entity.key), memoryKey(key))
+	} else {
+		stored, ok := memoryProperty(entity.properties, fieldName)
+		if !ok {
+			return false, nil
+		}
+		comparison, ok = compareMemoryValues(stored, value)
+		if !ok {
+			return false, nil
+		}
+	}
+	switch operator {
+	case "<":
+		return comparison < 0, nil
+	case "<=":
+		return comparison <= 0, nil
+	case ">":
+		return comparison > 0, nil
+	case ">=":
+		return comparison >= 0, nil
+	}
+	return false, fmt.Errorf("memory datastore: filter operator %q is not supported", operator)
+}
+
+// compareMemoryValues orders a stored value against a filter value, returning false when they cannot be ordered.
+func compareMemoryValues(stored, value interface{}) (int, bool) {
+	switch stored := stored.(type) {
+	case string:
+		v, ok := value.(string)
+		return strings.Compare(stored, v), ok
+	case int64:
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			switch {
+			case stored < v.Int():
+				return -1, true
+			case stored > v.Int():
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	case time.Time:
+		v, ok := value.(time.Time)
+		return stored.Compare(v), ok
+	}
+	return 0, false
+}
+
+// equalMemoryValues compares a stored value with a filter value, which may be any of the Go types
+// the Datastore client accepts for the stored type.
+func equalMemoryValues(stored, value interface{}) bool {
+	switch stored := stored.(type) {
+	case int64:
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return stored == v.Int()
+		}
+		return false
+	case float64:
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return stored == v.Float()
+		}
+		return false
+	case time.Time:
+		v, ok := value.(time.Time)
+		return ok && stored.Equal(v)
+	case *datastore.Key:
+		v, ok := value.(*datastore.Key)
+		return ok && stored.Equal(v)
+	case []byte:
+		v, ok := value.([]byte)
+		return ok && string(stored) == string(v)
+	default:
+		return reflect.DeepEqual(stored, value)
+	}
+}
+
+func projectMemoryProperties(properties []datastore.Property, projection []string) []datastore.Property {
+	projected := make([]datastore.Property, 0, len(projection))
+	for _, name := range projection {
+		value, ok := memoryProperty(properties, name)
+		if !ok {
+			return nil
+		}
+		projected = append(projected, datastore.Property{Name: name, Value: value})
+	}
+	return projected
+}
+
+func memoryProperty(properties []datastore.Property, name string) (interface{}, bool) {
+	for _, property := range properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return nil, false
+}
+
+func hasMemoryAncestor(key, ancestor *datastore.Key) bool {
+	for ; key != nil; key = key.Parent {
+		if key.Equal(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadMemoryEntity loads properties into dst the same way the Cloud Datastore client does,
+// including setting a "__key__" field to the entity's key.
+func loadMemoryEntity(dst interface{}, key *datastore.Key, properties []datastore.Property) error {
+	if pls, ok := dst.(datastore.PropertyLoadSaver); ok {
+		if keyLoader, ok := dst.(datastore.KeyLoader); ok {
+			if err := keyLoader.LoadKey(key); err != nil {
+				return err
+			}
+		}
+		return pls.Load(properties)
+	}
+	err := datastore.LoadStruct(dst, properties)
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(dst).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if strings.Split(field.Tag.Get("datastore"), ",")[0] == "__key__" && field.Type == reflect.TypeOf(key) {
+			value.Field(i).Set(reflect.ValueOf(key))
+		}
+	}
+	return nil
+}
+
+func memoryProperties(entity *pb.Entity) ([]datastore.Property, error) {
+	properties := make([]datastore.Property, 0, len(entity.Properties))
+	for name, value := range entity.Properties {
+		goValue, err := memoryValue(value)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, datastore.Property{
+			Name:    name,
+			Value:   goValue,
+			NoIndex: value.ExcludeFromIndexes,
+		})
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		return properties[i].Name < properties[j].Name
+	})
+	return properties, nil
+}
+
+func memoryValue(value *pb.Value) (interface{}, error) {
+	switch value := value.ValueType.(type) {
+	case *pb.Value_NullValue:
+		return nil, nil
+	case *pb.Value_BooleanValue:
+		return value.BooleanValue, nil
+	case *pb.Value_IntegerValue:
+		return value.IntegerValue, nil
+	case *pb.Value_DoubleValue:
+		return value.DoubleValue, nil
+	case *pb.Value_TimestampValue:
+		return value.TimestampValue.AsTime(), nil
+	case *pb.Value_KeyValue:
+		return memoryKeyFromProto(value.KeyValue), nil
+	case *pb.Value_StringValue:
+		return value.StringValue, nil
+	case *pb.Value_BlobValue:
+		return value.BlobValue, nil
+	case *pb.Value_GeoPointValue:
+		return datastore.GeoPoint{Lat: value.GeoPointValue.Latitude, Lng: value.GeoPointValue.Longitude}, nil
+	case *pb.Value_EntityValue:
+		properties, err := memoryProperties(value.EntityValue)
+		if err != nil {
+			return nil, err
+		}
+		var key *datastore.Key
+		if value.EntityValue.Key != nil {
+			key = memoryKeyFromProto(value.EntityValue.Key)
+		}
+		return &datastore.Entity{Key: key, Properties: properties}, nil
+	case *pb.Value_ArrayValue:
+		values := make([]interface{}, 0, len(value.ArrayValue.Values))
+		for _, arrayValue := range value.ArrayValue.Values {
+			goValue, err := memoryValue(arrayValue)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, goValue)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("memory datastore: unsupported value type %T", value)
+	}
+}
+
+func memoryKeyFromProto(keyProto *pb.Key) *datastore.Key {
+	var key *datastore.Key
+	for _, element := range keyProto.Path {
+		key = &datastore.Key{
+			Kind:   element.Kind,
+			ID:     element.GetId(),
+			Name:   element.GetName(),
+			Parent: key,
+		}
+		if keyProto.PartitionId != nil {
+			key.Namespace = keyProto.PartitionId.NamespaceId
+		}
+	}
+	return key
+}
+
+// memoryKey identifies an entity by its namespace and full key path.
+func memoryKey(key *datastore.Key) string {
+	var path []string
+	for ; key != nil; key = key.Parent {
+		path = append([]string{fmt.Sprintf("%s\x00%020d\x00%s", key.Kind, key.ID, key.Name)}, path...)
+		if key.Parent == nil {
+			path = append([]string{key.Namespace}, path...)
+		}
+	}
+	return strings.Join(path, "\x01")
+}
+
+// readUnexportedField copies an unexported field of a query or mutation, which the Datastore client only exposes once
+// they have been converted to protocol buffers, to dst. It returns an error when the field does not have dst's type.
+func readUnexportedField(value reflect.Value, name string, dst interface{}) error {
+	field := value.FieldByName(name)
+	dstValue := reflect.ValueOf(dst).Elem()
+	if !field.IsValid() || field.Type() != dstValue.Type() {
+		return fmt.Errorf("memory datastore: %s has no %s field of type %s", value.Type(), name, dstValue.Type())
+	}
+	dstValue.Set(reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem())
+	return nil
+}
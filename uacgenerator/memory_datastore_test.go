@@ -0,0 +1,209 @@
+package uacgenerator_test
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("MemoryDatastore", func() {
+	var (
+		memoryDatastore *uacgenerator.MemoryDatastore
+		uacGenerator    *uacgenerator.UacGenerator
+	)
+
+	BeforeEach(func() {
+		memoryDatastore = uacgenerator.NewMemoryDatastore()
+		uacGenerator = uacgenerator.NewUacGenerator(memoryDatastore, "uac")
+	})
+
+	Describe("Mutate", func() {
+		It("returns an already exists error when inserting an existing entity", func() {
+			key := uacGenerator.UacKey("123412341234")
+			_, err := memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(key, &uacgenerator.UacInfo{InstrumentName: "lolcat"}))
+			Expect(err).To(BeNil())
+
+			_, err = memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(key, &uacgenerator.UacInfo{InstrumentName: "dst2108a"}))
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+			uacInfo, err := uacGenerator.GetUacInfo("123412341234")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.InstrumentName).To(Equal("lolcat"))
+			Expect(uacInfo.UAC).To(Equal(key))
+		})
+
+		It("returns a not found error when updating a missing entity", func() {
+			_, err := memoryDatastore.Mutate(uacGenerator.Context, datastore.NewUpdate(uacGenerator.UacKey("123412341234"), &uacgenerator.UacInfo{}))
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("applies none of the mutations when one fails", func() {
+			_, err := memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(uacGenerator.UacKey("234523452345"), &uacgenerator.UacInfo{}))
+			Expect(err).To(BeNil())
+
+			_, err = memoryDatastore.Mutate(uacGenerator.Context,
+				datastore.NewInsert(uacGenerator.UacKey("123412341234"), &uacgenerator.UacInfo{}),
+				datastore.NewInsert(uacGenerator.UacKey("234523452345"), &uacgenerator.UacInfo{}),
+			)
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+			err = memoryDatastore.Get(uacGenerator.Context, uacGenerator.UacKey("123412341234"), &uacgenerator.UacInfo{})
+			Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		})
+	})
+
+	Describe("RunInTransaction", func() {
+		It("retries when an entity it read is changed before it commits", func() {
+			key := uacGenerator.UacKey("123412341234")
+			attempts := 0
+			err := memoryDatastore.RunInTransaction(uacGenerator.Context, func(transaction uacgenerator.Transaction) error {
+				attempts++
+				err := transaction.Get(key, &uacgenerator.UacInfo{})
+				if err != datastore.ErrNoSuchEntity {
+					return err
+				}
+				if attempts == 1 {
+					_, err = memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(key, &uacgenerator.UacInfo{InstrumentName: "dst2108a"}))
+					Expect(err).To(BeNil())
+				}
+				return transaction.Mutate(datastore.NewInsert(key, &uacgenerator.UacInfo{InstrumentName: "lolcat"}))
+			})
+			Expect(err).To(BeNil())
+			Expect(attempts).To(Equal(2))
+
+			uacInfo, err := uacGenerator.GetUacInfo("123412341234")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.InstrumentName).To(Equal("dst2108a"))
+		})
+
+		It("retries when the results of a query it ran change before it commits", func() {
+			instrumentKey := datastore.NameKey("instrument", "lolcat", nil)
+			attempts := 0
+			var caseUacs []*uacgenerator.CaseUac
+			err := memoryDatastore.RunInTransaction(uacGenerator.Context, func(transaction uacgenerator.Transaction) error {
+				attempts++
+				caseUacs = nil
+				_, err := transaction.GetAll(datastore.NewQuery("case_uac").Ancestor(instrumentKey), &caseUacs)
+				if err != nil {
+					return err
+				}
+				if attempts == 1 {
+					_, err = memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(
+						datastore.NameKey("case_uac", "1", instrumentKey),
+						&uacgenerator.CaseUac{UacKind: "uac", UAC: "123412341234"},
+					))
+					Expect(err).To(BeNil())
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(attempts).To(Equal(2))
+			Expect(caseUacs).To(HaveLen(1))
+		})
+	})
+
+	Describe("the generator", func() {
+		It("generates, counts, lists and deletes UACs", func() {
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(HaveLen(3))
+			_, err = uacGenerator.Generate("dst2108a", []string{"1"})
+			Expect(err).To(BeNil())
+
+			result, err = uacGenerator.Generate("lolcat", []string{"3", "4"})
+			Expect(err).To(BeNil())
+			Expect(result.Existing).To(Equal([]string{"3"}))
+			Expect(result.Generated).To(Equal([]string{"4"}))
+
+			uacCount, err := uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacCount).To(Equal(4))
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs).To(HaveLen(4))
+			Expect(uacs["4"].UAC).ToNot(BeNil())
+
+			instrumentNames, err := uacGenerator.GetInstruments()
+			Expect(err).To(BeNil())
+			Expect(instrumentNames).To(ConsistOf("lolcat", "dst2108a"))
+
+			Expect(uacGenerator.AdminDelete("lolcat")).To(Succeed())
+			uacCount, err = uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacCount).To(Equal(0))
+
+			result, err = uacGenerator.Generate("lolcat", []string{"3"})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(Equal([]string{"3"}))
+		})
+
+		It("finds cases given UACs before case index entities were introduced, and UACs replaced by a reissue", func() {
+			_, err := memoryDatastore.Mutate(uacGenerator.Context, datastore.NewInsert(
+				uacGenerator.UacKey("123412341234"),
+				&uacgenerator.UacInfo{InstrumentName: "lolcat", CaseID: "1"},
+			))
+			Expect(err).To(BeNil())
+			_, err = uacGenerator.Generate("lolcat", []string{"2"})
+			Expect(err).To(BeNil())
+			_, err = uacGenerator.ReissueUac("lolcat", "2", 0, "")
+			Expect(err).To(BeNil())
+
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+			Expect(err).To(BeNil())
+			Expect(result.Existing).To(Equal([]string{"1", "2"}))
+			Expect(result.Generated).To(Equal([]string{"3"}))
+		})
+
+		It("resumes generation jobs whose lease has expired", func() {
+			Expect(uacGenerator.Store.InsertGenerationJob(uacGenerator.Context, &uacgenerator.GenerationJob{
+				ID:             "stopped",
+				InstrumentName: "lolcat",
+				Status:         uacgenerator.JOBRUNNING,
+				UacsPerCase:    2,
+				Total:          5,
+				Processed:      2,
+				Created:        2,
+				RunnerID:       "stopped",
+				LeaseExpires:   time.Now().Add(-time.Minute),
+			}, []uacgenerator.JobCase{{CaseID: "1"}, {CaseID: "2", Uacs: 1}, {CaseID: "3"}})).To(Succeed())
+
+			resumed, err := uacGenerator.ResumeGenerationJobs()
+			Expect(err).To(BeNil())
+			Expect(resumed).To(Equal([]string{"stopped"}))
+			var job *uacgenerator.GenerationJob
+			Eventually(func() string {
+				job, err = uacGenerator.GetGenerationJob("stopped")
+				Expect(err).To(BeNil())
+				return job.Status
+			}).Should(Equal(uacgenerator.JOBCOMPLETED))
+			Expect(job.Processed).To(Equal(5))
+			Expect(job.Created).To(Equal(5))
+			Expect(job.Resumed).To(Equal(1))
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs).To(HaveLen(3))
+			Expect(uacs).To(HaveKey("2"))
+			Expect(uacs).To(HaveKey("3"))
+		})
+
+		It("disables UACs", func() {
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacGenerator.DisableUac(uacs[result.Generated[0]].UAC.Name)).To(Succeed())
+
+			disabled, err := uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(1))
+		})
+	})
+})
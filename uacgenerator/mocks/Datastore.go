@@ -7,6 +7,8 @@ import (
 
 	datastore "cloud.google.com/go/datastore"
 	mock "github.com/stretchr/testify/mock"
+
+	uacgenerator "github.com/ONSDigital/blaise-uac-service/uacgenerator"
 )
 
 // Datastore is an autogenerated mock type for the Datastore type
@@ -100,16 +102,39 @@ func (_m *Datastore) GetAll(_a0 context.Context, _a1 *datastore.Query, _a2 inter
 	return r0, r1
 }
 
+// Run provides a mock function with given fields: _a0, _a1
+func (_m *Datastore) Run(_a0 context.Context, _a1 *datastore.Query) uacgenerator.Iterator {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 uacgenerator.Iterator
+	if rf, ok := ret.Get(0).(func(context.Context, *datastore.Query) uacgenerator.Iterator); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uacgenerator.Iterator)
+		}
+	}
+
+	return r0
+}
+
+// RunInTransaction provides a mock function with given fields: _a0, _a1
+func (_m *Datastore) RunInTransaction(_a0 context.Context, _a1 func(uacgenerator.Transaction) error) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(uacgenerator.Transaction) error) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Mutate provides a mock function with given fields: _a0, _a1
 func (_m *Datastore) Mutate(_a0 context.Context, _a1 ...*datastore.Mutation) ([]*datastore.Key, error) {
-	_va := make([]interface{}, len(_a1))
-	for _i := range _a1 {
-		_va[_i] = _a1[_i]
-	}
-	var _ca []interface{}
-	_ca = append(_ca, _a0)
-	_ca = append(_ca, _va...)
-	ret := _m.Called(_ca...)
+	ret := _m.Called(_a0, _a1)
 
 	var r0 []*datastore.Key
 	if rf, ok := ret.Get(0).(func(context.Context, ...*datastore.Mutation) []*datastore.Key); ok {
@@ -3,8 +3,12 @@
 package mocks
 
 import (
+	io "io"
+
 	uacgenerator "github.com/ONSDigital/blaise-uac-service/uacgenerator"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // UacGeneratorInterface is an autogenerated mock type for the UacGeneratorInterface type
@@ -61,6 +65,193 @@ func (_m *UacGeneratorInterface) EnableUac(_a0 string) error {
 	return r0
 }
 
+// DisableUacs provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) DisableUacs(_a0 string, _a1 uacgenerator.BulkUacRequest) (*uacgenerator.BulkUacResult, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *uacgenerator.BulkUacResult
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.BulkUacRequest) *uacgenerator.BulkUacResult); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.BulkUacResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.BulkUacRequest) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnableUacs provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) EnableUacs(_a0 string, _a1 uacgenerator.BulkUacRequest) (*uacgenerator.BulkUacResult, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *uacgenerator.BulkUacResult
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.BulkUacRequest) *uacgenerator.BulkUacResult); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.BulkUacResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.BulkUacRequest) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReissueUac provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *UacGeneratorInterface) ReissueUac(_a0 string, _a1 string, _a2 int, _a3 string) (*uacgenerator.UacInfo, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *uacgenerator.UacInfo
+	if rf, ok := ret.Get(0).(func(string, string, int, string) *uacgenerator.UacInfo); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.UacInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, int, string) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInstrumentValidity provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) GetInstrumentValidity(_a0 string) (uacgenerator.Validity, error) {
+	ret := _m.Called(_a0)
+
+	var r0 uacgenerator.Validity
+	if rf, ok := ret.Get(0).(func(string) uacgenerator.Validity); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(uacgenerator.Validity)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetInstrumentValidity provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) SetInstrumentValidity(_a0 string, _a1 uacgenerator.Validity) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.Validity) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUacValidity provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) SetUacValidity(_a0 string, _a1 uacgenerator.Validity) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.Validity) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetUacsDenied provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) GetUacsDenied(_a0 string) (uacgenerator.Uacs, error) {
+	ret := _m.Called(_a0)
+
+	var r0 uacgenerator.Uacs
+	if rf, ok := ret.Get(0).(func(string) uacgenerator.Uacs); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uacgenerator.Uacs)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUacsExpiring provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) GetUacsExpiring(_a0 string, _a1 time.Duration) (uacgenerator.Uacs, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 uacgenerator.Uacs
+	if rf, ok := ret.Get(0).(func(string, time.Duration) uacgenerator.Uacs); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uacgenerator.Uacs)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, time.Duration) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuthenticateUac provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) AuthenticateUac(_a0 string) (*uacgenerator.UacInfo, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *uacgenerator.UacInfo
+	if rf, ok := ret.Get(0).(func(string) *uacgenerator.UacInfo); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.UacInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AdminDelete provides a mock function with given fields: _a0
 func (_m *UacGeneratorInterface) AdminDelete(_a0 string) error {
 	ret := _m.Called(_a0)
@@ -75,18 +266,96 @@ func (_m *UacGeneratorInterface) AdminDelete(_a0 string) error {
 	return r0
 }
 
+// GetInstrumentArchives provides a mock function with given fields:
+func (_m *UacGeneratorInterface) GetInstrumentArchives() ([]*uacgenerator.InstrumentArchive, error) {
+	ret := _m.Called()
+
+	var r0 []*uacgenerator.InstrumentArchive
+	if rf, ok := ret.Get(0).(func() []*uacgenerator.InstrumentArchive); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*uacgenerator.InstrumentArchive)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreInstrument provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) RestoreInstrument(_a0 string) (*uacgenerator.RestoreResult, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *uacgenerator.RestoreResult
+	if rf, ok := ret.Get(0).(func(string) *uacgenerator.RestoreResult); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.RestoreResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeArchives provides a mock function with given fields:
+func (_m *UacGeneratorInterface) PurgeArchives() ([]string, error) {
+	ret := _m.Called()
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Generate provides a mock function with given fields: _a0, _a1
-func (_m *UacGeneratorInterface) Generate(_a0 string, _a1 []string) error {
+func (_m *UacGeneratorInterface) Generate(_a0 string, _a1 []string) (*uacgenerator.GenerateResult, error) {
 	ret := _m.Called(_a0, _a1)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(string, []string) error); ok {
+	var r0 *uacgenerator.GenerateResult
+	if rf, ok := ret.Get(0).(func(string, []string) *uacgenerator.GenerateResult); ok {
 		r0 = rf(_a0, _a1)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.GenerateResult)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, []string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // GetAllUacs provides a mock function with given fields: _a0
@@ -222,3 +491,275 @@ func (_m *UacGeneratorInterface) ImportUACs(_a0 []string) (int, error) {
 
 	return r0, r1
 }
+
+// GetInstrumentUacKind provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) GetInstrumentUacKind(_a0 string) (string, error) {
+	ret := _m.Called(_a0)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetInstrumentUacKind provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) SetInstrumentUacKind(_a0 string, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GenerateWithOptions provides a mock function with given fields: _a0, _a1, _a2
+func (_m *UacGeneratorInterface) GenerateWithOptions(_a0 string, _a1 []string, _a2 uacgenerator.GenerateOptions) (*uacgenerator.GenerateResult, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *uacgenerator.GenerateResult
+	if rf, ok := ret.Get(0).(func(string, []string, uacgenerator.GenerateOptions) *uacgenerator.GenerateResult); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.GenerateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, []string, uacgenerator.GenerateOptions) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartGenerationJob provides a mock function with given fields: _a0, _a1, _a2
+func (_m *UacGeneratorInterface) StartGenerationJob(_a0 string, _a1 []string, _a2 uacgenerator.GenerateOptions) (*uacgenerator.GenerationJob, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *uacgenerator.GenerationJob
+	if rf, ok := ret.Get(0).(func(string, []string, uacgenerator.GenerateOptions) *uacgenerator.GenerationJob); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.GenerationJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, []string, uacgenerator.GenerateOptions) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGenerationJob provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) GetGenerationJob(_a0 string) (*uacgenerator.GenerationJob, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *uacgenerator.GenerationJob
+	if rf, ok := ret.Get(0).(func(string) *uacgenerator.GenerationJob); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.GenerationJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelGenerationJob provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) CancelGenerationJob(_a0 string) (*uacgenerator.GenerationJob, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *uacgenerator.GenerationJob
+	if rf, ok := ret.Get(0).(func(string) *uacgenerator.GenerationJob); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.GenerationJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUacsPage provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *UacGeneratorInterface) GetUacsPage(_a0 string, _a1 uacgenerator.UacFilter, _a2 string, _a3 int) (*uacgenerator.UacPage, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *uacgenerator.UacPage
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.UacFilter, string, int) *uacgenerator.UacPage); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.UacPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.UacFilter, string, int) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUacsPageByCaseID provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *UacGeneratorInterface) GetUacsPageByCaseID(_a0 string, _a1 uacgenerator.UacFilter, _a2 string, _a3 int) (*uacgenerator.UacPage, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *uacgenerator.UacPage
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.UacFilter, string, int) *uacgenerator.UacPage); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.UacPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.UacFilter, string, int) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StreamUacs provides a mock function with given fields: _a0, _a1, _a2
+func (_m *UacGeneratorInterface) StreamUacs(_a0 string, _a1 uacgenerator.UacFilter, _a2 func(*uacgenerator.UacInfo) error) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.UacFilter, func(*uacgenerator.UacInfo) error) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExportUacs provides a mock function with given fields: _a0, _a1, _a2
+func (_m *UacGeneratorInterface) ExportUacs(_a0 string, _a1 uacgenerator.ExportOptions, _a2 io.Writer) (*uacgenerator.ExportTrailer, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *uacgenerator.ExportTrailer
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.ExportOptions, io.Writer) *uacgenerator.ExportTrailer); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.ExportTrailer)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.ExportOptions, io.Writer) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveEncryptedExport provides a mock function with given fields: _a0, _a1
+func (_m *UacGeneratorInterface) SaveEncryptedExport(_a0 string, _a1 uacgenerator.ExportOptions) (*uacgenerator.ExportManifest, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *uacgenerator.ExportManifest
+	if rf, ok := ret.Get(0).(func(string, uacgenerator.ExportOptions) *uacgenerator.ExportManifest); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.ExportManifest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, uacgenerator.ExportOptions) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAuditEntries provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) GetAuditEntries(_a0 uacgenerator.AuditQuery) (*uacgenerator.AuditPage, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *uacgenerator.AuditPage
+	if rf, ok := ret.Get(0).(func(uacgenerator.AuditQuery) *uacgenerator.AuditPage); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*uacgenerator.AuditPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uacgenerator.AuditQuery) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WithActor provides a mock function with given fields: _a0
+func (_m *UacGeneratorInterface) WithActor(_a0 string) uacgenerator.UacGeneratorInterface {
+	ret := _m.Called(_a0)
+
+	var r0 uacgenerator.UacGeneratorInterface
+	if rf, ok := ret.Get(0).(func(string) uacgenerator.UacGeneratorInterface); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uacgenerator.UacGeneratorInterface)
+		}
+	}
+
+	return r0
+}
@@ -0,0 +1,87 @@
+package uacgenerator
+
+// MAXPAGESIZE is the most UACs returned in one page.
+const MAXPAGESIZE = 1000
+
+// UacPage is one page of an instrument's UACs. Cursor fetches the next page and is empty on the last page.
+type UacPage struct {
+	Uacs   Uacs   `json:"uacs"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// GetUacsPage returns a page of an instrument's UACs that match filter keyed by UAC, starting after cursor.
+// A limit outside 1 to MAXPAGESIZE returns MAXPAGESIZE UACs.
+func (uacGenerator *UacGenerator) GetUacsPage(instrumentName string, filter UacFilter, cursor string, limit int) (*UacPage, error) {
+	return uacGenerator.getUacsPage(instrumentName, filter, cursor, limit, false)
+}
+
+// GetUacsPageByCaseID returns a page of an instrument's UACs that match filter keyed by CaseSlotKey, starting after
+// cursor.
+func (uacGenerator *UacGenerator) GetUacsPageByCaseID(instrumentName string, filter UacFilter, cursor string, limit int) (*UacPage, error) {
+	return uacGenerator.getUacsPage(instrumentName, filter, cursor, limit, true)
+}
+
+func (uacGenerator *UacGenerator) getUacsPage(instrumentName string, filter UacFilter, cursor string, limit int, byCaseID bool) (*UacPage, error) {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MAXPAGESIZE {
+		limit = MAXPAGESIZE
+	}
+	page := &UacPage{Uacs: make(Uacs)}
+	page.Cursor, err = uacGenerator.Store.IterateUacs(uacGenerator.Context, uacKind, instrumentName, filter, cursor, limit, func(uacInfo *UacInfo) error {
+		if byCaseID {
+			// A case slot is listed with its current UAC
+			if uacInfo.ReissuedTo != "" {
+				return nil
+			}
+			uacGenerator.showUac(uacInfo)
+			page.Uacs[uacInfo.CaseSlot()] = uacInfo
+			return nil
+		}
+		if uacGenerator.UacKeyer != nil {
+			uacInfo.UacHash = uacGenerator.uacHash(uacInfo)
+			uacGenerator.showLinks(uacInfo)
+			page.Uacs[uacInfo.UacHash] = uacInfo
+			return nil
+		}
+		page.Uacs[uacInfo.UAC.Name] = uacInfo
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Uacs.BuildUacChunks()
+	return page, nil
+}
+
+// StreamUacs calls f with each of an instrument's UACs that match filter, with its full UAC and chunks, as they
+// are read from the store, so the instrument's UACs are never all held in memory. When UACs are keyed only their
+// hashes are shown.
+func (uacGenerator *UacGenerator) StreamUacs(instrumentName string, filter UacFilter, f func(*UacInfo) error) error {
+	return uacGenerator.streamUacs(instrumentName, filter, false, f)
+}
+
+// streamUacs reveals keyed UACs when reveal is set, which is only for encrypted exports.
+func (uacGenerator *UacGenerator) streamUacs(instrumentName string, filter UacFilter, reveal bool, f func(*UacInfo) error) error {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return err
+	}
+	_, err = uacGenerator.Store.IterateUacs(uacGenerator.Context, uacKind, instrumentName, filter, "", 0, func(uacInfo *UacInfo) error {
+		if reveal {
+			err := uacGenerator.revealUac(uacInfo)
+			if err != nil {
+				return err
+			}
+		} else {
+			uacGenerator.showUac(uacInfo)
+		}
+		if uacInfo.FullUAC != "" {
+			uacInfo.UacChunks = ChunkUAC(uacInfo.FullUAC)
+		}
+		return f(uacInfo)
+	})
+	return err
+}
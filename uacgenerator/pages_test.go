@@ -0,0 +1,89 @@
+package uacgenerator_test
+
+import (
+	"fmt"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Paging UACs", func() {
+	var caseIDs []string
+
+	BeforeEach(func() {
+		caseIDs = []string{}
+		for i := 0; i < 25; i++ {
+			caseIDs = append(caseIDs, fmt.Sprintf("%d", 1000+i))
+		}
+	})
+
+	pagingSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			_, err := uacGenerator.Generate("lolcat", caseIDs)
+			Expect(err).To(BeNil())
+			_, err = uacGenerator.Generate("dst2108a", caseIDs[:5])
+			Expect(err).To(BeNil())
+		})
+
+		It("returns every UAC once across the pages", func() {
+			pagedCaseIDs := []string{}
+			cursor := ""
+			for pages := 1; ; pages++ {
+				page, err := uacGenerator.GetUacsPageByCaseID("lolcat", uacgenerator.UacFilter{}, cursor, 10)
+				Expect(err).To(BeNil())
+				Expect(len(page.Uacs)).To(BeNumerically("<=", 10))
+				for caseID, uacInfo := range page.Uacs {
+					Expect(uacInfo.FullUAC).ToNot(BeEmpty())
+					Expect(uacInfo.UacChunks).ToNot(BeNil())
+					pagedCaseIDs = append(pagedCaseIDs, caseID)
+				}
+				if page.Cursor == "" {
+					Expect(pages).To(Equal(3))
+					break
+				}
+				cursor = page.Cursor
+			}
+			Expect(pagedCaseIDs).To(ConsistOf(caseIDs))
+		})
+
+		It("streams every UAC", func() {
+			streamedCaseIDs := []string{}
+			err := uacGenerator.StreamUacs("lolcat", uacgenerator.UacFilter{}, func(uacInfo *uacgenerator.UacInfo) error {
+				Expect(uacInfo.FullUAC).ToNot(BeEmpty())
+				streamedCaseIDs = append(streamedCaseIDs, uacInfo.CaseID)
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(streamedCaseIDs).To(ConsistOf(caseIDs))
+		})
+
+		It("stops streaming when f returns an error", func() {
+			streamed := 0
+			err := uacGenerator.StreamUacs("lolcat", uacgenerator.UacFilter{}, func(uacInfo *uacgenerator.UacInfo) error {
+				streamed++
+				return fmt.Errorf("client went away")
+			})
+			Expect(err).To(MatchError("client went away"))
+			Expect(streamed).To(Equal(1))
+		})
+
+		It("returns ErrInvalidCursor for a cursor it did not return", func() {
+			_, err := uacGenerator.GetUacsPage("lolcat", uacgenerator.UacFilter{}, "not a cursor!", 10)
+			Expect(err).To(Equal(uacgenerator.ErrInvalidCursor))
+		})
+	}
+
+	Context("with Datastore", func() {
+		pagingSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		pagingSpecs(newSQLUacGenerator)
+	})
+})
@@ -0,0 +1,122 @@
+package uacgenerator
+
+import (
+	"strings"
+	"time"
+)
+
+// PatternRule spots UACs following a pattern, which respondents find suspicious and which are easier to guess.
+// Rules are only applied to numeric UACs.
+type PatternRule interface {
+	// Name identifies the rule a UAC broke.
+	Name() string
+	Match(uac string) bool
+}
+
+// DefaultPatternRules returns the rules generated UACs are held to unless the generator is given others.
+func DefaultPatternRules() []PatternRule {
+	return []PatternRule{RepeatedBlocksRule{}, SequentialRunRule{Length: 4}, PalindromeRule{}, DateRule{}}
+}
+
+// RepeatedBlocksRule matches UACs with a block of one digit repeated, like 1111, or with two blocks the same.
+type RepeatedBlocksRule struct{}
+
+func (RepeatedBlocksRule) Name() string {
+	return "repeated_blocks"
+}
+
+func (RepeatedBlocksRule) Match(uac string) bool {
+	seenBlocks := make(map[string]bool)
+	for start := 0; start+4 <= len(uac); start += 4 {
+		block := uac[start : start+4]
+		if seenBlocks[block] || strings.Count(block, block[:1]) == 4 {
+			return true
+		}
+		seenBlocks[block] = true
+	}
+	return false
+}
+
+// SequentialRunRule matches UACs with Length digits in a row that each count up or each count down by one, like
+// 3456 or 8765.
+type SequentialRunRule struct {
+	Length int
+}
+
+func (SequentialRunRule) Name() string {
+	return "sequential_run"
+}
+
+func (rule SequentialRunRule) Match(uac string) bool {
+	if rule.Length < 2 {
+		return false
+	}
+	up, down := 1, 1
+	for i := 1; i < len(uac); i++ {
+		if uac[i] == uac[i-1]+1 {
+			up++
+		} else {
+			up = 1
+		}
+		if uac[i] == uac[i-1]-1 {
+			down++
+		} else {
+			down = 1
+		}
+		if up >= rule.Length || down >= rule.Length {
+			return true
+		}
+	}
+	return false
+}
+
+// PalindromeRule matches UACs that read the same backwards.
+type PalindromeRule struct{}
+
+func (PalindromeRule) Name() string {
+	return "palindrome"
+}
+
+func (PalindromeRule) Match(uac string) bool {
+	for i, j := 0, len(uac)-1; i < j; i, j = i+1, j-1 {
+		if uac[i] != uac[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// DateRule matches UACs with eight digits in a row that are a date from 1900 to 2099 written DDMMYYYY or
+// YYYYMMDD, like a date of birth.
+type DateRule struct{}
+
+func (DateRule) Name() string {
+	return "date"
+}
+
+func (DateRule) Match(uac string) bool {
+	for start := 0; start+8 <= len(uac); start++ {
+		digits := uac[start : start+8]
+		for _, layout := range []string{"02012006", "20060102"} {
+			date, err := time.Parse(layout, digits)
+			if err == nil && date.Year() >= 1900 && date.Year() <= 2099 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WeakPattern returns the name of the first of the generator's pattern rules a numeric UAC breaks, or "" when it
+// breaks none or is not numeric.
+func (uacGenerator *UacGenerator) WeakPattern(uac string) string {
+	if uac == "" || strings.Trim(uac, "0123456789") != "" {
+		return ""
+	}
+	for _, rule := range uacGenerator.PatternRules {
+		if rule.Match(uac) {
+			return rule.Name()
+		}
+	}
+	return ""
+}
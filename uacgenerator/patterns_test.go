@@ -0,0 +1,107 @@
+package uacgenerator_test
+
+import (
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("WeakPattern",
+	func(uac, expected string) {
+		uacGenerator := &uacgenerator.UacGenerator{PatternRules: uacgenerator.DefaultPatternRules()}
+		Expect(uacGenerator.WeakPattern(uac)).To(Equal(expected))
+	},
+	Entry("a UAC without a pattern", "582061937405", ""),
+	Entry("blocks of one digit", "111122223333", "repeated_blocks"),
+	Entry("a block of one digit", "582077773740", "repeated_blocks"),
+	Entry("blocks the same", "582019375820", "repeated_blocks"),
+	Entry("digits counting up", "582034567405", "sequential_run"),
+	Entry("digits counting down across blocks", "582098769405", "sequential_run"),
+	Entry("a palindrome", "582061160285", "palindrome"),
+	Entry("a DDMMYYYY date", "529021980745", "date"),
+	Entry("a YYYYMMDD date", "582019820315", "date"),
+	Entry("an impossible date", "587731022019", ""),
+	Entry("a date outside 1900 to 2099", "529021880745", ""),
+	Entry("a 16 character UAC", "bbbbbbbbbbbbbbbb", ""),
+)
+
+var _ = Describe("Weak patterns", func() {
+	var (
+		uacGenerator *uacgenerator.UacGenerator
+		queued       []string
+	)
+
+	BeforeEach(func() {
+		uacgenerator.RegisterUacFormat("uacqueued", queuedUacFormat{uacs: &queued})
+		uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uacqueued")
+	})
+
+	AfterEach(func() {
+		uacgenerator.UnregisterUacFormat("uacqueued")
+	})
+
+	It("generates UACs again when they follow a weak pattern", func() {
+		queued = []string{"1111222233334444", "1234123412341234", "5820619374058206"}
+		Expect(uacGenerator.NewUac("lolcat", "1", 0)).To(Equal("5820619374058206"))
+	})
+
+	It("gives up when every UAC follows a weak pattern", func() {
+		queued = make([]string, uacgenerator.MAXREJECTEDATTEMPTS)
+		for i := range queued {
+			queued[i] = "1111222233334444"
+		}
+		_, err := uacGenerator.NewUac("lolcat", "1", 0)
+		Expect(err).To(Equal(uacgenerator.ErrUacWeak))
+	})
+
+	It("generates UACs following weak patterns without pattern rules", func() {
+		uacGenerator.PatternRules = nil
+		queued = []string{"1111222233334444"}
+		Expect(uacGenerator.NewUac("lolcat", "1", 0)).To(Equal("1111222233334444"))
+	})
+
+	It("uses the pattern rules it is given", func() {
+		uacGenerator.PatternRules = []uacgenerator.PatternRule{uacgenerator.SequentialRunRule{Length: 3}}
+		queued = []string{"5820619374567206", "1111619374058206"}
+		Expect(uacGenerator.NewUac("lolcat", "1", 0)).To(Equal("1111619374058206"))
+	})
+
+	It("fails only the cases whose UACs all follow weak patterns when generating", func() {
+		queued = []string{"5820619374058206"}
+		for i := 0; i < uacgenerator.MAXREJECTEDATTEMPTS; i++ {
+			queued = append(queued, "1111222233334444")
+		}
+		queued = append(queued, "7394058206158206")
+
+		result, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+		Expect(err).To(BeNil())
+		Expect(result.Generated).To(Equal([]string{"1", "3"}))
+		Expect(result.Existing).To(BeEmpty())
+		Expect(result.Failed).To(Equal([]uacgenerator.CaseFailure{{CaseID: "2", Error: uacgenerator.ErrUacWeak.Error()}}))
+
+		uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+		Expect(err).To(BeNil())
+		Expect(uacs).To(HaveLen(2))
+		Expect(uacs["1"].UAC.Name).To(Equal("5820619374058206"))
+		Expect(uacs["3"].UAC.Name).To(Equal("7394058206158206"))
+	})
+
+	Describe("importing", func() {
+		BeforeEach(func() {
+			uacGenerator = uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+
+		It("imports UACs following weak patterns unless asked not to", func() {
+			Expect(uacGenerator.ImportUACs([]string{"123412341234"})).To(Equal(1))
+
+			uacGenerator.RejectWeakImports = true
+			_, err := uacGenerator.ImportUACs([]string{"582061937405", "111122223333"})
+			Expect(err).To(MatchError("Cannot import UACs because some followed weak patterns: [\"111122223333\"]"))
+			Expect(uacGenerator.ValidateUACs([]string{"582061937405", "2313", "111122223333"})).To(Equal(&uacgenerator.ImportError{
+				InvalidUACs: []string{"2313"},
+				WeakUACs:    []string{"111122223333"},
+			}))
+		})
+	})
+})
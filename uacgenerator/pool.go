@@ -0,0 +1,134 @@
+package uacgenerator
+
+import (
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+	"github.com/zenthangplus/goccm"
+)
+
+type GenerateOptions struct {
+	// ClaimFromPool assigns imported UACs from the unknown instrument to cases
+	// before generating new UACs.
+	ClaimFromPool bool
+	// UacsPerCase is how many UACs each case needs, one for each respondent slot. Cases need one when it is zero.
+	UacsPerCase int
+	// CaseUacs overrides UacsPerCase for the cases it gives by case ID.
+	CaseUacs map[string]int
+}
+
+// Validate returns ErrInvalidUacsPerCase when a case would need no UACs or more than MAXUACSPERCASE.
+func (options GenerateOptions) Validate() error {
+	if options.UacsPerCase < 0 || options.UacsPerCase > MAXUACSPERCASE {
+		return ErrInvalidUacsPerCase
+	}
+	for _, uacCount := range options.CaseUacs {
+		if uacCount < 1 || uacCount > MAXUACSPERCASE {
+			return ErrInvalidUacsPerCase
+		}
+	}
+	return nil
+}
+
+// caseSlots returns the slots of the cases that need a UAC, in the order the cases were given. A blank case ID
+// cannot be given any UACs, so it is only given one slot to fail.
+func (options GenerateOptions) caseSlots(caseIDs []string) []respondentSlot {
+	caseUacs := make(map[string]int, len(options.CaseUacs))
+	for caseID, uacCount := range options.CaseUacs {
+		caseUacs[strings.ToLower(caseID)] = uacCount
+	}
+	caseSlots := make([]respondentSlot, 0, len(caseIDs))
+	for _, caseID := range caseIDs {
+		uacCount := max(options.UacsPerCase, 1)
+		if caseUacCount, ok := caseUacs[strings.ToLower(caseID)]; ok {
+			uacCount = caseUacCount
+		}
+		if validateCaseID(caseID) != nil {
+			uacCount = 1
+		}
+		for slot := 0; slot < uacCount; slot++ {
+			caseSlots = append(caseSlots, respondentSlot{caseID: caseID, slot: slot})
+		}
+	}
+	return caseSlots
+}
+
+type uacSource int
+
+const (
+	uacExisted uacSource = iota
+	uacGenerated
+	uacFromPool
+	// uacFailed is reported with the error of a case slot that could not be given a UAC.
+	uacFailed
+)
+
+// uacPool hands out candidate UACs from the unknown instrument to the cases of a single generation.
+type uacPool struct {
+	uacKeys chan *datastore.Key
+}
+
+func (uacGenerator *UacGenerator) newUacPool(uacKind string, size int) (*uacPool, error) {
+	uacKeys, err := uacGenerator.Store.ListPoolUacs(uacGenerator.Context, uacKind, size)
+	if err != nil {
+		return nil, err
+	}
+	pool := &uacPool{uacKeys: make(chan *datastore.Key, len(uacKeys))}
+	for _, uacKey := range uacKeys {
+		pool.uacKeys <- uacKey
+	}
+	close(pool.uacKeys)
+	return pool, nil
+}
+
+// claimPoolUac assigns the next available pool UAC to a case slot, returning false when the pool is empty.
+func (uacGenerator *UacGenerator) claimPoolUac(pool *uacPool, instrumentName string, caseSlot respondentSlot) (bool, error) {
+	for uacKey := range pool.uacKeys {
+		auditEntries := uacGenerator.auditEntries(uacAuditEntry(AUDITCLAIM, uacKey,
+			&UacInfo{InstrumentName: UNKNOWNINSTRUMENT, CaseID: UNKNOWNINSTRUMENT},
+			&UacInfo{InstrumentName: instrumentName, CaseID: caseSlot.caseID, Slot: caseSlot.slot},
+		))
+		claimed, err := uacGenerator.Store.ClaimPoolUac(uacGenerator.Context, uacKey, instrumentName, caseSlot.caseID, caseSlot.slot, auditEntries)
+		if err != nil {
+			return false, err
+		}
+		if claimed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// claimPoolUacs claims pool UACs for the case slots concurrently and returns the slots left without one.
+func (uacGenerator *UacGenerator) claimPoolUacs(pool *uacPool, instrumentName string, caseSlots []respondentSlot, report func(string, uacSource, error)) []respondentSlot {
+	var (
+		unclaimedMu        sync.Mutex
+		unclaimedCaseSlots []respondentSlot
+	)
+	concurrent := goccm.New(MAXCONCURRENT)
+	for _, caseSlot := range caseSlots {
+		concurrent.Wait()
+		go func(caseSlot respondentSlot) {
+			defer concurrent.Done()
+			claimed, err := uacGenerator.claimPoolUac(pool, instrumentName, caseSlot)
+			if err == ErrCaseHasUac {
+				report(caseSlot.key(), uacExisted, nil)
+				return
+			}
+			if err != nil {
+				report(caseSlot.key(), uacFailed, err)
+				return
+			}
+			if claimed {
+				report(caseSlot.key(), uacFromPool, nil)
+				return
+			}
+			unclaimedMu.Lock()
+			unclaimedCaseSlots = append(unclaimedCaseSlots, caseSlot)
+			unclaimedMu.Unlock()
+		}(caseSlot)
+	}
+	concurrent.WaitAllDone()
+	return unclaimedCaseSlots
+}
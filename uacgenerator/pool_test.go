@@ -0,0 +1,163 @@
+package uacgenerator_test
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("GenerateWithOptions", func() {
+	var (
+		uacGenerator    *uacgenerator.UacGenerator
+		mockDatastore   *mocks.Datastore
+		mockTransaction *mocks.Transaction
+		instrumentName  = "lolcat"
+		caseIDs         = []string{"74628568", "74628561", "74628562"}
+		poolUACs        []string
+	)
+
+	BeforeEach(func() {
+		mockDatastore = &mocks.Datastore{}
+		mockTransaction = &mocks.Transaction{}
+		uacGenerator = uacgenerator.NewUacGenerator(mockDatastore, "uac")
+
+		mockDatastore.On("Get",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.InstrumentConfig"),
+		).Return(func(ctx context.Context, key *datastore.Key, dst interface{}) error {
+			*dst.(*uacgenerator.InstrumentConfig) = uacgenerator.InstrumentConfig{UacKind: "uac"}
+			return nil
+		})
+
+		mockDatastore.On("GetAll",
+			uacGenerator.Context,
+			mock.AnythingOfType("*datastore.Query"),
+			nil,
+		).Return(func(ctx context.Context, qry *datastore.Query, dst interface{}) []*datastore.Key {
+			var uacKeys []*datastore.Key
+			for _, uac := range poolUACs {
+				uacKeys = append(uacKeys, uacGenerator.UacKey(uac))
+			}
+			return uacKeys
+		}, nil)
+
+		listCaseSlots(mockDatastore, nil)
+
+		mockDatastore.On("Mutate",
+			uacGenerator.Context,
+			mock.AnythingOfType("[]*datastore.Mutation"),
+		).Return(nil, nil)
+
+		mockDatastore.On("RunInTransaction",
+			uacGenerator.Context,
+			mock.Anything,
+		).Return(func(ctx context.Context, f func(uacgenerator.Transaction) error) error {
+			return f(mockTransaction)
+		})
+
+		mockTransaction.On("Mutate", mock.AnythingOfType("[]*datastore.Mutation")).Return(nil)
+
+		mockTransaction.On("Get",
+			mock.AnythingOfType("*datastore.Key"),
+			mock.AnythingOfType("*uacgenerator.CaseUac"),
+		).Return(datastore.ErrNoSuchEntity)
+		readNoInstrumentConfigs(mockTransaction)
+	})
+
+	Context("when the pool has enough UACs for every case", func() {
+		BeforeEach(func() {
+			poolUACs = []string{"123412341234", "234523452345", "345634563456"}
+
+			mockTransaction.On("Get",
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.UacInfo"),
+			).Return(func(key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "unknown", CaseID: "unknown"}
+				return nil
+			})
+		})
+
+		It("claims a pool UAC for every case without generating any", func() {
+			result, err := uacGenerator.GenerateWithOptions(instrumentName, caseIDs, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+			Expect(result.FromPool).To(HaveLen(3))
+			Expect(result.Generated).To(HaveLen(0))
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
+			mockDatastore.AssertNumberOfCalls(GinkgoT(), "RunInTransaction", 3)
+		})
+	})
+
+	Context("when the pool runs out", func() {
+		BeforeEach(func() {
+			poolUACs = []string{"123412341234"}
+
+			mockTransaction.On("Get",
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.UacInfo"),
+			).Return(func(key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "unknown", CaseID: "unknown"}
+				return nil
+			})
+		})
+
+		It("generates UACs for the remaining cases", func() {
+			result, err := uacGenerator.GenerateWithOptions(instrumentName, caseIDs, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+			Expect(result.FromPool).To(HaveLen(1))
+			Expect(result.Generated).To(HaveLen(2))
+			// One claim and one batch of generated UACs
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 2)
+		})
+	})
+
+	Context("when a pool UAC has been claimed by someone else", func() {
+		BeforeEach(func() {
+			poolUACs = []string{"123412341234", "234523452345", "345634563456"}
+
+			mockTransaction.On("Get",
+				uacGenerator.UacKey("234523452345"),
+				mock.AnythingOfType("*uacgenerator.UacInfo"),
+			).Return(func(key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "dst2108a", CaseID: "1234"}
+				return nil
+			})
+			mockTransaction.On("Get",
+				mock.AnythingOfType("*datastore.Key"),
+				mock.AnythingOfType("*uacgenerator.UacInfo"),
+			).Return(func(key *datastore.Key, dst interface{}) error {
+				*dst.(*uacgenerator.UacInfo) = uacgenerator.UacInfo{InstrumentName: "unknown", CaseID: "unknown"}
+				return nil
+			})
+		})
+
+		It("skips it and generates a UAC instead", func() {
+			result, err := uacGenerator.GenerateWithOptions(instrumentName, caseIDs, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+			Expect(result.FromPool).To(HaveLen(2))
+			Expect(result.Generated).To(HaveLen(1))
+			// Two claims and one batch of generated UACs
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 3)
+		})
+	})
+
+	Context("when not claiming from the pool", func() {
+		BeforeEach(func() {
+			poolUACs = []string{"123412341234"}
+		})
+
+		It("generates UACs for every case", func() {
+			result, err := uacGenerator.GenerateWithOptions(instrumentName, caseIDs, uacgenerator.GenerateOptions{})
+			Expect(err).To(BeNil())
+			Expect(result.FromPool).To(HaveLen(0))
+			Expect(result.Generated).To(HaveLen(3))
+			mockDatastore.AssertNotCalled(GinkgoT(), "GetAll", uacGenerator.Context, mock.AnythingOfType("*datastore.Query"), nil)
+			mockTransaction.AssertNumberOfCalls(GinkgoT(), "Mutate", 1)
+		})
+	})
+})
@@ -0,0 +1,103 @@
+package uacgenerator
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ReissueUac replaces the current UAC of a slot of a case with a new UAC of the instrument's kind, disabling the
+// replaced UAC for REASONREISSUED with note and linking the two. A replaced UAC that was already disabled keeps why
+// it was disabled. The new UAC is returned in full, as generated UACs are.
+func (uacGenerator *UacGenerator) ReissueUac(instrumentName, caseID string, slot int, note string) (*UacInfo, error) {
+	err := validateCaseID(caseID)
+	if err != nil {
+		return nil, err
+	}
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacFormat, ok := GetUacFormat(uacKind)
+	if !ok {
+		return nil, fmt.Errorf("Cannot generate UACs for invalid UacKind")
+	}
+	change := UacStatusChange{Disabled: true, Reason: REASONREISSUED, Note: note}
+	for attempt := 0; attempt < 10; attempt++ {
+		uac, err := uacGenerator.generateUac(uacFormat)
+		if err != nil {
+			return nil, err
+		}
+		uacInfo, err := uacGenerator.newCaseUacInfo(uacKind, uac, instrumentName, caseID, slot)
+		if err != nil {
+			return nil, err
+		}
+		_, err = uacGenerator.Store.ReissueCaseUac(uacGenerator.Context, uacInfo, change, func(replaced *UacInfo) []*AuditEntry {
+			after := *replaced
+			after.Disabled = true
+			auditEntries := []*AuditEntry{
+				uacAuditEntry(AUDITREISSUE, replaced.UAC, replaced, &after),
+				uacAuditEntry(AUDITREISSUE, uacInfo.UAC, nil, uacInfo),
+			}
+			for _, auditEntry := range auditEntries {
+				auditEntry.Reason = change.Reason
+				auditEntry.Note = change.Note
+			}
+			return uacGenerator.auditEntries(auditEntries...)
+		})
+		if err == ErrUacExists {
+			continue
+		}
+		if err == datastore.ErrNoSuchEntity {
+			return nil, ErrCaseHasNoUac
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		uacGenerator.showLinks(uacInfo)
+		uacInfo.FullUAC = uac
+		uacInfo.UacChunks = ChunkUAC(uac)
+		return uacInfo, nil
+	}
+	return nil, fmt.Errorf("Could not generate a unique UAC in 10 attempts")
+}
+
+// showLinks shows the UACs a UAC is linked to by their hashes when UACs are keyed, including links made before the
+// UACs were keyed.
+func (uacGenerator *UacGenerator) showLinks(uacInfo *UacInfo) {
+	if uacGenerator.UacKeyer == nil {
+		return
+	}
+	uacInfo.renameLinks(func(link string) string {
+		return uacGenerator.keyedLinkName(uacInfo.UAC.Kind, link)
+	})
+}
+
+// keyedLinkName returns the name of the keyed UAC a link names, which is the link itself when it already names one.
+func (uacGenerator *UacGenerator) keyedLinkName(uacKind, link string) string {
+	if uacGenerator.UacKeyer.IsKeyName(link) {
+		return link
+	}
+	return uacGenerator.uacKey(uacKind, link).Name
+}
+
+// renameLinks renames the UACs a UAC was reissued from and to.
+func (uacInfo *UacInfo) renameLinks(linkName func(string) string) {
+	for _, link := range []*string{&uacInfo.ReissuedFrom, &uacInfo.ReissuedTo} {
+		if *link != "" {
+			*link = linkName(*link)
+		}
+	}
+}
+
+// currentCaseUac returns the UAC of a slot of a case that has not been replaced by a reissued UAC, or nil when there
+// is none.
+func currentCaseUac(uacInfos []*UacInfo, slot int) *UacInfo {
+	for _, uacInfo := range uacInfos {
+		if uacInfo.Slot == slot && uacInfo.ReissuedTo == "" {
+			return uacInfo
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package uacgenerator_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reissuing UACs", func() {
+	reissueSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+		})
+
+		generateUacs := func(instrumentName string, caseIDs ...string) uacgenerator.Uacs {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID(instrumentName)
+			Expect(err).To(BeNil())
+			return uacs
+		}
+
+		It("replaces a case's UAC and links the two", func() {
+			oldUac := generateUacs("lolcat", "1", "2")["1"].FullUAC
+
+			uacInfo, err := uacGenerator.ReissueUac("LOLcat", "1", 0, "Letter returned")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.FullUAC).NotTo(Equal(oldUac))
+			Expect(uacGenerator.ValidateUAC(uacInfo.FullUAC)).To(BeTrue())
+			Expect(uacInfo.UacChunks).NotTo(BeNil())
+			Expect(uacInfo.CaseID).To(Equal("1"))
+			Expect(uacInfo.ReissuedFrom).To(Equal(oldUac))
+			Expect(uacInfo.Disabled).To(BeFalse())
+
+			replaced, err := uacGenerator.GetUacInfo(oldUac)
+			Expect(err).To(BeNil())
+			Expect(replaced.Disabled).To(BeTrue())
+			Expect(replaced.DisabledReason).To(Equal(uacgenerator.REASONREISSUED))
+			Expect(replaced.DisabledNote).To(Equal("Letter returned"))
+			Expect(replaced.ReissuedTo).To(Equal(uacInfo.FullUAC))
+
+			uacs := generateUacs("lolcat")
+			Expect(uacs).To(HaveLen(2))
+			Expect(uacs["1"].FullUAC).To(Equal(uacInfo.FullUAC))
+			Expect(uacs["1"].ReissuedFrom).To(Equal(oldUac))
+			allUacs, err := uacGenerator.GetAllUacs("lolcat")
+			Expect(err).To(BeNil())
+			Expect(allUacs).To(HaveLen(3))
+			Expect(allUacs).To(HaveKey(oldUac))
+
+			for _, uac := range []string{oldUac, uacInfo.FullUAC} {
+				page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{UAC: uac})
+				Expect(err).To(BeNil())
+				reissue := page.Entries[len(page.Entries)-1]
+				Expect(reissue.Action).To(Equal(uacgenerator.AUDITREISSUE))
+				Expect(reissue.Reason).To(Equal(uacgenerator.REASONREISSUED))
+				Expect(reissue.Note).To(Equal("Letter returned"))
+			}
+		})
+
+		It("keeps why a UAC that was already disabled was disabled", func() {
+			oldUac := generateUacs("lolcat", "1")["1"].FullUAC
+			_, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{
+				UACs:   []string{oldUac},
+				Reason: uacgenerator.REASONCOMPROMISED,
+			})
+			Expect(err).To(BeNil())
+
+			uacInfo, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+			replaced, err := uacGenerator.GetUacInfo(oldUac)
+			Expect(err).To(BeNil())
+			Expect(replaced.DisabledReason).To(Equal(uacgenerator.REASONCOMPROMISED))
+			Expect(replaced.ReissuedTo).To(Equal(uacInfo.FullUAC))
+		})
+
+		It("does not enable a replaced UAC", func() {
+			oldUac := generateUacs("lolcat", "1")["1"].FullUAC
+			_, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+
+			Expect(uacGenerator.EnableUac(oldUac)).To(Equal(uacgenerator.ErrUacReissued))
+			result, err := uacGenerator.EnableUacs("lolcat", uacgenerator.BulkUacRequest{
+				UACs:   []string{oldUac},
+				Reason: uacgenerator.REASONREINSTATED,
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Items).To(Equal([]uacgenerator.BulkUacItem{
+				{UAC: oldUac, Status: uacgenerator.BULKFAILED, Error: "UAC has been reissued"},
+			}))
+
+			// A case ID is changed through its current UAC
+			result, err = uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{
+				CaseIDs: []string{"1"},
+				Reason:  uacgenerator.REASONREFUSAL,
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(Equal(1))
+			Expect(generateUacs("lolcat")["1"].Disabled).To(BeTrue())
+		})
+
+		It("exports each case with its current UAC", func() {
+			generateUacs("lolcat", "1", "2")
+			uacInfo, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+
+			export := &bytes.Buffer{}
+			trailer, err := uacGenerator.ExportUacs("lolcat", uacgenerator.ExportOptions{}, export)
+			Expect(err).To(BeNil())
+			Expect(trailer.Records).To(Equal(2))
+			Expect(export.String()).To(ContainSubstring(uacInfo.FullUAC))
+			Expect(export.String()).NotTo(ContainSubstring(uacInfo.ReissuedFrom))
+		})
+
+		It("keeps reissued UACs linked when they are restored", func() {
+			generateUacs("lolcat", "1")
+			uacInfo, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+			Expect(uacGenerator.AdminDelete("lolcat")).To(Succeed())
+
+			result, err := uacGenerator.RestoreInstrument("lolcat")
+			Expect(err).To(BeNil())
+			Expect(result.Restored).To(Equal(2))
+			Expect(result.Conflicts).To(BeEmpty())
+			restored := generateUacs("lolcat")["1"]
+			Expect(restored.FullUAC).To(Equal(uacInfo.FullUAC))
+			Expect(restored.ReissuedFrom).To(Equal(uacInfo.ReissuedFrom))
+
+			_, err = uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+		})
+
+		It("gives a case one current UAC when it is reissued concurrently", func() {
+			generateUacs("lolcat", "1")
+			var (
+				wg         sync.WaitGroup
+				reissuedMu sync.Mutex
+				reissued   int
+			)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+					if err == nil {
+						reissuedMu.Lock()
+						reissued++
+						reissuedMu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			Expect(reissued).To(BeNumerically(">", 0))
+
+			allUacs, err := uacGenerator.GetAllUacs("lolcat")
+			Expect(err).To(BeNil())
+			Expect(allUacs).To(HaveLen(1 + reissued))
+			var current []string
+			replacements := map[string]bool{}
+			for uac, uacInfo := range allUacs {
+				if uacInfo.ReissuedTo == "" {
+					current = append(current, uac)
+					continue
+				}
+				Expect(uacInfo.Disabled).To(BeTrue())
+				Expect(replacements).NotTo(HaveKey(uacInfo.ReissuedTo))
+				replacements[uacInfo.ReissuedTo] = true
+			}
+			Expect(current).To(HaveLen(1))
+			Expect(generateUacs("lolcat")["1"].FullUAC).To(Equal(current[0]))
+		})
+
+		It("cannot reissue the UAC of a case without one", func() {
+			generateUacs("lolcat", "1")
+			_, err := uacGenerator.ReissueUac("lolcat", "2", 0, "")
+			Expect(err).To(Equal(uacgenerator.ErrCaseHasNoUac))
+		})
+	}
+
+	Context("with Datastore", func() {
+		reissueSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		reissueSpecs(newSQLUacGenerator)
+	})
+
+	Context("when UACs are keyed", func() {
+		It("links the UACs by their hashes", func() {
+			uacGenerator := uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+			uacKeyer, err := uacgenerator.NewUacKeyer([]byte(strings.Repeat("k", 32)))
+			Expect(err).To(BeNil())
+			uacGenerator.UacKeyer = uacKeyer
+			_, err = uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			oldHash := uacs["1"].UacHash
+
+			uacInfo, err := uacGenerator.ReissueUac("lolcat", "1", 0, "")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.FullUAC).NotTo(BeEmpty())
+			Expect(uacInfo.ReissuedFrom).To(Equal(oldHash))
+			uacs, err = uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs["1"].ReissuedFrom).To(Equal(oldHash))
+			Expect(uacs["1"].FullUAC).To(BeEmpty())
+		})
+	})
+})
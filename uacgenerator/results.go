@@ -0,0 +1,57 @@
+package uacgenerator
+
+import "sort"
+
+// GenerateResult reports what happened to each case when generating UACs.
+type GenerateResult struct {
+	Generated []string      `json:"generated"`
+	FromPool  []string      `json:"from_pool"`
+	Existing  []string      `json:"existing"`
+	Failed    []CaseFailure `json:"failed"`
+	// Uacs are the UACs given to case slots by this generation by lowercased CaseSlotKey. They are only set when UACs
+	// are keyed, as this is the only time they are returned.
+	Uacs map[string]string `json:"-"`
+}
+
+type CaseFailure struct {
+	CaseID string `json:"case_id" datastore:"case_id"`
+	Error  string `json:"error" datastore:"error"`
+}
+
+func NewGenerateResult() *GenerateResult {
+	return &GenerateResult{
+		Generated: []string{},
+		FromPool:  []string{},
+		Existing:  []string{},
+		Failed:    []CaseFailure{},
+	}
+}
+
+func (result *GenerateResult) HasFailures() bool {
+	return len(result.Failed) > 0
+}
+
+func (result *GenerateResult) add(caseID string, source uacSource, err error) {
+	if err != nil {
+		result.Failed = append(result.Failed, CaseFailure{CaseID: caseID, Error: err.Error()})
+		return
+	}
+	switch source {
+	case uacExisted:
+		result.Existing = append(result.Existing, caseID)
+	case uacFromPool:
+		result.FromPool = append(result.FromPool, caseID)
+	case uacGenerated:
+		result.Generated = append(result.Generated, caseID)
+	}
+}
+
+// sort orders each list by case ID, as cases are reported in whatever order their generation finishes.
+func (result *GenerateResult) sort() {
+	sort.Strings(result.Generated)
+	sort.Strings(result.FromPool)
+	sort.Strings(result.Existing)
+	sort.Slice(result.Failed, func(i, j int) bool {
+		return result.Failed[i].CaseID < result.Failed[j].CaseID
+	})
+}
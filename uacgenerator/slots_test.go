@@ -0,0 +1,186 @@
+package uacgenerator_test
+
+import (
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Respondent slots", func() {
+	slotSpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var uacGenerator *uacgenerator.UacGenerator
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+		})
+
+		It("gives each case a UAC for each slot", func() {
+			result, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1", "2"}, uacgenerator.GenerateOptions{UacsPerCase: 2})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(ConsistOf("1", "1/1", "2", "2/1"))
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs).To(HaveLen(4))
+			Expect(uacs["1"].CaseID).To(Equal("1"))
+			Expect(uacs["1"].Slot).To(Equal(0))
+			Expect(uacs["1/1"].CaseID).To(Equal("1"))
+			Expect(uacs["1/1"].Slot).To(Equal(1))
+			Expect(uacs["1/1"].FullUAC).NotTo(Equal(uacs["1"].FullUAC))
+
+			for _, slot := range []int{0, 1} {
+				exists, err := uacGenerator.UacExistsForCase("lolcat", "2", slot)
+				Expect(err).To(BeNil())
+				Expect(exists).To(BeTrue())
+			}
+			exists, err := uacGenerator.UacExistsForCase("lolcat", "2", 2)
+			Expect(err).To(BeNil())
+			Expect(exists).To(BeFalse())
+		})
+
+		It("only gives UACs to the slots that do not have one", func() {
+			_, err := uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			firstUac := uacs["1"].FullUAC
+
+			result, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1"}, uacgenerator.GenerateOptions{UacsPerCase: 3})
+			Expect(err).To(BeNil())
+			Expect(result.Existing).To(ConsistOf("1"))
+			Expect(result.Generated).To(ConsistOf("1/1", "1/2"))
+
+			uacs, err = uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs).To(HaveLen(3))
+			Expect(uacs["1"].FullUAC).To(Equal(firstUac))
+		})
+
+		It("gives cases the number of UACs they are overridden with", func() {
+			result, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1", "2", "3"}, uacgenerator.GenerateOptions{
+				UacsPerCase: 2,
+				CaseUacs:    map[string]int{"2": 3, "3": 1},
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(ConsistOf("1", "1/1", "2", "2/1", "2/2", "3"))
+		})
+
+		It("rejects invalid numbers of UACs per case", func() {
+			for _, options := range []uacgenerator.GenerateOptions{
+				{UacsPerCase: -1},
+				{UacsPerCase: uacgenerator.MAXUACSPERCASE + 1},
+				{CaseUacs: map[string]int{"1": 0}},
+			} {
+				_, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1"}, options)
+				Expect(err).To(MatchError(uacgenerator.ErrInvalidUacsPerCase))
+			}
+			count, err := uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(0))
+		})
+
+		It("reissues the UAC of a slot", func() {
+			_, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1"}, uacgenerator.GenerateOptions{UacsPerCase: 2})
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+
+			uacInfo, err := uacGenerator.ReissueUac("lolcat", "1", 1, "")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.Slot).To(Equal(1))
+			Expect(uacInfo.ReissuedFrom).To(Equal(uacs["1/1"].FullUAC))
+
+			reissued, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(reissued["1"].FullUAC).To(Equal(uacs["1"].FullUAC))
+			Expect(reissued["1/1"].FullUAC).To(Equal(uacInfo.FullUAC))
+		})
+
+		It("disables the UAC of a slot by its case slot key", func() {
+			_, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1"}, uacgenerator.GenerateOptions{UacsPerCase: 2})
+			Expect(err).To(BeNil())
+
+			result, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{
+				CaseIDs: []string{"1/1"},
+				Reason:  uacgenerator.REASONCOMPROMISED,
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(Equal(1))
+
+			disabled, err := uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(1))
+			Expect(disabled).To(HaveKey("1/1"))
+		})
+
+		It("keeps the slots of case IDs containing a slash apart from the slots of other cases", func() {
+			result, err := uacGenerator.GenerateWithOptions("lolcat", []string{"abc", "abc/2"}, uacgenerator.GenerateOptions{UacsPerCase: 3})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(ConsistOf("abc", "abc/1", "abc/2", "abc/2/0", "abc/2/1", "abc/2/2"))
+			Expect(result.Failed).To(BeEmpty())
+
+			again, err := uacGenerator.Generate("lolcat", []string{"abc/2"})
+			Expect(err).To(BeNil())
+			Expect(again.Existing).To(Equal([]string{"abc/2/0"}))
+
+			Expect(uacGenerator.AddUacToDatastore("123412341234", "lolcat", "def/1")).To(Succeed())
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs["def/1/0"].CaseID).To(Equal("def/1"))
+
+			bulkResult, err := uacGenerator.DisableUacs("lolcat", uacgenerator.BulkUacRequest{
+				CaseIDs: []string{"abc/2", "abc/2/0"},
+				Reason:  uacgenerator.REASONCOMPROMISED,
+			})
+			Expect(err).To(BeNil())
+			Expect(bulkResult.Updated).To(Equal(2))
+			disabled, err := uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(2))
+			Expect(disabled["abc/2"].CaseID).To(Equal("abc"))
+			Expect(disabled["abc/2"].Slot).To(Equal(2))
+			Expect(disabled["abc/2/0"].CaseID).To(Equal("abc/2"))
+			Expect(disabled["abc/2/0"].Slot).To(Equal(0))
+		})
+
+		It("reissues the UAC of a case ID containing a slash", func() {
+			_, err := uacGenerator.Generate("lolcat", []string{"abc/2"})
+			Expect(err).To(BeNil())
+			reissued, err := uacGenerator.ReissueUac("lolcat", "abc/2", 0, "")
+			Expect(err).To(BeNil())
+			Expect(reissued.CaseID).To(Equal("abc/2"))
+
+			_, err = uacGenerator.ReissueUac("lolcat", "", 0, "")
+			Expect(err).To(MatchError("Cannot generate UACs for blank caseIDs"))
+		})
+	}
+
+	Context("with Datastore", func() {
+		slotSpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		slotSpecs(newSQLUacGenerator)
+	})
+
+	DescribeTable("ParseCaseSlotKey",
+		func(key, caseID string, slot int) {
+			parsedCaseID, parsedSlot := uacgenerator.ParseCaseSlotKey(key)
+			Expect(parsedCaseID).To(Equal(caseID))
+			Expect(parsedSlot).To(Equal(slot))
+			if slot > 0 {
+				Expect(uacgenerator.CaseSlotKey(caseID, slot)).To(Equal(key))
+			}
+		},
+		Entry("a case ID", "1001", "1001", 0),
+		Entry("a slot", "1001/1", "1001", 1),
+		Entry("a case ID with a slash", "a/b/2", "a/b", 2),
+		Entry("slot 0 of a case ID with a slash", "a/1/0", "a/1", 0),
+		Entry("slot 0 written out", "1001/0", "1001/0", 0),
+		Entry("a padded slot", "1001/01", "1001/01", 0),
+		Entry("not a slot", "a/b", "a/b", 0),
+	)
+})
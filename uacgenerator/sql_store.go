@@ -0,0 +1,1413 @@
+package uacgenerator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// SQL dialects supported by SQLUacStore
+const (
+	SQLITE   = "sqlite"
+	POSTGRES = "postgres"
+)
+
+// SQLREISSUEATTEMPTS is how many times a reissue is tried while concurrent reissues of the case replace its UAC first.
+const SQLREISSUEATTEMPTS = 3
+
+// sqlSchema works in both SQLite and PostgreSQL.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS uacs (
+		uac               TEXT      NOT NULL PRIMARY KEY,
+		uac_kind          TEXT      NOT NULL,
+		instrument_name   TEXT      NOT NULL,
+		case_id           TEXT      NOT NULL,
+		disabled          BOOLEAN   NOT NULL DEFAULT FALSE,
+		sealed_uac        TEXT      NOT NULL DEFAULT '',
+		created_at        TIMESTAMP,
+		updated_at        TIMESTAMP,
+		disabled_at       TIMESTAMP,
+		first_accessed_at TIMESTAMP,
+		disabled_reason   TEXT      NOT NULL DEFAULT '',
+		disabled_note     TEXT      NOT NULL DEFAULT '',
+		reissued_from     TEXT      NOT NULL DEFAULT '',
+		reissued_to       TEXT      NOT NULL DEFAULT '',
+		valid_from        TIMESTAMP,
+		valid_until       TIMESTAMP,
+		slot              INTEGER   NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS uacs_kind_instrument ON uacs (uac_kind, instrument_name, disabled)`,
+	`CREATE TABLE IF NOT EXISTS instruments (
+		instrument_name TEXT      NOT NULL PRIMARY KEY,
+		uac_kind        TEXT      NOT NULL,
+		valid_from      TIMESTAMP,
+		valid_until     TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS uac_audit (
+		id              TEXT      NOT NULL PRIMARY KEY,
+		uac             TEXT      NOT NULL,
+		uac_kind        TEXT      NOT NULL,
+		instrument_name TEXT      NOT NULL,
+		action          TEXT      NOT NULL,
+		actor           TEXT      NOT NULL,
+		recorded_at     TIMESTAMP NOT NULL,
+		before_state    TEXT      NOT NULL,
+		after_state     TEXT      NOT NULL,
+		reason          TEXT      NOT NULL DEFAULT '',
+		note            TEXT      NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS uac_audit_uac ON uac_audit (uac, id)`,
+	`CREATE INDEX IF NOT EXISTS uac_audit_instrument ON uac_audit (instrument_name, id)`,
+	`CREATE INDEX IF NOT EXISTS uac_audit_actor ON uac_audit (actor, id)`,
+	`CREATE TABLE IF NOT EXISTS archived_uacs (
+		uac               TEXT      NOT NULL PRIMARY KEY,
+		uac_kind          TEXT      NOT NULL,
+		instrument_name   TEXT      NOT NULL,
+		case_id           TEXT      NOT NULL,
+		disabled          BOOLEAN   NOT NULL DEFAULT FALSE,
+		sealed_uac        TEXT      NOT NULL DEFAULT '',
+		created_at        TIMESTAMP,
+		updated_at        TIMESTAMP,
+		disabled_at       TIMESTAMP,
+		first_accessed_at TIMESTAMP,
+		disabled_reason   TEXT      NOT NULL DEFAULT '',
+		disabled_note     TEXT      NOT NULL DEFAULT '',
+		reissued_from     TEXT      NOT NULL DEFAULT '',
+		reissued_to       TEXT      NOT NULL DEFAULT '',
+		valid_from        TIMESTAMP,
+		valid_until       TIMESTAMP,
+		slot              INTEGER   NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS archived_uacs_instrument ON archived_uacs (instrument_name)`,
+	`CREATE TABLE IF NOT EXISTS instrument_archives (
+		instrument_name TEXT      NOT NULL PRIMARY KEY,
+		uac_kind        TEXT      NOT NULL,
+		deleted_at      TIMESTAMP NOT NULL,
+		deleted_by      TEXT      NOT NULL,
+		purge_after     TIMESTAMP NOT NULL,
+		valid_from      TIMESTAMP,
+		valid_until     TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS generation_jobs (
+		id               TEXT      NOT NULL PRIMARY KEY,
+		instrument_name  TEXT      NOT NULL,
+		status           TEXT      NOT NULL,
+		claim_pool       BOOLEAN   NOT NULL DEFAULT FALSE,
+		uacs_per_case    INTEGER   NOT NULL DEFAULT 0,
+		total            INTEGER   NOT NULL DEFAULT 0,
+		processed        INTEGER   NOT NULL DEFAULT 0,
+		created          INTEGER   NOT NULL DEFAULT 0,
+		from_pool        INTEGER   NOT NULL DEFAULT 0,
+		existing         INTEGER   NOT NULL DEFAULT 0,
+		failed           INTEGER   NOT NULL DEFAULT 0,
+		failed_cases     TEXT      NOT NULL DEFAULT '[]',
+		error            TEXT      NOT NULL DEFAULT '',
+		cancel_requested BOOLEAN   NOT NULL DEFAULT FALSE,
+		started_by       TEXT      NOT NULL DEFAULT '',
+		runner_id        TEXT      NOT NULL DEFAULT '',
+		lease_expires    TIMESTAMP,
+		resumed          INTEGER   NOT NULL DEFAULT 0,
+		started_at       TIMESTAMP,
+		updated_at       TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS generation_jobs_status ON generation_jobs (status)`,
+	`CREATE TABLE IF NOT EXISTS generation_job_cases (
+		job_id   TEXT    NOT NULL,
+		position INTEGER NOT NULL,
+		case_id  TEXT    NOT NULL,
+		uacs     INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (job_id, position)
+	)`,
+}
+
+// sqlAddedColumns have been added to tables since they were first created, so CreateSchema adds them to tables
+// created before.
+var sqlAddedColumns = []struct {
+	table, column, definition string
+}{
+	{"uacs", "created_at", "TIMESTAMP"},
+	{"uacs", "updated_at", "TIMESTAMP"},
+	{"uacs", "disabled_at", "TIMESTAMP"},
+	{"uacs", "first_accessed_at", "TIMESTAMP"},
+	{"uacs", "disabled_reason", "TEXT NOT NULL DEFAULT ''"},
+	{"uacs", "disabled_note", "TEXT NOT NULL DEFAULT ''"},
+	{"archived_uacs", "created_at", "TIMESTAMP"},
+	{"archived_uacs", "updated_at", "TIMESTAMP"},
+	{"archived_uacs", "disabled_at", "TIMESTAMP"},
+	{"archived_uacs", "first_accessed_at", "TIMESTAMP"},
+	{"archived_uacs", "disabled_reason", "TEXT NOT NULL DEFAULT ''"},
+	{"archived_uacs", "disabled_note", "TEXT NOT NULL DEFAULT ''"},
+	{"uac_audit", "reason", "TEXT NOT NULL DEFAULT ''"},
+	{"uac_audit", "note", "TEXT NOT NULL DEFAULT ''"},
+	{"uacs", "reissued_from", "TEXT NOT NULL DEFAULT ''"},
+	{"uacs", "reissued_to", "TEXT NOT NULL DEFAULT ''"},
+	{"archived_uacs", "reissued_from", "TEXT NOT NULL DEFAULT ''"},
+	{"archived_uacs", "reissued_to", "TEXT NOT NULL DEFAULT ''"},
+	{"uacs", "valid_from", "TIMESTAMP"},
+	{"uacs", "valid_until", "TIMESTAMP"},
+	{"archived_uacs", "valid_from", "TIMESTAMP"},
+	{"archived_uacs", "valid_until", "TIMESTAMP"},
+	{"instruments", "valid_from", "TIMESTAMP"},
+	{"instruments", "valid_until", "TIMESTAMP"},
+	{"uacs", "slot", "INTEGER NOT NULL DEFAULT 0"},
+	{"archived_uacs", "slot", "INTEGER NOT NULL DEFAULT 0"},
+	{"instrument_archives", "valid_from", "TIMESTAMP"},
+	{"instrument_archives", "valid_until", "TIMESTAMP"},
+}
+
+// sqlIndexes are created once the added columns exist. Imported UACs all belong to the unknown instrument and case,
+// and UACs replaced by a reissued UAC no longer belong to their case, so both are left out of the unique index of
+// case slots, which replaces the earlier indexes of cases.
+var sqlIndexes = []string{
+	`DROP INDEX IF EXISTS uacs_instrument_case`,
+	`DROP INDEX IF EXISTS uacs_instrument_current_case`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uacs_instrument_current_case_slot ON uacs (instrument_name, case_id, slot)
+		WHERE instrument_name <> 'unknown' AND reissued_to = ''`,
+}
+
+// sqlUacColumns are the columns scanUac reads and sqlUacValues writes.
+const sqlUacColumns = `uac, uac_kind, instrument_name, case_id, disabled, sealed_uac, ` +
+	`created_at, updated_at, disabled_at, first_accessed_at, disabled_reason, disabled_note, reissued_from, reissued_to, ` +
+	`valid_from, valid_until, slot`
+
+const sqlUacPlaceholders = `?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?`
+
+// sqlJobColumns are the columns of generation_jobs, other than the ID, that scanJob reads and sqlJobValues writes.
+const sqlJobColumns = `instrument_name, status, claim_pool, uacs_per_case, total, processed, created, from_pool, ` +
+	`existing, failed, failed_cases, error, cancel_requested, started_by, runner_id, lease_expires, resumed, ` +
+	`started_at, updated_at`
+
+// SQLUacStore keeps UACs in a SQLite or PostgreSQL database. UACs are unique across kinds, and each slot of a case
+// of an instrument can only have one current UAC.
+type SQLUacStore struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+func NewSQLUacStore(db *sql.DB, dialect string) (*SQLUacStore, error) {
+	if dialect != SQLITE && dialect != POSTGRES {
+		return nil, fmt.Errorf("Unknown SQL dialect '%s'", dialect)
+	}
+	return &SQLUacStore{DB: db, Dialect: dialect}, nil
+}
+
+// CreateSchema creates the tables and indexes the store needs if they do not exist, and adds the columns added
+// since to tables that do not have them.
+func (store *SQLUacStore) CreateSchema(ctx context.Context) error {
+	for _, statement := range sqlSchema {
+		_, err := store.DB.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	for _, added := range sqlAddedColumns {
+		// Selecting a missing column fails in both SQLite and PostgreSQL
+		rows, err := store.DB.QueryContext(ctx, `SELECT `+added.column+` FROM `+added.table+` LIMIT 0`)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+		_, err = store.DB.ExecContext(ctx, `ALTER TABLE `+added.table+` ADD COLUMN `+added.column+` `+added.definition)
+		if err != nil {
+			return err
+		}
+	}
+	for _, statement := range sqlIndexes {
+		_, err := store.DB.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *SQLUacStore) InsertCaseUacs(ctx context.Context, uacInfos []*UacInfo, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	checkedInstruments := make(map[string]bool)
+	for _, uacInfo := range uacInfos {
+		if checkedInstruments[strings.ToLower(uacInfo.InstrumentName)] {
+			continue
+		}
+		err = store.checkInstrumentUacKind(ctx, transaction, uacInfo.InstrumentName, uacInfo.UAC.Kind)
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+		checkedInstruments[strings.ToLower(uacInfo.InstrumentName)] = true
+	}
+	statement, err := transaction.PrepareContext(ctx, store.rebind(
+		`INSERT INTO uacs (`+sqlUacColumns+`) VALUES (`+sqlUacPlaceholders+`)`,
+	))
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	defer statement.Close()
+	for _, uacInfo := range uacInfos {
+		_, err = statement.ExecContext(ctx, sqlUacValues(uacInfo)...)
+		if err != nil {
+			transaction.Rollback()
+			for _, uacInfo := range uacInfos {
+				if conflictErr := store.insertConflict(ctx, uacInfo); conflictErr != nil {
+					return ErrUacExists
+				}
+			}
+			return err
+		}
+	}
+	err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) InsertCaseUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = store.checkInstrumentUacKind(ctx, transaction, uacInfo.InstrumentName, uacInfo.UAC.Kind)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`INSERT INTO uacs (`+sqlUacColumns+`) VALUES (`+sqlUacPlaceholders+`)`,
+	), sqlUacValues(uacInfo)...)
+	if err != nil {
+		transaction.Rollback()
+		if conflictErr := store.insertConflict(ctx, uacInfo); conflictErr != nil {
+			return conflictErr
+		}
+		return err
+	}
+	err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) InsertPoolUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	return store.InsertCaseUac(ctx, &UacInfo{
+		InstrumentName: UNKNOWNINSTRUMENT,
+		CaseID:         UNKNOWNINSTRUMENT,
+		UAC:            uacInfo.UAC,
+		SealedUAC:      uacInfo.SealedUAC,
+		CreatedAt:      uacInfo.CreatedAt,
+		UpdatedAt:      uacInfo.UpdatedAt,
+	}, auditEntries)
+}
+
+func (store *SQLUacStore) ListPoolUacs(ctx context.Context, uacKind string, limit int) ([]*datastore.Key, error) {
+	rows, err := store.DB.QueryContext(ctx, store.rebind(
+		`SELECT uac FROM uacs WHERE uac_kind = ? AND instrument_name = ? AND disabled = ? ORDER BY uac LIMIT ?`,
+	), uacKind, UNKNOWNINSTRUMENT, false, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var uacKeys []*datastore.Key
+	for rows.Next() {
+		var uac string
+		if err := rows.Scan(&uac); err != nil {
+			return nil, err
+		}
+		uacKeys = append(uacKeys, uacKindKey(uacKind, uac))
+	}
+	return uacKeys, rows.Err()
+}
+
+// ClaimPoolUac only updates the UAC while it belongs to the unknown instrument, so a UAC can only be claimed once,
+// and the unique index of case slots stops a slot claiming two UACs. A claimed UAC is created when it is given to its
+// case.
+func (store *SQLUacStore) ClaimPoolUac(ctx context.Context, uacKey *datastore.Key, instrumentName, caseID string, slot int, auditEntries []*AuditEntry) (bool, error) {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	err = store.checkInstrumentUacKind(ctx, transaction, instrumentName, uacKey.Kind)
+	if err != nil {
+		transaction.Rollback()
+		return false, err
+	}
+	now := uacTimestamp()
+	result, err := transaction.ExecContext(ctx, store.rebind(
+		`UPDATE uacs SET instrument_name = ?, case_id = ?, slot = ?, created_at = ?, updated_at = ?
+		WHERE uac_kind = ? AND uac = ? AND instrument_name = ? AND disabled = ?`,
+	), strings.ToLower(instrumentName), strings.ToLower(caseID), slot, now, now, uacKey.Kind, uacKey.Name, UNKNOWNINSTRUMENT, false)
+	if err != nil {
+		transaction.Rollback()
+		if store.caseHasUac(ctx, instrumentName, caseID, slot) {
+			return false, ErrCaseHasUac
+		}
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err == nil && rowsAffected == 1 {
+		err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return false, err
+	}
+	return rowsAffected == 1, transaction.Commit()
+}
+
+func (store *SQLUacStore) GetUac(ctx context.Context, uac *datastore.Key) (*UacInfo, error) {
+	uacInfos, err := store.queryUacs(ctx, `uac_kind = ? AND uac = ?`, uac.Kind, uac.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(uacInfos) == 0 {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return uacInfos[0], nil
+}
+
+func (store *SQLUacStore) ListUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error) {
+	return store.queryUacs(ctx, `uac_kind = ? AND instrument_name = ?`, uacKind, strings.ToLower(instrumentName))
+}
+
+func (store *SQLUacStore) ListCaseSlots(ctx context.Context, uacKind, instrumentName string) ([]string, error) {
+	rows, err := store.DB.QueryContext(ctx, store.rebind(
+		`SELECT case_id, slot FROM uacs WHERE uac_kind = ? AND instrument_name = ?`,
+	), uacKind, strings.ToLower(instrumentName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var caseSlots []string
+	for rows.Next() {
+		var (
+			caseID string
+			slot   int
+		)
+		err = rows.Scan(&caseID, &slot)
+		if err != nil {
+			return nil, err
+		}
+		caseSlots = append(caseSlots, CaseSlotKey(caseID, slot))
+	}
+	return caseSlots, rows.Err()
+}
+
+// IterateUacs pages through UACs in UAC order, so the cursor is the last UAC of the page.
+func (store *SQLUacStore) IterateUacs(ctx context.Context, uacKind, instrumentName string, filter UacFilter, cursor string, limit int, f func(*UacInfo) error) (string, error) {
+	afterUac, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	filterConditions, filterArgs := sqlFilterConditions(filter)
+	query := `SELECT ` + sqlUacColumns + ` FROM uacs
+		WHERE uac_kind = ? AND instrument_name = ? AND uac > ?` + filterConditions + ` ORDER BY uac`
+	args := append([]interface{}{uacKind, strings.ToLower(instrumentName), string(afterUac)}, filterArgs...)
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := store.DB.QueryContext(ctx, store.rebind(query), args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var (
+		uacCount int
+		lastUac  string
+	)
+	for rows.Next() {
+		uacInfo, err := scanUac(rows)
+		if err != nil {
+			return "", err
+		}
+		uacCount++
+		lastUac = uacInfo.UAC.Name
+		err = f(uacInfo)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if limit <= 0 || uacCount < limit {
+		return "", nil
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(lastUac)), nil
+}
+
+func (store *SQLUacStore) ListDisabledUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error) {
+	return store.queryUacs(ctx, `uac_kind = ? AND instrument_name = ? AND disabled = ?`,
+		uacKind, strings.ToLower(instrumentName), true)
+}
+
+func (store *SQLUacStore) ListCaseUacs(ctx context.Context, uacKind, instrumentName, caseID string) ([]*UacInfo, error) {
+	return store.queryUacs(ctx, `uac_kind = ? AND instrument_name = ? AND case_id = ?`,
+		uacKind, strings.ToLower(instrumentName), strings.ToLower(caseID))
+}
+
+func (store *SQLUacStore) CountUacs(ctx context.Context, uacKind, instrumentName string) (int, error) {
+	var uacCount int
+	err := store.DB.QueryRowContext(ctx, store.rebind(
+		`SELECT COUNT(*) FROM uacs WHERE uac_kind = ? AND instrument_name = ?`,
+	), uacKind, strings.ToLower(instrumentName)).Scan(&uacCount)
+	return uacCount, err
+}
+
+// SetUacsDisabled keeps when and why UACs that were already disabled were disabled.
+func (store *SQLUacStore) SetUacsDisabled(ctx context.Context, uacs []*datastore.Key, change UacStatusChange, auditEntries []*AuditEntry) error {
+	now := uacTimestamp()
+	query := `UPDATE uacs SET disabled = ?, updated_at = ?, disabled_at = NULL, disabled_reason = '', disabled_note = ''
+		WHERE uac_kind = ? AND uac = ?`
+	args := []interface{}{false, now}
+	if change.Disabled {
+		query = `UPDATE uacs SET disabled = ?, updated_at = ?,
+			disabled_at = CASE WHEN disabled THEN disabled_at ELSE ? END,
+			disabled_reason = CASE WHEN disabled THEN disabled_reason ELSE ? END,
+			disabled_note = CASE WHEN disabled THEN disabled_note ELSE ? END
+			WHERE uac_kind = ? AND uac = ?`
+		args = []interface{}{true, now, now, change.Reason, change.Note}
+	}
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	statement, err := transaction.PrepareContext(ctx, store.rebind(query))
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	defer statement.Close()
+	for _, uac := range uacs {
+		result, err := statement.ExecContext(ctx, append(args, uac.Kind, uac.Name)...)
+		if err == nil {
+			var rowsAffected int64
+			rowsAffected, err = result.RowsAffected()
+			if err == nil && rowsAffected == 0 {
+				err = datastore.ErrNoSuchEntity
+			}
+		}
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+	}
+	err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+// ReissueCaseUac only replaces the current UAC while it is still current, and tries again when a concurrent
+// reissue of the case replaced it first.
+func (store *SQLUacStore) ReissueCaseUac(ctx context.Context, uacInfo *UacInfo, change UacStatusChange, auditEntries func(replaced *UacInfo) []*AuditEntry) (*UacInfo, error) {
+	for attempt := 0; attempt < SQLREISSUEATTEMPTS; attempt++ {
+		replaced, err := store.reissueCaseUac(ctx, uacInfo, change, auditEntries)
+		if err != datastore.ErrConcurrentTransaction {
+			return replaced, err
+		}
+	}
+	return nil, datastore.ErrConcurrentTransaction
+}
+
+func (store *SQLUacStore) reissueCaseUac(ctx context.Context, uacInfo *UacInfo, change UacStatusChange, auditEntries func(replaced *UacInfo) []*AuditEntry) (*UacInfo, error) {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := transaction.QueryContext(ctx, store.rebind(
+		`SELECT `+sqlUacColumns+` FROM uacs WHERE instrument_name = ? AND case_id = ? AND slot = ? AND reissued_to = ''`,
+	), strings.ToLower(uacInfo.InstrumentName), strings.ToLower(uacInfo.CaseID), uacInfo.Slot)
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+	var replaced *UacInfo
+	if rows.Next() {
+		replaced, err = scanUac(rows)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err == nil && replaced == nil {
+		err = datastore.ErrNoSuchEntity
+	}
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+
+	after := *replaced
+	change.apply(&after, uacTimestamp())
+	result, err := transaction.ExecContext(ctx, store.rebind(
+		`UPDATE uacs SET disabled = ?, updated_at = ?, disabled_at = ?, disabled_reason = ?, disabled_note = ?,
+		reissued_to = ? WHERE uac_kind = ? AND uac = ? AND reissued_to = ''`,
+	), after.Disabled, sqlTime(after.UpdatedAt), sqlTime(after.DisabledAt), after.DisabledReason, after.DisabledNote,
+		uacInfo.UAC.Name, replaced.UAC.Kind, replaced.UAC.Name)
+	if err == nil {
+		var rowsAffected int64
+		rowsAffected, err = result.RowsAffected()
+		if err == nil && rowsAffected == 0 {
+			err = datastore.ErrConcurrentTransaction
+		}
+	}
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+
+	uacInfo.ReissuedFrom = replaced.UAC.Name
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`INSERT INTO uacs (`+sqlUacColumns+`) VALUES (`+sqlUacPlaceholders+`)`,
+	), sqlUacValues(uacInfo)...)
+	if err != nil {
+		transaction.Rollback()
+		if store.uacExists(ctx, uacInfo.UAC) {
+			return nil, ErrUacExists
+		}
+		return nil, err
+	}
+	err = store.insertAuditEntries(ctx, transaction, auditEntries(replaced))
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+	return replaced, transaction.Commit()
+}
+
+// RecordUacAccess only updates UACs that have not been looked up before.
+func (store *SQLUacStore) RecordUacAccess(ctx context.Context, uac *datastore.Key) error {
+	result, err := store.DB.ExecContext(ctx, store.rebind(
+		`UPDATE uacs SET first_accessed_at = ? WHERE uac_kind = ? AND uac = ? AND first_accessed_at IS NULL`,
+	), uacTimestamp(), uac.Kind, uac.Name)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		_, err = store.GetUac(ctx, uac)
+		return err
+	}
+	return nil
+}
+
+// RekeyUac reads the UAC's links in the transaction that renames them, locking the UAC on PostgreSQL, so a link made
+// since the UAC was listed is renamed too.
+func (store *SQLUacStore) RekeyUac(ctx context.Context, uacInfo *UacInfo, uac *datastore.Key, sealedUac string, linkName func(string) string, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	rows, err := transaction.QueryContext(ctx, store.rebind(
+		`SELECT `+sqlUacColumns+` FROM uacs WHERE uac_kind = ? AND uac = ?`+store.lockRows("UPDATE"),
+	), uacInfo.UAC.Kind, uacInfo.UAC.Name)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	var currentUacInfo *UacInfo
+	if rows.Next() {
+		currentUacInfo, err = scanUac(rows)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err == nil && currentUacInfo == nil {
+		err = datastore.ErrNoSuchEntity
+	}
+	if err == nil {
+		currentUacInfo.renameLinks(linkName)
+		err = store.runInTransaction(ctx, transaction, []sqlStatement{{
+			`UPDATE uacs SET uac = ?, sealed_uac = ?, reissued_from = ?, reissued_to = ? WHERE uac_kind = ? AND uac = ?`,
+			[]interface{}{uac.Name, sealedUac, currentUacInfo.ReissuedFrom, currentUacInfo.ReissuedTo, uacInfo.UAC.Kind, uacInfo.UAC.Name},
+		}}, auditEntries)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) SetUacValidity(ctx context.Context, uac *datastore.Key, validity Validity, auditEntries []*AuditEntry) error {
+	return store.updateOne(ctx, auditEntries, `UPDATE uacs SET valid_from = ?, valid_until = ? WHERE uac_kind = ? AND uac = ?`,
+		sqlTime(validity.ValidFrom), sqlTime(validity.ValidUntil), uac.Kind, uac.Name)
+}
+
+func (store *SQLUacStore) ListInstruments(ctx context.Context, uacKind string) ([]string, error) {
+	rows, err := store.DB.QueryContext(ctx, store.rebind(
+		`SELECT DISTINCT instrument_name FROM uacs WHERE uac_kind = ? ORDER BY instrument_name`,
+	), uacKind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var instrumentNames []string
+	for rows.Next() {
+		var instrumentName string
+		if err := rows.Scan(&instrumentName); err != nil {
+			return nil, err
+		}
+		instrumentNames = append(instrumentNames, instrumentName)
+	}
+	return instrumentNames, rows.Err()
+}
+
+// ArchiveInstrument copies the UACs to archived_uacs and deletes them in one transaction with their audit entries. A
+// UAC archived before is replaced, as it has been given out again since. PostgreSQL locks the instrument before the
+// UACs are read, so no case is given a UAC the audit trail misses until the transaction ends.
+func (store *SQLUacStore) ArchiveInstrument(ctx context.Context, archive *InstrumentArchive, auditEntry func(*UacInfo) *AuditEntry) error {
+	instrumentName := strings.ToLower(archive.InstrumentName)
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var uacInfos []*UacInfo
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`SELECT uac_kind FROM instruments WHERE instrument_name = ?`+store.lockRows("UPDATE"),
+	), instrumentName)
+	if err == nil {
+		uacInfos, err = store.queryTable(ctx, transaction, `uacs`, `uac_kind = ? AND instrument_name = ?`, archive.UacKind, instrumentName)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	auditEntries := make([]*AuditEntry, len(uacInfos))
+	for i, uacInfo := range uacInfos {
+		auditEntries[i] = auditEntry(uacInfo)
+	}
+	err = store.runInTransaction(ctx, transaction, []sqlStatement{
+		{`DELETE FROM archived_uacs WHERE uac IN (SELECT uac FROM uacs WHERE uac_kind = ? AND instrument_name = ?)`,
+			[]interface{}{archive.UacKind, instrumentName}},
+		{`INSERT INTO archived_uacs (` + sqlUacColumns + `)
+			SELECT ` + sqlUacColumns + ` FROM uacs WHERE uac_kind = ? AND instrument_name = ?`,
+			[]interface{}{archive.UacKind, instrumentName}},
+		{`DELETE FROM uacs WHERE uac_kind = ? AND instrument_name = ?`, []interface{}{archive.UacKind, instrumentName}},
+		{`DELETE FROM instruments WHERE instrument_name = ?`, []interface{}{instrumentName}},
+		{`INSERT INTO instrument_archives (instrument_name, uac_kind, deleted_at, deleted_by, purge_after, valid_from, valid_until)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (instrument_name) DO UPDATE SET uac_kind = excluded.uac_kind, deleted_at = excluded.deleted_at,
+			deleted_by = excluded.deleted_by, purge_after = excluded.purge_after, valid_from = excluded.valid_from,
+			valid_until = excluded.valid_until`,
+			[]interface{}{instrumentName, archive.UacKind, archive.DeletedAt.UTC(), archive.DeletedBy, archive.PurgeAfter.UTC(),
+				sqlTime(archive.ValidFrom), sqlTime(archive.ValidUntil)}},
+	}, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) GetInstrumentArchive(ctx context.Context, instrumentName string) (*InstrumentArchive, error) {
+	archives, err := store.queryArchives(ctx, `WHERE instrument_name = ?`, strings.ToLower(instrumentName))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return archives[0], nil
+}
+
+func (store *SQLUacStore) ListInstrumentArchives(ctx context.Context) ([]*InstrumentArchive, error) {
+	return store.queryArchives(ctx, ``)
+}
+
+func (store *SQLUacStore) ListArchivedUacs(ctx context.Context, instrumentName string) ([]*UacInfo, error) {
+	return store.queryTable(ctx, store.DB, `archived_uacs`, `instrument_name = ?`, strings.ToLower(instrumentName))
+}
+
+func (store *SQLUacStore) RestoreUac(ctx context.Context, archivedUac *datastore.Key, uacInfo *UacInfo, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	result, err := transaction.ExecContext(ctx, store.rebind(
+		`DELETE FROM archived_uacs WHERE uac_kind = ? AND uac = ?`,
+	), archivedUac.Kind, archivedUac.Name)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		transaction.Rollback()
+		return datastore.ErrNoSuchEntity
+	}
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`INSERT INTO uacs (`+sqlUacColumns+`) VALUES (`+sqlUacPlaceholders+`)`,
+	), sqlUacValues(uacInfo)...)
+	if err != nil {
+		transaction.Rollback()
+		if conflictErr := store.insertConflict(ctx, uacInfo); conflictErr != nil {
+			return conflictErr
+		}
+		return err
+	}
+	err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) DeleteInstrumentArchive(ctx context.Context, instrumentName string, auditEntry func(*UacInfo) *AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var auditEntries []*AuditEntry
+	if auditEntry != nil {
+		uacInfos, err := store.queryTable(ctx, transaction, `archived_uacs`, `instrument_name = ?`, strings.ToLower(instrumentName))
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+		for _, uacInfo := range uacInfos {
+			auditEntries = append(auditEntries, auditEntry(uacInfo))
+		}
+	}
+	err = store.runInTransaction(ctx, transaction, []sqlStatement{
+		{`DELETE FROM archived_uacs WHERE instrument_name = ?`, []interface{}{strings.ToLower(instrumentName)}},
+		{`DELETE FROM instrument_archives WHERE instrument_name = ?`, []interface{}{strings.ToLower(instrumentName)}},
+	}, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) GetInstrumentConfig(ctx context.Context, instrumentName string) (*InstrumentConfig, error) {
+	var (
+		instrumentConfig      = &InstrumentConfig{InstrumentName: strings.ToLower(instrumentName)}
+		validFrom, validUntil sql.NullTime
+	)
+	err := store.DB.QueryRowContext(ctx, store.rebind(
+		`SELECT uac_kind, valid_from, valid_until FROM instruments WHERE instrument_name = ?`,
+	), strings.ToLower(instrumentName)).Scan(&instrumentConfig.UacKind, &validFrom, &validUntil)
+	if err == sql.ErrNoRows {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	if err != nil {
+		return nil, err
+	}
+	instrumentConfig.ValidFrom = scannedTime(validFrom)
+	instrumentConfig.ValidUntil = scannedTime(validUntil)
+	return instrumentConfig, nil
+}
+
+func (store *SQLUacStore) InsertInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error {
+	err := store.inTransaction(ctx, []sqlStatement{
+		{`INSERT INTO instruments (instrument_name, uac_kind) VALUES (?, ?)`, []interface{}{strings.ToLower(instrumentName), uacKind}},
+	}, auditEntries)
+	if err != nil {
+		if _, getErr := store.GetInstrumentConfig(ctx, instrumentName); getErr == nil {
+			return ErrInstrumentHasKind
+		}
+		return err
+	}
+	return nil
+}
+
+// SaveInstrumentConfig looks for UACs of another kind in the transaction that records the kind. PostgreSQL locks the
+// instrument until it commits, so cases cannot be given UACs of the old kind once it has looked.
+func (store *SQLUacStore) SaveInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = store.saveInstrumentConfig(ctx, transaction, strings.ToLower(instrumentName), uacKind)
+	if err == nil {
+		err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) saveInstrumentConfig(ctx context.Context, transaction *sql.Tx, instrumentName, uacKind string) error {
+	var currentUacKind string
+	err := transaction.QueryRowContext(ctx, store.rebind(
+		`SELECT uac_kind FROM instruments WHERE instrument_name = ?`+store.lockRows("UPDATE"),
+	), instrumentName).Scan(&currentUacKind)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if currentUacKind != uacKind {
+		var uacCount int
+		err = transaction.QueryRowContext(ctx, store.rebind(
+			`SELECT COUNT(*) FROM uacs WHERE instrument_name = ? AND uac_kind <> ?`,
+		), instrumentName, uacKind).Scan(&uacCount)
+		if err != nil {
+			return err
+		}
+		if uacCount > 0 {
+			return ErrInstrumentHasUacs
+		}
+	}
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`INSERT INTO instruments (instrument_name, uac_kind) VALUES (?, ?)
+		ON CONFLICT (instrument_name) DO UPDATE SET uac_kind = excluded.uac_kind`,
+	), instrumentName, uacKind)
+	return err
+}
+
+func (store *SQLUacStore) SaveInstrumentValidity(ctx context.Context, instrumentName string, validity Validity, auditEntries []*AuditEntry) error {
+	return store.updateOne(ctx, auditEntries, `UPDATE instruments SET valid_from = ?, valid_until = ? WHERE instrument_name = ?`,
+		sqlTime(validity.ValidFrom), sqlTime(validity.ValidUntil), strings.ToLower(instrumentName))
+}
+
+// insertAuditEntries adds entries to the audit trail in the transaction that makes the changes they record, saving
+// the states before and after each change as JSON.
+func (store *SQLUacStore) insertAuditEntries(ctx context.Context, transaction *sql.Tx, entries []*AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	statement, err := transaction.PrepareContext(ctx, store.rebind(
+		`INSERT INTO uac_audit (id, uac, uac_kind, instrument_name, action, actor, recorded_at, before_state, after_state,
+		reason, note) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, entry := range entries {
+		before, err := json.Marshal(entry.Before)
+		if err != nil {
+			return err
+		}
+		after, err := json.Marshal(entry.After)
+		if err != nil {
+			return err
+		}
+		_, err = statement.ExecContext(ctx, entry.ID, entry.UAC, entry.UacKind, entry.InstrumentName, entry.Action,
+			entry.Actor, entry.Timestamp.UTC(), string(before), string(after), entry.Reason, entry.Note)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAuditEntries filters time ranges on the ID, as audit IDs sort by time.
+func (store *SQLUacStore) ListAuditEntries(ctx context.Context, query AuditQuery) ([]*AuditEntry, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	filter := func(condition string, arg interface{}) {
+		where = append(where, condition)
+		args = append(args, arg)
+	}
+	if query.UAC != "" {
+		filter(`uac = ?`, query.UAC)
+	}
+	if query.InstrumentName != "" {
+		filter(`instrument_name = ?`, query.InstrumentName)
+	}
+	if query.Actor != "" {
+		filter(`actor = ?`, query.Actor)
+	}
+	if !query.From.IsZero() {
+		filter(`id >= ?`, auditIDBound(query.From))
+	}
+	if !query.To.IsZero() {
+		filter(`id < ?`, auditIDBound(query.To))
+	}
+	if query.Cursor != "" {
+		filter(`id > ?`, query.Cursor)
+	}
+	statement := `SELECT id, uac, uac_kind, instrument_name, action, actor, recorded_at, before_state, after_state,
+		reason, note FROM uac_audit`
+	if len(where) > 0 {
+		statement += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	statement += ` ORDER BY id`
+	if query.Limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+	rows, err := store.DB.QueryContext(ctx, store.rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*AuditEntry
+	for rows.Next() {
+		var (
+			entry         = &AuditEntry{}
+			before, after string
+		)
+		err := rows.Scan(&entry.ID, &entry.UAC, &entry.UacKind, &entry.InstrumentName, &entry.Action, &entry.Actor,
+			&entry.Timestamp, &before, &after, &entry.Reason, &entry.Note)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(before), &entry.Before); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(after), &entry.After); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = entry.Timestamp.UTC()
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// InsertGenerationJob saves the job and its cases, numbered in order, in one transaction.
+func (store *SQLUacStore) InsertGenerationJob(ctx context.Context, job *GenerationJob, cases []JobCase) error {
+	values, err := sqlJobValues(job)
+	if err != nil {
+		return err
+	}
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = transaction.ExecContext(ctx, store.rebind(
+		`INSERT INTO generation_jobs (id, `+sqlJobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	), append([]interface{}{job.ID}, values...)...)
+	if err == nil {
+		err = store.insertJobCases(ctx, transaction, job.ID, cases)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) GetGenerationJob(ctx context.Context, jobID string) (*GenerationJob, error) {
+	return store.queryJob(ctx, store.DB, jobID, "")
+}
+
+func (store *SQLUacStore) ListRunningGenerationJobs(ctx context.Context) ([]*GenerationJob, error) {
+	return store.queryJobs(ctx, store.DB, `status = ? ORDER BY id`, JOBRUNNING)
+}
+
+func (store *SQLUacStore) ListGenerationJobCases(ctx context.Context, jobID string) ([]JobCase, error) {
+	rows, err := store.DB.QueryContext(ctx, store.rebind(
+		`SELECT case_id, uacs FROM generation_job_cases WHERE job_id = ? ORDER BY position`,
+	), jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cases []JobCase
+	for rows.Next() {
+		var jobCase JobCase
+		err = rows.Scan(&jobCase.CaseID, &jobCase.Uacs)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, jobCase)
+	}
+	return cases, rows.Err()
+}
+
+// UpdateGenerationJob locks the job's row while update runs, so concurrent updates wait for each other.
+func (store *SQLUacStore) UpdateGenerationJob(ctx context.Context, jobID string, update func(*GenerationJob) error) (*GenerationJob, error) {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	job, err := store.queryJob(ctx, transaction, jobID, store.lockRows("UPDATE"))
+	if err == nil {
+		err = update(job)
+	}
+	var values []interface{}
+	if err == nil {
+		values, err = sqlJobValues(job)
+	}
+	if err == nil {
+		_, err = transaction.ExecContext(ctx, store.rebind(
+			`UPDATE generation_jobs SET (`+sqlJobColumns+`) = (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			WHERE id = ?`,
+		), append(values, jobID)...)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+	return job, transaction.Commit()
+}
+
+func (store *SQLUacStore) Close() error {
+	return store.DB.Close()
+}
+
+func (store *SQLUacStore) queryUacs(ctx context.Context, where string, args ...interface{}) ([]*UacInfo, error) {
+	return store.queryTable(ctx, store.DB, `uacs`, where, args...)
+}
+
+// sqlQueryer is a database or a transaction.
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryTable reads the UACs of uacs, or of archived_uacs which has the same columns.
+func (store *SQLUacStore) queryTable(ctx context.Context, queryer sqlQueryer, table, where string, args ...interface{}) ([]*UacInfo, error) {
+	rows, err := queryer.QueryContext(ctx, store.rebind(
+		`SELECT `+sqlUacColumns+` FROM `+table+` WHERE `+where+` ORDER BY uac`,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var uacInfos []*UacInfo
+	for rows.Next() {
+		uacInfo, err := scanUac(rows)
+		if err != nil {
+			return nil, err
+		}
+		uacInfos = append(uacInfos, uacInfo)
+	}
+	return uacInfos, rows.Err()
+}
+
+func (store *SQLUacStore) queryArchives(ctx context.Context, where string, args ...interface{}) ([]*InstrumentArchive, error) {
+	rows, err := store.DB.QueryContext(ctx, store.rebind(
+		`SELECT instrument_name, uac_kind, deleted_at, deleted_by, purge_after, valid_from, valid_until FROM instrument_archives `+where+
+			` ORDER BY instrument_name`,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var archives []*InstrumentArchive
+	for rows.Next() {
+		var (
+			archive               = &InstrumentArchive{}
+			validFrom, validUntil sql.NullTime
+		)
+		err := rows.Scan(&archive.InstrumentName, &archive.UacKind, &archive.DeletedAt, &archive.DeletedBy, &archive.PurgeAfter,
+			&validFrom, &validUntil)
+		if err != nil {
+			return nil, err
+		}
+		archive.DeletedAt = archive.DeletedAt.UTC()
+		archive.PurgeAfter = archive.PurgeAfter.UTC()
+		archive.ValidFrom = scannedTime(validFrom)
+		archive.ValidUntil = scannedTime(validUntil)
+		archives = append(archives, archive)
+	}
+	return archives, rows.Err()
+}
+
+// queryJob reads a job, returning datastore.ErrNoSuchEntity when it does not exist. lock is added to the select.
+func (store *SQLUacStore) queryJob(ctx context.Context, queryer sqlQueryer, jobID, lock string) (*GenerationJob, error) {
+	jobs, err := store.queryJobs(ctx, queryer, `id = ?`+lock, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	return jobs[0], nil
+}
+
+func (store *SQLUacStore) queryJobs(ctx context.Context, queryer sqlQueryer, where string, args ...interface{}) ([]*GenerationJob, error) {
+	rows, err := queryer.QueryContext(ctx, store.rebind(
+		`SELECT id, `+sqlJobColumns+` FROM generation_jobs WHERE `+where,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []*GenerationJob
+	for rows.Next() {
+		var (
+			job                                = &GenerationJob{}
+			failedCases                        string
+			leaseExpires, startedAt, updatedAt sql.NullTime
+		)
+		err = rows.Scan(&job.ID, &job.InstrumentName, &job.Status, &job.ClaimFromPool, &job.UacsPerCase, &job.Total,
+			&job.Processed, &job.Created, &job.FromPool, &job.Existing, &job.Failed, &failedCases, &job.Error,
+			&job.CancelRequested, &job.StartedBy, &job.RunnerID, &leaseExpires, &job.Resumed, &startedAt, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(failedCases), &job.FailedCases)
+		if err != nil {
+			return nil, err
+		}
+		job.LeaseExpires = scannedTime(leaseExpires)
+		job.StartedAt = scannedTime(startedAt)
+		job.UpdatedAt = scannedTime(updatedAt)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// insertJobCases saves the cases of a job in the transaction that saves the job.
+func (store *SQLUacStore) insertJobCases(ctx context.Context, transaction *sql.Tx, jobID string, cases []JobCase) error {
+	if len(cases) == 0 {
+		return nil
+	}
+	statement, err := transaction.PrepareContext(ctx, store.rebind(
+		`INSERT INTO generation_job_cases (job_id, position, case_id, uacs) VALUES (?, ?, ?, ?)`,
+	))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for position, jobCase := range cases {
+		_, err = statement.ExecContext(ctx, jobID, position, jobCase.CaseID, jobCase.Uacs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateOne runs an update and adds its audit entries in one transaction, returning datastore.ErrNoSuchEntity when it
+// updated nothing.
+func (store *SQLUacStore) updateOne(ctx context.Context, auditEntries []*AuditEntry, query string, args ...interface{}) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	result, err := transaction.ExecContext(ctx, store.rebind(query), args...)
+	if err == nil {
+		var rowsAffected int64
+		rowsAffected, err = result.RowsAffected()
+		if err == nil && rowsAffected == 0 {
+			err = datastore.ErrNoSuchEntity
+		}
+	}
+	if err == nil {
+		err = store.insertAuditEntries(ctx, transaction, auditEntries)
+	}
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+// sqlStatement is a statement run by inTransaction with its arguments.
+type sqlStatement struct {
+	query string
+	args  []interface{}
+}
+
+// inTransaction runs statements in order, then adds the audit entries, in one transaction, rolling back when any
+// fails.
+func (store *SQLUacStore) inTransaction(ctx context.Context, statements []sqlStatement, auditEntries []*AuditEntry) error {
+	transaction, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = store.runInTransaction(ctx, transaction, statements, auditEntries)
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+	return transaction.Commit()
+}
+
+func (store *SQLUacStore) runInTransaction(ctx context.Context, transaction *sql.Tx, statements []sqlStatement, auditEntries []*AuditEntry) error {
+	for _, statement := range statements {
+		_, err := transaction.ExecContext(ctx, store.rebind(statement.query), statement.args...)
+		if err != nil {
+			return err
+		}
+	}
+	return store.insertAuditEntries(ctx, transaction, auditEntries)
+}
+
+// scanUac reads a row selected with sqlUacColumns.
+func scanUac(rows *sql.Rows) (*UacInfo, error) {
+	var (
+		uac, uacKind                                      string
+		createdAt, updatedAt, disabledAt, firstAccessedAt sql.NullTime
+		validFrom, validUntil                             sql.NullTime
+		uacInfo                                           = &UacInfo{}
+	)
+	err := rows.Scan(&uac, &uacKind, &uacInfo.InstrumentName, &uacInfo.CaseID, &uacInfo.Disabled, &uacInfo.SealedUAC,
+		&createdAt, &updatedAt, &disabledAt, &firstAccessedAt, &uacInfo.DisabledReason, &uacInfo.DisabledNote,
+		&uacInfo.ReissuedFrom, &uacInfo.ReissuedTo, &validFrom, &validUntil, &uacInfo.Slot)
+	if err != nil {
+		return nil, err
+	}
+	uacInfo.UAC = uacKindKey(uacKind, uac)
+	uacInfo.CreatedAt = scannedTime(createdAt)
+	uacInfo.UpdatedAt = scannedTime(updatedAt)
+	uacInfo.DisabledAt = scannedTime(disabledAt)
+	uacInfo.FirstAccessedAt = scannedTime(firstAccessedAt)
+	uacInfo.ValidFrom = scannedTime(validFrom)
+	uacInfo.ValidUntil = scannedTime(validUntil)
+	return uacInfo, nil
+}
+
+// sqlUacValues returns the values of sqlUacColumns for a UAC, with the instrument and case lowercased.
+func sqlUacValues(uacInfo *UacInfo) []interface{} {
+	return []interface{}{uacInfo.UAC.Name, uacInfo.UAC.Kind, strings.ToLower(uacInfo.InstrumentName),
+		strings.ToLower(uacInfo.CaseID), uacInfo.Disabled, uacInfo.SealedUAC, sqlTime(uacInfo.CreatedAt),
+		sqlTime(uacInfo.UpdatedAt), sqlTime(uacInfo.DisabledAt), sqlTime(uacInfo.FirstAccessedAt), uacInfo.DisabledReason,
+		uacInfo.DisabledNote, uacInfo.ReissuedFrom, uacInfo.ReissuedTo, sqlTime(uacInfo.ValidFrom),
+		sqlTime(uacInfo.ValidUntil), uacInfo.Slot}
+}
+
+// sqlJobValues returns the values of sqlJobColumns of a job, saving its failed cases as JSON.
+func sqlJobValues(job *GenerationJob) ([]interface{}, error) {
+	failedCases := []CaseFailure{}
+	if job.FailedCases != nil {
+		failedCases = job.FailedCases
+	}
+	failedCasesJSON, err := json.Marshal(failedCases)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{job.InstrumentName, job.Status, job.ClaimFromPool, job.UacsPerCase, job.Total, job.Processed,
+		job.Created, job.FromPool, job.Existing, job.Failed, string(failedCasesJSON), job.Error, job.CancelRequested,
+		job.StartedBy, job.RunnerID, sqlTime(job.LeaseExpires.UTC()), job.Resumed, sqlTime(job.StartedAt.UTC()),
+		sqlTime(job.UpdatedAt.UTC())}, nil
+}
+
+// sqlTime saves a zero time as NULL.
+func sqlTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func scannedTime(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC()
+}
+
+// sqlFilterConditions returns the conditions, each starting with AND, that select the UACs matching a filter.
+// Comparisons are never true of NULL, so UACs without a timestamp do not match a range on it.
+func sqlFilterConditions(filter UacFilter) (string, []interface{}) {
+	var (
+		conditions strings.Builder
+		args       []interface{}
+	)
+	for _, columnRange := range []struct {
+		column    string
+		timeRange TimeRange
+	}{
+		{"created_at", filter.Created},
+		{"updated_at", filter.Updated},
+		{"disabled_at", filter.Disabled},
+		{"first_accessed_at", filter.FirstAccessed},
+	} {
+		if !columnRange.timeRange.From.IsZero() {
+			conditions.WriteString(` AND ` + columnRange.column + ` >= ?`)
+			args = append(args, columnRange.timeRange.From.UTC())
+		}
+		if !columnRange.timeRange.To.IsZero() {
+			conditions.WriteString(` AND ` + columnRange.column + ` < ?`)
+			args = append(args, columnRange.timeRange.To.UTC())
+		}
+	}
+	return conditions.String(), args
+}
+
+// checkInstrumentUacKind returns ErrInstrumentUacKindChanged when the instrument has recorded a UAC kind other than
+// uacKind. PostgreSQL holds a share lock on the instrument until the transaction ends, so its kind cannot be changed in
+// between.
+func (store *SQLUacStore) checkInstrumentUacKind(ctx context.Context, transaction *sql.Tx, instrumentName, uacKind string) error {
+	var instrumentUacKind string
+	err := transaction.QueryRowContext(ctx, store.rebind(
+		`SELECT uac_kind FROM instruments WHERE instrument_name = ?`+store.lockRows("SHARE"),
+	), strings.ToLower(instrumentName)).Scan(&instrumentUacKind)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if instrumentUacKind != uacKind {
+		return ErrInstrumentUacKindChanged
+	}
+	return nil
+}
+
+// lockRows returns the clause that locks the rows a PostgreSQL select reads until its transaction ends. SQLite only
+// runs one writing transaction at a time, so needs none.
+func (store *SQLUacStore) lockRows(strength string) string {
+	if store.Dialect != POSTGRES {
+		return ""
+	}
+	return " FOR " + strength
+}
+
+// insertConflict returns why a UAC could not be inserted, as the drivers report constraint violations differently.
+func (store *SQLUacStore) insertConflict(ctx context.Context, uacInfo *UacInfo) error {
+	if !strings.EqualFold(uacInfo.InstrumentName, UNKNOWNINSTRUMENT) && store.caseHasUac(ctx, uacInfo.InstrumentName, uacInfo.CaseID, uacInfo.Slot) {
+		return ErrCaseHasUac
+	}
+	if store.uacExists(ctx, uacInfo.UAC) {
+		return ErrUacExists
+	}
+	return nil
+}
+
+func (store *SQLUacStore) uacExists(ctx context.Context, uac *datastore.Key) bool {
+	var uacCount int
+	err := store.DB.QueryRowContext(ctx, store.rebind(`SELECT COUNT(*) FROM uacs WHERE uac = ?`), uac.Name).Scan(&uacCount)
+	return err == nil && uacCount > 0
+}
+
+// caseHasUac reports whether the slot of a case has a current UAC. Reissued UACs are left out, as they are by the unique
+// index of case slots.
+func (store *SQLUacStore) caseHasUac(ctx context.Context, instrumentName, caseID string, slot int) bool {
+	var uacCount int
+	err := store.DB.QueryRowContext(ctx, store.rebind(
+		`SELECT COUNT(*) FROM uacs WHERE instrument_name = ? AND case_id = ? AND slot = ? AND reissued_to = ''`,
+	), strings.ToLower(instrumentName), strings.ToLower(caseID), slot).Scan(&uacCount)
+	return err == nil && uacCount > 0
+}
+
+// rebind replaces the ? placeholders of a query with PostgreSQL's numbered placeholders.
+func (store *SQLUacStore) rebind(query string) string {
+	if store.Dialect != POSTGRES {
+		return query
+	}
+	var (
+		rebound     strings.Builder
+		placeholder = 0
+	)
+	for _, character := range query {
+		if character == '?' {
+			placeholder++
+			rebound.WriteString("$" + strconv.Itoa(placeholder))
+			continue
+		}
+		rebound.WriteRune(character)
+	}
+	return rebound.String()
+}
@@ -0,0 +1,273 @@
+package uacgenerator_test
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	_ "modernc.org/sqlite"
+)
+
+var _ = Describe("SQLUacStore", func() {
+	var (
+		store        *uacgenerator.SQLUacStore
+		uacGenerator *uacgenerator.UacGenerator
+	)
+
+	BeforeEach(func() {
+		store = newSQLStore()
+		uacGenerator = uacgenerator.NewUacGeneratorWithStore(store, "uac")
+	})
+
+	AfterEach(func() {
+		store.Close()
+	})
+
+	Describe("InsertCaseUac", func() {
+		It("returns ErrUacExists when the UAC exists", func() {
+			Expect(uacGenerator.AddUacToDatastore("123412341234", "lolcat", "1")).To(Succeed())
+			err := uacGenerator.AddUacToDatastore("123412341234", "dst2108a", "1")
+			Expect(err).To(Equal(uacgenerator.ErrUacExists))
+		})
+
+		It("returns ErrCaseHasUac when the case has a UAC", func() {
+			Expect(uacGenerator.AddUacToDatastore("123412341234", "lolcat", "1")).To(Succeed())
+			err := uacGenerator.AddUacToDatastore("234523452345", "LOLcat", "1")
+			Expect(err).To(Equal(uacgenerator.ErrCaseHasUac))
+		})
+
+		It("returns ErrUacExists when the case only has a reissued UAC", func() {
+			Expect(store.InsertCaseUac(context.Background(), &uacgenerator.UacInfo{
+				InstrumentName: "lolcat",
+				CaseID:         "1",
+				UAC:            uacGenerator.UacKey("123412341234"),
+				ReissuedTo:     "234523452345",
+			}, nil)).To(Succeed())
+			err := uacGenerator.AddUacToDatastore("123412341234", "lolcat", "1")
+			Expect(err).To(Equal(uacgenerator.ErrUacExists))
+		})
+	})
+
+	Describe("InsertCaseUacs", func() {
+		It("inserts none of the UACs when one exists", func() {
+			Expect(uacGenerator.AddUacToDatastore("234523452345", "lolcat", "1")).To(Succeed())
+
+			err := store.InsertCaseUacs(context.Background(), []*uacgenerator.UacInfo{
+				{InstrumentName: "lolcat", CaseID: "2", UAC: uacGenerator.UacKey("123412341234")},
+				{InstrumentName: "lolcat", CaseID: "3", UAC: uacGenerator.UacKey("234523452345")},
+			}, nil)
+			Expect(err).To(Equal(uacgenerator.ErrUacExists))
+
+			_, err = store.GetUac(context.Background(), uacGenerator.UacKey("123412341234"))
+			Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+		})
+	})
+
+	Describe("CreateSchema", func() {
+		It("adds the columns added since to tables created before them", func() {
+			db, err := sql.Open("sqlite", ":memory:")
+			Expect(err).To(BeNil())
+			db.SetMaxOpenConns(1)
+			for _, table := range []string{"uacs", "archived_uacs"} {
+				_, err = db.Exec(`CREATE TABLE ` + table + ` (
+					uac             TEXT    NOT NULL PRIMARY KEY,
+					uac_kind        TEXT    NOT NULL,
+					instrument_name TEXT    NOT NULL,
+					case_id         TEXT    NOT NULL,
+					disabled        BOOLEAN NOT NULL DEFAULT FALSE,
+					sealed_uac      TEXT    NOT NULL DEFAULT ''
+				)`)
+				Expect(err).To(BeNil())
+			}
+			_, err = db.Exec(`CREATE UNIQUE INDEX uacs_instrument_case ON uacs (instrument_name, case_id)
+				WHERE instrument_name <> 'unknown'`)
+			Expect(err).To(BeNil())
+			_, err = db.Exec(`INSERT INTO uacs (uac, uac_kind, instrument_name, case_id) VALUES ('123412341234', 'uac', 'lolcat', '1')`)
+			Expect(err).To(BeNil())
+			_, err = db.Exec(`CREATE TABLE instruments (instrument_name TEXT NOT NULL PRIMARY KEY, uac_kind TEXT NOT NULL)`)
+			Expect(err).To(BeNil())
+			_, err = db.Exec(`INSERT INTO instruments (instrument_name, uac_kind) VALUES ('lolcat', 'uac')`)
+			Expect(err).To(BeNil())
+			oldStore, err := uacgenerator.NewSQLUacStore(db, uacgenerator.SQLITE)
+			Expect(err).To(BeNil())
+			defer oldStore.Close()
+
+			Expect(oldStore.CreateSchema(context.Background())).To(Succeed())
+			Expect(oldStore.CreateSchema(context.Background())).To(Succeed())
+			uacInfo, err := oldStore.GetUac(context.Background(), uacGenerator.UacKey("123412341234"))
+			Expect(err).To(BeNil())
+			Expect(uacInfo.CreatedAt.IsZero()).To(BeTrue())
+			Expect(oldStore.RecordUacAccess(context.Background(), uacInfo.UAC)).To(Succeed())
+			uacInfo, err = oldStore.GetUac(context.Background(), uacInfo.UAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.FirstAccessedAt.IsZero()).To(BeFalse())
+			Expect(oldStore.SetUacsDisabled(context.Background(), []*datastore.Key{uacInfo.UAC}, uacgenerator.UacStatusChange{
+				Disabled: true,
+				Reason:   uacgenerator.REASONDECEASED,
+			}, nil)).To(Succeed())
+			uacInfo, err = oldStore.GetUac(context.Background(), uacInfo.UAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.DisabledReason).To(Equal(uacgenerator.REASONDECEASED))
+
+			validUntil := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			Expect(oldStore.SetUacValidity(context.Background(), uacInfo.UAC, uacgenerator.Validity{ValidUntil: validUntil}, nil)).To(Succeed())
+			uacInfo, err = oldStore.GetUac(context.Background(), uacInfo.UAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.ValidUntil).To(Equal(validUntil))
+			Expect(oldStore.SaveInstrumentValidity(context.Background(), "lolcat", uacgenerator.Validity{ValidUntil: validUntil}, nil)).To(Succeed())
+			instrumentConfig, err := oldStore.GetInstrumentConfig(context.Background(), "lolcat")
+			Expect(err).To(BeNil())
+			Expect(instrumentConfig.UacKind).To(Equal("uac"))
+			Expect(instrumentConfig.ValidUntil).To(Equal(validUntil))
+
+			replaced, err := oldStore.ReissueCaseUac(context.Background(), &uacgenerator.UacInfo{
+				InstrumentName: "lolcat",
+				CaseID:         "1",
+				UAC:            uacGenerator.UacKey("567856785678"),
+			}, uacgenerator.UacStatusChange{Disabled: true, Reason: uacgenerator.REASONREISSUED},
+				func(*uacgenerator.UacInfo) []*uacgenerator.AuditEntry { return nil })
+			Expect(err).To(BeNil())
+			Expect(replaced.UAC.Name).To(Equal("123412341234"))
+		})
+	})
+
+	Describe("the generator", func() {
+		It("generates, counts, lists and deletes UACs", func() {
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2", "3"})
+			Expect(err).To(BeNil())
+			Expect(result.Generated).To(HaveLen(3))
+			_, err = uacGenerator.Generate("dst2108a", []string{"1"})
+			Expect(err).To(BeNil())
+
+			result, err = uacGenerator.Generate("LOLcat", []string{"3", "4"})
+			Expect(err).To(BeNil())
+			Expect(result.Existing).To(Equal([]string{"3"}))
+			Expect(result.Generated).To(Equal([]string{"4"}))
+
+			uacCount, err := uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacCount).To(Equal(4))
+
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacs).To(HaveLen(4))
+
+			uacInfo, err := uacGenerator.GetUacInfo(uacs["4"].FullUAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.CaseID).To(Equal("4"))
+
+			instrumentNames, err := uacGenerator.GetInstruments()
+			Expect(err).To(BeNil())
+			Expect(instrumentNames).To(Equal([]string{"dst2108a", "lolcat"}))
+
+			Expect(uacGenerator.AdminDelete("lolcat")).To(Succeed())
+			uacCount, err = uacGenerator.GetUacCount("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacCount).To(Equal(0))
+		})
+
+		It("disables and enables UACs", func() {
+			result, err := uacGenerator.Generate("lolcat", []string{"1", "2"})
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID("lolcat")
+			Expect(err).To(BeNil())
+			uac := uacs[result.Generated[0]].FullUAC
+
+			Expect(uacGenerator.DisableUac(uac)).To(Succeed())
+			disabled, err := uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(1))
+
+			Expect(uacGenerator.EnableUac(uac)).To(Succeed())
+			disabled, err = uacGenerator.GetAllUacsDisabled("lolcat")
+			Expect(err).To(BeNil())
+			Expect(disabled).To(HaveLen(0))
+		})
+
+		It("claims imported UACs before generating", func() {
+			importCount, err := uacGenerator.ImportUACs([]string{"123412341234", "234523452345"})
+			Expect(err).To(BeNil())
+			Expect(importCount).To(Equal(2))
+
+			result, err := uacGenerator.GenerateWithOptions("lolcat", []string{"1", "2", "3"}, uacgenerator.GenerateOptions{ClaimFromPool: true})
+			Expect(err).To(BeNil())
+			Expect(result.FromPool).To(HaveLen(2))
+			Expect(result.Generated).To(HaveLen(1))
+
+			uacInfo, err := uacGenerator.GetUacInfo("123412341234")
+			Expect(err).To(BeNil())
+			Expect(uacInfo.InstrumentName).To(Equal("lolcat"))
+		})
+
+		It("records the UAC kind of each instrument", func() {
+			uacGenerator.UacKinds = []string{"uac16"}
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac16")).To(Succeed())
+			_, err := uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+
+			uacKind, err := uacGenerator.GetInstrumentUacKind("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacKind).To(Equal("uac16"))
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac")).To(Equal(uacgenerator.ErrInstrumentHasUacs))
+		})
+
+		It("makes no change that cannot be recorded in the audit trail", func() {
+			_, err := store.DB.Exec(`DROP TABLE uac_audit`)
+			Expect(err).To(BeNil())
+
+			_, err = uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).ToNot(BeNil())
+			_, err = store.GetInstrumentConfig(context.Background(), "lolcat")
+			Expect(err).To(Equal(datastore.ErrNoSuchEntity))
+
+			Expect(store.InsertInstrumentConfig(context.Background(), "lolcat", "uac", nil)).To(Succeed())
+			result, err := uacGenerator.Generate("lolcat", []string{"1"})
+			Expect(err).To(BeNil())
+			Expect(result.HasFailures()).To(BeTrue())
+			Expect(uacGenerator.GetUacCount("lolcat")).To(Equal(0))
+		})
+
+		It("runs generation jobs, resuming those whose lease has expired", func() {
+			job, err := uacGenerator.StartGenerationJob("lolcat", []string{"1", "2"}, uacgenerator.GenerateOptions{CaseUacs: map[string]int{"2": 2}})
+			Expect(err).To(BeNil())
+			Eventually(func() string {
+				job, err = uacGenerator.GetGenerationJob(job.ID)
+				Expect(err).To(BeNil())
+				return job.Status
+			}).Should(Equal(uacgenerator.JOBCOMPLETED))
+			Expect(job.Created).To(Equal(3))
+			Expect(uacGenerator.GetUacCount("lolcat")).To(Equal(3))
+
+			Expect(store.InsertGenerationJob(context.Background(), &uacgenerator.GenerationJob{
+				ID:             "stopped",
+				InstrumentName: "dst2108a",
+				Status:         uacgenerator.JOBRUNNING,
+				Total:          3,
+				Processed:      1,
+				Created:        1,
+				RunnerID:       "stopped",
+				LeaseExpires:   time.Now().Add(-time.Minute),
+			}, []uacgenerator.JobCase{{CaseID: "1"}, {CaseID: "2"}, {CaseID: "3"}})).To(Succeed())
+			job, err = uacGenerator.GetGenerationJob("stopped")
+			Expect(err).To(BeNil())
+			Expect(job.Resumed).To(Equal(0))
+
+			resumed, err := uacGenerator.ResumeGenerationJobs()
+			Expect(err).To(BeNil())
+			Expect(resumed).To(Equal([]string{"stopped"}))
+			Eventually(func() string {
+				job, err = uacGenerator.GetGenerationJob("stopped")
+				Expect(err).To(BeNil())
+				return job.Status
+			}).Should(Equal(uacgenerator.JOBCOMPLETED))
+			Expect(job.Processed).To(Equal(3))
+			Expect(job.Created).To(Equal(3))
+			Expect(job.Resumed).To(Equal(1))
+			Expect(uacGenerator.GetUacCount("dst2108a")).To(Equal(2))
+		})
+	})
+})
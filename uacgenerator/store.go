@@ -0,0 +1,100 @@
+package uacgenerator
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+)
+
+// UacStore saves UACs and the UAC kind recorded for each instrument. UACs are identified by a Datastore key of
+// their kind and UAC whichever store is used, and stores return datastore.ErrNoSuchEntity when a UAC or instrument
+// does not exist, so the generator does not need to know where UACs are kept. Methods that change UACs or instruments
+// add the audit entries they are given to the audit trail in the same commit, so every change committed is recorded and
+// nothing is recorded for a change that fails. Audit entries cannot be changed or removed once added.
+type UacStore interface {
+	// InsertCaseUacs saves new UACs for case slots all or nothing, returning an error for which alreadyExistsError
+	// is true when any of the UACs exists or any of the slots already has a UAC.
+	InsertCaseUacs(ctx context.Context, uacInfos []*UacInfo, auditEntries []*AuditEntry) error
+	// InsertCaseUac saves a new UAC for a slot of a case, returning ErrCaseHasUac when the slot already has a UAC
+	// and an error for which alreadyExistsError is true when the UAC exists.
+	InsertCaseUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error
+	// InsertPoolUac saves an imported UAC against the unknown instrument.
+	InsertPoolUac(ctx context.Context, uacInfo *UacInfo, auditEntries []*AuditEntry) error
+	// ListPoolUacs returns up to limit enabled UACs of a kind that belong to the unknown instrument.
+	ListPoolUacs(ctx context.Context, uacKind string, limit int) ([]*datastore.Key, error)
+	// ClaimPoolUac moves a UAC from the unknown instrument to a slot of a case, returning false when the UAC has
+	// already been claimed or disabled and ErrCaseHasUac when the slot already has a UAC. The audit entries are only
+	// added when the UAC is claimed.
+	ClaimPoolUac(ctx context.Context, uac *datastore.Key, instrumentName, caseID string, slot int, auditEntries []*AuditEntry) (bool, error)
+	GetUac(context.Context, *datastore.Key) (*UacInfo, error)
+	ListUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error)
+	// ListCaseSlots returns the lowercased CaseSlotKeys of an instrument's case slots that have a UAC of a kind,
+	// without loading the UACs where the store can avoid it.
+	ListCaseSlots(ctx context.Context, uacKind, instrumentName string) ([]string, error)
+	// IterateUacs calls f with an instrument's UACs of a kind that match filter in order, starting after cursor.
+	// When limit is above zero it stops after limit UACs and returns a cursor for the rest, which is empty when
+	// there are no more.
+	IterateUacs(ctx context.Context, uacKind, instrumentName string, filter UacFilter, cursor string, limit int, f func(*UacInfo) error) (string, error)
+	ListDisabledUacs(ctx context.Context, uacKind, instrumentName string) ([]*UacInfo, error)
+	// ListCaseUacs returns the UACs of every slot of a case.
+	ListCaseUacs(ctx context.Context, uacKind, instrumentName, caseID string) ([]*UacInfo, error)
+	CountUacs(ctx context.Context, uacKind, instrumentName string) (int, error)
+	// SetUacsDisabled disables or enables UACs all or nothing, recording when they were changed and, unless they were
+	// already disabled, when and why they were disabled. It returns datastore.ErrNoSuchEntity when any UAC does not exist.
+	SetUacsDisabled(ctx context.Context, uacs []*datastore.Key, change UacStatusChange, auditEntries []*AuditEntry) error
+	// ReissueCaseUac inserts uacInfo as the current UAC of its case slot, disabling the slot's current UAC with change
+	// and linking the two, all or nothing. It returns the replaced UAC as it was, datastore.ErrNoSuchEntity when the
+	// slot has no UAC, or ErrUacExists when the new UAC is taken. The audit entries are made by auditEntries from the
+	// replaced UAC as it was.
+	ReissueCaseUac(ctx context.Context, uacInfo *UacInfo, change UacStatusChange, auditEntries func(replaced *UacInfo) []*AuditEntry) (*UacInfo, error)
+	// SetUacValidity sets when a UAC can be used, returning datastore.ErrNoSuchEntity when it does not exist.
+	SetUacValidity(ctx context.Context, uac *datastore.Key, validity Validity, auditEntries []*AuditEntry) error
+	// RecordUacAccess records when a UAC was first looked up, keeping the time of any earlier lookup.
+	RecordUacAccess(ctx context.Context, uac *datastore.Key) error
+	// RekeyUac moves a UAC to a new key with its sealed UAC, keeping its instrument, case, slot and whether it is
+	// disabled. The UACs it was reissued from and to are renamed by linkName in the same commit, so no link is left
+	// naming a UAC by the UAC itself.
+	RekeyUac(ctx context.Context, uacInfo *UacInfo, uac *datastore.Key, sealedUac string, linkName func(string) string, auditEntries []*AuditEntry) error
+	// ListInstruments returns the distinct instruments that have UACs of a kind.
+	ListInstruments(ctx context.Context, uacKind string) ([]string, error)
+	// ArchiveInstrument moves an instrument's UACs of the archive's kind to its archive, saving the archive over
+	// any earlier one, and deletes the UAC kind recorded for it. auditEntry makes the audit entry of each UAC
+	// archived, which is added in the commit that archives it.
+	ArchiveInstrument(ctx context.Context, archive *InstrumentArchive, auditEntry func(*UacInfo) *AuditEntry) error
+	GetInstrumentArchive(ctx context.Context, instrumentName string) (*InstrumentArchive, error)
+	ListInstrumentArchives(context.Context) ([]*InstrumentArchive, error)
+	// ListArchivedUacs returns the UACs in an instrument's archive with the keys they were archived under.
+	ListArchivedUacs(ctx context.Context, instrumentName string) ([]*UacInfo, error)
+	// RestoreUac moves a UAC archived under archivedUac back to uacInfo, returning ErrCaseHasUac when the case slot
+	// has a UAC again and an error for which alreadyExistsError is true when the UAC exists.
+	RestoreUac(ctx context.Context, archivedUac *datastore.Key, uacInfo *UacInfo, auditEntries []*AuditEntry) error
+	// DeleteInstrumentArchive permanently deletes an instrument's archived UACs, then its archive. auditEntry, when
+	// not nil, makes the audit entry of each archived UAC, which is added in the commit that deletes it.
+	DeleteInstrumentArchive(ctx context.Context, instrumentName string, auditEntry func(*UacInfo) *AuditEntry) error
+	GetInstrumentConfig(ctx context.Context, instrumentName string) (*InstrumentConfig, error)
+	// InsertInstrumentConfig records the UAC kind for an instrument, returning an error for which
+	// alreadyExistsError is true when the instrument has already recorded one.
+	InsertInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error
+	// SaveInstrumentConfig records the UAC kind for an instrument, keeping its validity. It returns
+	// ErrInstrumentHasUacs when a case of the instrument has a UAC of another kind, checking in the transaction that
+	// records the kind, and the inserts and claims of case UACs return ErrInstrumentUacKindChanged once it has
+	// recorded another kind, so no case UAC is left of a kind the instrument no longer has.
+	SaveInstrumentConfig(ctx context.Context, instrumentName, uacKind string, auditEntries []*AuditEntry) error
+	// SaveInstrumentValidity sets when an instrument's UACs can be used, returning datastore.ErrNoSuchEntity when the
+	// instrument has not recorded a UAC kind.
+	SaveInstrumentValidity(ctx context.Context, instrumentName string, validity Validity, auditEntries []*AuditEntry) error
+	// ListAuditEntries returns up to query.Limit entries matching a query in ID order.
+	ListAuditEntries(ctx context.Context, query AuditQuery) ([]*AuditEntry, error)
+	// InsertGenerationJob saves a new job with its cases, which are kept in order so the job can be resumed.
+	InsertGenerationJob(ctx context.Context, job *GenerationJob, cases []JobCase) error
+	// GetGenerationJob returns a job with its ID, or datastore.ErrNoSuchEntity when it does not exist.
+	GetGenerationJob(ctx context.Context, jobID string) (*GenerationJob, error)
+	// ListRunningGenerationJobs returns the jobs that are running, or were when the instance running them stopped.
+	ListRunningGenerationJobs(context.Context) ([]*GenerationJob, error)
+	// ListGenerationJobCases returns the cases of a job in the order they were saved.
+	ListGenerationJobCases(ctx context.Context, jobID string) ([]JobCase, error)
+	// UpdateGenerationJob calls update with a job as it is saved and saves the job it changes, in a transaction so
+	// no other change to the job is lost. Nothing is saved when update returns an error, which is returned.
+	UpdateGenerationJob(ctx context.Context, jobID string, update func(*GenerationJob) error) (*GenerationJob, error)
+	Close() error
+}
@@ -1,13 +1,34 @@
 package uacgenerator_test
 
 import (
+	"context"
+	"database/sql"
 	"testing"
 
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	_ "modernc.org/sqlite"
 )
 
 func TestUacgenerator(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Uacgenerator Suite")
 }
+
+// newSQLStore returns a store over an empty in-memory SQLite database.
+func newSQLStore() *uacgenerator.SQLUacStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	Expect(err).To(BeNil())
+	// Every connection to :memory: opens a new database
+	db.SetMaxOpenConns(1)
+	store, err := uacgenerator.NewSQLUacStore(db, uacgenerator.SQLITE)
+	Expect(err).To(BeNil())
+	Expect(store.CreateSchema(context.Background())).To(Succeed())
+	return store
+}
+
+// newSQLUacGenerator returns a generator of "uac" UACs over an empty in-memory SQLite database.
+func newSQLUacGenerator() *uacgenerator.UacGenerator {
+	return uacgenerator.NewUacGeneratorWithStore(newSQLStore(), "uac")
+}
@@ -0,0 +1,197 @@
+package uacgenerator
+
+import (
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// Statuses GetUacInfo reports a UAC in
+const (
+	UACACTIVE      = "active"
+	UACDISABLED    = "disabled"
+	UACEXPIRED     = "expired"
+	UACNOTYETVALID = "not_yet_valid"
+)
+
+// Validity is when UACs can be used, from ValidFrom up to, but not including, ValidUntil. Either end is left open
+// when it is zero.
+type Validity struct {
+	ValidFrom  time.Time `json:"valid_from,omitzero"`
+	ValidUntil time.Time `json:"valid_until,omitzero"`
+}
+
+func (validity Validity) Validate() error {
+	if !validity.ValidFrom.IsZero() && !validity.ValidUntil.IsZero() && !validity.ValidUntil.After(validity.ValidFrom) {
+		return ErrInvalidValidity
+	}
+	return nil
+}
+
+// Override returns the validity with the ends that are set in override replacing its own.
+func (validity Validity) Override(override Validity) Validity {
+	if !override.ValidFrom.IsZero() {
+		validity.ValidFrom = override.ValidFrom
+	}
+	if !override.ValidUntil.IsZero() {
+		validity.ValidUntil = override.ValidUntil
+	}
+	return validity
+}
+
+func (validity Validity) equal(other Validity) bool {
+	return validity.ValidFrom.Equal(other.ValidFrom) && validity.ValidUntil.Equal(other.ValidUntil)
+}
+
+// Status is UACEXPIRED or UACNOTYETVALID when t is outside the validity, and UACACTIVE otherwise.
+func (validity Validity) Status(t time.Time) string {
+	if !validity.ValidUntil.IsZero() && !t.Before(validity.ValidUntil) {
+		return UACEXPIRED
+	}
+	if !validity.ValidFrom.IsZero() && t.Before(validity.ValidFrom) {
+		return UACNOTYETVALID
+	}
+	return UACACTIVE
+}
+
+// utc truncates the validity as the stores keep it, so it reads back as it was saved.
+func (validity Validity) utc() Validity {
+	return Validity{ValidFrom: validityTime(validity.ValidFrom), ValidUntil: validityTime(validity.ValidUntil)}
+}
+
+func validityTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func (uacInfo *UacInfo) validity() Validity {
+	return Validity{ValidFrom: uacInfo.ValidFrom, ValidUntil: uacInfo.ValidUntil}
+}
+
+func (instrumentConfig *InstrumentConfig) validity() Validity {
+	return Validity{ValidFrom: instrumentConfig.ValidFrom, ValidUntil: instrumentConfig.ValidUntil}
+}
+
+// GetInstrumentValidity returns when an instrument's UACs can be used, which is always when none has been set.
+func (uacGenerator *UacGenerator) GetInstrumentValidity(instrumentName string) (Validity, error) {
+	instrumentConfig, err := uacGenerator.Store.GetInstrumentConfig(uacGenerator.Context, instrumentName)
+	if err == datastore.ErrNoSuchEntity {
+		return Validity{}, nil
+	}
+	if err != nil {
+		return Validity{}, err
+	}
+	return instrumentConfig.validity(), nil
+}
+
+// SetInstrumentValidity sets when an instrument's UACs can be used, recording the default UAC kind against the
+// instrument if it does not have one yet. UACs with validity of their own keep the ends they set.
+func (uacGenerator *UacGenerator) SetInstrumentValidity(instrumentName string, validity Validity) error {
+	err := validity.Validate()
+	if err != nil {
+		return err
+	}
+	uacKind, err := uacGenerator.recordInstrumentUacKind(instrumentName)
+	if err != nil {
+		return err
+	}
+	before, err := uacGenerator.GetInstrumentValidity(instrumentName)
+	if err != nil {
+		return err
+	}
+	validity = validity.utc()
+	return uacGenerator.Store.SaveInstrumentValidity(uacGenerator.Context, instrumentName, validity,
+		uacGenerator.auditEntries(validityAuditEntry(instrumentName, uacKind, before, validity)))
+}
+
+// SetUacValidity sets when a UAC can be used, overriding the ends of its instrument's validity that are set.
+// A zero validity leaves the UAC to its instrument's. It returns ErrInvalidValidity when the UAC could never be used
+// with the ends it keeps from its instrument.
+func (uacGenerator *UacGenerator) SetUacValidity(uac string, validity Validity) error {
+	err := validity.Validate()
+	if err != nil {
+		return err
+	}
+	uacInfo, err := uacGenerator.findUacInfo(uac)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(uacInfo.InstrumentName, UNKNOWNINSTRUMENT) {
+		instrumentValidity, err := uacGenerator.GetInstrumentValidity(uacInfo.InstrumentName)
+		if err != nil {
+			return err
+		}
+		err = instrumentValidity.Override(validity).Validate()
+		if err != nil {
+			return err
+		}
+	}
+	validity = validity.utc()
+	after := *uacInfo
+	after.ValidFrom = validity.ValidFrom
+	after.ValidUntil = validity.ValidUntil
+	return uacGenerator.Store.SetUacValidity(uacGenerator.Context, uacInfo.UAC, validity,
+		uacGenerator.auditEntries(uacAuditEntry(AUDITSETVALIDITY, uacInfo.UAC, uacInfo, &after)))
+}
+
+// GetUacsExpiring returns the enabled current UACs of an instrument's cases that stop being valid within a period
+// from now, keyed by CaseSlotKey. UACs that have already expired are not included.
+func (uacGenerator *UacGenerator) GetUacsExpiring(instrumentName string, within time.Duration) (Uacs, error) {
+	uacKind, err := uacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	instrumentValidity, err := uacGenerator.GetInstrumentValidity(instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	uacInfos, err := uacGenerator.Store.ListUacs(uacGenerator.Context, uacKind, instrumentName)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		now      = time.Now()
+		expiring = TimeRange{From: now, To: now.Add(within)}
+		uacs     = make(Uacs)
+	)
+	for _, uacInfo := range uacInfos {
+		if uacInfo.Disabled || uacInfo.ReissuedTo != "" {
+			continue
+		}
+		uacGenerator.showValidity(uacInfo, instrumentValidity, now)
+		if uacInfo.ValidUntil.IsZero() || !expiring.Contains(uacInfo.ValidUntil) {
+			continue
+		}
+		uacGenerator.showUac(uacInfo)
+		uacs[uacInfo.CaseSlot()] = uacInfo
+	}
+	return uacs, nil
+}
+
+// showValidity sets the validity a UAC is shown with, its own or its instrument's, and its status at now.
+func (uacGenerator *UacGenerator) showValidity(uacInfo *UacInfo, instrumentValidity Validity, now time.Time) {
+	validity := instrumentValidity.Override(uacInfo.validity())
+	uacInfo.ValidFrom = validity.ValidFrom
+	uacInfo.ValidUntil = validity.ValidUntil
+	uacInfo.Status = UACDISABLED
+	if !uacInfo.Disabled {
+		uacInfo.Status = validity.Status(now)
+	}
+}
+
+// uacValidity shows a looked up UAC with the validity of its instrument when it has none of its own.
+func (uacGenerator *UacGenerator) uacValidity(uacInfo *UacInfo) error {
+	instrumentValidity := Validity{}
+	if !strings.EqualFold(uacInfo.InstrumentName, UNKNOWNINSTRUMENT) {
+		var err error
+		instrumentValidity, err = uacGenerator.GetInstrumentValidity(uacInfo.InstrumentName)
+		if err != nil {
+			return err
+		}
+	}
+	uacGenerator.showValidity(uacInfo, instrumentValidity, time.Now())
+	return nil
+}
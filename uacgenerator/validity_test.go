@@ -0,0 +1,156 @@
+package uacgenerator_test
+
+import (
+	"time"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UAC validity", func() {
+	validitySpecs := func(newUacGenerator func() *uacgenerator.UacGenerator) {
+		var (
+			uacGenerator *uacgenerator.UacGenerator
+			now          time.Time
+		)
+
+		BeforeEach(func() {
+			uacGenerator = newUacGenerator()
+			now = time.Now().UTC().Truncate(time.Second)
+		})
+
+		generateUacs := func(instrumentName string, caseIDs ...string) uacgenerator.Uacs {
+			_, err := uacGenerator.Generate(instrumentName, caseIDs)
+			Expect(err).To(BeNil())
+			uacs, err := uacGenerator.GetAllUacsByCaseID(instrumentName)
+			Expect(err).To(BeNil())
+			return uacs
+		}
+
+		uacStatus := func(uac string) string {
+			uacInfo, err := uacGenerator.GetUacInfo(uac)
+			Expect(err).To(BeNil())
+			return uacInfo.Status
+		}
+
+		It("reports whether UACs are in their instrument's validity", func() {
+			uacs := generateUacs("lolcat", "1", "2")
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACACTIVE))
+
+			expired := uacgenerator.Validity{ValidFrom: now.Add(-48 * time.Hour), ValidUntil: now.Add(-time.Hour)}
+			Expect(uacGenerator.SetInstrumentValidity("LOLcat", expired)).To(Succeed())
+			uacInfo, err := uacGenerator.GetUacInfo(uacs["1"].FullUAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.Status).To(Equal(uacgenerator.UACEXPIRED))
+			Expect(uacInfo.ValidUntil).To(Equal(expired.ValidUntil))
+			Expect(uacInfo.Disabled).To(BeFalse())
+
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", uacgenerator.Validity{ValidFrom: now.Add(time.Hour)})).To(Succeed())
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACNOTYETVALID))
+
+			Expect(uacGenerator.DisableUac(uacs["2"].FullUAC)).To(Succeed())
+			Expect(uacStatus(uacs["2"].FullUAC)).To(Equal(uacgenerator.UACDISABLED))
+
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", uacgenerator.Validity{})).To(Succeed())
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACACTIVE))
+		})
+
+		It("lets a UAC override the ends of its instrument's validity", func() {
+			uacs := generateUacs("lolcat", "1", "2")
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", uacgenerator.Validity{
+				ValidFrom:  now.Add(-48 * time.Hour),
+				ValidUntil: now.Add(-time.Hour),
+			})).To(Succeed())
+
+			extended := uacgenerator.Validity{ValidUntil: now.Add(time.Hour)}
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, extended)).To(Succeed())
+			uacInfo, err := uacGenerator.GetUacInfo(uacs["1"].FullUAC)
+			Expect(err).To(BeNil())
+			Expect(uacInfo.Status).To(Equal(uacgenerator.UACACTIVE))
+			Expect(uacInfo.ValidFrom).To(Equal(now.Add(-48 * time.Hour)))
+			Expect(uacInfo.ValidUntil).To(Equal(extended.ValidUntil))
+			Expect(uacStatus(uacs["2"].FullUAC)).To(Equal(uacgenerator.UACEXPIRED))
+
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, uacgenerator.Validity{})).To(Succeed())
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACEXPIRED))
+
+			page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{UAC: uacs["1"].FullUAC})
+			Expect(err).To(BeNil())
+			Expect(page.Entries).To(HaveLen(3))
+			Expect(page.Entries[1].Action).To(Equal(uacgenerator.AUDITSETVALIDITY))
+			Expect(page.Entries[1].Before.ValidUntil.IsZero()).To(BeTrue())
+			Expect(page.Entries[1].After.ValidUntil).To(Equal(extended.ValidUntil))
+		})
+
+		It("lists the UACs expiring within a period", func() {
+			uacs := generateUacs("lolcat", "1", "2", "3", "4", "5")
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", uacgenerator.Validity{ValidUntil: now.Add(10 * 24 * time.Hour)})).To(Succeed())
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, uacgenerator.Validity{ValidUntil: now.Add(time.Hour)})).To(Succeed())
+			Expect(uacGenerator.SetUacValidity(uacs["2"].FullUAC, uacgenerator.Validity{ValidUntil: now.Add(-time.Hour)})).To(Succeed())
+			Expect(uacGenerator.SetUacValidity(uacs["3"].FullUAC, uacgenerator.Validity{ValidUntil: now.Add(time.Hour)})).To(Succeed())
+			Expect(uacGenerator.DisableUac(uacs["3"].FullUAC)).To(Succeed())
+			Expect(uacGenerator.SetUacValidity(uacs["4"].FullUAC, uacgenerator.Validity{ValidUntil: now.Add(100 * 24 * time.Hour)})).To(Succeed())
+
+			expiring, err := uacGenerator.GetUacsExpiring("LOLcat", 24*time.Hour)
+			Expect(err).To(BeNil())
+			Expect(expiring).To(HaveLen(1))
+			Expect(expiring["1"].FullUAC).To(Equal(uacs["1"].FullUAC))
+			Expect(expiring["1"].Status).To(Equal(uacgenerator.UACACTIVE))
+
+			expiring, err = uacGenerator.GetUacsExpiring("lolcat", 30*24*time.Hour)
+			Expect(err).To(BeNil())
+			Expect(expiring).To(HaveLen(2))
+			Expect(expiring).To(HaveKey("1"))
+			Expect(expiring["5"].ValidUntil).To(Equal(now.Add(10 * 24 * time.Hour)))
+		})
+
+		It("keeps an instrument's validity and UAC kind apart", func() {
+			validity := uacgenerator.Validity{ValidUntil: now.Add(time.Hour)}
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", validity)).To(Succeed())
+			uacKind, err := uacGenerator.GetInstrumentUacKind("lolcat")
+			Expect(err).To(BeNil())
+			Expect(uacKind).To(Equal("uac"))
+
+			Expect(uacGenerator.SetInstrumentUacKind("lolcat", "uac")).To(Succeed())
+			Expect(uacGenerator.GetInstrumentValidity("lolcat")).To(Equal(validity))
+
+			page, err := uacGenerator.GetAuditEntries(uacgenerator.AuditQuery{InstrumentName: "lolcat"})
+			Expect(err).To(BeNil())
+			Expect(page.Entries[1].Action).To(Equal(uacgenerator.AUDITSETVALIDITY))
+			Expect(page.Entries[1].After.ValidUntil).To(Equal(validity.ValidUntil))
+		})
+
+		It("needs a validity to end after it starts", func() {
+			uacs := generateUacs("lolcat", "1")
+			backwards := uacgenerator.Validity{ValidFrom: now, ValidUntil: now.Add(-time.Hour)}
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", backwards)).To(Equal(uacgenerator.ErrInvalidValidity))
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, backwards)).To(Equal(uacgenerator.ErrInvalidValidity))
+			Expect(uacGenerator.GetInstrumentValidity("lolcat")).To(Equal(uacgenerator.Validity{}))
+		})
+
+		It("needs a UAC's validity to end after the start it keeps from its instrument", func() {
+			uacs := generateUacs("lolcat", "1")
+			Expect(uacGenerator.SetInstrumentValidity("lolcat", uacgenerator.Validity{ValidFrom: now.Add(time.Hour)})).To(Succeed())
+			endsFirst := uacgenerator.Validity{ValidUntil: now}
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, endsFirst)).To(Equal(uacgenerator.ErrInvalidValidity))
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACNOTYETVALID))
+
+			Expect(uacGenerator.SetUacValidity(uacs["1"].FullUAC, uacgenerator.Validity{
+				ValidFrom:  now.Add(-time.Hour),
+				ValidUntil: endsFirst.ValidUntil,
+			})).To(Succeed())
+			Expect(uacStatus(uacs["1"].FullUAC)).To(Equal(uacgenerator.UACEXPIRED))
+		})
+	}
+
+	Context("with Datastore", func() {
+		validitySpecs(func() *uacgenerator.UacGenerator {
+			return uacgenerator.NewUacGenerator(uacgenerator.NewMemoryDatastore(), "uac")
+		})
+	})
+
+	Context("with SQL", func() {
+		validitySpecs(newSQLUacGenerator)
+	})
+})
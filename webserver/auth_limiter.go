@@ -0,0 +1,174 @@
+package webserver
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited = errors.New("Too many attempts, try again later")
+	ErrLockedOut   = errors.New("Too many failed attempts, try again later")
+)
+
+// AuthLimiter limits how often UACs can be authenticated, by each client and by everyone, and locks a client out
+// after MaxFailures failed attempts in a row. Each lockout a client earns lasts twice as long as the last, up to
+// MaxLockout, until it next authenticates. A limit of zero is not applied, and a nil AuthLimiter limits nothing.
+type AuthLimiter struct {
+	ClientLimit  int
+	ClientWindow time.Duration
+	GlobalLimit  int
+	GlobalWindow time.Duration
+	MaxFailures  int
+	Lockout      time.Duration
+	MaxLockout   time.Duration
+	// Now is time.Now when nil
+	Now func() time.Time
+
+	mutex   sync.Mutex
+	global  authWindow
+	clients map[string]*authClient
+	swept   time.Time
+}
+
+// authWindow counts the attempts made in a fixed window.
+type authWindow struct {
+	start    time.Time
+	attempts int
+}
+
+type authClient struct {
+	window      authWindow
+	failures    int
+	lockouts    int
+	lockedUntil time.Time
+	lastSeen    time.Time
+}
+
+// NewAuthLimiter returns an AuthLimiter with the limits the service uses by default.
+func NewAuthLimiter() *AuthLimiter {
+	return &AuthLimiter{
+		ClientLimit:  10,
+		ClientWindow: time.Minute,
+		GlobalLimit:  1000,
+		GlobalWindow: time.Minute,
+		MaxFailures:  5,
+		Lockout:      time.Minute,
+		MaxLockout:   24 * time.Hour,
+	}
+}
+
+// Allow counts an attempt by a client, returning ErrLockedOut or ErrRateLimited, with how long to wait before
+// trying again, when it must not be made.
+func (limiter *AuthLimiter) Allow(client string) (time.Duration, error) {
+	if limiter == nil {
+		return 0, nil
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	state := limiter.client(client, now)
+	if now.Before(state.lockedUntil) {
+		return state.lockedUntil.Sub(now), ErrLockedOut
+	}
+	if wait := state.window.wait(now, limiter.ClientLimit, limiter.ClientWindow); wait > 0 {
+		return wait, ErrRateLimited
+	}
+	if wait := limiter.global.wait(now, limiter.GlobalLimit, limiter.GlobalWindow); wait > 0 {
+		return wait, ErrRateLimited
+	}
+	state.window.attempts++
+	limiter.global.attempts++
+	return 0, nil
+}
+
+// Failure records a failed attempt by a client, returning true when it locks the client out.
+func (limiter *AuthLimiter) Failure(client string) bool {
+	if limiter == nil || limiter.MaxFailures <= 0 {
+		return false
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	state := limiter.client(client, now)
+	state.failures++
+	if state.failures < limiter.MaxFailures {
+		return false
+	}
+	state.failures = 0
+	state.lockouts++
+	lockout := limiter.Lockout
+	for i := 1; i < state.lockouts; i++ {
+		if limiter.MaxLockout > 0 && lockout >= limiter.MaxLockout {
+			break
+		}
+		lockout *= 2
+	}
+	if limiter.MaxLockout > 0 {
+		lockout = min(lockout, limiter.MaxLockout)
+	}
+	state.lockedUntil = now.Add(lockout)
+	return true
+}
+
+// Success records a client authenticating, clearing its failures and lockouts.
+func (limiter *AuthLimiter) Success(client string) {
+	if limiter == nil {
+		return
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	state := limiter.client(client, limiter.now())
+	state.failures = 0
+	state.lockouts = 0
+	state.lockedUntil = time.Time{}
+}
+
+func (limiter *AuthLimiter) now() time.Time {
+	if limiter.Now == nil {
+		return time.Now()
+	}
+	return limiter.Now()
+}
+
+// client returns the state kept for a client, first forgetting the clients that have been neither seen nor locked
+// out for longer than a lockout can last.
+func (limiter *AuthLimiter) client(client string, now time.Time) *authClient {
+	if limiter.clients == nil {
+		limiter.clients = make(map[string]*authClient)
+	}
+	idle := max(limiter.ClientWindow, limiter.MaxLockout)
+	if idle > 0 && now.Sub(limiter.swept) >= idle {
+		for name, state := range limiter.clients {
+			if now.Sub(state.lastSeen) >= idle && now.Sub(state.lockedUntil) >= idle {
+				delete(limiter.clients, name)
+			}
+		}
+		limiter.swept = now
+	}
+	state, ok := limiter.clients[client]
+	if !ok {
+		state = &authClient{}
+		limiter.clients[client] = state
+	}
+	state.lastSeen = now
+	return state
+}
+
+// wait starts a new window when the last has ended, returning how long until it ends when it has no attempts left.
+func (window *authWindow) wait(now time.Time, limit int, length time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	if now.Sub(window.start) >= length {
+		window.start = now
+		window.attempts = 0
+	}
+	if window.attempts < limit {
+		return 0
+	}
+	return window.start.Add(length).Sub(now)
+}
@@ -0,0 +1,78 @@
+package webserver_test
+
+import (
+	"time"
+
+	"github.com/ONSDigital/blaise-uac-service/webserver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuthLimiter", func() {
+	var (
+		now         time.Time
+		authLimiter *webserver.AuthLimiter
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		authLimiter = &webserver.AuthLimiter{
+			ClientLimit:  2,
+			ClientWindow: time.Minute,
+			GlobalLimit:  3,
+			GlobalWindow: time.Minute,
+			MaxFailures:  2,
+			Lockout:      time.Minute,
+			MaxLockout:   3 * time.Minute,
+			Now:          func() time.Time { return now },
+		}
+	})
+
+	It("limits the attempts of each client and of everyone", func() {
+		Expect(authLimiter.Allow("1")).To(Equal(time.Duration(0)))
+		now = now.Add(15 * time.Second)
+		Expect(authLimiter.Allow("1")).To(Equal(time.Duration(0)))
+		retryAfter, err := authLimiter.Allow("1")
+		Expect(err).To(Equal(webserver.ErrRateLimited))
+		Expect(retryAfter).To(Equal(45 * time.Second))
+
+		Expect(authLimiter.Allow("2")).To(Equal(time.Duration(0)))
+		_, err = authLimiter.Allow("3")
+		Expect(err).To(Equal(webserver.ErrRateLimited))
+
+		now = now.Add(45 * time.Second)
+		Expect(authLimiter.Allow("1")).To(Equal(time.Duration(0)))
+		Expect(authLimiter.Allow("3")).To(Equal(time.Duration(0)))
+	})
+
+	It("locks clients out for longer each time until they authenticate", func() {
+		Expect(authLimiter.Failure("1")).To(BeFalse())
+		Expect(authLimiter.Failure("1")).To(BeTrue())
+		retryAfter, err := authLimiter.Allow("1")
+		Expect(err).To(Equal(webserver.ErrLockedOut))
+		Expect(retryAfter).To(Equal(time.Minute))
+		Expect(authLimiter.Allow("2")).To(Equal(time.Duration(0)))
+
+		for _, lockout := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+			now = now.Add(retryAfter)
+			authLimiter.Failure("1")
+			Expect(authLimiter.Failure("1")).To(BeTrue())
+			retryAfter, err = authLimiter.Allow("1")
+			Expect(err).To(Equal(webserver.ErrLockedOut))
+			Expect(retryAfter).To(Equal(lockout))
+		}
+
+		authLimiter.Success("1")
+		Expect(authLimiter.Allow("1")).To(Equal(time.Duration(0)))
+		authLimiter.Failure("1")
+		authLimiter.Failure("1")
+		retryAfter, _ = authLimiter.Allow("1")
+		Expect(retryAfter).To(Equal(time.Minute))
+	})
+
+	It("limits nothing when nil", func() {
+		var nilLimiter *webserver.AuthLimiter
+		Expect(nilLimiter.Allow("1")).To(Equal(time.Duration(0)))
+		Expect(nilLimiter.Failure("1")).To(BeFalse())
+	})
+})
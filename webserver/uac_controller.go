@@ -2,10 +2,16 @@ package webserver
 
 import (
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/datastore"
 	"github.com/ONSDigital/blaise-uac-service/blaiserestapi"
@@ -13,6 +19,26 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// IDENTITYHEADER carries the identity Identity-Aware Proxy has authenticated a request as.
+const IDENTITYHEADER = "X-Goog-Authenticated-User-Email"
+
+// Reasons an authentication can fail with
+const (
+	AUTHINVALIDREQUEST = "invalid_request"
+	AUTHNOTFOUND       = "not_found"
+	AUTHPROBABLETYPO   = "probable_typo"
+	AUTHDISABLED       = "disabled"
+	AUTHREISSUED       = "reissued"
+	AUTHEXPIRED        = "expired"
+	AUTHNOTYETVALID    = "not_yet_valid"
+	AUTHRATELIMITED    = "rate_limited"
+	AUTHLOCKEDOUT      = "locked_out"
+)
+
+// authenticationMetrics counts the outcomes of authentications by reason, as "authenticated" when they succeed, and
+// the lockouts they cause, published under uac_authentications by DebugHandler.
+var authenticationMetrics = expvar.NewMap("uac_authentications")
+
 type ResponseError struct {
 	Error string `json:"error"`
 }
@@ -21,15 +47,52 @@ type UACRequest struct {
 	UAC string `json:"uac"`
 }
 
+// UACGenerateRequest generates UACs for cases. Each case is given UacsPerCase UACs, or one when it is not set, unless
+// CaseUacs gives how many the case needs.
 type UACGenerateRequest struct {
-	InstrumentName string   `json:"instrument_name"`
-	CaseIDs        []string `json:"case_ids"`
-	Disabled       bool     `json:"disabled" datastore:"disabled"`
+	InstrumentName string         `json:"instrument_name"`
+	CaseIDs        []string       `json:"case_ids"`
+	Disabled       bool           `json:"disabled" datastore:"disabled"`
+	ClaimPool      bool           `json:"claim_pool"`
+	UacsPerCase    int            `json:"uacs_per_case"`
+	CaseUacs       map[string]int `json:"case_uacs"`
+}
+
+type UACGenerateResponse struct {
+	Result *uacgenerator.GenerateResult `json:"result"`
+	Uacs   uacgenerator.Uacs            `json:"uacs"`
+}
+
+type UACKindRequest struct {
+	UacKind string `json:"uac_kind"`
+}
+
+// UACReissueRequest reissues the UAC of a slot of a case, which is the case's only UAC when no slot is given.
+type UACReissueRequest struct {
+	Note string `json:"note"`
+	Slot int    `json:"slot"`
+}
+
+type UACAuthenticateResponse struct {
+	InstrumentName string `json:"instrument_name"`
+	CaseID         string `json:"case_id"`
+	Slot           int    `json:"slot,omitempty"`
+}
+
+type UACAuthenticateError struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+type UACValidityRequest struct {
+	UAC string `json:"uac"`
+	uacgenerator.Validity
 }
 
 type UacController struct {
 	BlaiseRestApi blaiserestapi.BlaiseRestApiInterface
 	UacGenerator  uacgenerator.UacGeneratorInterface
+	AuthLimiter   *AuthLimiter
 }
 
 func (uacController *UacController) AddRoutes(httpRouter *gin.Engine) {
@@ -39,104 +102,434 @@ func (uacController *UacController) AddRoutes(httpRouter *gin.Engine) {
 		uacsGroup.GET("/instrument/:instrumentName", uacController.UACGetAllEndpoint)
 		uacsGroup.GET("/instrument/:instrumentName/bycaseid", uacController.UACGetAllByCaseIDEndpoint)
 		uacsGroup.GET("/instrument/:instrumentName/count", uacController.UACCountEndpoint)
+		uacsGroup.GET("/instrument/:instrumentName/kind", uacController.UACKindEndpoint)
+		uacsGroup.GET("/instrument/:instrumentName/validity", uacController.UACValidityEndpoint)
+		uacsGroup.GET("/instrument/:instrumentName/expiring", uacController.UACExpiringEndpoint)
+		uacsGroup.GET("/instrument/:instrumentName/denied", uacController.UACDeniedEndpoint)
+		uacsGroup.GET("/instrument/:instrumentName/export", uacController.UACExportEndpoint)
+		uacsGroup.POST("/instrument/:instrumentName/export", uacController.UACEncryptedExportEndpoint)
 		uacsGroup.POST("/generate", uacController.UACGenerateEndpoint)
 		uacsGroup.POST("/uac", uacController.GetUacInfoEndpoint)
+		uacsGroup.POST("/uac/validity", uacController.UACSetValidityEndpoint)
+		uacsGroup.POST("/authenticate", uacController.UACAuthenticateEndpoint)
 		uacsGroup.DELETE("/admin/instrument/:instrumentName", uacController.AdminDeleteEndpoint)
+		uacsGroup.PUT("/admin/instrument/:instrumentName/kind", uacController.AdminSetUACKindEndpoint)
+		uacsGroup.PUT("/admin/instrument/:instrumentName/validity", uacController.AdminSetValidityEndpoint)
+		uacsGroup.POST("/admin/instrument/:instrumentName/restore", uacController.AdminRestoreEndpoint)
+		uacsGroup.GET("/admin/archives", uacController.AdminArchivesEndpoint)
+		uacsGroup.POST("/admin/archives/purge", uacController.AdminPurgeEndpoint)
 		uacsGroup.GET("/instruments", uacController.ListInstrumentsEndpoint)
 		uacsGroup.POST("/import", uacController.ImportEndpoint)
+		uacsGroup.GET("/audit", uacController.UACAuditEndpoint)
+
+		uacsGroup.POST("/instrument/:instrumentName/jobs", uacController.UACInstrumentGenerateJobEndpoint)
+		uacsGroup.POST("/jobs", uacController.UACGenerateJobEndpoint)
+		uacsGroup.GET("/jobs/:jobID", uacController.UACJobEndpoint)
+		uacsGroup.POST("/jobs/:jobID/cancel", uacController.UACCancelJobEndpoint)
 
 		uacsGroup.GET("/uac/disable/:uac", uacController.UACDisableEndpoint)
 		uacsGroup.GET("/uac/enable/:uac", uacController.UACEnableEndpoint)
 		uacsGroup.GET("/uac/:instrumentName/disabled", uacController.UACGetAllDisabledEndpoint)
+		uacsGroup.POST("/instrument/:instrumentName/disable", uacController.UACBulkDisableEndpoint)
+		uacsGroup.POST("/instrument/:instrumentName/enable", uacController.UACBulkEnableEndpoint)
+		uacsGroup.POST("/instrument/:instrumentName/case/:caseID/reissue", uacController.UACReissueEndpoint)
 
 	}
 }
 
 func (uacController *UacController) UACInstrumentGenerateEndpoint(context *gin.Context) {
 	instrumentName := context.Param("instrumentName")
-	instrumentModes, err := uacController.BlaiseRestApi.GetInstrumentModes(instrumentName)
-	if err != nil {
-		uacController.blaiseRestApiError(context, err)
+	options, ok := uacController.getGenerateOptions(context)
+	if !ok {
 		return
 	}
-	if !instrumentModes.HasCawi() {
-		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: fmt.Sprintf("Instrument '%s' is not installed in CAWI mode", instrumentName)})
+	caseIDs, ok := uacController.getCawiCaseIDs(context, instrumentName)
+	if !ok {
 		return
 	}
-	caseIDs, err := uacController.BlaiseRestApi.GetCaseIds(instrumentName)
+	uacController.generate(context, instrumentName, caseIDs, options)
+}
+
+func (uacController *UacController) UACGenerateEndpoint(context *gin.Context) {
+	uacGenerateRequest, ok := uacController.getUacGenerateRequest(context)
+	if !ok {
+		return
+	}
+	uacController.generate(context, uacGenerateRequest.InstrumentName, uacGenerateRequest.CaseIDs, uacGenerateRequest.options())
+}
+
+func (uacController *UacController) UACInstrumentGenerateJobEndpoint(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+	options, ok := uacController.getGenerateOptions(context)
+	if !ok {
+		return
+	}
+	caseIDs, ok := uacController.getCawiCaseIDs(context, instrumentName)
+	if !ok {
+		return
+	}
+	uacController.startGenerationJob(context, instrumentName, caseIDs, options)
+}
+
+func (uacController *UacController) UACGenerateJobEndpoint(context *gin.Context) {
+	uacGenerateRequest, ok := uacController.getUacGenerateRequest(context)
+	if !ok {
+		return
+	}
+	uacController.startGenerationJob(context, uacGenerateRequest.InstrumentName, uacGenerateRequest.CaseIDs, uacGenerateRequest.options())
+}
+
+func (uacController *UacController) UACJobEndpoint(context *gin.Context) {
+	job, err := uacController.UacGenerator.GetGenerationJob(context.Param("jobID"))
 	if err != nil {
-		uacController.blaiseRestApiError(context, err)
+		if err == datastore.ErrNoSuchEntity {
+			context.AbortWithStatusJSON(http.StatusNotFound, ResponseError{Error: "Job not found"})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	err = uacController.UacGenerator.Generate(instrumentName, caseIDs)
+	context.JSON(http.StatusOK, job)
+}
+
+func (uacController *UacController) UACCancelJobEndpoint(context *gin.Context) {
+	job, err := uacController.UacGenerator.CancelGenerationJob(context.Param("jobID"))
 	if err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			context.AbortWithStatusJSON(http.StatusNotFound, ResponseError{Error: "Job not found"})
+			return
+		}
+		if err == uacgenerator.ErrJobFinished {
+			context.AbortWithStatusJSON(http.StatusConflict, ResponseError{Error: err.Error()})
+			return
+		}
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	uacs, err := uacController.UacGenerator.GetAllUacs(instrumentName)
+	context.JSON(http.StatusOK, job)
+}
+
+func (uacController *UacController) startGenerationJob(context *gin.Context, instrumentName string, caseIDs []string, options uacgenerator.GenerateOptions) {
+	job, err := uacController.generator(context).StartGenerationJob(instrumentName, caseIDs, options)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	uacs.BuildUacChunks()
-	context.JSON(http.StatusOK, uacs)
+	context.JSON(http.StatusAccepted, job)
 }
 
-func (uacController *UacController) UACGenerateEndpoint(context *gin.Context) {
+// getCawiCaseIDs gets the case IDs for an instrument installed in CAWI mode, responding with an error otherwise.
+func (uacController *UacController) getCawiCaseIDs(context *gin.Context, instrumentName string) ([]string, bool) {
+	instrumentModes, err := uacController.BlaiseRestApi.GetInstrumentModes(instrumentName)
+	if err != nil {
+		uacController.blaiseRestApiError(context, err)
+		return nil, false
+	}
+	if !instrumentModes.HasCawi() {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: fmt.Sprintf("Instrument '%s' is not installed in CAWI mode", instrumentName)})
+		return nil, false
+	}
+	caseIDs, err := uacController.BlaiseRestApi.GetCaseIds(instrumentName)
+	if err != nil {
+		uacController.blaiseRestApiError(context, err)
+		return nil, false
+	}
+	return caseIDs, true
+}
+
+func (uacController *UacController) getUacGenerateRequest(context *gin.Context) (UACGenerateRequest, bool) {
 	body, err := io.ReadAll(context.Request.Body)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
-		return
+		return UACGenerateRequest{}, false
 	}
 	defer context.Request.Body.Close()
 	var uacGenerateRequest UACGenerateRequest
 	err = json.Unmarshal(body, &uacGenerateRequest)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
-		return
+		return UACGenerateRequest{}, false
 	}
 	if uacGenerateRequest.InstrumentName == "" {
 		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Must provide instrument name"})
-		return
+		return UACGenerateRequest{}, false
+	}
+	if err := uacGenerateRequest.options().Validate(); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return UACGenerateRequest{}, false
+	}
+	return uacGenerateRequest, true
+}
+
+func (uacGenerateRequest UACGenerateRequest) options() uacgenerator.GenerateOptions {
+	return uacgenerator.GenerateOptions{
+		ClaimFromPool: uacGenerateRequest.ClaimPool,
+		UacsPerCase:   uacGenerateRequest.UacsPerCase,
+		CaseUacs:      uacGenerateRequest.CaseUacs,
+	}
+}
+
+// getGenerateOptions reads the claim_pool and uacs_per_case query parameters of an instrument's generation,
+// responding with an error when uacs_per_case is not a valid number of UACs.
+func (uacController *UacController) getGenerateOptions(context *gin.Context) (uacgenerator.GenerateOptions, bool) {
+	options := uacgenerator.GenerateOptions{ClaimFromPool: context.Query("claim_pool") == "true"}
+	if context.Query("uacs_per_case") != "" {
+		uacsPerCase, err := strconv.Atoi(context.Query("uacs_per_case"))
+		if err != nil || uacsPerCase < 1 {
+			uacsPerCase = -1
+		}
+		options.UacsPerCase = uacsPerCase
+	}
+	if err := options.Validate(); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return uacgenerator.GenerateOptions{}, false
+	}
+	return options, true
+}
+
+// generate responds with all UACs for the instrument once UACs have been generated for the cases.
+// When some case slots fail, when claiming UACs from the pool, or when asked with ?result=true, the response also
+// reports what happened to each case slot. A 207 is used when some case slots failed.
+func (uacController *UacController) generate(context *gin.Context, instrumentName string, caseIDs []string, options uacgenerator.GenerateOptions) {
+	var (
+		generator = uacController.generator(context)
+		result    *uacgenerator.GenerateResult
+		err       error
+	)
+	if options.ClaimFromPool || options.UacsPerCase > 0 || len(options.CaseUacs) > 0 {
+		result, err = generator.GenerateWithOptions(instrumentName, caseIDs, options)
+	} else {
+		result, err = generator.Generate(instrumentName, caseIDs)
 	}
-	err = uacController.UacGenerator.Generate(uacGenerateRequest.InstrumentName, uacGenerateRequest.CaseIDs)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	uacs, err := uacController.UacGenerator.GetAllUacs(uacGenerateRequest.InstrumentName)
+	uacs, err := generator.GetAllUacs(instrumentName)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	uacs = uacs.Reveal(result.Uacs)
 	uacs.BuildUacChunks()
+	if result.HasFailures() {
+		context.JSON(http.StatusMultiStatus, UACGenerateResponse{Result: result, Uacs: uacs})
+		return
+	}
+	if options.ClaimFromPool || context.Query("result") == "true" {
+		context.JSON(http.StatusOK, UACGenerateResponse{Result: result, Uacs: uacs})
+		return
+	}
 	context.JSON(http.StatusOK, uacs)
 }
 
 func (uacController *UacController) UACGetAllEndpoint(context *gin.Context) {
 	instrumentName := context.Param("instrumentName")
+	filter, ok := uacController.getUacFilter(context)
+	if !ok {
+		return
+	}
+	if context.Query("stream") == "true" {
+		uacController.streamUacs(context, instrumentName, filter)
+		return
+	}
+	if context.Query("limit") != "" || context.Query("cursor") != "" {
+		uacController.getUacsPage(context, instrumentName, filter, uacController.UacGenerator.GetUacsPage)
+		return
+	}
 
 	uacs, err := uacController.UacGenerator.GetAllUacs(instrumentName)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	uacs = uacs.Filter(filter)
 	uacs.BuildUacChunks()
 	context.JSON(http.StatusOK, uacs)
 }
 
 func (uacController *UacController) UACGetAllByCaseIDEndpoint(context *gin.Context) {
 	instrumentName := context.Param("instrumentName")
+	filter, ok := uacController.getUacFilter(context)
+	if !ok {
+		return
+	}
+	if context.Query("stream") == "true" {
+		uacController.streamUacs(context, instrumentName, filter)
+		return
+	}
+	if context.Query("limit") != "" || context.Query("cursor") != "" {
+		uacController.getUacsPage(context, instrumentName, filter, uacController.UacGenerator.GetUacsPageByCaseID)
+		return
+	}
 
 	uacs, err := uacController.UacGenerator.GetAllUacsByCaseID(instrumentName)
 	if err != nil {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	uacs = uacs.Filter(filter)
 	uacs.BuildUacChunks()
 	context.JSON(http.StatusOK, uacs)
 }
 
+func (uacController *UacController) getUacsPage(context *gin.Context, instrumentName string, filter uacgenerator.UacFilter,
+	getPage func(string, uacgenerator.UacFilter, string, int) (*uacgenerator.UacPage, error)) {
+	limit := uacgenerator.MAXPAGESIZE
+	if context.Query("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(context.Query("limit"))
+		if err != nil || limit < 1 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Limit must be a positive number"})
+			return
+		}
+	}
+	page, err := getPage(instrumentName, filter, context.Query("cursor"), limit)
+	if err != nil {
+		if err == uacgenerator.ErrInvalidCursor {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// streamUacs writes a UAC per line as newline delimited JSON. Once the first UAC has been written the status
+// cannot change, so an error after that is written as a final line instead.
+func (uacController *UacController) streamUacs(context *gin.Context, instrumentName string, filter uacgenerator.UacFilter) {
+	encoder := json.NewEncoder(context.Writer)
+	err := uacController.UacGenerator.StreamUacs(instrumentName, filter, func(uacInfo *uacgenerator.UacInfo) error {
+		if !context.Writer.Written() {
+			context.Header("Content-Type", "application/x-ndjson")
+			context.Status(http.StatusOK)
+		}
+		return encoder.Encode(uacInfo)
+	})
+	if err != nil {
+		if !context.Writer.Written() {
+			_ = context.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		log.Println(err)
+		_ = encoder.Encode(ResponseError{Error: err.Error()})
+		return
+	}
+	if !context.Writer.Written() {
+		context.Header("Content-Type", "application/x-ndjson")
+		context.Status(http.StatusOK)
+		context.Writer.WriteHeaderNow()
+	}
+}
+
+// UACExportEndpoint writes an instrument's UACs as CSV for the print supplier. Once the first row has been
+// written the status cannot change, so an export that fails part way through ends without its trailer row.
+func (uacController *UacController) UACExportEndpoint(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+	options, ok := uacController.getExportOptions(context)
+	if !ok {
+		return
+	}
+	if err := options.Validate(); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	context.Header("Content-Type", "text/csv")
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, instrumentName))
+	_, err := uacController.UacGenerator.ExportUacs(instrumentName, options, context.Writer)
+	if err != nil {
+		if err == uacgenerator.ErrUacsKeyed {
+			context.Writer.Header().Del("Content-Disposition")
+			context.AbortWithStatusJSON(http.StatusForbidden, ResponseError{Error: err.Error()})
+			return
+		}
+		if !context.Writer.Written() {
+			context.Writer.Header().Del("Content-Disposition")
+			_ = context.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		log.Println(err)
+	}
+}
+
+// UACEncryptedExportEndpoint saves an encrypted export and its manifest for transfer to a third party, and
+// responds with the manifest.
+func (uacController *UacController) UACEncryptedExportEndpoint(context *gin.Context) {
+	options, ok := uacController.getExportOptions(context)
+	if !ok {
+		return
+	}
+	if err := options.Validate(); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	manifest, err := uacController.UacGenerator.SaveEncryptedExport(context.Param("instrumentName"), options)
+	if err != nil {
+		if err == uacgenerator.ErrExportsNotEncrypted {
+			context.AbortWithStatusJSON(http.StatusNotImplemented, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusCreated, manifest)
+}
+
+func (uacController *UacController) getExportOptions(context *gin.Context) (uacgenerator.ExportOptions, bool) {
+	options := uacgenerator.ExportOptions{
+		Header:         true,
+		ChunkSeparator: context.Query("chunk_separator"),
+	}
+	if context.Query("columns") != "" {
+		options.Columns = strings.Split(context.Query("columns"), ",")
+	}
+	if context.Query("header") != "" {
+		header, err := strconv.ParseBool(context.Query("header"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Header must be true or false"})
+			return options, false
+		}
+		options.Header = header
+	}
+	if context.Query("delimiter") != "" {
+		delimiter := []rune(context.Query("delimiter"))
+		if len(delimiter) != 1 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Delimiter must be a single character"})
+			return options, false
+		}
+		options.Delimiter = delimiter[0]
+	}
+	var ok bool
+	options.Filter, ok = uacController.getUacFilter(context)
+	return options, ok
+}
+
+// getUacFilter parses the optional ranges of lifecycle timestamps a listing is limited to, responding with a 400
+// when any is invalid.
+func (uacController *UacController) getUacFilter(context *gin.Context) (uacgenerator.UacFilter, bool) {
+	var filter uacgenerator.UacFilter
+	for _, timeRange := range []struct {
+		parameter string
+		name      string
+		timeRange *uacgenerator.TimeRange
+	}{
+		{"created", "Created", &filter.Created},
+		{"updated", "Updated", &filter.Updated},
+		{"disabled", "Disabled", &filter.Disabled},
+		{"first_accessed", "First accessed", &filter.FirstAccessed},
+	} {
+		var ok bool
+		if timeRange.timeRange.From, ok = uacController.getQueryTime(context, timeRange.parameter+"_from", timeRange.name+" from"); !ok {
+			return filter, false
+		}
+		if timeRange.timeRange.To, ok = uacController.getQueryTime(context, timeRange.parameter+"_to", timeRange.name+" to"); !ok {
+			return filter, false
+		}
+	}
+	return filter, true
+}
+
 func (uacController *UacController) ListInstrumentsEndpoint(context *gin.Context) {
 	instrumentNames, err := uacController.UacGenerator.GetInstruments()
 	if err != nil {
@@ -157,6 +550,135 @@ func (uacController *UacController) UACCountEndpoint(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"count": uacCount})
 }
 
+func (uacController *UacController) UACKindEndpoint(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+
+	uacKind, err := uacController.UacGenerator.GetInstrumentUacKind(instrumentName)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, uacgenerator.InstrumentConfig{InstrumentName: instrumentName, UacKind: uacKind})
+}
+
+func (uacController *UacController) AdminSetUACKindEndpoint(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer context.Request.Body.Close()
+	var uacKindRequest UACKindRequest
+	err = json.Unmarshal(body, &uacKindRequest)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	err = uacController.generator(context).SetInstrumentUacKind(instrumentName, uacKindRequest.UacKind)
+	if err != nil {
+		if err == uacgenerator.ErrInvalidUacKind || err == uacgenerator.ErrInstrumentHasUacs {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, uacgenerator.InstrumentConfig{InstrumentName: instrumentName, UacKind: uacKindRequest.UacKind})
+}
+
+func (uacController *UacController) UACValidityEndpoint(context *gin.Context) {
+	validity, err := uacController.UacGenerator.GetInstrumentValidity(context.Param("instrumentName"))
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, validity)
+}
+
+func (uacController *UacController) AdminSetValidityEndpoint(context *gin.Context) {
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer context.Request.Body.Close()
+	var validity uacgenerator.Validity
+	err = json.Unmarshal(body, &validity)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	err = uacController.generator(context).SetInstrumentValidity(context.Param("instrumentName"), validity)
+	if err != nil {
+		if err == uacgenerator.ErrInvalidValidity {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, validity)
+}
+
+// UACSetValidityEndpoint sets when a UAC can be used, overriding its instrument's validity.
+func (uacController *UacController) UACSetValidityEndpoint(context *gin.Context) {
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer context.Request.Body.Close()
+	var validityRequest UACValidityRequest
+	err = json.Unmarshal(body, &validityRequest)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	err = uacController.generator(context).SetUacValidity(validityRequest.UAC, validityRequest.Validity)
+	if err != nil {
+		switch err {
+		case datastore.ErrNoSuchEntity:
+			context.AbortWithStatusJSON(http.StatusNotFound, ResponseError{Error: "UAC not found"})
+		case uacgenerator.ErrInvalidValidity:
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		case uacgenerator.ErrProbableTypo:
+			context.AbortWithStatusJSON(http.StatusUnprocessableEntity, ResponseError{Error: err.Error()})
+		default:
+			_ = context.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	context.JSON(http.StatusOK, validityRequest.Validity)
+}
+
+// UACExpiringEndpoint lists the UACs that stop being valid within the within parameter, a duration such as 720h.
+func (uacController *UacController) UACExpiringEndpoint(context *gin.Context) {
+	within, err := time.ParseDuration(context.Query("within"))
+	if err != nil || within <= 0 {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Within must be a positive duration"})
+		return
+	}
+	uacs, err := uacController.UacGenerator.GetUacsExpiring(context.Param("instrumentName"), within)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	uacs.BuildUacChunks()
+	context.JSON(http.StatusOK, uacs)
+}
+
+// UACDeniedEndpoint reports the UACs of an instrument that spell a word on the denylist, so they can be reissued.
+func (uacController *UacController) UACDeniedEndpoint(context *gin.Context) {
+	uacs, err := uacController.UacGenerator.GetUacsDenied(context.Param("instrumentName"))
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	uacs.BuildUacChunks()
+	context.JSON(http.StatusOK, uacs)
+}
+
 func (uacController *UacController) GetUacInfoEndpoint(context *gin.Context) {
 	uac, err := uacController.getUacRequest(context)
 	if err != nil {
@@ -171,6 +693,10 @@ func (uacController *UacController) GetUacInfoEndpoint(context *gin.Context) {
 			context.JSON(http.StatusNotFound, nil)
 			return
 		}
+		if err == uacgenerator.ErrProbableTypo {
+			context.JSON(http.StatusUnprocessableEntity, ResponseError{Error: err.Error()})
+			return
+		}
 		log.Println(err)
 		context.AbortWithStatusJSON(http.StatusInternalServerError, nil)
 		return
@@ -178,9 +704,66 @@ func (uacController *UacController) GetUacInfoEndpoint(context *gin.Context) {
 	context.JSON(http.StatusOK, uacInfo)
 }
 
+// UACAuthenticateEndpoint is for respondents to authenticate with a UAC, so unlike GetUacInfoEndpoint it only
+// succeeds for a UAC that can be used now, and limits how often each client can try.
+func (uacController *UacController) UACAuthenticateEndpoint(context *gin.Context) {
+	client := context.ClientIP()
+	retryAfter, err := uacController.AuthLimiter.Allow(client)
+	if err != nil {
+		reason := AUTHRATELIMITED
+		if err == ErrLockedOut {
+			reason = AUTHLOCKEDOUT
+		}
+		authenticationMetrics.Add(reason, 1)
+		context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		context.AbortWithStatusJSON(http.StatusTooManyRequests, UACAuthenticateError{Error: err.Error(), Reason: reason})
+		return
+	}
+
+	uac, err := uacController.getUacRequest(context)
+	if err != nil || strings.TrimSpace(uac.UAC) == "" {
+		authenticationMetrics.Add(AUTHINVALIDREQUEST, 1)
+		context.AbortWithStatusJSON(http.StatusBadRequest, UACAuthenticateError{Error: "Must provide a UAC", Reason: AUTHINVALIDREQUEST})
+		return
+	}
+
+	uacInfo, err := uacController.UacGenerator.AuthenticateUac(uac.UAC)
+	if err != nil {
+		status, reason := http.StatusForbidden, ""
+		switch err {
+		case datastore.ErrNoSuchEntity:
+			status, reason = http.StatusNotFound, AUTHNOTFOUND
+			err = errors.New("UAC not found")
+		case uacgenerator.ErrProbableTypo:
+			status, reason = http.StatusUnprocessableEntity, AUTHPROBABLETYPO
+		case uacgenerator.ErrUacDisabled:
+			reason = AUTHDISABLED
+		case uacgenerator.ErrUacReissued:
+			reason = AUTHREISSUED
+		case uacgenerator.ErrUacExpired:
+			reason = AUTHEXPIRED
+		case uacgenerator.ErrUacNotYetValid:
+			reason = AUTHNOTYETVALID
+		default:
+			log.Println(err)
+			context.AbortWithStatusJSON(http.StatusInternalServerError, nil)
+			return
+		}
+		authenticationMetrics.Add(reason, 1)
+		if status != http.StatusForbidden && uacController.AuthLimiter.Failure(client) {
+			authenticationMetrics.Add("lockouts", 1)
+		}
+		context.AbortWithStatusJSON(status, UACAuthenticateError{Error: err.Error(), Reason: reason})
+		return
+	}
+	uacController.AuthLimiter.Success(client)
+	authenticationMetrics.Add("authenticated", 1)
+	context.JSON(http.StatusOK, UACAuthenticateResponse{InstrumentName: uacInfo.InstrumentName, CaseID: uacInfo.CaseID, Slot: uacInfo.Slot})
+}
+
 func (uacController *UacController) AdminDeleteEndpoint(context *gin.Context) {
 	instrumentName := context.Param("instrumentName")
-	err := uacController.UacGenerator.AdminDelete(instrumentName)
+	err := uacController.generator(context).AdminDelete(instrumentName)
 	if err != nil {
 		log.Println(err)
 		context.AbortWithStatusJSON(http.StatusInternalServerError, nil)
@@ -189,6 +772,42 @@ func (uacController *UacController) AdminDeleteEndpoint(context *gin.Context) {
 	context.JSON(http.StatusNoContent, nil)
 }
 
+func (uacController *UacController) AdminRestoreEndpoint(context *gin.Context) {
+	result, err := uacController.generator(context).RestoreInstrument(context.Param("instrumentName"))
+	if err != nil {
+		if err == uacgenerator.ErrNoArchive {
+			context.AbortWithStatusJSON(http.StatusNotFound, ResponseError{Error: err.Error()})
+			return
+		}
+		if err == uacgenerator.ErrInvalidUacKind || err == uacgenerator.ErrInstrumentHasUacs {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+func (uacController *UacController) AdminArchivesEndpoint(context *gin.Context) {
+	archives, err := uacController.UacGenerator.GetInstrumentArchives()
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, archives)
+}
+
+// AdminPurgeEndpoint purges the archives whose retention has passed, so it can be called on a schedule.
+func (uacController *UacController) AdminPurgeEndpoint(context *gin.Context) {
+	purged, err := uacController.generator(context).PurgeArchives()
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
 func (uacController *UacController) ImportEndpoint(context *gin.Context) {
 	body, err := io.ReadAll(context.Request.Body)
 	if err != nil {
@@ -202,7 +821,7 @@ func (uacController *UacController) ImportEndpoint(context *gin.Context) {
 		_ = context.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	importCount, err := uacController.UacGenerator.ImportUACs(uacs)
+	importCount, err := uacController.generator(context).ImportUACs(uacs)
 	if err != nil {
 		if _, ok := err.(*uacgenerator.ImportError); ok {
 			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
@@ -214,6 +833,66 @@ func (uacController *UacController) ImportEndpoint(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"uacs_imported": importCount})
 }
 
+// UACAuditEndpoint returns a page of the audit trail, filtered by the uac, instrument_name and actor parameters and
+// from and to RFC 3339 times.
+func (uacController *UacController) UACAuditEndpoint(context *gin.Context) {
+	query := uacgenerator.AuditQuery{
+		UAC:            context.Query("uac"),
+		InstrumentName: context.Query("instrument_name"),
+		Actor:          context.Query("actor"),
+		Cursor:         context.Query("cursor"),
+		Limit:          uacgenerator.MAXPAGESIZE,
+	}
+	var ok bool
+	if query.From, ok = uacController.getQueryTime(context, "from", "From"); !ok {
+		return
+	}
+	if query.To, ok = uacController.getQueryTime(context, "to", "To"); !ok {
+		return
+	}
+	if context.Query("limit") != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(context.Query("limit"))
+		if err != nil || query.Limit < 1 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: "Limit must be a positive number"})
+			return
+		}
+	}
+	page, err := uacController.UacGenerator.GetAuditEntries(query)
+	if err != nil {
+		if err == uacgenerator.ErrInvalidCursor {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, page)
+}
+
+// getQueryTime parses an optional RFC 3339 time parameter, responding with a 400 when it is invalid.
+func (uacController *UacController) getQueryTime(context *gin.Context, parameter, name string) (time.Time, bool) {
+	if context.Query(parameter) == "" {
+		return time.Time{}, true
+	}
+	queryTime, err := time.Parse(time.RFC3339Nano, context.Query(parameter))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: name + " must be an RFC 3339 time"})
+		return time.Time{}, false
+	}
+	return queryTime, true
+}
+
+// generator returns the UacGenerator to make changes with, which records the identity the request was authenticated
+// as in the audit trail when there is one.
+func (uacController *UacController) generator(context *gin.Context) uacgenerator.UacGeneratorInterface {
+	actor := strings.TrimPrefix(context.GetHeader(IDENTITYHEADER), "accounts.google.com:")
+	if actor == "" {
+		return uacController.UacGenerator
+	}
+	return uacController.UacGenerator.WithActor(actor)
+}
+
 func (uacController *UacController) blaiseRestApiError(context *gin.Context, err error) {
 	if err.Error() == "Instrument not found" {
 		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
@@ -223,6 +902,10 @@ func (uacController *UacController) blaiseRestApiError(context *gin.Context, err
 		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
 		return
 	}
+	if err == uacgenerator.ErrUacReissued {
+		context.AbortWithStatusJSON(http.StatusConflict, ResponseError{Error: err.Error()})
+		return
+	}
 	_ = context.AbortWithError(http.StatusInternalServerError, err)
 }
 
@@ -244,7 +927,7 @@ func (uacController *UacController) getUacRequest(context *gin.Context) (UACRequ
 func (uacController *UacController) UACDisableEndpoint(context *gin.Context) {
 	uac := context.Param("uac")
 
-	err := uacController.UacGenerator.DisableUac(uac)
+	err := uacController.generator(context).DisableUac(uac)
 	if err != nil {
 		uacController.blaiseRestApiError(context, err)
 		return
@@ -255,7 +938,7 @@ func (uacController *UacController) UACDisableEndpoint(context *gin.Context) {
 func (uacController *UacController) UACEnableEndpoint(context *gin.Context) {
 	uac := context.Param("uac")
 
-	err := uacController.UacGenerator.EnableUac(uac)
+	err := uacController.generator(context).EnableUac(uac)
 	if err != nil {
 		uacController.blaiseRestApiError(context, err)
 		return
@@ -263,6 +946,79 @@ func (uacController *UacController) UACEnableEndpoint(context *gin.Context) {
 	context.JSON(http.StatusOK, nil)
 }
 
+func (uacController *UacController) UACBulkDisableEndpoint(context *gin.Context) {
+	uacController.setUacsDisabled(context, true)
+}
+
+func (uacController *UacController) UACBulkEnableEndpoint(context *gin.Context) {
+	uacController.setUacsDisabled(context, false)
+}
+
+// setUacsDisabled responds with what happened to each UAC and case of a bulk change, using a 207 when some failed.
+func (uacController *UacController) setUacsDisabled(context *gin.Context, disabled bool) {
+	instrumentName := context.Param("instrumentName")
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer context.Request.Body.Close()
+	var bulkRequest uacgenerator.BulkUacRequest
+	err = json.Unmarshal(body, &bulkRequest)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+		return
+	}
+	var result *uacgenerator.BulkUacResult
+	if disabled {
+		result, err = uacController.generator(context).DisableUacs(instrumentName, bulkRequest)
+	} else {
+		result, err = uacController.generator(context).EnableUacs(instrumentName, bulkRequest)
+	}
+	if err != nil {
+		if err == uacgenerator.ErrInvalidReason || err == uacgenerator.ErrNoBulkItems || err == uacgenerator.ErrTooManyBulkItems {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if result.HasFailures() {
+		context.JSON(http.StatusMultiStatus, result)
+		return
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+// UACReissueEndpoint responds with the case's new UAC in full. The body, with a note on why the UAC was reissued, is
+// optional.
+func (uacController *UacController) UACReissueEndpoint(context *gin.Context) {
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer context.Request.Body.Close()
+	var reissueRequest UACReissueRequest
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &reissueRequest)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, ResponseError{Error: err.Error()})
+			return
+		}
+	}
+	uacInfo, err := uacController.generator(context).ReissueUac(context.Param("instrumentName"), context.Param("caseID"), reissueRequest.Slot, reissueRequest.Note)
+	if err != nil {
+		if err == uacgenerator.ErrCaseHasNoUac {
+			context.AbortWithStatusJSON(http.StatusNotFound, ResponseError{Error: err.Error()})
+			return
+		}
+		_ = context.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	context.JSON(http.StatusOK, uacInfo)
+}
+
 func (uacController *UacController) UACGetAllDisabledEndpoint(context *gin.Context) {
 	instrumentName := context.Param("instrumentName")
 
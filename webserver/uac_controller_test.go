@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"cloud.google.com/go/datastore"
 	"github.com/ONSDigital/blaise-uac-service/blaiserestapi"
@@ -77,7 +78,7 @@ var _ = Describe("UAC Controller", func() {
 			BeforeEach(func() {
 				mockBlaiseRestApi.On("GetInstrumentModes", "test123").Return(blaiserestapi.InstrumentModes{"CAWI"}, nil)
 
-				mockUacGenerator.On("Generate", "test123", []string{"12345"}).Return(nil)
+				mockUacGenerator.On("Generate", "test123", []string{"12345"}).Return(uacgenerator.NewGenerateResult(), nil)
 			})
 
 			Context("when the instrument does exist when getting case ids", func() {
@@ -110,6 +111,108 @@ var _ = Describe("UAC Controller", func() {
 		})
 	})
 
+	Describe("POST /uacs/instrument/:instrumentName?claim_pool=true", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123?claim_pool=true", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockBlaiseRestApi.On("GetInstrumentModes", "test123").Return(blaiserestapi.InstrumentModes{"CAWI"}, nil)
+			mockBlaiseRestApi.On("GetCaseIds", "test123").Return([]string{"12452", "12453"}, nil)
+			mockUacGenerator.On("GenerateWithOptions", "test123", []string{"12452", "12453"}, uacgenerator.GenerateOptions{ClaimFromPool: true}).
+				Return(&uacgenerator.GenerateResult{
+					Generated: []string{"12453"},
+					FromPool:  []string{"12452"},
+					Existing:  []string{},
+					Failed:    []uacgenerator.CaseFailure{},
+				}, nil)
+			mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+				"125634896985": {
+					InstrumentName: "test123",
+					CaseID:         "12452",
+				},
+			}, nil)
+		})
+
+		It("claims UACs from the pool and reports where they came from", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"result":{"generated":["12453"],"from_pool":["12452"],"existing":[],"failed":[]},"uacs":{"125634896985":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false}}}`))
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName?uacs_per_case=2", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			uacsPerCase  string
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123?uacs_per_case="+uacsPerCase, nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("with a valid number of UACs per case", func() {
+			BeforeEach(func() {
+				uacsPerCase = "2"
+				mockBlaiseRestApi.On("GetInstrumentModes", "test123").Return(blaiserestapi.InstrumentModes{"CAWI"}, nil)
+				mockBlaiseRestApi.On("GetCaseIds", "test123").Return([]string{"12452"}, nil)
+				mockUacGenerator.On("GenerateWithOptions", "test123", []string{"12452"}, uacgenerator.GenerateOptions{UacsPerCase: 2}).
+					Return(&uacgenerator.GenerateResult{
+						Generated: []string{"12452", "12452/1"},
+						FromPool:  []string{},
+						Existing:  []string{},
+						Failed:    []uacgenerator.CaseFailure{},
+					}, nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+					"125634896985": {
+						InstrumentName: "test123",
+						CaseID:         "12452",
+					},
+					"789456123012": {
+						InstrumentName: "test123",
+						CaseID:         "12452",
+						Slot:           1,
+					},
+				}, nil)
+			})
+
+			It("generates a UAC for each slot of the cases", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"125634896985":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false},"789456123012":{"instrument_name":"test123","case_id":"12452","slot":1,"uac_chunks":{"uac1":"7894","uac2":"5612","uac3":"3012"},"disabled":false}}`))
+			})
+		})
+
+		Context("with too many UACs per case", func() {
+			BeforeEach(func() {
+				uacsPerCase = "17"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UACs per case must be from 1 to 16"}`))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "GenerateWithOptions", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("with a number of UACs per case that is not a number", func() {
+			BeforeEach(func() {
+				uacsPerCase = "two"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UACs per case must be from 1 to 16"}`))
+			})
+		})
+	})
+
 	Describe("GET /uacs/instrument/:instrumentName", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
@@ -206,313 +309,1218 @@ var _ = Describe("UAC Controller", func() {
 		})
 	})
 
-	Describe("/uacs/instruments", func() {
+	Describe("GET /uacs/instrument/:instrumentName/kind", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/instruments", nil)
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123/kind", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
 		BeforeEach(func() {
-			mockUacGenerator.On("GetInstruments").Return([]string{"foo", "bar"}, nil)
+			mockUacGenerator.On("GetInstrumentUacKind", "test123").Return("uac16", nil)
 		})
 
-		It("Returns instrument_names with a status Ok", func() {
+		It("Returns the instrument's UAC kind with a status Ok", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-			Expect(httpRecorder.Body.String()).To(Equal(`["foo","bar"]`))
-		})
-	})
-
-	Describe("POST /uacs/generate", func() {
-		var (
-			httpRecorder *httptest.ResponseRecorder
-		)
-
-		Context("when case_ids and instrument_name are provided", func() {
-			JustBeforeEach(func() {
-				requestBody := `{"instrument_name": "test123", "case_ids": ["123", "456", "789"]}`
-				httpRecorder = httptest.NewRecorder()
-				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
-				httpRouter.ServeHTTP(httpRecorder, req)
-			})
-
-			BeforeEach(func() {
-				mockUacGenerator.On("Generate", "test123", []string{"123", "456", "789"}).Return(nil)
-				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
-					"125634896985": {
-						InstrumentName: "test123",
-						CaseID:         "12452",
-					},
-				}, nil)
-			})
-
-			It("generates and return a bunch of UACs", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-				Expect(httpRecorder.Body.String()).To(Equal(`{"125634896985":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false}}`))
-			})
-		})
-
-		Context("when no case_ids are provided", func() {
-			JustBeforeEach(func() {
-				requestBody := `{"instrument_name": "test123"}`
-				httpRecorder = httptest.NewRecorder()
-				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
-				httpRouter.ServeHTTP(httpRecorder, req)
-			})
-
-			BeforeEach(func() {
-				mockUacGenerator.On("Generate", "test123", []string(nil)).Return(nil)
-				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{}, nil)
-			})
-
-			It("generated nothing, and returns as such", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-				Expect(httpRecorder.Body.String()).To(Equal(`{}`))
-			})
-		})
-
-		Context("when instrument_name is not provided", func() {
-			JustBeforeEach(func() {
-				requestBody := `{"case_ids": ["123", "456", "789"]}`
-				httpRecorder = httptest.NewRecorder()
-				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
-				httpRouter.ServeHTTP(httpRecorder, req)
-			})
-
-			It("generates and return a bunch of UACs", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
-				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Must provide instrument name"}`))
-			})
+			Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","uac_kind":"uac16"}`))
 		})
 	})
 
-	Describe("/uacs/uac", func() {
+	Describe("PUT /uacs/admin/instrument/:instrumentName/kind", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
-			requestBody  io.Reader
 		)
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("POST", "/uacs/uac", requestBody)
+			req, _ := http.NewRequest("PUT", "/uacs/admin/instrument/test123/kind", bytes.NewBufferString(`{"uac_kind":"uac16"}`))
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
-		Context("A valid UAC returns UACInfo for that code", func() {
+		Context("when the kind can be set", func() {
 			BeforeEach(func() {
-				requestBody = bytes.NewReader([]byte(`{"uac":"98765432101"}`))
-				mockUacGenerator.On("GetUacInfo", "98765432101").Return(&uacgenerator.UacInfo{
-					InstrumentName: "test123",
-					CaseID:         "12452",
-				}, nil)
+				mockUacGenerator.On("SetInstrumentUacKind", "test123", "uac16").Return(nil)
 			})
 
-			It("Gets UAC Info for a valid UAC Code", func() {
+			It("Returns the instrument's UAC kind with a status Ok", func() {
 				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-				Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","case_id":"12452","disabled":false}`))
-			})
-		})
-
-		Context("Returns bad request if no body is posted", func() {
-			BeforeEach(func() {
-				requestBody = bytes.NewReader([]byte(``))
-			})
-
-			It("Returns an empty body and a bad request status", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
-				Expect(httpRecorder.Body.String()).To(Equal("null"))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","uac_kind":"uac16"}`))
 			})
 		})
 
-		Context("Returns bad request if no body is invalid JSON", func() {
+		Context("when the instrument already has UACs", func() {
 			BeforeEach(func() {
-				requestBody = bytes.NewReader([]byte(`{"blah":Blah}`))
+				mockUacGenerator.On("SetInstrumentUacKind", "test123", "uac16").Return(uacgenerator.ErrInstrumentHasUacs)
 			})
 
-			It("Returns an empty body and a bad request status", func() {
+			It("returns a http 400 error", func() {
 				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
-				Expect(httpRecorder.Body.String()).To(Equal("null"))
-			})
-		})
-
-		Context("Returns bad request if no body is invalid JSON", func() {
-			BeforeEach(func() {
-				requestBody = bytes.NewReader([]byte(`{"uac":"98765432101"}`))
-				mockUacGenerator.On("GetUacInfo", "98765432101").Return(nil, datastore.ErrNoSuchEntity)
-			})
-
-			It("Returns an empty body and a not found status", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
-				Expect(httpRecorder.Body.String()).To(Equal("null"))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Cannot change the UAC kind of an instrument that already has UACs"}`))
 			})
 		})
 	})
 
-	Describe("POST /import", func() {
+	Describe("POST /uacs/admin/instrument/:instrumentName/restore", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
 		JustBeforeEach(func() {
-			requestBody := `["123456789123","123456789145","123556789987"]`
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("POST", "/uacs/import", bytes.NewBufferString(requestBody))
+			req, _ := http.NewRequest("POST", "/uacs/admin/instrument/test123/restore", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
-		Context("and importing the UACs is successful", func() {
+		Context("when the instrument has an archive", func() {
 			BeforeEach(func() {
-				mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).Return(3, nil)
+				mockUacGenerator.On("RestoreInstrument", "test123").Return(&uacgenerator.RestoreResult{
+					Restored:  2,
+					Conflicts: []string{"12452"},
+				}, nil)
 			})
 
-			It("imports all of the UACs", func() {
+			It("returns the UACs restored and the cases left in the archive", func() {
 				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-				Expect(httpRecorder.Body.String()).To(Equal(`{"uacs_imported":3}`))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"restored":2,"conflicts":["12452"]}`))
 			})
 		})
 
-		Context("and importing the UACs errors", func() {
-			Context("and the error is an import error", func() {
-				BeforeEach(func() {
-					mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).
-						Return(0, &uacgenerator.ImportError{InvalidUACs: []string{"foobar"}})
-				})
-
-				It("errors and doesn't import anything", func() {
-					Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
-					Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Cannot import UACs because some were invalid: [\"foobar\"]"}`))
-				})
+		Context("when the instrument has no archive", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("RestoreInstrument", "test123").Return(nil, uacgenerator.ErrNoArchive)
 			})
 
-			Context("and the error is any other error", func() {
-				BeforeEach(func() {
-					mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).Return(0, fmt.Errorf("invalid uac"))
-				})
-
-				It("errors and doesn't import anything", func() {
-					Expect(httpRecorder.Code).To(Equal(http.StatusInternalServerError))
-				})
+			It("returns a http 404 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Instrument has no archived UACs"}`))
 			})
 		})
 	})
 
-	Describe("GET /uacs/uac/:instrumentName/disabled", func() {
+	Describe("GET /uacs/admin/archives", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/uac/test123/disabled", nil)
+			req, _ := http.NewRequest("GET", "/uacs/admin/archives", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
 		BeforeEach(func() {
-			mockUacGenerator.On("GetAllUacsDisabled", "test123").Return(uacgenerator.Uacs{
-				"12452": {
-					InstrumentName: "test123",
-					CaseID:         "12452",
-					FullUAC:        "125634896985",
-					Disabled:       true,
-				},
-				"65858": {
-					InstrumentName: "test123",
-					CaseID:         "65858",
-					FullUAC:        "78945612309",
-					Disabled:       true,
-				},
-			}, nil)
+			mockUacGenerator.On("GetInstrumentArchives").Return([]*uacgenerator.InstrumentArchive{{
+				InstrumentName: "test123",
+				UacKind:        "uac",
+				DeletedAt:      time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+				DeletedBy:      "alice@example.com",
+				PurgeAfter:     time.Date(2026, 10, 31, 9, 30, 0, 0, time.UTC),
+			}}, nil)
 		})
 
-		It("Gets all UACs for an installed instrument that are disabled", func() {
+		It("returns the archives", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
-			Expect(httpRecorder.Body.String()).To(Equal(`{"12452":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"full_uac":"125634896985","disabled":true},"65858":{"instrument_name":"test123","case_id":"65858","uac_chunks":{"uac1":"7894","uac2":"5612","uac3":"309"},"full_uac":"78945612309","disabled":true}}`))
+			Expect(httpRecorder.Body.String()).To(MatchJSON(`[{
+				"instrument_name": "test123",
+				"uac_kind": "uac",
+				"deleted_at": "2026-10-01T09:30:00Z",
+				"deleted_by": "alice@example.com",
+				"purge_after": "2026-10-31T09:30:00Z"
+			}]`))
 		})
 	})
 
-	Describe("GET /uacs/disable/:uac", func() {
+	Describe("POST /uacs/admin/archives/purge", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/uac/disable/123456789", nil)
+			req, _ := http.NewRequest("POST", "/uacs/admin/archives/purge", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
 		BeforeEach(func() {
-			mockUacGenerator.On("DisableUac", "123456789").Return(nil)
+			mockUacGenerator.On("PurgeArchives").Return([]string{"test123"}, nil)
 		})
 
-		It("Sets the Disabled flag to true", func() {
+		It("returns the instruments purged", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"purged":["test123"]}`))
 		})
 	})
 
-	Describe("GET /uacs/enable/:uac", func() {
+	Describe("/uacs/instruments", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/uac/enable/87654321", nil)
+			req, _ := http.NewRequest("GET", "/uacs/instruments", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
 		BeforeEach(func() {
-			mockUacGenerator.On("EnableUac", "87654321").Return(nil)
+			mockUacGenerator.On("GetInstruments").Return([]string{"foo", "bar"}, nil)
 		})
 
-		It("Sets the Disabled flag to false", func() {
+		It("Returns instrument_names with a status Ok", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`["foo","bar"]`))
 		})
 	})
 
-	Describe("GET /uacs/enable/:uac with a non existing uac", func() {
+	Describe("POST /uacs/generate", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
 		)
 
-		JustBeforeEach(func() {
-			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/uac/enable/1234", nil)
-			httpRouter.ServeHTTP(httpRecorder, req)
-		})
+		Context("when case_ids and instrument_name are provided", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123", "456", "789"]}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
 
-		BeforeEach(func() {
-			mockUacGenerator.On("EnableUac", "1234").Return(fmt.Errorf("invalid uac"))
-		})
+			BeforeEach(func() {
+				mockUacGenerator.On("Generate", "test123", []string{"123", "456", "789"}).Return(uacgenerator.NewGenerateResult(), nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+					"125634896985": {
+						InstrumentName: "test123",
+						CaseID:         "12452",
+					},
+				}, nil)
+			})
 
-		It("returns a http 400 error", func() {
+			It("generates and return a bunch of UACs", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"125634896985":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false}}`))
+			})
+		})
+
+		Context("when the result is asked for", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123"]}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate?result=true", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				result := uacgenerator.NewGenerateResult()
+				result.Generated = []string{"123"}
+				mockUacGenerator.On("Generate", "test123", []string{"123"}).Return(result, nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+					"125634896985": {
+						InstrumentName: "test123",
+						CaseID:         "123",
+					},
+				}, nil)
+			})
+
+			It("reports what happened to each case with the UACs", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"result":{"generated":["123"],"from_pool":[],"existing":[],"failed":[]},"uacs":{"125634896985":{"instrument_name":"test123","case_id":"123","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false}}}`))
+			})
+		})
+
+		Context("when the cases need more than one UAC", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123", "456"], "uacs_per_case": 2, "case_uacs": {"456": 3}}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				mockUacGenerator.On("GenerateWithOptions", "test123", []string{"123", "456"}, uacgenerator.GenerateOptions{
+					UacsPerCase: 2,
+					CaseUacs:    map[string]int{"456": 3},
+				}).Return(uacgenerator.NewGenerateResult(), nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{}, nil)
+			})
+
+			It("generates the UACs with the numbers of UACs the cases need", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				mockUacGenerator.AssertCalled(GinkgoT(), "GenerateWithOptions", "test123", []string{"123", "456"}, uacgenerator.GenerateOptions{
+					UacsPerCase: 2,
+					CaseUacs:    map[string]int{"456": 3},
+				})
+			})
+		})
+
+		Context("when a case needs an invalid number of UACs", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123"], "case_uacs": {"123": 0}}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UACs per case must be from 1 to 16"}`))
+			})
+		})
+
+		Context("when some cases fail", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123", "456", "789"]}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				mockUacGenerator.On("Generate", "test123", []string{"123", "456", "789"}).Return(&uacgenerator.GenerateResult{
+					Generated: []string{"123"},
+					FromPool:  []string{},
+					Existing:  []string{"456"},
+					Failed:    []uacgenerator.CaseFailure{{CaseID: "789", Error: "Massive mutation explosion"}},
+				}, nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+					"125634896985": {
+						InstrumentName: "test123",
+						CaseID:         "123",
+					},
+				}, nil)
+			})
+
+			It("returns a http 207 reporting what happened to each case", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusMultiStatus))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"result":{"generated":["123"],"from_pool":[],"existing":["456"],"failed":[{"case_id":"789","error":"Massive mutation explosion"}]},"uacs":{"125634896985":{"instrument_name":"test123","case_id":"123","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"disabled":false}}}`))
+			})
+		})
+
+		Context("when UACs are keyed", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123", "case_ids": ["123", "456"]}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				mockUacGenerator.On("Generate", "test123", []string{"123", "456"}).Return(&uacgenerator.GenerateResult{
+					Generated: []string{"123"},
+					FromPool:  []string{},
+					Existing:  []string{"456"},
+					Failed:    []uacgenerator.CaseFailure{},
+					Uacs:      map[string]string{"123": "125634896985"},
+				}, nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{
+					"5e1f": {InstrumentName: "test123", CaseID: "123", UacHash: "5e1f"},
+					"a0c2": {InstrumentName: "test123", CaseID: "456", UacHash: "a0c2"},
+				}, nil)
+			})
+
+			It("only shows the UACs generated this time", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(MatchJSON(`{
+					"125634896985": {"instrument_name": "test123", "case_id": "123", "full_uac": "125634896985",
+						"uac_chunks": {"uac1": "1256", "uac2": "3489", "uac3": "6985"}, "disabled": false},
+					"a0c2": {"instrument_name": "test123", "case_id": "456", "uac_hash": "a0c2", "disabled": false}
+				}`))
+			})
+		})
+
+		Context("when no case_ids are provided", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"instrument_name": "test123"}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				mockUacGenerator.On("Generate", "test123", []string(nil)).Return(uacgenerator.NewGenerateResult(), nil)
+				mockUacGenerator.On("GetAllUacs", "test123").Return(uacgenerator.Uacs{}, nil)
+			})
+
+			It("generated nothing, and returns as such", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{}`))
+			})
+		})
+
+		Context("when instrument_name is not provided", func() {
+			JustBeforeEach(func() {
+				requestBody := `{"case_ids": ["123", "456", "789"]}`
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/uacs/generate", bytes.NewBufferString(requestBody))
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("generates and return a bunch of UACs", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Must provide instrument name"}`))
+			})
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName/jobs", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123/jobs", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the instrument is installed in CAWI mode", func() {
+			BeforeEach(func() {
+				mockBlaiseRestApi.On("GetInstrumentModes", "test123").Return(blaiserestapi.InstrumentModes{"CAWI"}, nil)
+				mockBlaiseRestApi.On("GetCaseIds", "test123").Return([]string{"12452", "12453"}, nil)
+				mockUacGenerator.On("StartGenerationJob", "test123", []string{"12452", "12453"}, uacgenerator.GenerateOptions{}).
+					Return(&uacgenerator.GenerationJob{ID: "job1", InstrumentName: "test123", Status: uacgenerator.JOBRUNNING, Total: 2}, nil)
+			})
+
+			It("starts a job and returns a http 202", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusAccepted))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`"id":"job1","instrument_name":"test123","status":"running"`))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`"total":2,"processed":0`))
+			})
+		})
+
+		Context("when the instrument is not installed in CAWI mode", func() {
+			BeforeEach(func() {
+				mockBlaiseRestApi.On("GetInstrumentModes", "test123").Return(blaiserestapi.InstrumentModes{"CATI"}, nil)
+			})
+
+			It("returns a http 400 without starting a job", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "StartGenerationJob", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+	})
+
+	Describe("GET /uacs/jobs/:jobID", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/jobs/job1", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the job exists", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("GetGenerationJob", "job1").Return(&uacgenerator.GenerationJob{
+					ID:             "job1",
+					InstrumentName: "test123",
+					Status:         uacgenerator.JOBRUNNING,
+					Total:          20,
+					Processed:      10,
+					Created:        7,
+					Existing:       2,
+					Failed:         1,
+				}, nil)
+			})
+
+			It("returns the job's progress", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`"total":20,"processed":10,"created":7,"from_pool":0,"existing":2,"failed":1`))
+			})
+		})
+
+		Context("when the job does not exist", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("GetGenerationJob", "job1").Return(nil, datastore.ErrNoSuchEntity)
+			})
+
+			It("returns a http 404", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("POST /uacs/jobs/:jobID/cancel", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/jobs/job1/cancel", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the job is running", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("CancelGenerationJob", "job1").Return(&uacgenerator.GenerationJob{
+					ID:              "job1",
+					Status:          uacgenerator.JOBRUNNING,
+					CancelRequested: true,
+				}, nil)
+			})
+
+			It("returns the job with the cancellation requested", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`"cancel_requested":true`))
+			})
+		})
+
+		Context("when the job has finished", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("CancelGenerationJob", "job1").Return(nil, uacgenerator.ErrJobFinished)
+			})
+
+			It("returns a http 409", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusConflict))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Generation job has already finished"}`))
+			})
+		})
+	})
+
+	Describe("/uacs/uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			requestBody  io.Reader
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/uac", requestBody)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("A valid UAC returns UACInfo for that code", func() {
+			BeforeEach(func() {
+				requestBody = bytes.NewReader([]byte(`{"uac":"98765432101"}`))
+				mockUacGenerator.On("GetUacInfo", "98765432101").Return(&uacgenerator.UacInfo{
+					InstrumentName: "test123",
+					CaseID:         "12452",
+				}, nil)
+			})
+
+			It("Gets UAC Info for a valid UAC Code", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","case_id":"12452","disabled":false}`))
+			})
+		})
+
+		Context("Returns bad request if no body is posted", func() {
+			BeforeEach(func() {
+				requestBody = bytes.NewReader([]byte(``))
+			})
+
+			It("Returns an empty body and a bad request status", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal("null"))
+			})
+		})
+
+		Context("Returns bad request if no body is invalid JSON", func() {
+			BeforeEach(func() {
+				requestBody = bytes.NewReader([]byte(`{"blah":Blah}`))
+			})
+
+			It("Returns an empty body and a bad request status", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal("null"))
+			})
+		})
+
+		Context("Returns bad request if no body is invalid JSON", func() {
+			BeforeEach(func() {
+				requestBody = bytes.NewReader([]byte(`{"uac":"98765432101"}`))
+				mockUacGenerator.On("GetUacInfo", "98765432101").Return(nil, datastore.ErrNoSuchEntity)
+			})
+
+			It("Returns an empty body and a not found status", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+				Expect(httpRecorder.Body.String()).To(Equal("null"))
+			})
+		})
+
+		Context("Returns unprocessable entity if the UAC is a probable typo", func() {
+			BeforeEach(func() {
+				requestBody = bytes.NewReader([]byte(`{"uac":"123412341235"}`))
+				mockUacGenerator.On("GetUacInfo", "123412341235").Return(nil, uacgenerator.ErrProbableTypo)
+			})
+
+			It("Returns a probable typo error and an unprocessable entity status", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC is probably mistyped"}`))
+			})
+		})
+	})
+
+	Describe("POST /import", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			requestBody := `["123456789123","123456789145","123556789987"]`
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/import", bytes.NewBufferString(requestBody))
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("and importing the UACs is successful", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).Return(3, nil)
+			})
+
+			It("imports all of the UACs", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"uacs_imported":3}`))
+			})
+		})
+
+		Context("and importing the UACs errors", func() {
+			Context("and the error is an import error", func() {
+				BeforeEach(func() {
+					mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).
+						Return(0, &uacgenerator.ImportError{InvalidUACs: []string{"foobar"}})
+				})
+
+				It("errors and doesn't import anything", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+					Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Cannot import UACs because some were invalid: [\"foobar\"]"}`))
+				})
+			})
+
+			Context("and the error is any other error", func() {
+				BeforeEach(func() {
+					mockUacGenerator.On("ImportUACs", mock.AnythingOfType("[]string")).Return(0, fmt.Errorf("invalid uac"))
+				})
+
+				It("errors and doesn't import anything", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusInternalServerError))
+				})
+			})
+		})
+	})
+
+	Describe("GET /uacs/uac/:instrumentName/disabled", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/test123/disabled", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("GetAllUacsDisabled", "test123").Return(uacgenerator.Uacs{
+				"12452": {
+					InstrumentName: "test123",
+					CaseID:         "12452",
+					FullUAC:        "125634896985",
+					Disabled:       true,
+				},
+				"65858": {
+					InstrumentName: "test123",
+					CaseID:         "65858",
+					FullUAC:        "78945612309",
+					Disabled:       true,
+				},
+			}, nil)
+		})
+
+		It("Gets all UACs for an installed instrument that are disabled", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"12452":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"full_uac":"125634896985","disabled":true},"65858":{"instrument_name":"test123","case_id":"65858","uac_chunks":{"uac1":"7894","uac2":"5612","uac3":"309"},"full_uac":"78945612309","disabled":true}}`))
+		})
+	})
+
+	Describe("GET /uacs/disable/:uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/disable/123456789", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("DisableUac", "123456789").Return(nil)
+		})
+
+		It("Sets the Disabled flag to true", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("GET /uacs/enable/:uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/enable/87654321", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("EnableUac", "87654321").Return(nil)
+		})
+
+		It("Sets the Disabled flag to false", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("GET /uacs/enable/:uac with a non existing uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/enable/1234", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("EnableUac", "1234").Return(fmt.Errorf("invalid uac"))
+		})
+
+		It("returns a http 400 error", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
 			Expect(httpRecorder.Body.String()).To(Equal(`{"error":"invalid uac"}`))
 		})
 	})
 
-	Describe("GET /uacs/disable/:uac with a non existing uac", func() {
-		var (
-			httpRecorder *httptest.ResponseRecorder
-		)
+	Describe("GET /uacs/disable/:uac with a non existing uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/disable/1234", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("DisableUac", "1234").Return(fmt.Errorf("invalid uac"))
+		})
+
+		It("returns a http 400 error", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"error":"invalid uac"}`))
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName/disable", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			body         string
+		)
+
+		BeforeEach(func() {
+			body = `{"uacs":["123456789"],"case_ids":["12452","65858"],"reason":"refusal","note":"Rang to refuse"}`
+		})
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123/disable", bytes.NewBufferString(body))
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		bulkRequest := uacgenerator.BulkUacRequest{
+			UACs:    []string{"123456789"},
+			CaseIDs: []string{"12452", "65858"},
+			Reason:  uacgenerator.REASONREFUSAL,
+			Note:    "Rang to refuse",
+		}
+
+		Context("when every UAC is found", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("DisableUacs", "test123", bulkRequest).Return(&uacgenerator.BulkUacResult{
+					Updated:  2,
+					NotFound: 1,
+					Items: []uacgenerator.BulkUacItem{
+						{UAC: "123456789", Status: uacgenerator.BULKUPDATED},
+						{CaseID: "12452", Status: uacgenerator.BULKUPDATED},
+						{CaseID: "65858", Status: uacgenerator.BULKNOTFOUND},
+					},
+				}, nil)
+			})
+
+			It("returns what happened to each UAC and case with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"updated":2,"unchanged":0,"not_found":1,"failed":0,"items":[{"uac":"123456789","status":"updated"},{"case_id":"12452","status":"updated"},{"case_id":"65858","status":"not_found"}]}`))
+			})
+		})
+
+		Context("when some UACs fail", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("DisableUacs", "test123", bulkRequest).Return(&uacgenerator.BulkUacResult{
+					Updated: 2,
+					Failed:  1,
+					Items: []uacgenerator.BulkUacItem{
+						{UAC: "123456789", Status: uacgenerator.BULKUPDATED},
+						{CaseID: "12452", Status: uacgenerator.BULKUPDATED},
+						{CaseID: "65858", Status: uacgenerator.BULKFAILED, Error: "datastore unavailable"},
+					},
+				}, nil)
+			})
+
+			It("returns a http 207", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusMultiStatus))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`{"case_id":"65858","status":"failed","error":"datastore unavailable"}`))
+			})
+		})
+
+		Context("when the reason is not valid", func() {
+			BeforeEach(func() {
+				body = `{"case_ids":["12452"],"reason":"bored"}`
+				mockUacGenerator.On("DisableUacs", "test123", uacgenerator.BulkUacRequest{
+					CaseIDs: []string{"12452"},
+					Reason:  "bored",
+				}).Return(nil, uacgenerator.ErrInvalidReason)
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Invalid reason"}`))
+			})
+		})
+
+		Context("when the body is not JSON", func() {
+			BeforeEach(func() {
+				body = `lolcat`
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "DisableUacs", mock.Anything, mock.Anything)
+			})
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName/enable", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123/enable", bytes.NewBufferString(`{"case_ids":["12452"],"reason":"reinstated"}`))
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("EnableUacs", "test123", uacgenerator.BulkUacRequest{
+				CaseIDs: []string{"12452"},
+				Reason:  uacgenerator.REASONREINSTATED,
+			}).Return(&uacgenerator.BulkUacResult{
+				Updated: 1,
+				Items:   []uacgenerator.BulkUacItem{{CaseID: "12452", Status: uacgenerator.BULKUPDATED}},
+			}, nil)
+		})
+
+		It("returns what happened to each case with a status Ok", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"updated":1,"unchanged":0,"not_found":0,"failed":0,"items":[{"case_id":"12452","status":"updated"}]}`))
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName/case/:caseID/reissue", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			body         io.Reader
+		)
+
+		BeforeEach(func() {
+			body = bytes.NewBufferString(`{"note":"Letter returned"}`)
+		})
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/instrument/test123/case/12452/reissue", body)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the case has a UAC", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("ReissueUac", "test123", "12452", 0, "Letter returned").Return(&uacgenerator.UacInfo{
+					InstrumentName: "test123",
+					CaseID:         "12452",
+					FullUAC:        "125634896985",
+					UacChunks:      &uacgenerator.UacChunks{UAC1: "1256", UAC2: "3489", UAC3: "6985"},
+					ReissuedFrom:   "789456123012",
+				}, nil)
+			})
+
+			It("returns the case's new UAC with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"full_uac":"125634896985","disabled":false,"reissued_from":"789456123012"}`))
+			})
+		})
+
+		Context("with a slot", func() {
+			BeforeEach(func() {
+				body = bytes.NewBufferString(`{"slot":1}`)
+				mockUacGenerator.On("ReissueUac", "test123", "12452", 1, "").Return(&uacgenerator.UacInfo{
+					InstrumentName: "test123",
+					CaseID:         "12452",
+					Slot:           1,
+				}, nil)
+			})
+
+			It("reissues the UAC of the slot", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				mockUacGenerator.AssertCalled(GinkgoT(), "ReissueUac", "test123", "12452", 1, "")
+			})
+		})
+
+		Context("without a body", func() {
+			BeforeEach(func() {
+				body = bytes.NewReader([]byte(``))
+				mockUacGenerator.On("ReissueUac", "test123", "12452", 0, "").Return(&uacgenerator.UacInfo{
+					InstrumentName: "test123",
+					CaseID:         "12452",
+				}, nil)
+			})
+
+			It("reissues the UAC without a note", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				mockUacGenerator.AssertCalled(GinkgoT(), "ReissueUac", "test123", "12452", 0, "")
+			})
+		})
+
+		Context("when the case has no UAC", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("ReissueUac", "test123", "12452", 0, "Letter returned").Return(nil, uacgenerator.ErrCaseHasNoUac)
+			})
+
+			It("returns a http 404 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Case has no UAC"}`))
+			})
+		})
+	})
+
+	Describe("GET /uacs/enable/:uac with a reissued uac", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/enable/789456123012", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("EnableUac", "789456123012").Return(uacgenerator.ErrUacReissued)
+		})
+
+		It("returns a http 409 error", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusConflict))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC has been reissued"}`))
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName/validity", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123/validity", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			mockUacGenerator.On("GetInstrumentValidity", "test123").Return(uacgenerator.Validity{
+				ValidUntil: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			}, nil)
+		})
+
+		It("returns the instrument's validity with a status Ok", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"valid_until":"2026-12-01T00:00:00Z"}`))
+		})
+	})
+
+	Describe("PUT /uacs/admin/instrument/:instrumentName/validity", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			validity     = uacgenerator.Validity{
+				ValidFrom:  time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+				ValidUntil: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			}
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/uacs/admin/instrument/test123/validity",
+				bytes.NewBufferString(`{"valid_from":"2026-09-01T00:00:00Z","valid_until":"2026-12-01T00:00:00Z"}`))
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the validity can be set", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("SetInstrumentValidity", "test123", validity).Return(nil)
+			})
+
+			It("returns the instrument's validity with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"valid_from":"2026-09-01T00:00:00Z","valid_until":"2026-12-01T00:00:00Z"}`))
+			})
+		})
+
+		Context("when the validity ends before it starts", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("SetInstrumentValidity", "test123", validity).Return(uacgenerator.ErrInvalidValidity)
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Valid until must be after valid from"}`))
+			})
+		})
+	})
+
+	Describe("POST /uacs/uac/validity", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			validity     = uacgenerator.Validity{ValidUntil: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)}
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/uac/validity",
+				bytes.NewBufferString(`{"uac":"125634896985","valid_until":"2026-12-01T00:00:00Z"}`))
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the UAC exists", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("SetUacValidity", "125634896985", validity).Return(nil)
+			})
+
+			It("returns the UAC's validity with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"valid_until":"2026-12-01T00:00:00Z"}`))
+			})
+		})
+
+		Context("when the UAC does not exist", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("SetUacValidity", "125634896985", validity).Return(datastore.ErrNoSuchEntity)
+			})
+
+			It("returns a http 404 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC not found"}`))
+			})
+		})
+	})
+
+	Describe("POST /uacs/authenticate", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			body         string
+		)
+
+		BeforeEach(func() {
+			body = `{"uac":"1256 3489 6985"}`
+			uacController.AuthLimiter = &webserver.AuthLimiter{MaxFailures: 2, Lockout: time.Minute}
+		})
+
+		AfterEach(func() {
+			uacController.AuthLimiter = nil
+		})
+
+		authenticate := func() *httptest.ResponseRecorder {
+			httpRecorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/uacs/authenticate", bytes.NewBufferString(body))
+			httpRouter.ServeHTTP(httpRecorder, req)
+			return httpRecorder
+		}
+
+		JustBeforeEach(func() {
+			httpRecorder = authenticate()
+		})
+
+		Context("when the UAC can be used", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("AuthenticateUac", "1256 3489 6985").Return(&uacgenerator.UacInfo{
+					InstrumentName: "test123",
+					CaseID:         "12452",
+					FullUAC:        "125634896985",
+				}, nil)
+			})
+
+			It("returns the UAC's case with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"instrument_name":"test123","case_id":"12452"}`))
+			})
+		})
+
+		Context("when the UAC is disabled", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("AuthenticateUac", "1256 3489 6985").Return(nil, uacgenerator.ErrUacDisabled)
+			})
+
+			It("returns a http 403 error with the reason", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC is disabled","reason":"disabled"}`))
+			})
+		})
+
+		Context("when the UAC has expired", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("AuthenticateUac", "1256 3489 6985").Return(nil, uacgenerator.ErrUacExpired)
+			})
+
+			It("returns a http 403 error with the reason", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC has expired","reason":"expired"}`))
+			})
+		})
+
+		Context("when no UAC is given", func() {
+			BeforeEach(func() {
+				body = `{"uac":" "}`
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Must provide a UAC","reason":"invalid_request"}`))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "AuthenticateUac", mock.Anything)
+			})
+		})
+
+		Context("when the UAC does not exist", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("AuthenticateUac", "1256 3489 6985").Return(nil, datastore.ErrNoSuchEntity)
+			})
+
+			It("returns a http 404 error, locking the client out after too many", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UAC not found","reason":"not_found"}`))
+
+				Expect(authenticate().Code).To(Equal(http.StatusNotFound))
+				lockedOut := authenticate()
+				Expect(lockedOut.Code).To(Equal(http.StatusTooManyRequests))
+				Expect(lockedOut.Header().Get("Retry-After")).To(Equal("60"))
+				Expect(lockedOut.Body.String()).To(Equal(`{"error":"Too many failed attempts, try again later","reason":"locked_out"}`))
+				mockUacGenerator.AssertNumberOfCalls(GinkgoT(), "AuthenticateUac", 2)
+			})
+		})
+
+		Context("when the client has made too many attempts", func() {
+			BeforeEach(func() {
+				uacController.AuthLimiter.ClientLimit = 1
+				uacController.AuthLimiter.ClientWindow = time.Minute
+				mockUacGenerator.On("AuthenticateUac", "1256 3489 6985").Return(nil, uacgenerator.ErrUacDisabled)
+			})
+
+			It("returns a http 429 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				rateLimited := authenticate()
+				Expect(rateLimited.Code).To(Equal(http.StatusTooManyRequests))
+				Expect(rateLimited.Header().Get("Retry-After")).To(Equal("60"))
+				Expect(rateLimited.Body.String()).To(Equal(`{"error":"Too many attempts, try again later","reason":"rate_limited"}`))
+			})
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName/expiring", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			within       string
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123/expiring?within="+within, nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when the period is a duration", func() {
+			BeforeEach(func() {
+				within = "720h"
+				mockUacGenerator.On("GetUacsExpiring", "test123", 720*time.Hour).Return(uacgenerator.Uacs{
+					"12452": &uacgenerator.UacInfo{
+						InstrumentName: "test123",
+						CaseID:         "12452",
+						FullUAC:        "125634896985",
+						ValidUntil:     time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+						Status:         uacgenerator.UACACTIVE,
+					},
+				}, nil)
+			})
+
+			It("returns the UACs expiring within the period with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"12452":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"1256","uac2":"3489","uac3":"6985"},"full_uac":"125634896985","disabled":false,"valid_until":"2026-12-01T00:00:00Z","status":"active"}}`))
+			})
+		})
+
+		Context("when the period is not a duration", func() {
+			BeforeEach(func() {
+				within = "a+month"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Within must be a positive duration"}`))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "GetUacsExpiring", mock.Anything, mock.Anything)
+			})
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName/denied", func() {
+		var httpRecorder *httptest.ResponseRecorder
 
 		JustBeforeEach(func() {
 			httpRecorder = httptest.NewRecorder()
-			req, _ := http.NewRequest("GET", "/uacs/uac/disable/1234", nil)
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123/denied", nil)
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
-		BeforeEach(func() {
-			mockUacGenerator.On("DisableUac", "1234").Return(fmt.Errorf("invalid uac"))
+		Context("when UACs are denied", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("GetUacsDenied", "test123").Return(uacgenerator.Uacs{
+					"12452": &uacgenerator.UacInfo{
+						InstrumentName: "test123",
+						CaseID:         "12452",
+						FullUAC:        "580085801234",
+						DeniedWord:     "boob",
+					},
+				}, nil)
+			})
+
+			It("returns the denied UACs with a status Ok", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"12452":{"instrument_name":"test123","case_id":"12452","uac_chunks":{"uac1":"5800","uac2":"8580","uac3":"1234"},"full_uac":"580085801234","disabled":false,"denied_word":"boob"}}`))
+			})
 		})
 
-		It("returns a http 400 error", func() {
-			Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
-			Expect(httpRecorder.Body.String()).To(Equal(`{"error":"invalid uac"}`))
+		Context("when the UACs cannot be listed", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("GetUacsDenied", "test123").Return(nil, fmt.Errorf("Massive explosions"))
+			})
+
+			It("returns a http 500 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusInternalServerError))
+			})
 		})
 	})
 
@@ -536,4 +1544,427 @@ var _ = Describe("UAC Controller", func() {
 			Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Instrument not found"}`))
 		})
 	})
+
+	Describe("GET /uacs/instrument/:instrumentName/bycaseid filtered by lifecycle timestamps", func() {
+		var httpRecorder *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			mockUacGenerator.On("GetAllUacsByCaseID", "test123").Return(uacgenerator.Uacs{
+				"12452": {InstrumentName: "test123", CaseID: "12452", FullUAC: "125634896985",
+					CreatedAt: time.Date(2026, 9, 30, 12, 0, 0, 0, time.UTC)},
+				"65858": {InstrumentName: "test123", CaseID: "65858", FullUAC: "458956873215",
+					CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+			}, nil)
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123/bycaseid?created_from=2026-10-01T00:00:00Z", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		It("returns the UACs issued since then", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(MatchJSON(`{
+				"65858": {
+					"instrument_name": "test123",
+					"case_id": "65858",
+					"disabled": false,
+					"full_uac": "458956873215",
+					"uac_chunks": {"uac1": "4589", "uac2": "5687", "uac3": "3215"},
+					"created_at": "2026-10-01T12:00:00Z"
+				}
+			}`))
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName with a limit", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			url          string
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", url, nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when there are more UACs", func() {
+			BeforeEach(func() {
+				url = "/uacs/instrument/test123?limit=1&cursor=abc"
+				mockUacGenerator.On("GetUacsPage", "test123", uacgenerator.UacFilter{}, "abc", 1).Return(&uacgenerator.UacPage{
+					Uacs: uacgenerator.Uacs{
+						"125634896985": {InstrumentName: "test123", CaseID: "12452"},
+					},
+					Cursor: "def",
+				}, nil)
+			})
+
+			It("returns the page and a cursor for the next page", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(MatchJSON(`{
+					"uacs": {"125634896985": {"instrument_name": "test123", "case_id": "12452", "disabled": false}},
+					"cursor": "def"
+				}`))
+			})
+		})
+
+		Context("when the limit is not a positive number", func() {
+			BeforeEach(func() {
+				url = "/uacs/instrument/test123?limit=0"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Limit must be a positive number"}`))
+			})
+		})
+
+		Context("when filtered by lifecycle timestamps", func() {
+			BeforeEach(func() {
+				url = "/uacs/instrument/test123?limit=1&created_from=2026-10-01T09:00:00Z&first_accessed_to=2026-10-02T00:00:00Z"
+				mockUacGenerator.On("GetUacsPage", "test123", uacgenerator.UacFilter{
+					Created:       uacgenerator.TimeRange{From: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)},
+					FirstAccessed: uacgenerator.TimeRange{To: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)},
+				}, "", 1).Return(&uacgenerator.UacPage{Uacs: uacgenerator.Uacs{}}, nil)
+			})
+
+			It("returns the page of matching UACs", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(MatchJSON(`{"uacs": {}}`))
+			})
+		})
+
+		Context("when a timestamp filter is not an RFC 3339 time", func() {
+			BeforeEach(func() {
+				url = "/uacs/instrument/test123?limit=1&disabled_to=yesterday"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Disabled to must be an RFC 3339 time"}`))
+			})
+		})
+
+		Context("when the cursor is invalid", func() {
+			BeforeEach(func() {
+				url = "/uacs/instrument/test123/bycaseid?cursor=abc"
+				mockUacGenerator.On("GetUacsPageByCaseID", "test123", uacgenerator.UacFilter{}, "abc", uacgenerator.MAXPAGESIZE).Return(nil, uacgenerator.ErrInvalidCursor)
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Invalid cursor"}`))
+			})
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName?stream=true", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			streamErr    error
+		)
+
+		JustBeforeEach(func() {
+			mockUacGenerator.On("StreamUacs", "test123", uacgenerator.UacFilter{}, mock.Anything).Return(func(instrumentName string, filter uacgenerator.UacFilter, f func(*uacgenerator.UacInfo) error) error {
+				for _, caseID := range []string{"12452", "65858"} {
+					if err := f(&uacgenerator.UacInfo{InstrumentName: "test123", CaseID: caseID}); err != nil {
+						return err
+					}
+				}
+				return streamErr
+			})
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/instrument/test123?stream=true", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("when every UAC is read", func() {
+			BeforeEach(func() {
+				streamErr = nil
+			})
+
+			It("writes a line of JSON for each UAC", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+				Expect(httpRecorder.Body.String()).To(Equal(
+					`{"instrument_name":"test123","case_id":"12452","disabled":false}` + "\n" +
+						`{"instrument_name":"test123","case_id":"65858","disabled":false}` + "\n",
+				))
+			})
+		})
+
+		Context("when reading fails part way through", func() {
+			BeforeEach(func() {
+				streamErr = fmt.Errorf("Datastore went away")
+			})
+
+			It("writes the error as the last line", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(HaveSuffix(`{"error":"Datastore went away"}` + "\n"))
+			})
+		})
+	})
+
+	Describe("GET /uacs/instrument/:instrumentName/export", func() {
+		var httpRecorder *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+		})
+
+		Context("with layout options", func() {
+			BeforeEach(func() {
+				expectedOptions := uacgenerator.ExportOptions{
+					Columns:        []string{"case_id", "uac1", "uac2", "uac3"},
+					Header:         false,
+					Delimiter:      '|',
+					ChunkSeparator: "-",
+				}
+				mockUacGenerator.On("ExportUacs", "test123", expectedOptions, mock.Anything).Return(
+					func(instrumentName string, options uacgenerator.ExportOptions, writer io.Writer) *uacgenerator.ExportTrailer {
+						_, _ = io.WriteString(writer, "12452|1234|5678|9012\nTRAILER|1|abc\n")
+						return &uacgenerator.ExportTrailer{Records: 1, Checksum: "abc"}
+					}, nil)
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export?columns=case_id,uac1,uac2,uac3&header=false&delimiter=%7C&chunk_separator=-", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("writes the export as a CSV attachment", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+				Expect(httpRecorder.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="test123.csv"`))
+				Expect(httpRecorder.Body.String()).To(Equal("12452|1234|5678|9012\nTRAILER|1|abc\n"))
+			})
+		})
+
+		Context("with the time of the last print run", func() {
+			BeforeEach(func() {
+				expectedOptions := uacgenerator.ExportOptions{
+					Header:         true,
+					Delimiter:      ',',
+					ChunkSeparator: " ",
+					Columns:        uacgenerator.DefaultExportColumns(),
+					Filter:         uacgenerator.UacFilter{Created: uacgenerator.TimeRange{From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}},
+				}
+				mockUacGenerator.On("ExportUacs", "test123", expectedOptions, mock.Anything).Return(&uacgenerator.ExportTrailer{}, nil)
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export?created_from=2026-10-01T00:00:00Z", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("exports the UACs issued since", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				mockUacGenerator.AssertExpectations(GinkgoT())
+			})
+		})
+
+		Context("with an unknown column", func() {
+			BeforeEach(func() {
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export?columns=case_id,postcode", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a 400", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Unknown column: postcode"}`))
+				mockUacGenerator.AssertNotCalled(GinkgoT(), "ExportUacs", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("with a delimiter longer than a character", func() {
+			BeforeEach(func() {
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export?delimiter=%7C%7C", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a 400", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Delimiter must be a single character"}`))
+			})
+		})
+
+		Context("when UACs are keyed", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("ExportUacs", "test123", mock.Anything, mock.Anything).Return(nil, uacgenerator.ErrUacsKeyed)
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a 403", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"UACs are keyed and can only be exported encrypted"}`))
+			})
+		})
+
+		Context("when the export fails before anything is written", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("ExportUacs", "test123", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("Datastore went away"))
+				req, _ := http.NewRequest("GET", "/uacs/instrument/test123/export", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a 500", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(httpRecorder.Header().Get("Content-Disposition")).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("POST /uacs/instrument/:instrumentName/export", func() {
+		var httpRecorder *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+		})
+
+		Context("when exports are encrypted", func() {
+			BeforeEach(func() {
+				expectedOptions := uacgenerator.ExportOptions{Header: true, Delimiter: ',', ChunkSeparator: " ", Columns: uacgenerator.DefaultExportColumns()}
+				mockUacGenerator.On("SaveEncryptedExport", "test123", expectedOptions).Return(&uacgenerator.ExportManifest{
+					File:            "test123-20260101T000000Z.csv.age",
+					InstrumentName:  "test123",
+					Records:         2,
+					PlaintextSHA256: "abc",
+					Encryption:      "age",
+					Recipients:      []string{"age1recipient"},
+				}, nil)
+				req, _ := http.NewRequest("POST", "/uacs/instrument/test123/export", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("responds with the manifest", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusCreated))
+				Expect(httpRecorder.Body.String()).To(MatchJSON(`{
+					"file": "test123-20260101T000000Z.csv.age",
+					"instrument_name": "test123",
+					"records": 2,
+					"plaintext_sha256": "abc",
+					"encryption": "age",
+					"recipients": ["age1recipient"],
+					"created": "0001-01-01T00:00:00Z"
+				}`))
+			})
+		})
+
+		Context("when no recipients are configured", func() {
+			BeforeEach(func() {
+				mockUacGenerator.On("SaveEncryptedExport", "test123", mock.Anything).Return(nil, uacgenerator.ErrExportsNotEncrypted)
+				req, _ := http.NewRequest("POST", "/uacs/instrument/test123/export", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("returns a 501", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusNotImplemented))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Encrypted exports need export recipients"}`))
+			})
+		})
+	})
+
+	Describe("GET /uacs/audit", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+			url          string
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", url, nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("with filters", func() {
+			BeforeEach(func() {
+				url = "/uacs/audit?uac=123412341234&instrument_name=test123&actor=alice@example.com" +
+					"&from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z&limit=1&cursor=abc"
+				mockUacGenerator.On("GetAuditEntries", uacgenerator.AuditQuery{
+					UAC:            "123412341234",
+					InstrumentName: "test123",
+					Actor:          "alice@example.com",
+					From:           time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+					To:             time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC),
+					Cursor:         "abc",
+					Limit:          1,
+				}).Return(&uacgenerator.AuditPage{
+					Entries: []*uacgenerator.AuditEntry{{
+						ID:             "def",
+						UAC:            "123412341234",
+						UacKind:        "uac",
+						InstrumentName: "test123",
+						Action:         uacgenerator.AUDITDISABLE,
+						Actor:          "alice@example.com",
+						Timestamp:      time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+						Before:         &uacgenerator.AuditState{InstrumentName: "test123", CaseID: "12452"},
+						After:          &uacgenerator.AuditState{InstrumentName: "test123", CaseID: "12452", Disabled: true},
+					}},
+					Cursor: "def",
+				}, nil)
+			})
+
+			It("returns the matching audit entries", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(MatchJSON(`{
+					"entries": [{
+						"id": "def",
+						"uac": "123412341234",
+						"uac_kind": "uac",
+						"instrument_name": "test123",
+						"action": "disable",
+						"actor": "alice@example.com",
+						"timestamp": "2026-10-01T09:30:00Z",
+						"before": {"instrument_name": "test123", "case_id": "12452", "disabled": false},
+						"after": {"instrument_name": "test123", "case_id": "12452", "disabled": true}
+					}],
+					"cursor": "def"
+				}`))
+			})
+		})
+
+		Context("when a time is not RFC 3339", func() {
+			BeforeEach(func() {
+				url = "/uacs/audit?from=yesterday"
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"From must be an RFC 3339 time"}`))
+			})
+		})
+
+		Context("when the cursor is invalid", func() {
+			BeforeEach(func() {
+				url = "/uacs/audit?cursor=abc"
+				mockUacGenerator.On("GetAuditEntries", uacgenerator.AuditQuery{Cursor: "abc", Limit: uacgenerator.MAXPAGESIZE}).
+					Return(nil, uacgenerator.ErrInvalidCursor)
+			})
+
+			It("returns a http 400 error", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpRecorder.Body.String()).To(Equal(`{"error":"Invalid cursor"}`))
+			})
+		})
+	})
+
+	Describe("Requests with an authenticated identity", func() {
+		var (
+			httpRecorder    *httptest.ResponseRecorder
+			actingGenerator *mockuacgenerator.UacGeneratorInterface
+		)
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/uacs/uac/disable/123456789", nil)
+			req.Header.Set(webserver.IDENTITYHEADER, "accounts.google.com:alice@example.com")
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			actingGenerator = &mockuacgenerator.UacGeneratorInterface{}
+			mockUacGenerator.On("WithActor", "alice@example.com").Return(actingGenerator)
+			actingGenerator.On("DisableUac", "123456789").Return(nil)
+		})
+
+		It("makes the change as that identity", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			actingGenerator.AssertCalled(GinkgoT(), "DisableUac", "123456789")
+			mockUacGenerator.AssertNotCalled(GinkgoT(), "DisableUac", "123456789")
+		})
+	})
 })
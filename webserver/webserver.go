@@ -1,6 +1,9 @@
 package webserver
 
 import (
+	"expvar"
+	"net/http"
+
 	"github.com/ONSDigital/blaise-uac-service/blaiserestapi"
 	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
 	"github.com/gin-gonic/gin"
@@ -9,16 +12,42 @@ import (
 type Server struct {
 	BlaiseRestApi blaiserestapi.BlaiseRestApiInterface
 	UacGenerator  uacgenerator.UacGeneratorInterface
+	// AuthLimiter is NewAuthLimiter() when nil
+	AuthLimiter *AuthLimiter
+	// TrustedPlatform is the header the platform the service runs on sets to the client's IP, such as
+	// X-Appengine-User-Ip on App Engine, which clients cannot set themselves.
+	TrustedPlatform string
+	// TrustedProxies are the proxies whose X-Forwarded-For gives the client's IP. None are trusted when it is empty, so
+	// clients cannot get around the authentication limits by making up their IP.
+	TrustedProxies []string
 }
 
-func (server *Server) SetupRouter() *gin.Engine {
+func (server *Server) SetupRouter() (*gin.Engine, error) {
 	httpRouter := gin.Default()
+	httpRouter.TrustedPlatform = server.TrustedPlatform
+	err := httpRouter.SetTrustedProxies(server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	authLimiter := server.AuthLimiter
+	if authLimiter == nil {
+		authLimiter = NewAuthLimiter()
+	}
 	uacController := &UacController{
 		BlaiseRestApi: server.BlaiseRestApi,
 		UacGenerator:  server.UacGenerator,
+		AuthLimiter:   authLimiter,
 	}
 	uacController.AddRoutes(httpRouter)
 	healthController := &HealthController{}
 	healthController.AddRoutes(httpRouter)
-	return httpRouter
+	return httpRouter, nil
+}
+
+// DebugHandler serves the expvar metrics at /debug/vars. They are not routed by SetupRouter, so they are only served
+// on an internal listener.
+func DebugHandler() http.Handler {
+	debugMux := http.NewServeMux()
+	debugMux.Handle("/debug/vars", expvar.Handler())
+	return debugMux
 }
@@ -0,0 +1,87 @@
+package webserver_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ONSDigital/blaise-uac-service/uacgenerator"
+	"github.com/ONSDigital/blaise-uac-service/webserver"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mockuacgenerator "github.com/ONSDigital/blaise-uac-service/uacgenerator/mocks"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server           *webserver.Server
+		mockUacGenerator *mockuacgenerator.UacGeneratorInterface
+	)
+
+	BeforeEach(func() {
+		mockUacGenerator = &mockuacgenerator.UacGeneratorInterface{}
+		mockUacGenerator.On("AuthenticateUac", "123412341234").Return(nil, uacgenerator.ErrUacDisabled)
+		server = &webserver.Server{
+			UacGenerator: mockUacGenerator,
+			AuthLimiter:  &webserver.AuthLimiter{ClientLimit: 1, ClientWindow: time.Minute},
+		}
+	})
+
+	authenticate := func(httpRouter *gin.Engine, header, ip string) int {
+		httpRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/uacs/authenticate", bytes.NewBufferString(`{"uac":"123412341234"}`))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set(header, ip)
+		httpRouter.ServeHTTP(httpRecorder, req)
+		return httpRecorder.Code
+	}
+
+	Context("when no proxies are trusted", func() {
+		It("limits clients however they set X-Forwarded-For", func() {
+			httpRouter, err := server.SetupRouter()
+			Expect(err).To(BeNil())
+			Expect(authenticate(httpRouter, "X-Forwarded-For", "203.0.113.1")).To(Equal(http.StatusForbidden))
+			for i := 2; i < 5; i++ {
+				Expect(authenticate(httpRouter, "X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))).To(Equal(http.StatusTooManyRequests))
+			}
+		})
+	})
+
+	Context("when the platform sets the client's IP", func() {
+		BeforeEach(func() {
+			server.TrustedPlatform = "X-Appengine-User-Ip"
+		})
+
+		It("limits each client by the platform's header", func() {
+			httpRouter, err := server.SetupRouter()
+			Expect(err).To(BeNil())
+			Expect(authenticate(httpRouter, "X-Appengine-User-Ip", "203.0.113.1")).To(Equal(http.StatusForbidden))
+			Expect(authenticate(httpRouter, "X-Appengine-User-Ip", "203.0.113.1")).To(Equal(http.StatusTooManyRequests))
+			Expect(authenticate(httpRouter, "X-Appengine-User-Ip", "203.0.113.2")).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	It("rejects trusted proxies that are not addresses", func() {
+		server.TrustedProxies = []string{"not a proxy"}
+		_, err := server.SetupRouter()
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("only serves the metrics on the debug handler", func() {
+		httpRouter, err := server.SetupRouter()
+		Expect(err).To(BeNil())
+		httpRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/debug/vars", nil)
+		httpRouter.ServeHTTP(httpRecorder, req)
+		Expect(httpRecorder.Code).To(Equal(http.StatusNotFound))
+
+		httpRecorder = httptest.NewRecorder()
+		webserver.DebugHandler().ServeHTTP(httpRecorder, req)
+		Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+		Expect(httpRecorder.Body.String()).To(ContainSubstring(`"uac_authentications"`))
+	})
+})